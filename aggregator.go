@@ -2,27 +2,67 @@ package netjugo
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"net/netip"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"github.com/holiman/uint256"
 )
 
-// Memory pool for IPPrefix allocations to reduce GC pressure
-var ipPrefixPool = sync.Pool{
-	New: func() interface{} {
-		return &IPPrefix{
-			Min: new(uint256.Int),
-			Max: new(uint256.Int),
-		}
-	},
+// Memory pool for IPPrefix allocations to reduce GC pressure. It is held
+// behind an atomic pointer so Compact can swap in an empty pool.
+var ipPrefixPool atomic.Pointer[sync.Pool]
+
+// Pool counters reported through MemoryStats
+var (
+	poolingEnabled atomic.Bool
+	poolGets       atomic.Int64
+	poolPuts       atomic.Int64
+	poolMisses     atomic.Int64
+)
+
+func init() {
+	poolingEnabled.Store(true)
+	ipPrefixPool.Store(newIPPrefixPool())
+}
+
+func newIPPrefixPool() *sync.Pool {
+	return &sync.Pool{
+		New: func() interface{} {
+			poolMisses.Add(1)
+			return newIPPrefix()
+		},
+	}
+}
+
+func newIPPrefix() *IPPrefix {
+	return &IPPrefix{
+		Min: new(uint256.Int),
+		Max: new(uint256.Int),
+	}
+}
+
+// SetPooling enables or disables the package-wide IPPrefix pool. With
+// pooling disabled every prefix is allocated directly and released
+// prefixes are left to the garbage collector, which can be cheaper for
+// short-lived aggregators. Pooling is enabled by default.
+func SetPooling(enabled bool) {
+	poolingEnabled.Store(enabled)
 }
 
 type IPPrefix struct {
@@ -40,43 +80,276 @@ type PrefixAggregator struct {
 	ExcludeIPv6      []*IPPrefix
 	MinPrefixLenIPv4 int
 	MinPrefixLenIPv6 int
-	mu               sync.RWMutex
-	originalCount    int
-	lastProcessTime  time.Duration
-	warnings         []string
-	warningHandler   func(string)
+	// mu lets readers in while Aggregate works, see aggregatorLock
+	mu aggregatorLock
+	// render caches the rendered result, guarded by renderMu under the
+	// read lock
+	render        *renderedResult
+	renderMu      sync.Mutex
+	originalCount int
+	originalIPv4  int
+	skippedLines  int
+	// skippedByReason splits skippedLines by SkipReason
+	skippedByReason [skipReasonCount]int
+	// ingested holds every distinct prefix added under dedupOnIngest,
+	// masked, and duplicatesRemoved counts the copies it dropped
+	dedupOnIngest     bool
+	ingested          map[netip.Prefix]struct{}
+	duplicatesRemoved int
+	// skippedExclusions counts the exclusions the last Aggregate skipped
+	skippedExclusions int
+	lastProcessTime   time.Duration
+	// mergeDeadline ends the merge passes of AggregateWithin early, and
+	// mergeCut records that it did
+	mergeDeadline time.Time
+	mergeCut      bool
+	warnings      []Warning
+	// maxWarnings caps warnings; totalWarnings and warningCounts count
+	// every warning, stored or not
+	maxWarnings    int
+	totalWarnings  int
+	warningCounts  []WarningSummary
+	warningHandler func(string)
+	// warningChans are the channels from WarningsChan
+	warningChans     []chan Warning
+	warningsOverflow WarningsOverflow
+	logger           *slog.Logger
+	metrics          Metrics
+	outputOrder      OutputOrder
+	ipv6Format       IPv6Format
+	syntax           lineSyntax
+	constraintOrder  ConstraintOrder
+	includeRounding  IncludeRounding
+	exclusionSets    []*exclusionSet
+	// restrictIPv4 and restrictIPv6 are the prefixes of
+	// SetRestrictToPrefixes; restricted is set while they apply
+	restrictIPv4 []*IPPrefix
+	restrictIPv6 []*IPPrefix
+	restricted   bool
+	// rejectDefaultRoute turns the emergent default route warning into an
+	// error; explicitDefault* record whether the input had one
+	rejectDefaultRoute  bool
+	explicitDefaultIPv4 bool
+	explicitDefaultIPv6 bool
+	maxResultPrefixes   int
+	failOnEmptyResult   bool
+	pointToPoint        bool
+	// outputHeader enables the comment header of the writers, stamped
+	// with now, or time.Now if nil
+	outputHeader        bool
+	now                 func() time.Time
+	validateConstraints bool
+	// memoryBudget is checked every memoryCheckInterval adds, counted by
+	// addsSinceCheck
+	memoryBudget   int64
+	addsSinceCheck int
+	// originals holds every added prefix when retainOriginals is set, so
+	// Reaggregate can rebuild the input; originalsIncomplete records that
+	// prefixes were added while it was off
+	retainOriginals     bool
+	originalsIncomplete bool
+	originals           []netip.Prefix
+	// inputs records the text of every added prefix in minimal change
+	// mode
+	minimalChange bool
+	inputs        []inputRecord
+	// tags holds the sorted tags of every input prefix added with
+	// AddFromReaderTagged
+	tags map[netip.Prefix][]string
+	// aggregated and reconfigured track the lifecycle of the working lists:
+	//
+	//   - fresh: the lists hold only added prefixes; Aggregate merges in the
+	//     includes and applies the constraints
+	//   - aggregated: the lists hold a result. Prefixes added now join it
+	//     as pending entries, and the next Aggregate merges them in and
+	//     applies the constraints again, which gives the same result as
+	//     aggregating everything at once. The includes are already part of
+	//     the result and are not added twice.
+	//   - reconfigured: a setting that shapes the result changed while
+	//     aggregated, so the next Aggregate must start again from the
+	//     originals
+	//
+	// Reset and restoreOriginals return to fresh.
+	aggregated   bool
+	reconfigured bool
+	// exclusionCauses maps result prefixes produced by splitting around
+	// exclusions to those exclusions, when trackExclusions is set
+	trackExclusions bool
+	exclusionCauses map[netip.Prefix][]netip.Prefix
+	// appliedExclusions holds the exclusions that have removed something
+	// since the aggregator was last fresh, so one that only finds the
+	// hole it made in an earlier result is not reported as skipped, with
+	// what they did for GetExclusionEffects
+	appliedExclusions map[exclusionKey]exclusionCounts
+	// sortedIPv4 and sortedIPv6 count the leading entries of each list
+	// known to be in canonical order; entries after them are pending and
+	// get merged in by the next Aggregate.
+	sortedIPv4 int
+	sortedIPv6 int
+	// dirty is set by every mutating method and cleared by Aggregate, so
+	// repeated or concurrent Aggregate calls on unchanged data are no-ops.
+	dirty bool
+	// changes counts rewrites of the working lists, so ForEachPrefix can
+	// tell that the result it is reading has gone
+	changes uint64
+	// lastStats holds the statistics computed when Aggregate last
+	// completed, for GetStats to return until the next mutation
+	lastStats *cachedStats
+	// closed is set by Close, after which the aggregator holds nothing
+	closed bool
 }
 
+// OutputOrder selects the order in which results are returned and
+// written. IPv4 prefixes always precede IPv6 prefixes.
+type OutputOrder int
+
+const (
+	// OrderAddress sorts by address, then by prefix length (less specific
+	// first). This is the default.
+	OrderAddress OutputOrder = iota
+	// OrderPrefixLengthFirst sorts most-specific prefixes first, then by
+	// address, for devices that evaluate rules top-down.
+	OrderPrefixLengthFirst
+)
+
+// IPv6Format selects how IPv6 prefixes are rendered as strings
+type IPv6Format int
+
+const (
+	// IPv6Compressed uses RFC 5952 form with "::" compression. This is the
+	// default.
+	IPv6Compressed IPv6Format = iota
+	// IPv6Expanded prints all eight hextets zero-padded, for parsers that
+	// do not understand "::".
+	IPv6Expanded
+)
+
+// ConstraintOrder decides which wins when an include and an exclude
+// prefix overlap
+type ConstraintOrder int
+
+const (
+	// ExcludesWin applies exclusions after includes are merged in, so an
+	// exclusion can carve holes out of an include. This is the default.
+	ExcludesWin ConstraintOrder = iota
+	// IncludesWin restores include prefixes after exclusions, so they
+	// always appear intact in the output.
+	IncludesWin
+)
+
+// IncludeRounding decides what happens to an include prefix more specific
+// than the minimum prefix length of its family
+type IncludeRounding int
+
+const (
+	// IncludeExpand rounds the include up to the minimum length like any
+	// other prefix, with a WarnIncludeRounded warning. This is the
+	// default.
+	IncludeExpand IncludeRounding = iota
+	// IncludeExact keeps the include at its own length
+	IncludeExact
+	// IncludeReject makes Aggregate fail with ErrIncludeTooSpecific
+	IncludeReject
+)
+
 type AggregationStats struct {
-	IPv4PrefixCount  int
-	IPv6PrefixCount  int
-	TotalPrefixes    int
-	OriginalCount    int
+	IPv4PrefixCount   int
+	IPv6PrefixCount   int
+	TotalPrefixes     int
+	OriginalCount     int
+	OriginalIPv4Count int
+	OriginalIPv6Count int
+	// SkippedLines counts input lines AddFromReader could not parse
+	SkippedLines int
+	// SkippedByReason splits SkippedLines by SkipReason, so junk input
+	// can be told apart from lines skipped by design. There is no reason
+	// for duplicates: the copies SetDedupOnIngest drops are added, not
+	// skipped, and counted in DuplicatesRemoved instead.
+	SkippedByReason [skipReasonCount]int
+	// DuplicatesRemoved counts the prefixes SetDedupOnIngest dropped as
+	// already added
+	DuplicatesRemoved int
+	// IncludedCount and ExcludedCount are the configured include and
+	// exclude prefixes
+	IncludedCount int
+	ExcludedCount int
+	// SkippedExclusions counts the exclusions the last Aggregate skipped
+	// because they were too specific or overlapped no prefix left to
+	// exclude from, each also reported as a warning
+	SkippedExclusions int
+	// TotalWarnings counts the warnings of the last Aggregate, including
+	// those beyond the SetMaxWarnings cap
+	TotalWarnings int
+	// ReductionRatio is relative to OriginalCount+IncludedCount and never
+	// negative
 	ReductionRatio   float64
 	ProcessingTimeMs int64
 	MemoryUsageBytes int64
 }
 
+// CompactOptions controls what Compact releases beyond trimming the
+// result slices.
+type CompactOptions struct {
+	// ReleaseConstraints drops the include and exclude prefixes. They are
+	// already reflected in the aggregated result, but a later Aggregate
+	// after adding more prefixes will no longer apply them.
+	ReleaseConstraints bool
+	// ClearPool discards every IPPrefix currently cached in the pool.
+	ClearPool bool
+}
+
 type MemoryStats struct {
 	AllocBytes      int64
 	TotalAllocBytes int64
 	SysBytes        int64
 	NumGC           int64
+	// AggregatorBytes is the estimated size of the aggregator, the sum
+	// of the four parts below
 	AggregatorBytes int64
+	// ResultBytes covers the working prefix lists, IncludeBytes and
+	// ExcludeBytes the include and exclude prefixes, exclusion sets
+	// included, and OtherBytes the aggregator itself, retained originals
+	// and tags
+	ResultBytes  int64
+	IncludeBytes int64
+	ExcludeBytes int64
+	OtherBytes   int64
+	// Package-wide IPPrefix pool counters since process start. PoolMisses
+	// counts gets that had to allocate a new prefix.
+	PoolGets   int64
+	PoolPuts   int64
+	PoolMisses int64
 }
 
 // acquireIPPrefix gets an IPPrefix from the pool
 func acquireIPPrefix() *IPPrefix {
-	return ipPrefixPool.Get().(*IPPrefix)
+	if !poolingEnabled.Load() {
+		return newIPPrefix()
+	}
+	poolGets.Add(1)
+	return ipPrefixPool.Load().Get().(*IPPrefix)
 }
 
 // releaseIPPrefix returns an IPPrefix to the pool
 func releaseIPPrefix(p *IPPrefix) {
+	if !poolingEnabled.Load() {
+		return
+	}
 	// Clear the prefix before returning to pool
 	p.Prefix = netip.Prefix{}
 	p.Min.Clear()
 	p.Max.Clear()
-	ipPrefixPool.Put(p)
+	poolPuts.Add(1)
+	ipPrefixPool.Load().Put(p)
+}
+
+// cloneIPPrefix returns a pooled copy of p
+func cloneIPPrefix(p *IPPrefix) *IPPrefix {
+	c := acquireIPPrefix()
+	c.Prefix = p.Prefix
+	c.Min.Set(p.Min)
+	c.Max.Set(p.Max)
+	return c
 }
 
 func NewPrefixAggregator() *PrefixAggregator {
@@ -89,7 +362,52 @@ func NewPrefixAggregator() *PrefixAggregator {
 		ExcludeIPv6:      make([]*IPPrefix, 0),
 		MinPrefixLenIPv4: 0,
 		MinPrefixLenIPv6: 0,
+		metrics:          NopMetrics{},
+		syntax:           defaultLineSyntax(),
+		maxWarnings:      DefaultMaxWarnings,
+		dirty:            true,
+	}
+}
+
+// Clone returns an independent copy of the aggregator, including its
+// input, constraints, settings and warning handler. Cloning before
+// Aggregate allows one input to be aggregated under different settings.
+// Channels from WarningsChan are not shared with the copy.
+func (pa *PrefixAggregator) Clone() *PrefixAggregator {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	// The working copy of Aggregate, with what it shares copied too
+	c := pa.workingCopy()
+	c.IncludeIPv4, c.IncludeIPv6 = clonePrefixSlice(pa.IncludeIPv4), clonePrefixSlice(pa.IncludeIPv6)
+	c.ExcludeIPv4, c.ExcludeIPv6 = clonePrefixSlice(pa.ExcludeIPv4), clonePrefixSlice(pa.ExcludeIPv6)
+	c.restrictIPv4, c.restrictIPv6 = clonePrefixSlice(pa.restrictIPv4), clonePrefixSlice(pa.restrictIPv6)
+	c.ingested = maps.Clone(pa.ingested)
+	c.syntax = pa.syntax.clone()
+	c.originals = append([]netip.Prefix(nil), pa.originals...)
+	c.inputs = slices.Clone(pa.inputs)
+	c.tags = cloneTags(pa.tags)
+	c.warningChans = nil
+	c.exclusionSets = nil
+	for _, set := range pa.exclusionSets {
+		c.exclusionSets = append(c.exclusionSets, cloneExclusionSet(set))
+	}
+	return c
+}
+
+// samePrefixes reports whether a and b hold the same prefixes in the same
+// order
+func samePrefixes(a, b []*IPPrefix) bool {
+	return slices.EqualFunc(a, b, func(p, q *IPPrefix) bool { return p.Prefix == q.Prefix })
+}
+
+// clonePrefixSlice returns a pooled deep copy of prefixes
+func clonePrefixSlice(prefixes []*IPPrefix) []*IPPrefix {
+	result := make([]*IPPrefix, len(prefixes))
+	for i, p := range prefixes {
+		result[i] = cloneIPPrefix(p)
 	}
+	return result
 }
 
 func (pa *PrefixAggregator) SetMinPrefixLength(ipv4Len, ipv6Len int) error {
@@ -103,8 +421,209 @@ func (pa *PrefixAggregator) SetMinPrefixLength(ipv4Len, ipv6Len int) error {
 	pa.mu.Lock()
 	defer pa.mu.Unlock()
 
+	if pa.closed {
+		return ErrClosed
+	}
+
+	if ipv4Len == pa.MinPrefixLenIPv4 && ipv6Len == pa.MinPrefixLenIPv6 {
+		return nil
+	}
 	pa.MinPrefixLenIPv4 = ipv4Len
 	pa.MinPrefixLenIPv6 = ipv6Len
+	pa.reconfigure()
+	return nil
+}
+
+// SetOutputOrder selects the order used by GetPrefixes, GetIPv4Prefixes,
+// GetIPv6Prefixes and the writers.
+func (pa *PrefixAggregator) SetOutputOrder(order OutputOrder) error {
+	if order != OrderAddress && order != OrderPrefixLengthFirst {
+		return fmt.Errorf("%w: unknown output order %d", ErrInvalidOption, order)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.outputOrder = order
+	return nil
+}
+
+// SetIPv6Format selects how IPv6 prefixes are rendered by GetPrefixes,
+// GetIPv6Prefixes and the writers.
+func (pa *PrefixAggregator) SetIPv6Format(format IPv6Format) error {
+	if format != IPv6Compressed && format != IPv6Expanded {
+		return fmt.Errorf("%w: unknown IPv6 format %d", ErrInvalidOption, format)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.ipv6Format = format
+	return nil
+}
+
+// SetConstraintOrder selects whether exclusions may remove include space
+// (ExcludesWin, the default) or includes are protected (IncludesWin).
+func (pa *PrefixAggregator) SetConstraintOrder(order ConstraintOrder) error {
+	if order != ExcludesWin && order != IncludesWin {
+		return fmt.Errorf("%w: unknown constraint order %d", ErrInvalidOption, order)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	if order == pa.constraintOrder {
+		return nil
+	}
+	pa.constraintOrder = order
+	pa.reconfigure()
+	return nil
+}
+
+// SetIncludeRounding selects what Aggregate does with include prefixes
+// more specific than the minimum prefix length: round them up
+// (IncludeExpand, the default), keep them exact (IncludeExact) or fail
+// (IncludeReject).
+func (pa *PrefixAggregator) SetIncludeRounding(policy IncludeRounding) error {
+	if policy < IncludeExpand || policy > IncludeReject {
+		return fmt.Errorf("%w: unknown include rounding %d", ErrInvalidOption, policy)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	if policy == pa.includeRounding {
+		return nil
+	}
+	pa.includeRounding = policy
+	pa.reconfigure()
+	return nil
+}
+
+// SetPointToPointFriendly acknowledges inputs full of point-to-point
+// links: exclusions up to /31 and /127 no longer warn as more specific
+// than recommended, only host exclusions do. It changes no result.
+func (pa *PrefixAggregator) SetPointToPointFriendly(enabled bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return
+	}
+
+	pa.pointToPoint = enabled
+	pa.dirty = true
+}
+
+// SetRejectDefaultRoute makes Aggregate fail with ErrDefaultRoute, rather
+// than only warn, when the result aggregates to 0.0.0.0/0 or ::/0 without
+// that prefix being in the input.
+func (pa *PrefixAggregator) SetRejectDefaultRoute(reject bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return
+	}
+
+	pa.rejectDefaultRoute = reject
+	pa.dirty = true
+}
+
+// SetFailOnEmptyResult makes WriteToFile, WriteToWriter and WriteToFiles
+// fail with ErrEmptyResult, rather than write nothing, when the result
+// holds no prefixes
+func (pa *PrefixAggregator) SetFailOnEmptyResult(fail bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return
+	}
+
+	pa.failOnEmptyResult = fail
+}
+
+// memoryCheckInterval is how many adds pass between memory budget checks
+const memoryCheckInterval = 10000
+
+// SetMemoryBudget makes AddPrefix and the loaders fail with a
+// *MemoryBudgetError once the estimated memory use (see GetStats) passes
+// bytes. The estimate is checked every 10,000 adds. Zero, the default,
+// means no budget. The budget does not cover the copy of the working lists
+// Aggregate makes, which takes peak prefix memory to about twice the
+// estimate while it runs.
+func (pa *PrefixAggregator) SetMemoryBudget(bytes int64) error {
+	if bytes < 0 {
+		return fmt.Errorf("%w: memory budget must not be negative, got %d", ErrInvalidOption, bytes)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.memoryBudget = bytes
+	pa.addsSinceCheck = 0
+	return nil
+}
+
+// checkMemoryBudget is called before each add and enforces the budget
+// every memoryCheckInterval calls. Once crossed, every add fails.
+func (pa *PrefixAggregator) checkMemoryBudget() error {
+	if pa.memoryBudget == 0 {
+		return nil
+	}
+	if pa.addsSinceCheck++; pa.addsSinceCheck < memoryCheckInterval {
+		return nil
+	}
+
+	if usage := pa.calculateMemoryUsage().total(); usage > pa.memoryBudget {
+		return &MemoryBudgetError{
+			Budget:   pa.memoryBudget,
+			Usage:    usage,
+			Prefixes: len(pa.IPv4Prefixes) + len(pa.IPv6Prefixes),
+		}
+	}
+	pa.addsSinceCheck = 0
+	return nil
+}
+
+// SetMaxResultPrefixes makes Aggregate fail with ErrResultTooLarge as soon
+// as exclusions grow the result beyond n prefixes. Zero, the default,
+// means no limit.
+func (pa *PrefixAggregator) SetMaxResultPrefixes(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%w: maximum result prefixes must not be negative, got %d", ErrInvalidOption, n)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.maxResultPrefixes = n
+	pa.dirty = true
 	return nil
 }
 
@@ -112,22 +631,31 @@ func (pa *PrefixAggregator) SetIncludePrefixes(prefixes []string) error {
 	pa.mu.Lock()
 	defer pa.mu.Unlock()
 
-	pa.IncludeIPv4 = pa.IncludeIPv4[:0]
-	pa.IncludeIPv6 = pa.IncludeIPv6[:0]
+	if pa.closed {
+		return ErrClosed
+	}
 
+	ipv4, ipv6 := make([]*IPPrefix, 0), make([]*IPPrefix, 0)
 	for _, prefixStr := range prefixes {
 		ipPrefix, err := parseIPPrefix(prefixStr)
 		if err != nil {
+			releaseLists(ipv4, ipv6)
 			return fmt.Errorf("failed to parse include prefix %q: %w", prefixStr, err)
 		}
 
 		if ipPrefix.Prefix.Addr().Is4() {
-			pa.IncludeIPv4 = append(pa.IncludeIPv4, ipPrefix)
+			ipv4 = append(ipv4, ipPrefix)
 		} else {
-			pa.IncludeIPv6 = append(pa.IncludeIPv6, ipPrefix)
+			ipv6 = append(ipv6, ipPrefix)
 		}
 	}
 
+	if samePrefixes(ipv4, pa.IncludeIPv4) && samePrefixes(ipv6, pa.IncludeIPv6) {
+		releaseLists(ipv4, ipv6)
+		return nil
+	}
+	pa.IncludeIPv4, pa.IncludeIPv6 = ipv4, ipv6
+	pa.reconfigure()
 	return nil
 }
 
@@ -135,26 +663,152 @@ func (pa *PrefixAggregator) SetExcludePrefixes(prefixes []string) error {
 	pa.mu.Lock()
 	defer pa.mu.Unlock()
 
-	pa.ExcludeIPv4 = pa.ExcludeIPv4[:0]
-	pa.ExcludeIPv6 = pa.ExcludeIPv6[:0]
+	if pa.closed {
+		return ErrClosed
+	}
 
+	ipv4, ipv6 := make([]*IPPrefix, 0), make([]*IPPrefix, 0)
 	for _, prefixStr := range prefixes {
 		ipPrefix, err := parseIPPrefix(prefixStr)
 		if err != nil {
+			releaseLists(ipv4, ipv6)
 			return fmt.Errorf("failed to parse exclude prefix %q: %w", prefixStr, err)
 		}
 
 		if ipPrefix.Prefix.Addr().Is4() {
-			pa.ExcludeIPv4 = append(pa.ExcludeIPv4, ipPrefix)
+			ipv4 = append(ipv4, ipPrefix)
 		} else {
-			pa.ExcludeIPv6 = append(pa.ExcludeIPv6, ipPrefix)
+			ipv6 = append(ipv6, ipPrefix)
 		}
 	}
 
+	if samePrefixes(ipv4, pa.ExcludeIPv4) && samePrefixes(ipv6, pa.ExcludeIPv6) {
+		releaseLists(ipv4, ipv6)
+		return nil
+	}
+	pa.ExcludeIPv4, pa.ExcludeIPv6 = ipv4, ipv6
+	pa.reconfigure()
+	return nil
+}
+
+// AddExcludeRange excludes every address from start to end, inclusive,
+// as the smallest set of prefixes covering the range. The exclusions are
+// added to those set with SetExcludePrefixes, which replaces them all.
+func (pa *PrefixAggregator) AddExcludeRange(start, end string) error {
+	first, err := netip.ParseAddr(strings.TrimSpace(start))
+	if err != nil {
+		return fmt.Errorf("%w: range start %q: %v", ErrInvalidPrefix, start, err)
+	}
+	last, err := netip.ParseAddr(strings.TrimSpace(end))
+	if err != nil {
+		return fmt.Errorf("%w: range end %q: %v", ErrInvalidPrefix, end, err)
+	}
+	prefixes, err := RangeToPrefixes(first, last)
+	if err != nil {
+		return fmt.Errorf("failed to convert exclude range %s-%s: %w", start, end, err)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	add4, add6 := make([]*IPPrefix, 0), make([]*IPPrefix, 0)
+	for _, prefix := range prefixes {
+		ipPrefix, err := ipPrefixFrom(prefix)
+		if err != nil {
+			releaseLists(add4, add6)
+			return fmt.Errorf("failed to convert exclude range %s-%s: %w", start, end, err)
+		}
+		if prefix.Addr().Is4() {
+			add4 = append(add4, ipPrefix)
+		} else {
+			add6 = append(add6, ipPrefix)
+		}
+	}
+
+	pa.ExcludeIPv4, pa.ExcludeIPv6 = append(pa.ExcludeIPv4, add4...), append(pa.ExcludeIPv6, add6...)
+	pa.reconfigure()
+	return nil
+}
+
+// SetIncludeNetipPrefixes is SetIncludePrefixes for callers that already
+// hold parsed prefixes, skipping the round trip through strings
+func (pa *PrefixAggregator) SetIncludeNetipPrefixes(prefixes []netip.Prefix) error {
+	return pa.setNetipConstraints(&pa.IncludeIPv4, &pa.IncludeIPv6, "include", prefixes, true)
+}
+
+// AddIncludeNetipPrefixes adds prefixes to the include prefixes instead of
+// replacing them
+func (pa *PrefixAggregator) AddIncludeNetipPrefixes(prefixes []netip.Prefix) error {
+	return pa.setNetipConstraints(&pa.IncludeIPv4, &pa.IncludeIPv6, "include", prefixes, false)
+}
+
+// SetExcludeNetipPrefixes is SetExcludePrefixes for callers that already
+// hold parsed prefixes, skipping the round trip through strings
+func (pa *PrefixAggregator) SetExcludeNetipPrefixes(prefixes []netip.Prefix) error {
+	return pa.setNetipConstraints(&pa.ExcludeIPv4, &pa.ExcludeIPv6, "exclude", prefixes, true)
+}
+
+// AddExcludeNetipPrefixes adds prefixes to the exclude prefixes instead of
+// replacing them
+func (pa *PrefixAggregator) AddExcludeNetipPrefixes(prefixes []netip.Prefix) error {
+	return pa.setNetipConstraints(&pa.ExcludeIPv4, &pa.ExcludeIPv6, "exclude", prefixes, false)
+}
+
+// setNetipConstraints routes prefixes to the IPv4 or IPv6 list of a
+// constraint, emptying both first if replace is set
+func (pa *PrefixAggregator) setNetipConstraints(ipv4, ipv6 *[]*IPPrefix, kind string, prefixes []netip.Prefix, replace bool) error {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	add4, add6 := make([]*IPPrefix, 0), make([]*IPPrefix, 0)
+	for i, prefix := range prefixes {
+		if !prefix.IsValid() {
+			releaseLists(add4, add6)
+			return fmt.Errorf("%w: %s prefix %d is not valid", ErrInvalidPrefix, kind, i)
+		}
+		ipPrefix, err := ipPrefixFrom(prefix)
+		if err != nil {
+			releaseLists(add4, add6)
+			return fmt.Errorf("failed to convert %s prefix %s: %w", kind, prefix, err)
+		}
+
+		if prefix.Addr().Is4() {
+			add4 = append(add4, ipPrefix)
+		} else {
+			add6 = append(add6, ipPrefix)
+		}
+	}
+
+	switch {
+	case !replace && len(prefixes) == 0:
+		return nil
+	case replace && samePrefixes(add4, *ipv4) && samePrefixes(add6, *ipv6):
+		releaseLists(add4, add6)
+		return nil
+	case replace:
+		*ipv4, *ipv6 = add4, add6
+	default:
+		*ipv4, *ipv6 = append(*ipv4, add4...), append(*ipv6, add6...)
+	}
+	pa.reconfigure()
 	return nil
 }
 
 func (pa *PrefixAggregator) AddPrefix(prefixStr string) error {
+	return pa.addPrefixText(prefixStr, prefixStr, "")
+}
+
+// addPrefixText is AddPrefix for a prefix read from a line, which is
+// recorded as text in minimal change mode and tagged with tag if set
+func (pa *PrefixAggregator) addPrefixText(prefixStr, text, tag string) error {
 	ipPrefix, err := parseIPPrefix(prefixStr)
 	if err != nil {
 		return fmt.Errorf("failed to parse prefix %q: %w", prefixStr, err)
@@ -163,131 +817,435 @@ func (pa *PrefixAggregator) AddPrefix(prefixStr string) error {
 	pa.mu.Lock()
 	defer pa.mu.Unlock()
 
+	prefix := ipPrefix.Prefix
+	if err := pa.addParsed(ipPrefix, text); err != nil {
+		return err
+	}
+	pa.recordTag(prefix, tag)
+	return nil
+}
+
+// addParsed adds a parsed prefix, taking ownership of it; text is the
+// prefix as it was given. The caller holds the write lock.
+func (pa *PrefixAggregator) addParsed(ipPrefix *IPPrefix, text string) error {
+	if pa.closed {
+		releaseIPPrefix(ipPrefix)
+		return ErrClosed
+	}
+	if pa.dropDuplicate(ipPrefix.Prefix) {
+		releaseIPPrefix(ipPrefix)
+		return nil
+	}
+	if err := pa.checkMemoryBudget(); err != nil {
+		releaseIPPrefix(ipPrefix)
+		return err
+	}
+
+	if pa.retainOriginals {
+		pa.originals = append(pa.originals, ipPrefix.Prefix)
+	}
+	pa.recordInput(ipPrefix.Prefix, text)
+
+	isDefault := ipPrefix.Prefix.Bits() == 0
 	if ipPrefix.Prefix.Addr().Is4() {
+		pa.sortedIPv4 = extendSorted(pa.IPv4Prefixes, pa.sortedIPv4, ipPrefix)
 		pa.IPv4Prefixes = append(pa.IPv4Prefixes, ipPrefix)
+		pa.originalIPv4++
+		pa.explicitDefaultIPv4 = pa.explicitDefaultIPv4 || isDefault
 	} else {
+		pa.sortedIPv6 = extendSorted(pa.IPv6Prefixes, pa.sortedIPv6, ipPrefix)
 		pa.IPv6Prefixes = append(pa.IPv6Prefixes, ipPrefix)
+		pa.explicitDefaultIPv6 = pa.explicitDefaultIPv6 || isDefault
 	}
 
 	pa.originalCount++
+	pa.changes++
+	pa.dirty = true
 	return nil
 }
 
+// extendSorted returns the sorted count for list after p is appended:
+// input that arrives in order never needs sorting.
+func extendSorted(list []*IPPrefix, sorted int, p *IPPrefix) int {
+	if sorted != len(list) {
+		return sorted
+	}
+	if sorted > 0 && compareIPPrefix(list[sorted-1], p) > 0 {
+		return sorted
+	}
+	return sorted + 1
+}
+
+// AddPrefixes adds every valid entry of prefixes. It is not
+// transactional: if any entries fail, the rest are still added and a
+// *MultiError lists each failure along with how many entries were added,
+// so a retry should resubmit only the failed entries.
 func (pa *PrefixAggregator) AddPrefixes(prefixes []string) error {
-	for _, prefixStr := range prefixes {
+	var failed []*EntryError
+	added := 0
+	for i, prefixStr := range prefixes {
 		if err := pa.AddPrefix(prefixStr); err != nil {
-			return err
+			failed = append(failed, &EntryError{Index: i, Input: prefixStr, Err: err})
+			// Every later entry would fail the same way
+			if errors.Is(err, ErrMemoryBudgetExceeded) || errors.Is(err, ErrClosed) {
+				break
+			}
+			continue
 		}
+		added++
+	}
+	if len(failed) > 0 {
+		return &MultiError{Errors: failed, Added: added}
 	}
 	return nil
 }
 
+// ReadResult reports what a single AddFromReaderCount or AddFromFileCount
+// call loaded
+type ReadResult struct {
+	Added   int // prefixes added
+	Skipped int // lines that could not be parsed
+}
+
+// SkipReason says why an input line was skipped
+type SkipReason int
+
+const (
+	// SkipParseError marks a line that is not a valid prefix or address
+	SkipParseError SkipReason = iota
+	// SkipMalformedRecord marks a CSV row that could not be read or has
+	// no prefix column
+	SkipMalformedRecord
+
+	skipReasonCount
+)
+
+// SkipReasons returns every SkipReason, in index order of
+// AggregationStats.SkippedByReason
+func SkipReasons() []SkipReason {
+	return []SkipReason{SkipParseError, SkipMalformedRecord}
+}
+
+func (r SkipReason) String() string {
+	switch r {
+	case SkipParseError:
+		return "parse-error"
+	case SkipMalformedRecord:
+		return "malformed-record"
+	}
+	return fmt.Sprintf("SkipReason(%d)", int(r))
+}
+
 func (pa *PrefixAggregator) AddFromFile(path string) error {
+	_, err := pa.AddFromFileCount(path)
+	return err
+}
+
+// AddFromFileCount is AddFromFile, also returning how many prefixes were
+// added and how many lines were skipped
+func (pa *PrefixAggregator) AddFromFileCount(path string) (ReadResult, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("%w: %s", ErrFileNotFound, path)
+			return ReadResult{}, fmt.Errorf("%w: %s", ErrFileNotFound, path)
 		}
-		return fmt.Errorf("failed to open file %s: %w", path, err)
+		return ReadResult{}, fmt.Errorf("failed to open file %s: %w", path, err)
 	}
 	defer func(file *os.File) {
 		_ = file.Close()
 	}(file)
 
-	return pa.AddFromReader(file)
+	return pa.AddFromReaderCount(file)
 }
 
 func (pa *PrefixAggregator) AddFromReader(reader io.Reader) error {
+	_, err := pa.AddFromReaderCount(reader)
+	return err
+}
+
+// AddFromReaderCount is AddFromReader, also returning how many prefixes
+// were added and how many lines were skipped. The counts cover lines read
+// before any read error.
+func (pa *PrefixAggregator) AddFromReaderCount(reader io.Reader) (ReadResult, error) {
+	return pa.addFromReader(reader, "", nil)
+}
+
+// addFromReader parses reader line by line, tagging each prefix with tag
+// (if set) and calling onAdd (if set) after each prefix is added
+func (pa *PrefixAggregator) addFromReader(reader io.Reader, tag string, onAdd func() error) (ReadResult, error) {
+	var result ReadResult
+	logger, metrics := pa.observers()
+	defer func() {
+		metrics.AddPrefixesLoaded(result.Added)
+	}()
+
+	pa.mu.RLock()
+	syntax := pa.syntax
+	pa.mu.RUnlock()
+
 	scanner := bufio.NewScanner(reader)
 	lineNumber := 0
 
 	for scanner.Scan() {
 		lineNumber++
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines, comments, and common header words
-		if line == "" || strings.HasPrefix(line, "#") ||
-			line == "network" || line == "prefix" || line == "cidr" {
+		line, text, kind := syntax.parse(scanner.Text())
+		if kind == lineIgnored {
+			continue
+		}
+		if kind == lineInvalid {
+			pa.countSkippedLine(SkipParseError)
+			skippedLine(logger, metrics, "", tag, lineNumber, scanner.Text(), SkipParseError, nil)
+			result.Skipped++
 			continue
 		}
 
-		// Handle lines that might be missing CIDR notation
-		if !strings.Contains(line, "/") {
-			// Try to add /32 for IPv4 addresses or /128 for IPv6 addresses
-			if strings.Contains(line, ":") {
-				line = line + "/128"
-			} else if strings.Count(line, ".") == 3 {
-				line = line + "/32"
-			} else {
-				// Skip invalid lines
-				continue
+		if err := pa.addPrefixText(line, text, tag); err != nil {
+			if errors.Is(err, ErrMemoryBudgetExceeded) || errors.Is(err, ErrClosed) {
+				return result, fmt.Errorf("line %d: %w", lineNumber, err)
 			}
+			// Count the error but continue processing (graceful degradation)
+			pa.countSkippedLine(SkipParseError)
+			skippedLine(logger, metrics, "", tag, lineNumber, scanner.Text(), SkipParseError, err)
+			result.Skipped++
+			continue
 		}
+		result.Added++
 
-		if err := pa.AddPrefix(line); err != nil {
-			// Log the error but continue processing (graceful degradation)
-			continue
+		if onAdd != nil {
+			if err := onAdd(); err != nil {
+				return result, err
+			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading input: %w", err)
+		return result, fmt.Errorf("error reading input: %w", err)
 	}
 
-	return nil
+	return result, nil
+}
+
+// countSkippedLine records a reader line that could not be added
+func (pa *PrefixAggregator) countSkippedLine(reason SkipReason) {
+	pa.mu.Lock()
+	pa.skippedLines++
+	pa.skippedByReason[reason]++
+	pa.mu.Unlock()
 }
 
 func (pa *PrefixAggregator) Reset() error {
 	pa.mu.Lock()
 	defer pa.mu.Unlock()
 
-	// Release all prefixes back to the pool
-	for _, p := range pa.IPv4Prefixes {
-		releaseIPPrefix(p)
-	}
-	for _, p := range pa.IPv6Prefixes {
-		releaseIPPrefix(p)
-	}
-	for _, p := range pa.IncludeIPv4 {
-		releaseIPPrefix(p)
-	}
-	for _, p := range pa.IncludeIPv6 {
-		releaseIPPrefix(p)
-	}
-	for _, p := range pa.ExcludeIPv4 {
-		releaseIPPrefix(p)
-	}
-	for _, p := range pa.ExcludeIPv6 {
-		releaseIPPrefix(p)
+	if pa.closed {
+		return ErrClosed
 	}
 
+	pa.releasePrefixes()
 	pa.IPv4Prefixes = pa.IPv4Prefixes[:0]
 	pa.IPv6Prefixes = pa.IPv6Prefixes[:0]
+	pa.render = nil
 	pa.IncludeIPv4 = pa.IncludeIPv4[:0]
 	pa.IncludeIPv6 = pa.IncludeIPv6[:0]
 	pa.ExcludeIPv4 = pa.ExcludeIPv4[:0]
 	pa.ExcludeIPv6 = pa.ExcludeIPv6[:0]
+	pa.releaseRestriction()
+	pa.sortedIPv4, pa.sortedIPv6 = 0, 0
+	pa.exclusionSets = nil
+	pa.originals = nil
+	pa.originalsIncomplete = false
+	pa.inputs = nil
+	pa.tags = nil
+	pa.aggregated, pa.reconfigured = false, false
+	pa.exclusionCauses = nil
+	pa.appliedExclusions = nil
 	pa.originalCount = 0
+	pa.originalIPv4 = 0
+	pa.explicitDefaultIPv4, pa.explicitDefaultIPv6 = false, false
+	pa.addsSinceCheck = 0
+	pa.skippedLines = 0
+	pa.skippedByReason = [skipReasonCount]int{}
+	pa.ingested = nil
+	pa.duplicatesRemoved = 0
+	pa.skippedExclusions = 0
 	pa.lastProcessTime = 0
 	pa.clearWarnings()
+	pa.closeWarningChans()
+	pa.changes++
+	pa.dirty = true
+
+	return nil
+}
 
+// Close returns every prefix the aggregator holds to the pool, closes
+// the channels from WarningsChan and drops the result, so a discarded
+// aggregator does not keep pooled memory until it is collected. Every
+// later call that can fail returns ErrClosed, and the others return empty
+// results. Closing again is a no-op.
+func (pa *PrefixAggregator) Close() error {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return nil
+	}
+
+	pa.releasePrefixes()
+	pa.IPv4Prefixes, pa.IPv6Prefixes = nil, nil
+	pa.render = nil
+	pa.IncludeIPv4, pa.IncludeIPv6 = nil, nil
+	pa.ExcludeIPv4, pa.ExcludeIPv6 = nil, nil
+	pa.releaseRestriction()
+	pa.sortedIPv4, pa.sortedIPv6 = 0, 0
+	pa.exclusionSets = nil
+	pa.originals = nil
+	pa.inputs = nil
+	pa.tags = nil
+	pa.ingested = nil
+	pa.exclusionCauses = nil
+	pa.appliedExclusions = nil
+	pa.clearWarnings()
+	pa.closeWarningChans()
+	pa.changes++
+	pa.closed = true
 	return nil
 }
 
+// releasePrefixes returns the prefixes of the working, include and
+// exclude lists and of the exclusion sets to the pool. The caller drops
+// the lists.
+func (pa *PrefixAggregator) releasePrefixes() {
+	for _, list := range [][]*IPPrefix{pa.IPv4Prefixes, pa.IPv6Prefixes, pa.IncludeIPv4, pa.IncludeIPv6, pa.ExcludeIPv4, pa.ExcludeIPv6} {
+		for _, p := range list {
+			releaseIPPrefix(p)
+		}
+	}
+	for _, set := range pa.exclusionSets {
+		releaseExclusionSet(set)
+	}
+}
+
+// Compact shrinks the aggregator's memory after aggregation by
+// reallocating the result slices to their exact length and optionally
+// releasing the constraint lists and the shared prefix pool.
+func (pa *PrefixAggregator) Compact(opts CompactOptions) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return
+	}
+
+	pa.IPv4Prefixes = compactPrefixSlice(pa.IPv4Prefixes)
+	pa.IPv6Prefixes = compactPrefixSlice(pa.IPv6Prefixes)
+	pa.render = nil
+
+	if opts.ReleaseConstraints {
+		for _, list := range [][]*IPPrefix{pa.IncludeIPv4, pa.IncludeIPv6, pa.ExcludeIPv4, pa.ExcludeIPv6} {
+			for _, p := range list {
+				releaseIPPrefix(p)
+			}
+		}
+		pa.IncludeIPv4 = make([]*IPPrefix, 0)
+		pa.IncludeIPv6 = make([]*IPPrefix, 0)
+		pa.ExcludeIPv4 = make([]*IPPrefix, 0)
+		pa.ExcludeIPv6 = make([]*IPPrefix, 0)
+		for _, set := range pa.exclusionSets {
+			releaseExclusionSet(set)
+		}
+		pa.exclusionSets = nil
+	}
+
+	if opts.ClearPool {
+		ipPrefixPool.Store(newIPPrefixPool())
+	}
+	pa.lastStats = nil
+}
+
+func compactPrefixSlice(prefixes []*IPPrefix) []*IPPrefix {
+	if len(prefixes) == cap(prefixes) {
+		return prefixes
+	}
+	result := make([]*IPPrefix, len(prefixes))
+	copy(result, prefixes)
+	return result
+}
+
+// GetPrefixes returns the current prefixes as strings, IPv4 first. The
+// result is rendered once and kept until it changes, so repeated calls
+// only allocate the slice. The strings share one block of memory, which
+// stays live while any of them is held; for multi-million prefix sets
+// prefer WriteToWriter or ForEachPrefixString, and GetPrefixesAppend to
+// reuse the slice between calls.
 func (pa *PrefixAggregator) GetPrefixes() []string {
 	pa.mu.RLock()
 	defer pa.mu.RUnlock()
 
-	result := make([]string, 0, len(pa.IPv4Prefixes)+len(pa.IPv6Prefixes))
+	return pa.appendAllPrefixStrings(make([]string, 0, len(pa.IPv4Prefixes)+len(pa.IPv6Prefixes)))
+}
+
+// GetPrefixesAppend appends the current prefixes to dst, as GetPrefixes
+// returns them, and returns the extended slice. Passing dst[:0] from an
+// earlier call avoids reallocating the slice.
+func (pa *PrefixAggregator) GetPrefixesAppend(dst []string) []string {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return pa.appendAllPrefixStrings(slices.Grow(dst, len(pa.IPv4Prefixes)+len(pa.IPv6Prefixes)))
+}
 
-	for _, prefix := range pa.IPv4Prefixes {
-		result = append(result, prefix.Prefix.String())
+func (pa *PrefixAggregator) appendAllPrefixStrings(dst []string) []string {
+	r := pa.rendered()
+	for i := 0; i < r.len(); i++ {
+		dst = append(dst, r.prefix(i))
 	}
-	for _, prefix := range pa.IPv6Prefixes {
-		result = append(result, prefix.Prefix.String())
+	return dst
+}
+
+// appendPrefixStrings renders a single-family list in the configured
+// output order and format
+func (pa *PrefixAggregator) appendPrefixStrings(dst []string, prefixes []*IPPrefix) []string {
+	for _, prefix := range pa.orderedPrefixes(prefixes) {
+		dst = append(dst, pa.formatPrefix(prefix.Prefix))
 	}
+	return dst
+}
 
-	return result
+// formatPrefix renders a prefix using the configured IPv6 format
+func (pa *PrefixAggregator) formatPrefix(p netip.Prefix) string {
+	if pa.ipv6Format == IPv6Expanded && p.Addr().Is6() {
+		return p.Addr().StringExpanded() + "/" + strconv.Itoa(p.Bits())
+	}
+	return p.String()
+}
+
+// appendFormattedPrefix is formatPrefix for byte buffers
+func (pa *PrefixAggregator) appendFormattedPrefix(dst []byte, p netip.Prefix) []byte {
+	if pa.ipv6Format == IPv6Expanded && p.Addr().Is6() {
+		dst = append(dst, p.Addr().StringExpanded()...)
+		dst = append(dst, '/')
+		return strconv.AppendInt(dst, int64(p.Bits()), 10)
+	}
+	return p.AppendTo(dst)
+}
+
+// orderedPrefixes returns a single-family result list in the configured
+// output order. The stored lists are always kept in canonical address
+// order, so this only copies when another order was requested.
+func (pa *PrefixAggregator) orderedPrefixes(prefixes []*IPPrefix) []*IPPrefix {
+	if pa.outputOrder != OrderPrefixLengthFirst || len(prefixes) <= 1 {
+		return prefixes
+	}
+
+	ordered := make([]*IPPrefix, len(prefixes))
+	copy(ordered, prefixes)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if bi, bj := ordered[i].Prefix.Bits(), ordered[j].Prefix.Bits(); bi != bj {
+			return bi > bj
+		}
+		return ordered[i].Min.Cmp(ordered[j].Min) < 0
+	})
+	return ordered
 }
 
 func (pa *PrefixAggregator) GetIPv4Prefixes() []string {
@@ -295,11 +1253,7 @@ func (pa *PrefixAggregator) GetIPv4Prefixes() []string {
 	defer pa.mu.RUnlock()
 
 	result := make([]string, 0, len(pa.IPv4Prefixes))
-	for _, prefix := range pa.IPv4Prefixes {
-		result = append(result, prefix.Prefix.String())
-	}
-
-	return result
+	return pa.appendPrefixStrings(result, pa.IPv4Prefixes)
 }
 
 func (pa *PrefixAggregator) GetIPv6Prefixes() []string {
@@ -307,54 +1261,188 @@ func (pa *PrefixAggregator) GetIPv6Prefixes() []string {
 	defer pa.mu.RUnlock()
 
 	result := make([]string, 0, len(pa.IPv6Prefixes))
-	for _, prefix := range pa.IPv6Prefixes {
-		result = append(result, prefix.Prefix.String())
-	}
+	return pa.appendPrefixStrings(result, pa.IPv6Prefixes)
+}
 
-	return result
+// AppendIPv4Prefixes appends the prefixes GetIPv4Prefixes returns to dst
+// and returns the extended slice. Passing dst[:0] from an earlier call
+// reuses its backing array; the strings are still allocated.
+func (pa *PrefixAggregator) AppendIPv4Prefixes(dst []string) []string {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return pa.appendPrefixStrings(slices.Grow(dst, len(pa.IPv4Prefixes)), pa.IPv4Prefixes)
+}
+
+// AppendIPv6Prefixes is AppendIPv4Prefixes for GetIPv6Prefixes
+func (pa *PrefixAggregator) AppendIPv6Prefixes(dst []string) []string {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return pa.appendPrefixStrings(slices.Grow(dst, len(pa.IPv6Prefixes)), pa.IPv6Prefixes)
+}
+
+// AppendIPv4NetipPrefixes appends the current IPv4 prefixes to dst, in
+// the order GetIPv4Prefixes returns them, and returns the extended slice.
+// Reusing a large enough dst between calls makes it allocation free in
+// the default output order.
+func (pa *PrefixAggregator) AppendIPv4NetipPrefixes(dst []netip.Prefix) []netip.Prefix {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return pa.appendNetipPrefixes(dst, pa.IPv4Prefixes)
+}
+
+// AppendIPv6NetipPrefixes is AppendIPv4NetipPrefixes for IPv6
+func (pa *PrefixAggregator) AppendIPv6NetipPrefixes(dst []netip.Prefix) []netip.Prefix {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return pa.appendNetipPrefixes(dst, pa.IPv6Prefixes)
+}
+
+func (pa *PrefixAggregator) appendNetipPrefixes(dst []netip.Prefix, prefixes []*IPPrefix) []netip.Prefix {
+	dst = slices.Grow(dst, len(prefixes))
+	for _, p := range pa.orderedPrefixes(prefixes) {
+		dst = append(dst, p.Prefix)
+	}
+	return dst
 }
 
+// GetStats returns the statistics computed by the last Aggregate, without
+// recomputing them, until the aggregator is next changed. Before the first
+// Aggregate and after a change they describe the prefixes as they stand,
+// counting input not yet merged.
 func (pa *PrefixAggregator) GetStats() AggregationStats {
 	pa.mu.RLock()
 	defer pa.mu.RUnlock()
 
+	return pa.currentStats()
+}
+
+// cachedStats is an AggregationStats with what it depends on beyond the
+// dirty flag: the working lists and the skipped line and duplicate counts
+type cachedStats struct {
+	stats      AggregationStats
+	memory     memoryBreakdown
+	changes    uint64
+	skipped    int
+	duplicates int
+}
+
+// currentStats is GetStats for callers holding the lock
+func (pa *PrefixAggregator) currentStats() AggregationStats {
+	if c := pa.cachedStatsValid(); c != nil {
+		return c.stats
+	}
+	return pa.stats(pa.calculateMemoryUsage())
+}
+
+// currentMemory is the size estimate of GetMemoryStats, from the cache
+// when it is valid. The caller holds the lock.
+func (pa *PrefixAggregator) currentMemory() memoryBreakdown {
+	if c := pa.cachedStatsValid(); c != nil {
+		return c.memory
+	}
+	return pa.calculateMemoryUsage()
+}
+
+func (pa *PrefixAggregator) cachedStatsValid() *cachedStats {
+	if c := pa.lastStats; c != nil && !pa.dirty && c.changes == pa.changes && c.skipped == pa.skippedLines && c.duplicates == pa.duplicatesRemoved {
+		return c
+	}
+	return nil
+}
+
+// cacheStats computes the statistics of a completed Aggregate for
+// GetStats. The caller holds the write lock.
+func (pa *PrefixAggregator) cacheStats() AggregationStats {
+	memory := pa.calculateMemoryUsage()
+	stats := pa.stats(memory)
+	pa.lastStats = &cachedStats{stats: stats, memory: memory, changes: pa.changes, skipped: pa.skippedLines, duplicates: pa.duplicatesRemoved}
+	return stats
+}
+
+// stats is GetStats for callers holding the lock, given the memory
+// estimate
+func (pa *PrefixAggregator) stats(memory memoryBreakdown) AggregationStats {
 	ipv4Count := len(pa.IPv4Prefixes)
 	ipv6Count := len(pa.IPv6Prefixes)
 	totalPrefixes := ipv4Count + ipv6Count
 
-	var reductionRatio float64
-	if pa.originalCount > 0 {
-		reductionRatio = 1.0 - (float64(totalPrefixes) / float64(pa.originalCount))
+	includedCount := len(pa.IncludeIPv4) + len(pa.IncludeIPv6)
+	excludedCount := len(pa.ExcludeIPv4) + len(pa.ExcludeIPv6)
+	for _, set := range pa.exclusionSets {
+		if set.enabled {
+			excludedCount += len(set.ipv4) + len(set.ipv6)
+		}
 	}
 
-	memoryUsage := pa.calculateMemoryUsage()
+	// Includes are part of the input, so they count towards the baseline.
+	// Exclusions can still split prefixes, so clamp at zero.
+	var reductionRatio float64
+	if baseline := pa.originalCount + includedCount; baseline > 0 {
+		reductionRatio = 1.0 - (float64(totalPrefixes) / float64(baseline))
+		if reductionRatio < 0 {
+			reductionRatio = 0
+		}
+	}
 
 	return AggregationStats{
-		IPv4PrefixCount:  ipv4Count,
-		IPv6PrefixCount:  ipv6Count,
-		TotalPrefixes:    totalPrefixes,
-		OriginalCount:    pa.originalCount,
-		ReductionRatio:   reductionRatio,
-		ProcessingTimeMs: pa.lastProcessTime.Milliseconds(),
-		MemoryUsageBytes: memoryUsage,
+		IPv4PrefixCount:   ipv4Count,
+		IPv6PrefixCount:   ipv6Count,
+		TotalPrefixes:     totalPrefixes,
+		OriginalCount:     pa.originalCount,
+		OriginalIPv4Count: pa.originalIPv4,
+		OriginalIPv6Count: pa.originalCount - pa.originalIPv4,
+		SkippedLines:      pa.skippedLines,
+		SkippedByReason:   pa.skippedByReason,
+		DuplicatesRemoved: pa.duplicatesRemoved,
+		IncludedCount:     includedCount,
+		ExcludedCount:     excludedCount,
+		SkippedExclusions: pa.skippedExclusions,
+		TotalWarnings:     pa.totalWarnings,
+		ReductionRatio:    reductionRatio,
+		ProcessingTimeMs:  pa.lastProcessTime.Milliseconds(),
+		MemoryUsageBytes:  memory.total(),
 	}
 }
 
-func (pa *PrefixAggregator) calculateMemoryUsage() int64 {
-	var totalMemory int64
+// memoryBreakdown is the size estimate of an aggregator by what holds it
+type memoryBreakdown struct {
+	result, include, exclude, other int64
+}
 
-	// Size of PrefixAggregator struct itself
-	totalMemory += int64(unsafe.Sizeof(*pa))
+func (m memoryBreakdown) total() int64 {
+	return m.result + m.include + m.exclude + m.other
+}
 
-	// Calculate memory for IPv4 prefixes
-	totalMemory += pa.calculatePrefixSliceMemory(pa.IPv4Prefixes)
-	totalMemory += pa.calculatePrefixSliceMemory(pa.IPv6Prefixes)
-	totalMemory += pa.calculatePrefixSliceMemory(pa.IncludeIPv4)
-	totalMemory += pa.calculatePrefixSliceMemory(pa.IncludeIPv6)
-	totalMemory += pa.calculatePrefixSliceMemory(pa.ExcludeIPv4)
-	totalMemory += pa.calculatePrefixSliceMemory(pa.ExcludeIPv6)
+func (pa *PrefixAggregator) calculateMemoryUsage() memoryBreakdown {
+	var m memoryBreakdown
+
+	m.result += pa.calculatePrefixSliceMemory(pa.IPv4Prefixes)
+	m.result += pa.calculatePrefixSliceMemory(pa.IPv6Prefixes)
+	m.include += pa.calculatePrefixSliceMemory(pa.IncludeIPv4)
+	m.include += pa.calculatePrefixSliceMemory(pa.IncludeIPv6)
+	m.exclude += pa.calculatePrefixSliceMemory(pa.ExcludeIPv4)
+	m.exclude += pa.calculatePrefixSliceMemory(pa.ExcludeIPv6)
+	m.exclude += pa.calculatePrefixSliceMemory(pa.restrictIPv4)
+	m.exclude += pa.calculatePrefixSliceMemory(pa.restrictIPv6)
+	for _, set := range pa.exclusionSets {
+		m.exclude += pa.calculatePrefixSliceMemory(set.ipv4)
+		m.exclude += pa.calculatePrefixSliceMemory(set.ipv6)
+	}
+
+	// Size of PrefixAggregator struct itself
+	m.other += int64(unsafe.Sizeof(*pa))
+	m.other += int64(cap(pa.originals)) * int64(unsafe.Sizeof(netip.Prefix{}))
+	for _, tags := range pa.tags {
+		m.other += int64(unsafe.Sizeof(netip.Prefix{})) + int64(cap(tags))*int64(unsafe.Sizeof(""))
+	}
+	m.other += pa.ingestedMemory()
+	m.other += pa.renderedMemory()
 
-	return totalMemory
+	return m
 }
 
 func (pa *PrefixAggregator) calculatePrefixSliceMemory(prefixes []*IPPrefix) int64 {
@@ -368,42 +1456,54 @@ func (pa *PrefixAggregator) calculatePrefixSliceMemory(prefixes []*IPPrefix) int
 	// Slice backing array (pointers to IPPrefix)
 	sliceMemory += int64(cap(prefixes)) * int64(unsafe.Sizeof((*IPPrefix)(nil)))
 
-	// Each IPPrefix struct and its uint256.Int allocations
-	for _, prefix := range prefixes {
-		if prefix != nil {
-			// IPPrefix struct itself
-			sliceMemory += int64(unsafe.Sizeof(*prefix))
-
-			// Two uint256.Int structs (Min and Max)
-			if prefix.Min != nil {
-				sliceMemory += int64(unsafe.Sizeof(*prefix.Min))
-			}
-			if prefix.Max != nil {
-				sliceMemory += int64(unsafe.Sizeof(*prefix.Max))
-			}
-		}
-	}
+	// Each IPPrefix struct and its two uint256.Int allocations. Min and
+	// Max are never nil, so this needs no walk over the list.
+	var p IPPrefix
+	var v uint256.Int
+	sliceMemory += int64(len(prefixes)) * int64(unsafe.Sizeof(p)+2*unsafe.Sizeof(v))
 
 	return sliceMemory
 }
 
+// GetMemoryStats reports the runtime and pool counters and the estimated
+// size of the aggregator. runtime.ReadMemStats stops the world, so it runs
+// before the read lock is taken, which only covers the size estimate.
 func (pa *PrefixAggregator) GetMemoryStats() MemoryStats {
-	pa.mu.RLock()
-	defer pa.mu.RUnlock()
-
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
+	pa.mu.RLock()
+	memory := pa.currentMemory()
+	pa.mu.RUnlock()
+
 	return MemoryStats{
 		AllocBytes:      int64(m.Alloc),
 		TotalAllocBytes: int64(m.TotalAlloc),
 		SysBytes:        int64(m.Sys),
 		NumGC:           int64(m.NumGC),
-		AggregatorBytes: pa.calculateMemoryUsage(),
+		AggregatorBytes: memory.total(),
+		ResultBytes:     memory.result,
+		IncludeBytes:    memory.include,
+		ExcludeBytes:    memory.exclude,
+		OtherBytes:      memory.other,
+		PoolGets:        poolGets.Load(),
+		PoolPuts:        poolPuts.Load(),
+		PoolMisses:      poolMisses.Load(),
 	}
 }
 
 func (pa *PrefixAggregator) WriteToFile(path string) error {
+	// Check before creating the file, so a refused write leaves none behind
+	pa.mu.RLock()
+	err := pa.checkEmptyOutput()
+	if pa.closed {
+		err = ErrClosed
+	}
+	pa.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
 	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create file %s: %w", path, err)
@@ -415,51 +1515,188 @@ func (pa *PrefixAggregator) WriteToFile(path string) error {
 	return pa.WriteToWriter(file)
 }
 
+// WriteToWriter writes the current prefixes one per line, formatting them
+// straight into a buffer. The aggregator stays read-locked until the last
+// line is written. A write error says how many prefixes reached writer.
 func (pa *PrefixAggregator) WriteToWriter(writer io.Writer) error {
-	prefixes := pa.GetPrefixes()
+	_, err := pa.WriteToWriterN(writer)
+	return err
+}
 
-	for _, prefix := range prefixes {
-		if _, err := fmt.Fprintf(writer, "%s\n", prefix); err != nil {
-			return fmt.Errorf("failed to write prefix %s: %w", prefix, err)
+// WriteToWriterN is WriteToWriter, also returning how many prefixes reached
+// writer as complete lines, header lines not counted. The first error from
+// writer stops the write.
+func (pa *PrefixAggregator) WriteToWriterN(writer io.Writer) (int, error) {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if pa.closed {
+		return 0, ErrClosed
+	}
+
+	if err := pa.checkEmptyOutput(); err != nil {
+		return 0, err
+	}
+
+	r := pa.rendered()
+	counter := &lineCounter{w: writer}
+	w := bufio.NewWriter(counter)
+	err := pa.writeHeader(w, r)
+	if err == nil {
+		err = pa.writePrefixLines(w, r, 0, -1)
+	}
+	if err == nil {
+		if err = w.Flush(); err != nil {
+			err = fmt.Errorf("failed to write prefixes: %w", err)
 		}
 	}
 
-	return nil
+	written := max(counter.lines-pa.headerLines(), 0)
+	if err != nil {
+		return written, fmt.Errorf("%w (%d of %d prefixes written)", err, written, r.len())
+	}
+	return written, nil
 }
 
-// SetWarningHandler sets a custom handler for warnings
-func (pa *PrefixAggregator) SetWarningHandler(handler func(string)) {
-	pa.mu.Lock()
-	defer pa.mu.Unlock()
-	pa.warningHandler = handler
+// lineCounter counts the lines that reached w
+type lineCounter struct {
+	w     io.Writer
+	lines int
 }
 
-// GetWarnings returns all warnings generated during processing
-func (pa *PrefixAggregator) GetWarnings() []string {
+func (c *lineCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.lines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+// WriteSplit writes the IPv4 prefixes to v4 and the IPv6 prefixes to v6,
+// one per line, in a single read-locked pass. A nil writer skips its
+// family.
+func (pa *PrefixAggregator) WriteSplit(v4, v6 io.Writer) error {
+	if v4 == nil && v6 == nil {
+		return fmt.Errorf("%w: WriteSplit needs at least one writer", ErrInvalidOption)
+	}
+
 	pa.mu.RLock()
 	defer pa.mu.RUnlock()
 
-	if len(pa.warnings) == 0 {
-		return nil
+	if pa.closed {
+		return ErrClosed
 	}
 
-	// Return a copy to prevent external modification
-	result := make([]string, len(pa.warnings))
-	copy(result, pa.warnings)
-	return result
+	if err := pa.checkEmptyOutput(); err != nil {
+		return err
+	}
+
+	r := pa.rendered()
+	split := r.counts[0]
+	for _, family := range []struct {
+		writer     io.Writer
+		start, end int
+	}{{v4, 0, split}, {v6, split, -1}} {
+		if family.writer == nil {
+			continue
+		}
+		w := bufio.NewWriter(family.writer)
+		if err := pa.writeHeader(w, r); err != nil {
+			return err
+		}
+		if err := pa.writePrefixLines(w, r, family.start, family.end); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("failed to write prefixes: %w", err)
+		}
+	}
+	return nil
 }
 
-// addWarning adds a warning message
-func (pa *PrefixAggregator) addWarning(msg string) {
-	pa.warnings = append(pa.warnings, msg)
+// WriteToFiles writes the aggregated prefixes into numbered files holding
+// at most maxPerFile prefixes each and returns the paths it created, in
+// order. pathPattern may contain a printf verb for the file number (for
+// example "out-%03d.txt"); otherwise "-001", "-002", ... is inserted before
+// the extension. At least one file is written unless SetFailOnEmptyResult
+// refuses an empty result.
+func (pa *PrefixAggregator) WriteToFiles(pathPattern string, maxPerFile int) ([]string, error) {
+	if maxPerFile <= 0 {
+		return nil, fmt.Errorf("%w: maxPerFile must be positive, got %d", ErrInvalidOption, maxPerFile)
+	}
+
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
 
-	// Call handler if set
-	if pa.warningHandler != nil {
-		pa.warningHandler(msg)
+	if pa.closed {
+		return nil, ErrClosed
 	}
+
+	if err := pa.checkEmptyOutput(); err != nil {
+		return nil, err
+	}
+
+	r := pa.rendered()
+	total := r.len()
+
+	var paths []string
+	for start := 0; start == 0 || start < total; start += maxPerFile {
+		path := chunkPath(pathPattern, len(paths)+1)
+		if err := pa.writePrefixFile(path, r, start, min(start+maxPerFile, total)); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// checkEmptyOutput returns ErrEmptyResult for an empty result under
+// SetFailOnEmptyResult
+func (pa *PrefixAggregator) checkEmptyOutput() error {
+	if pa.failOnEmptyResult && len(pa.IPv4Prefixes)+len(pa.IPv6Prefixes) == 0 {
+		return fmt.Errorf("%w: no prefixes to write", ErrEmptyResult)
+	}
+	return nil
+}
+
+// writePrefixLines writes lines start to end of the rendered result, or
+// to the last line if end is negative
+func (pa *PrefixAggregator) writePrefixLines(w *bufio.Writer, r *renderedResult, start, end int) error {
+	if end < 0 {
+		end = r.len()
+	}
+
+	for i := start; i < end; i++ {
+		if _, err := w.WriteString(r.line(i)); err != nil {
+			return fmt.Errorf("failed to write prefix %s: %w", r.prefix(i), err)
+		}
+	}
+	return nil
 }
 
-// clearWarnings clears all warnings
-func (pa *PrefixAggregator) clearWarnings() {
-	pa.warnings = nil
+// chunkPath returns the path of the n-th chunk for pathPattern
+func chunkPath(pathPattern string, n int) string {
+	if strings.Contains(pathPattern, "%") {
+		return fmt.Sprintf(pathPattern, n)
+	}
+	ext := filepath.Ext(pathPattern)
+	return fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(pathPattern, ext), n, ext)
+}
+
+func (pa *PrefixAggregator) writePrefixFile(path string, r *renderedResult, start, end int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+
+	w := bufio.NewWriter(file)
+	if err := pa.writePrefixLines(w, r, start, end); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+
+	return file.Close()
 }
@@ -1,33 +1,122 @@
 package netjugo
 
 import (
+	"errors"
 	"fmt"
-	"sort"
+	"net/netip"
+	"slices"
 	"time"
 
 	"github.com/holiman/uint256"
 )
 
+// Aggregate merges the loaded prefixes and applies the configured
+// constraints. Calling it again without any intervening mutation is a
+// cheap no-op, so concurrent callers all observe the same result.
+//
+// Aggregate works on a copy of the working lists and swaps the result in
+// at the end, so readers such as GetPrefixes and GetStats do not wait for
+// it and see the previous result until then. Methods that change the
+// aggregator still wait for it to finish. The copy holds every working
+// prefix a second time, so the prefix memory peaks at about twice its
+// usual size while Aggregate runs. SetMemoryBudget does not cover this
+// copy.
 func (pa *PrefixAggregator) Aggregate() error {
 	start := time.Now()
 
-	pa.mu.Lock()
-	defer pa.mu.Unlock()
+	pa.mu.run.Lock()
+	defer pa.mu.run.Unlock()
 
-	// Clear any previous warnings
-	pa.clearWarnings()
+	if !pa.dirty && !pa.closed {
+		return nil
+	}
+	return pa.aggregateCopy(start, nil)
+}
 
-	// Add include prefixes to main lists
-	if err := pa.processInclusions(); err != nil {
-		return fmt.Errorf("failed to process inclusions: %w", err)
+// AggregateStats is Aggregate, also returning the statistics of the
+// result, as GetStats would right after it
+func (pa *PrefixAggregator) AggregateStats() (AggregationStats, error) {
+	start := time.Now()
+
+	pa.mu.run.Lock()
+	defer pa.mu.run.Unlock()
+
+	if pa.dirty || pa.closed {
+		if err := pa.aggregateCopy(start, nil); err != nil {
+			return AggregationStats{}, err
+		}
 	}
+	return pa.currentStats(), nil
+}
 
-	// Enforce minimum prefix lengths on all prefixes (including newly added includes)
-	if err := pa.enforceMinPrefixLengths(); err != nil {
+// AggregateWithin is Aggregate with a time budget for merging. Once d has
+// passed, the merge passes stop and the lists are only cleared of nested
+// prefixes, so the result covers the same addresses but may hold sibling
+// prefixes a full run would have merged. Every other phase, exclusions
+// included, always runs to completion. complete reports whether merging
+// converged; if not, the aggregator stays dirty and the next Aggregate
+// finishes the job.
+func (pa *PrefixAggregator) AggregateWithin(d time.Duration) (complete bool, err error) {
+	if d <= 0 {
+		return false, fmt.Errorf("%w: time budget must be positive, got %v", ErrInvalidOption, d)
+	}
+	start := time.Now()
+
+	pa.mu.run.Lock()
+	defer pa.mu.run.Unlock()
+
+	if !pa.dirty && !pa.closed {
+		return true, nil
+	}
+	err = pa.aggregateCopy(start, func(w *PrefixAggregator) error {
+		w.mergeDeadline = start.Add(d)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	// A run cut short leaves the aggregator dirty
+	return !pa.dirty, nil
+}
+
+// aggregateLocked runs Aggregate on the working copy of aggregateCopy
+func (pa *PrefixAggregator) aggregateLocked(start time.Time) error {
+	if pa.closed {
+		return ErrClosed
+	}
+	if !pa.dirty {
+		return nil
+	}
+	pa.changes++
+	phase := start
+
+	// Settings changed since the input was merged, so start again from
+	// the retained originals
+	if pa.reconfigured {
+		if err := pa.restoreOriginals(); err != nil {
+			return fmt.Errorf("settings changed after Aggregate: %w", err)
+		}
+		pa.logPhase("restore originals", &phase)
+	}
+
+	// Clear any previous warnings
+	pa.clearWarnings()
+	pa.warnConstraintIssues()
+	if err := pa.checkIncludeRounding(); err != nil {
 		return err
 	}
 
-	// Sort and deduplicate
+	// Add include prefixes to main lists, unless a previous result
+	// already holds them
+	if !pa.aggregated {
+		if err := pa.processInclusions(); err != nil {
+			return fmt.Errorf("failed to process inclusions: %w", err)
+		}
+	}
+	pa.aggregated = pa.aggregated || len(pa.IPv4Prefixes) > 0 || len(pa.IPv6Prefixes) > 0
+	pa.logPhase("inclusions", &phase)
+
+	// Sort and deduplicate once; every later phase keeps the lists sorted
 	if err := pa.sortAndDeduplicateIPv4(); err != nil {
 		return err
 	}
@@ -35,6 +124,17 @@ func (pa *PrefixAggregator) Aggregate() error {
 	if err := pa.sortAndDeduplicateIPv6(); err != nil {
 		return err
 	}
+	pa.logPhase("sort", &phase)
+
+	// Enforce minimum prefix lengths on all prefixes (including newly added includes)
+	if err := pa.enforceMinPrefixLengths(); err != nil {
+		return err
+	}
+	pa.logPhase("minimum lengths", &phase)
+
+	// The lists are rewritten from here on; finalize re-establishes the
+	// sorted prefix, so a failed run falls back to a full sort next time
+	pa.sortedIPv4, pa.sortedIPv6 = 0, 0
 
 	// Initial aggregation
 	if err := pa.aggregatePrefixes(&pa.IPv4Prefixes); err != nil {
@@ -44,33 +144,135 @@ func (pa *PrefixAggregator) Aggregate() error {
 	if err := pa.aggregatePrefixes(&pa.IPv6Prefixes); err != nil {
 		return err
 	}
+	pa.logPhase("merge", &phase)
+
+	// Cut out everything outside the restriction, then the exclusions
+	if err := pa.applyRestriction(); err != nil {
+		return fmt.Errorf("failed to apply restriction: %w", err)
+	}
+	pa.logPhase("restriction", &phase)
 
-	// Process exclusions after initial aggregation
 	if err := pa.processExclusionsNew(); err != nil {
 		return fmt.Errorf("failed to process exclusions: %w", err)
 	}
+	pa.logPhase("exclusions", &phase)
 
-	// Final sort after exclusion processing
-	if err := pa.sortAndDeduplicateIPv4(); err != nil {
+	if pa.constraintOrder == IncludesWin {
+		if err := pa.protectInclusions(); err != nil {
+			return fmt.Errorf("failed to protect inclusions: %w", err)
+		}
+		if err := pa.applyRestriction(); err != nil {
+			return fmt.Errorf("failed to apply restriction: %w", err)
+		}
+		pa.logPhase("protect inclusions", &phase)
+	}
+
+	if err := pa.checkResultSize(); err != nil {
 		return err
 	}
 
-	if err := pa.sortAndDeduplicateIPv6(); err != nil {
+	if err := pa.finalize(); err != nil {
 		return err
 	}
+	pa.pruneSplits()
+	pa.logPhase("finalize", &phase)
+
+	if err := pa.checkDefaultRoutes(); err != nil {
+		return err
+	}
+	pa.checkEmptyResult()
 
 	pa.lastProcessTime = time.Since(start)
+	pa.dirty = pa.mergeCut
+	pa.metrics.ObserveAggregation(pa.cacheStats())
+	if pa.logger != nil {
+		pa.logger.Info("aggregation complete", "duration", pa.lastProcessTime,
+			"ipv4_prefixes", len(pa.IPv4Prefixes), "ipv6_prefixes", len(pa.IPv6Prefixes), "warnings", len(pa.warnings))
+	}
 	return nil
 }
 
+// finalize puts the result lists into their canonical order: by address,
+// then by prefix length (less specific first), without duplicates. The
+// configured OutputOrder is applied on top of this when results are read.
+func (pa *PrefixAggregator) finalize() error {
+	// The phases before keep the lists sorted, so this is normally just
+	// the check
+	for _, list := range []*[]*IPPrefix{&pa.IPv4Prefixes, &pa.IPv6Prefixes} {
+		if !slices.IsSortedFunc(*list, compareIPPrefix) {
+			sortPrefixes(*list)
+		}
+		if err := pa.deduplicate(list); err != nil {
+			return err
+		}
+	}
+
+	pa.sortedIPv4 = len(pa.IPv4Prefixes)
+	pa.sortedIPv6 = len(pa.IPv6Prefixes)
+	return nil
+}
+
+// checkDefaultRoutes warns, or fails with SetRejectDefaultRoute, when a
+// finalized result list starts with a default route that no input or
+// include prefix asked for
+func (pa *PrefixAggregator) checkDefaultRoutes() error {
+	families := []struct {
+		prefixes []*IPPrefix
+		includes []*IPPrefix
+		explicit bool
+	}{
+		{pa.IPv4Prefixes, pa.IncludeIPv4, pa.explicitDefaultIPv4},
+		{pa.IPv6Prefixes, pa.IncludeIPv6, pa.explicitDefaultIPv6},
+	}
+
+	for _, f := range families {
+		// Canonical order puts a default route first
+		if len(f.prefixes) == 0 || f.prefixes[0].Prefix.Bits() != 0 || f.explicit || hasDefaultRoute(f.includes) {
+			continue
+		}
+
+		route := f.prefixes[0].Prefix
+		if pa.rejectDefaultRoute {
+			return fmt.Errorf("%w: %s was not in the input", ErrDefaultRoute, route)
+		}
+		pa.addWarning(Warning{
+			Code:    WarnDefaultRoute,
+			Message: fmt.Sprintf("WARNING: result aggregated to the default route %s, which was not in the input. Check the input for errors.", route),
+			Prefix:  route,
+		})
+	}
+
+	return nil
+}
+
+// checkEmptyResult warns when input or include prefixes were given but
+// the exclusions removed all of them
+func (pa *PrefixAggregator) checkEmptyResult() {
+	input := pa.originalCount + len(pa.IncludeIPv4) + len(pa.IncludeIPv6)
+	if input == 0 || len(pa.IPv4Prefixes)+len(pa.IPv6Prefixes) > 0 {
+		return
+	}
+	pa.addWarning(Warning{
+		Code:    WarnEmptyResult,
+		Message: fmt.Sprintf("WARNING: result is empty, the exclusions removed all %d input prefixes. Check the exclusion list.", input),
+	})
+}
+
+func hasDefaultRoute(prefixes []*IPPrefix) bool {
+	for _, p := range prefixes {
+		if p.Prefix.Bits() == 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (pa *PrefixAggregator) sortAndDeduplicateIPv4() error {
 	if len(pa.IPv4Prefixes) == 0 {
 		return nil
 	}
 
-	sort.Slice(pa.IPv4Prefixes, func(i, j int) bool {
-		return pa.IPv4Prefixes[i].Min.Cmp(pa.IPv4Prefixes[j].Min) < 0
-	})
+	mergePending(pa.IPv4Prefixes, pa.sortedIPv4)
 
 	return pa.deduplicate(&pa.IPv4Prefixes)
 }
@@ -80,13 +282,54 @@ func (pa *PrefixAggregator) sortAndDeduplicateIPv6() error {
 		return nil
 	}
 
-	sort.Slice(pa.IPv6Prefixes, func(i, j int) bool {
-		return pa.IPv6Prefixes[i].Min.Cmp(pa.IPv6Prefixes[j].Min) < 0
-	})
+	mergePending(pa.IPv6Prefixes, pa.sortedIPv6)
 
 	return pa.deduplicate(&pa.IPv6Prefixes)
 }
 
+// mergePending sorts a list whose first sorted entries are already in
+// canonical order. Only the pending tail is sorted and then merged in, so
+// re-aggregating mostly unchanged data stays close to linear.
+func mergePending(prefixes []*IPPrefix, sorted int) {
+	if sorted <= 0 || sorted > len(prefixes) {
+		sortPrefixes(prefixes)
+		return
+	}
+	if sorted == len(prefixes) {
+		return
+	}
+
+	sortPrefixes(prefixes[sorted:])
+	if compareIPPrefix(prefixes[sorted-1], prefixes[sorted]) <= 0 {
+		return
+	}
+
+	// Merge from the back so only the tail needs a buffer
+	pending := append([]*IPPrefix(nil), prefixes[sorted:]...)
+	i, j := sorted-1, len(pending)-1
+	for k := len(prefixes) - 1; j >= 0; k-- {
+		if i >= 0 && compareIPPrefix(prefixes[i], pending[j]) > 0 {
+			prefixes[k] = prefixes[i]
+			i--
+		} else {
+			prefixes[k] = pending[j]
+			j--
+		}
+	}
+}
+
+// sortPrefixes sorts a single-family list into canonical order. The sort
+// is stable, so equal entries keep their input order and the result never
+// depends on how the sort happened to run.
+func sortPrefixes(prefixes []*IPPrefix) {
+	slices.SortStableFunc(prefixes, compareIPPrefix)
+}
+
+// compareIPPrefix is ComparePrefixes for pooled prefixes
+func compareIPPrefix(a, b *IPPrefix) int {
+	return ComparePrefixes(a.Prefix, b.Prefix)
+}
+
 func (pa *PrefixAggregator) deduplicate(prefixes *[]*IPPrefix) error {
 	if len(*prefixes) <= 1 {
 		return nil
@@ -102,6 +345,8 @@ func (pa *PrefixAggregator) deduplicate(prefixes *[]*IPPrefix) error {
 			if writeIndex != readIndex {
 				(*prefixes)[writeIndex] = current
 			}
+		} else {
+			releaseIPPrefix(current)
 		}
 	}
 
@@ -113,6 +358,13 @@ func isDuplicatePrefix(a, b *IPPrefix) bool {
 	return a.Min.Cmp(b.Min) == 0 && a.Max.Cmp(b.Max) == 0
 }
 
+// maxMergeIterations is a safety limit to prevent infinite loops; tests
+// lower it to reach the non-convergence path
+var maxMergeIterations = 5000
+
+// nonConvergenceSample is how many prefixes a NonConvergenceError carries
+const nonConvergenceSample = 10
+
 func (pa *PrefixAggregator) aggregatePrefixes(prefixes *[]*IPPrefix) error {
 	if len(*prefixes) <= 1 {
 		return nil
@@ -120,10 +372,13 @@ func (pa *PrefixAggregator) aggregatePrefixes(prefixes *[]*IPPrefix) error {
 
 	changed := true
 	iterations := 0
-	maxIterations := 5000 // Safety limit to prevent infinite loops
+	// firstChange is where in the rewritten list the last pass first
+	// merged something
+	firstChange := 0
 
-	for changed && iterations < maxIterations {
+	for changed && iterations < maxMergeIterations {
 		changed = false
+		firstChange = -1
 		iterations++
 
 		newPrefixes := make([]*IPPrefix, 0, len(*prefixes))
@@ -141,18 +396,26 @@ func (pa *PrefixAggregator) aggregatePrefixes(prefixes *[]*IPPrefix) error {
 
 			if contains(current, next) {
 				newPrefixes = append(newPrefixes, current)
+				releaseIPPrefix(next)
 				i += 2
 				changed = true
+				firstChange = markChange(firstChange, len(newPrefixes))
 			} else if contains(next, current) {
 				newPrefixes = append(newPrefixes, next)
+				releaseIPPrefix(current)
 				i += 2
 				changed = true
+				firstChange = markChange(firstChange, len(newPrefixes))
 			} else if areAdjacent(current, next) {
-				merged, err := mergeAdjacent(current, next)
-				if err == nil {
+				// Adjacent blocks only form a CIDR block when they are
+				// siblings, which is cheap to check on the prefixes
+				if merged, ok := mergeSiblings(current, next); ok {
 					newPrefixes = append(newPrefixes, merged)
+					releaseIPPrefix(current)
+					releaseIPPrefix(next)
 					i += 2
 					changed = true
+					firstChange = markChange(firstChange, len(newPrefixes))
 				} else {
 					newPrefixes = append(newPrefixes, current)
 					i++
@@ -161,8 +424,11 @@ func (pa *PrefixAggregator) aggregatePrefixes(prefixes *[]*IPPrefix) error {
 				merged, err := mergeOverlapping(current, next)
 				if err == nil {
 					newPrefixes = append(newPrefixes, merged)
+					releaseIPPrefix(current)
+					releaseIPPrefix(next)
 					i += 2
 					changed = true
+					firstChange = markChange(firstChange, len(newPrefixes))
 				} else {
 					newPrefixes = append(newPrefixes, current)
 					i++
@@ -174,166 +440,221 @@ func (pa *PrefixAggregator) aggregatePrefixes(prefixes *[]*IPPrefix) error {
 		}
 
 		*prefixes = newPrefixes
+
+		if changed && !pa.mergeDeadline.IsZero() && time.Now().After(pa.mergeDeadline) {
+			pa.dropNested(prefixes)
+			pa.mergeCut = true
+			return nil
+		}
 	}
 
-	if iterations >= maxIterations {
-		return fmt.Errorf("aggregation did not converge after %d iterations - possible infinite loop detected", maxIterations)
+	if changed {
+		return pa.nonConvergence(*prefixes, iterations, firstChange)
 	}
 
 	return nil
 }
 
+// dropNested sorts a partly merged list and removes every prefix inside
+// another, leaving it sorted and disjoint
+func (pa *PrefixAggregator) dropNested(prefixes *[]*IPPrefix) {
+	sortPrefixes(*prefixes)
+	kept := (*prefixes)[:0]
+	for _, p := range *prefixes {
+		if n := len(kept); n > 0 && p.Min.Cmp(kept[n-1].Max) <= 0 {
+			releaseIPPrefix(p)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	clear((*prefixes)[len(kept):])
+	*prefixes = kept
+}
+
+// markChange returns the index of the first change in a pass, given the
+// length of the rewritten list just after a merge
+func markChange(firstChange, length int) int {
+	if firstChange < 0 {
+		return length - 1
+	}
+	return firstChange
+}
+
+// nonConvergence logs and returns the error for a merge that hit the
+// iteration limit, sampling the list from where the last pass first
+// changed it
+func (pa *PrefixAggregator) nonConvergence(prefixes []*IPPrefix, iterations, firstChange int) error {
+	end := min(firstChange+nonConvergenceSample, len(prefixes))
+	e := &NonConvergenceError{
+		Iterations: iterations,
+		Family:     prefixFamily(prefixes[0].Prefix),
+		Prefixes:   len(prefixes),
+		Sample:     make([]netip.Prefix, 0, end-firstChange),
+	}
+	for _, p := range prefixes[firstChange:end] {
+		e.Sample = append(e.Sample, p.Prefix)
+	}
+
+	if pa.logger != nil {
+		pa.logger.Warn("aggregation did not converge", "iterations", e.Iterations,
+			"family", e.Family, "prefixes", e.Prefixes, "sample", e.Sample)
+	}
+	return e
+}
+
 func contains(outer, inner *IPPrefix) bool {
 	return outer.Min.Cmp(inner.Min) <= 0 && outer.Max.Cmp(inner.Max) >= 0
 }
 
 func areAdjacent(a, b *IPPrefix) bool {
-	one := uint256.NewInt(1)
+	var next uint256.Int
 
-	aMaxPlusOne := new(uint256.Int).Add(a.Max, one)
-	if aMaxPlusOne.Cmp(b.Min) == 0 {
+	if next.AddUint64(a.Max, 1).Eq(b.Min) {
 		return true
 	}
-
-	bMaxPlusOne := new(uint256.Int).Add(b.Max, one)
-	return bMaxPlusOne.Cmp(a.Min) == 0
+	return next.AddUint64(b.Max, 1).Eq(a.Min)
 }
 
 func overlaps(a, b *IPPrefix) bool {
 	return !(a.Max.Cmp(b.Min) < 0 || b.Max.Cmp(a.Min) < 0)
 }
 
-func mergeAdjacent(a, b *IPPrefix) (*IPPrefix, error) {
-	var minVal, maxVal *uint256.Int
-
-	if a.Min.Cmp(b.Min) <= 0 {
-		minVal = new(uint256.Int).Set(a.Min)
-	} else {
-		minVal = new(uint256.Int).Set(b.Min)
+// mergeSiblings merges two adjacent prefixes when they are the two halves
+// of their common parent: equal lengths, and the lower one aligned to the
+// doubled size. Any other adjacent pair cannot form a single CIDR block.
+func mergeSiblings(a, b *IPPrefix) (*IPPrefix, bool) {
+	lo, hi := a, b
+	if b.Min.Lt(a.Min) {
+		lo, hi = b, a
 	}
 
-	if a.Max.Cmp(b.Max) >= 0 {
-		maxVal = new(uint256.Int).Set(a.Max)
-	} else {
-		maxVal = new(uint256.Int).Set(b.Max)
+	bits := lo.Prefix.Bits()
+	if bits == 0 || bits != hi.Prefix.Bits() {
+		return nil, false
 	}
 
-	if canMergeToValidPrefix(minVal, maxVal, a.Prefix.Addr().Is4()) {
-		prefix, err := uint256RangeToPrefix(minVal, maxVal, a.Prefix.Addr().Is4())
-		if err != nil {
-			return nil, err
-		}
-
-		result := acquireIPPrefix()
-		result.Prefix = prefix
-		result.Min.Set(minVal)
-		result.Max.Set(maxVal)
-
-		return result, nil
+	addr := lo.Prefix.Masked().Addr()
+	parent := netip.PrefixFrom(addr, bits-1).Masked()
+	if parent.Addr() != addr {
+		return nil, false
 	}
 
-	return nil, fmt.Errorf("cannot merge ranges into valid CIDR prefix")
+	result := acquireIPPrefix()
+	result.Prefix = parent
+	result.Min.Set(lo.Min)
+	result.Max.Set(hi.Max)
+
+	return result, true
 }
 
 func mergeOverlapping(a, b *IPPrefix) (*IPPrefix, error) {
-	var minVal, maxVal *uint256.Int
+	return mergeRanges(a, b)
+}
 
-	if a.Min.Cmp(b.Min) <= 0 {
-		minVal = new(uint256.Int).Set(a.Min)
-	} else {
-		minVal = new(uint256.Int).Set(b.Min)
+// mergeRanges returns a prefix covering the union of a and b, or an error
+// when the union is not a single CIDR prefix
+func mergeRanges(a, b *IPPrefix) (*IPPrefix, error) {
+	minVal := a.Min
+	if b.Min.Cmp(minVal) < 0 {
+		minVal = b.Min
+	}
+	maxVal := a.Max
+	if b.Max.Cmp(maxVal) > 0 {
+		maxVal = b.Max
 	}
 
-	if a.Max.Cmp(b.Max) >= 0 {
-		maxVal = new(uint256.Int).Set(a.Max)
-	} else {
-		maxVal = new(uint256.Int).Set(b.Max)
+	prefix, ok := tryMergeToPrefix(minVal, maxVal, a.Prefix.Addr().Is4())
+	if !ok {
+		return nil, errCannotMerge
 	}
 
-	if canMergeToValidPrefix(minVal, maxVal, a.Prefix.Addr().Is4()) {
-		prefix, err := uint256RangeToPrefix(minVal, maxVal, a.Prefix.Addr().Is4())
-		if err != nil {
-			return nil, err
-		}
+	result := acquireIPPrefix()
+	result.Prefix = prefix
+	result.Min.Set(minVal)
+	result.Max.Set(maxVal)
 
-		result := acquireIPPrefix()
-		result.Prefix = prefix
-		result.Min.Set(minVal)
-		result.Max.Set(maxVal)
+	return result, nil
+}
 
-		return result, nil
-	}
+var errCannotMerge = errors.New("cannot merge ranges into valid CIDR prefix")
 
-	return nil, fmt.Errorf("cannot merge ranges into valid CIDR prefix")
-}
+// tryMergeToPrefix converts [minVal, maxVal] to a prefix if the range is
+// exactly one CIDR block. The size and alignment are checked first so the
+// common failure case does no conversion work or allocation.
+func tryMergeToPrefix(minVal, maxVal *uint256.Int, isIPv4 bool) (netip.Prefix, bool) {
+	// For a CIDR block max-min is the host mask: one less than a power of
+	// two, with no bits shared with the network address
+	var hostMask, check uint256.Int
+	hostMask.Sub(maxVal, minVal)
+	check.AddUint64(&hostMask, 1)
+	if !check.And(&check, &hostMask).IsZero() {
+		return netip.Prefix{}, false
+	}
+	if !check.And(minVal, &hostMask).IsZero() {
+		return netip.Prefix{}, false
+	}
 
-func canMergeToValidPrefix(minVal, maxVal *uint256.Int, isIPv4 bool) bool {
-	_, err := uint256RangeToPrefix(minVal, maxVal, isIPv4)
-	return err == nil
+	prefix, err := uint256RangeToPrefix(minVal, maxVal, isIPv4)
+	return prefix, err == nil
 }
 
 func (pa *PrefixAggregator) enforceMinPrefixLengths() error {
-	if err := pa.enforceMinPrefixLengthIPv4(); err != nil {
+	if err := pa.enforceMinPrefixLength(&pa.IPv4Prefixes, pa.MinPrefixLenIPv4, "IPv4", pa.exactIncludes(pa.IncludeIPv4, pa.MinPrefixLenIPv4)); err != nil {
 		return err
 	}
-
-	if err := pa.enforceMinPrefixLengthIPv6(); err != nil {
-		return err
-	}
-
-	return nil
+	return pa.enforceMinPrefixLength(&pa.IPv6Prefixes, pa.MinPrefixLenIPv6, "IPv6", pa.exactIncludes(pa.IncludeIPv6, pa.MinPrefixLenIPv6))
 }
 
-func (pa *PrefixAggregator) enforceMinPrefixLengthIPv4() error {
-	if pa.MinPrefixLenIPv4 == 0 || len(pa.IPv4Prefixes) == 0 {
+// exactIncludes returns the includes longer than minLen that IncludeExact
+// keeps unrounded, or nil
+func (pa *PrefixAggregator) exactIncludes(includes []*IPPrefix, minLen int) map[netip.Prefix]struct{} {
+	if pa.includeRounding != IncludeExact || minLen == 0 {
 		return nil
 	}
-
-	newPrefixes := make([]*IPPrefix, 0, len(pa.IPv4Prefixes))
-
-	for _, prefix := range pa.IPv4Prefixes {
-		if prefix.Prefix.Bits() >= pa.MinPrefixLenIPv4 {
-			// Round up to minimum prefix length (make it less specific)
-			// This includes prefixes that are EQUAL to or MORE specific than minimum
-			rounded, err := roundUpToMinLength(prefix, pa.MinPrefixLenIPv4)
-			if err != nil {
-				return fmt.Errorf("failed to round up IPv4 prefix %s: %w", prefix.Prefix.String(), err)
+	var keep map[netip.Prefix]struct{}
+	for _, p := range includes {
+		if p.Prefix.Bits() > minLen {
+			if keep == nil {
+				keep = make(map[netip.Prefix]struct{})
 			}
-			newPrefixes = append(newPrefixes, rounded)
-		} else {
-			// Prefix is already less specific than minimum
-			newPrefixes = append(newPrefixes, prefix)
+			keep[p.Prefix.Masked()] = struct{}{}
 		}
 	}
-
-	pa.IPv4Prefixes = newPrefixes
-	return nil
+	return keep
 }
 
-func (pa *PrefixAggregator) enforceMinPrefixLengthIPv6() error {
-	if pa.MinPrefixLenIPv6 == 0 || len(pa.IPv6Prefixes) == 0 {
+// enforceMinPrefixLength rounds every prefix of a sorted list more
+// specific than minLen up to it, in place, except those in keep. Rounding
+// keeps the list sorted by Min, so it is only re-sorted when a rounded
+// copy ties with a neighbour, and then deduplicated.
+func (pa *PrefixAggregator) enforceMinPrefixLength(prefixes *[]*IPPrefix, minLen int, family string, keep map[netip.Prefix]struct{}) error {
+	if minLen == 0 || len(*prefixes) == 0 {
 		return nil
 	}
 
-	newPrefixes := make([]*IPPrefix, 0, len(pa.IPv6Prefixes))
-
-	for _, prefix := range pa.IPv6Prefixes {
-		if prefix.Prefix.Bits() >= pa.MinPrefixLenIPv6 {
-			// Round up to minimum prefix length (make it less specific)
-			// This includes prefixes that are EQUAL to or MORE specific than minimum
-			rounded, err := roundUpToMinLength(prefix, pa.MinPrefixLenIPv6)
-			if err != nil {
-				return fmt.Errorf("failed to round up IPv6 prefix %s: %w", prefix.Prefix.String(), err)
-			}
-			newPrefixes = append(newPrefixes, rounded)
-		} else {
-			// Prefix is already less specific than minimum
-			newPrefixes = append(newPrefixes, prefix)
+	rounded := false
+	for i, prefix := range *prefixes {
+		if _, ok := keep[prefix.Prefix.Masked()]; ok {
+			continue
+		}
+		r, err := roundUpToMinLength(prefix, minLen)
+		if err != nil {
+			return fmt.Errorf("failed to round up %s prefix %s: %w", family, prefix.Prefix.String(), err)
+		}
+		if r != prefix {
+			releaseIPPrefix(prefix)
+			(*prefixes)[i] = r
+			rounded = true
 		}
 	}
+	if !rounded {
+		return nil
+	}
 
-	pa.IPv6Prefixes = newPrefixes
-	return nil
+	if !slices.IsSortedFunc(*prefixes, compareIPPrefix) {
+		sortPrefixes(*prefixes)
+	}
+	return pa.deduplicate(prefixes)
 }
 
 func roundUpToMinLength(prefix *IPPrefix, minLength int) (*IPPrefix, error) {
@@ -350,7 +671,7 @@ func roundUpToMinLength(prefix *IPPrefix, minLength int) (*IPPrefix, error) {
 	// Create a new prefix with the minimum length
 	newPrefix, err := addr.Prefix(minLength)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create prefix with length %d: %w", minLength, err)
+		return nil, fmt.Errorf("%w: failed to create prefix with length %d: %w", ErrInvalidMinPrefixLen, minLength, err)
 	}
 
 	// Calculate the new min and max for the rounded prefix
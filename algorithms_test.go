@@ -1,7 +1,19 @@
 package netjugo
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
 )
 
 func TestBasicAggregation(t *testing.T) {
@@ -197,3 +209,541 @@ func TestSinglePrefix(t *testing.T) {
 		t.Errorf("Expected 192.168.1.0/24, got %s", result[0])
 	}
 }
+
+func TestConcurrentAggregate(t *testing.T) {
+	pa := NewPrefixAggregator()
+
+	if err := pa.AddPrefixes([]string{"192.168.0.0/24", "192.168.1.0/24", "2001:db8::/33", "2001:db8:8000::/33"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetIncludePrefixes([]string{"10.0.0.0/24"}); err != nil {
+		t.Fatalf("Failed to set include prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"192.168.1.0/25"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- pa.Aggregate()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent aggregation failed: %v", err)
+		}
+	}
+
+	expected := []string{"10.0.0.0/24", "192.168.0.0/24", "192.168.1.128/25", "2001:db8::/32"}
+	result := pa.GetPrefixes()
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, result)
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("Result[%d] = %s, want %s", i, result[i], expected[i])
+		}
+	}
+}
+
+func TestAggregateNoOpWhenUnchanged(t *testing.T) {
+	pa := NewPrefixAggregator()
+
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.1.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if pa.dirty {
+		t.Error("Aggregator should be clean after Aggregate")
+	}
+
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to re-aggregate: %v", err)
+	}
+	if result := pa.GetPrefixes(); len(result) != 1 || result[0] != "10.0.0.0/23" {
+		t.Errorf("Expected [10.0.0.0/23], got %v", result)
+	}
+
+	// Any mutation makes the next Aggregate do real work again
+	if err := pa.AddPrefix("10.0.2.0/23"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if !pa.dirty {
+		t.Error("AddPrefix should mark the aggregator dirty")
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if result := pa.GetPrefixes(); len(result) != 1 || result[0] != "10.0.0.0/22" {
+		t.Errorf("Expected [10.0.0.0/22], got %v", result)
+	}
+}
+
+func TestOutputOrdering(t *testing.T) {
+	newAggregator := func() *PrefixAggregator {
+		pa := NewPrefixAggregator()
+		err := pa.AddPrefixes([]string{
+			"2001:db8::/32",
+			"10.0.0.0/14",
+			"192.168.1.1",
+			"10.0.0.0/8",
+			"::1",
+			"1.1.1.1/32",
+			"2001:db8:8000::1",
+		})
+		if err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.SetExcludePrefixes([]string{"10.0.0.0/10", "2001:db8::/33"}); err != nil {
+			t.Fatalf("Failed to set exclude prefixes: %v", err)
+		}
+		return pa
+	}
+
+	tests := []struct {
+		name     string
+		order    OutputOrder
+		expected []string
+	}{
+		{
+			name:  "address order",
+			order: OrderAddress,
+			expected: []string{
+				"1.1.1.1/32", "10.64.0.0/10", "10.128.0.0/9", "192.168.1.1/32",
+				"::1/128", "2001:db8:8000::/33",
+			},
+		},
+		{
+			name:  "prefix length first",
+			order: OrderPrefixLengthFirst,
+			expected: []string{
+				"1.1.1.1/32", "192.168.1.1/32", "10.64.0.0/10", "10.128.0.0/9",
+				"::1/128", "2001:db8:8000::/33",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pa := newAggregator()
+			if err := pa.SetOutputOrder(tt.order); err != nil {
+				t.Fatalf("Failed to set output order: %v", err)
+			}
+			if err := pa.Aggregate(); err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+
+			result := pa.GetPrefixes()
+			if fmt.Sprint(result) != fmt.Sprint(tt.expected) {
+				t.Errorf("GetPrefixes() = %v\nwant %v", result, tt.expected)
+			}
+
+			var buf bytes.Buffer
+			if err := pa.WriteToWriter(&buf); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+			if got := strings.Fields(buf.String()); fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Errorf("WriteToWriter() = %v\nwant %v", got, tt.expected)
+			}
+
+			v4 := append(pa.GetIPv4Prefixes(), pa.GetIPv6Prefixes()...)
+			if fmt.Sprint(v4) != fmt.Sprint(tt.expected) {
+				t.Errorf("GetIPv4Prefixes()+GetIPv6Prefixes() = %v\nwant %v", v4, tt.expected)
+			}
+		})
+	}
+}
+
+// Aggregate merges nested prefixes, so they only reach the output when it
+// is read without aggregating
+func TestOutputOrderingNested(t *testing.T) {
+	input := []string{"10.0.0.0/8", "10.0.0.0/24", "10.0.0.1/32", "10.1.0.0/16", "2001:db8::/32", "2001:db8::1/128"}
+
+	tests := []struct {
+		order    OutputOrder
+		expected string
+	}{
+		{OrderAddress, "10.0.0.0/8 10.0.0.0/24 10.0.0.1/32 10.1.0.0/16 2001:db8::/32 2001:db8::1/128"},
+		{OrderPrefixLengthFirst, "10.0.0.1/32 10.0.0.0/24 10.1.0.0/16 10.0.0.0/8 2001:db8::1/128 2001:db8::/32"},
+	}
+
+	for _, tt := range tests {
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefixes(input); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.SetOutputOrder(tt.order); err != nil {
+			t.Fatalf("Failed to set output order: %v", err)
+		}
+
+		if got := strings.Join(pa.GetPrefixes(), " "); got != tt.expected {
+			t.Errorf("Order %d: GetPrefixes() = %s\nwant %s", tt.order, got, tt.expected)
+		}
+
+		var buf bytes.Buffer
+		if err := pa.WriteToWriter(&buf); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		if got := strings.Join(strings.Fields(buf.String()), " "); got != tt.expected {
+			t.Errorf("Order %d: WriteToWriter() = %s\nwant %s", tt.order, got, tt.expected)
+		}
+
+		// Chunk boundaries do not reorder anything
+		paths, err := pa.WriteToFiles(filepath.Join(t.TempDir(), "acl.txt"), 4)
+		if err != nil {
+			t.Fatalf("WriteToFiles failed: %v", err)
+		}
+		var combined []string
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", path, err)
+			}
+			combined = append(combined, strings.Fields(string(data))...)
+		}
+		if got := strings.Join(combined, " "); got != tt.expected {
+			t.Errorf("Order %d: WriteToFiles() = %s\nwant %s", tt.order, got, tt.expected)
+		}
+	}
+}
+
+func TestCanonicalSortTieBreak(t *testing.T) {
+	var prefixes []*IPPrefix
+	for _, s := range []string{"10.0.0.0/24", "10.0.0.0/8", "9.0.0.0/8", "10.0.0.0/16"} {
+		p, err := parseIPPrefix(s)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", s, err)
+		}
+		prefixes = append(prefixes, p)
+	}
+
+	sortPrefixes(prefixes)
+
+	expected := []string{"9.0.0.0/8", "10.0.0.0/8", "10.0.0.0/16", "10.0.0.0/24"}
+	for i, p := range prefixes {
+		if p.Prefix.String() != expected[i] {
+			t.Errorf("Position %d: got %s, want %s", i, p.Prefix, expected[i])
+		}
+	}
+}
+
+func TestSetOutputOrderInvalid(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.SetOutputOrder(OutputOrder(42)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestIncrementalAggregateMatchesFresh(t *testing.T) {
+	incremental := NewPrefixAggregator()
+
+	var all []string
+	for round := 0; round < 20; round++ {
+		// Interleave in-order, out-of-order, duplicate and mergeable input
+		batch := []string{
+			fmt.Sprintf("10.%d.0.0/24", 200-round*7),
+			fmt.Sprintf("10.%d.1.0/24", 200-round*7),
+			fmt.Sprintf("10.%d.0.0/16", round*3),
+			fmt.Sprintf("2001:db8:%x::/48", 0xffff-round*31),
+			"10.0.0.0/24",
+		}
+		all = append(all, batch...)
+		if err := incremental.AddPrefixes(batch); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := incremental.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+
+		fresh := NewPrefixAggregator()
+		if err := fresh.AddPrefixes(all); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := fresh.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+
+		got, want := incremental.GetPrefixes(), fresh.GetPrefixes()
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("Round %d: incremental result %v, want %v", round, got, want)
+		}
+	}
+}
+
+func TestMergePending(t *testing.T) {
+	mk := func(specs ...string) []*IPPrefix {
+		list := make([]*IPPrefix, len(specs))
+		for i, s := range specs {
+			p, err := parseIPPrefix(s)
+			if err != nil {
+				t.Fatalf("Failed to parse %s: %v", s, err)
+			}
+			list[i] = p
+		}
+		return list
+	}
+
+	list := mk("10.0.0.0/24", "10.0.2.0/24", "10.0.4.0/24", "10.0.3.0/24", "9.0.0.0/8", "10.0.2.0/23")
+	mergePending(list, 3)
+
+	var got []string
+	for _, p := range list {
+		got = append(got, p.Prefix.String())
+	}
+	want := "9.0.0.0/8,10.0.0.0/24,10.0.2.0/23,10.0.2.0/24,10.0.3.0/24,10.0.4.0/24"
+	if strings.Join(got, ",") != want {
+		t.Errorf("mergePending gave %v, want %s", got, want)
+	}
+}
+
+func TestTryMergeToPrefix(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string // empty when the union is not a CIDR block
+	}{
+		{"10.0.0.0/25", "10.0.0.128/25", "10.0.0.0/24"},
+		{"10.0.1.0/24", "10.0.2.0/24", ""}, // not aligned
+		{"10.0.0.0/24", "10.0.1.0/25", ""}, // sizes differ
+		{"0.0.0.0/1", "128.0.0.0/1", "0.0.0.0/0"},
+		{"2001:db8::/33", "2001:db8:8000::/33", "2001:db8::/32"},
+		{"::/1", "8000::/1", "::/0"},
+		{"2001:db8:1::/48", "2001:db8:2::/48", ""},
+	}
+
+	for _, tt := range tests {
+		a, err := parseIPPrefix(tt.a)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", tt.a, err)
+		}
+		b, err := parseIPPrefix(tt.b)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", tt.b, err)
+		}
+
+		prefix, ok := tryMergeToPrefix(a.Min, b.Max, a.Prefix.Addr().Is4())
+		if ok != (tt.want != "") {
+			t.Errorf("tryMergeToPrefix(%s, %s) ok = %v, want %v", tt.a, tt.b, ok, tt.want != "")
+			continue
+		}
+		if ok && prefix.String() != tt.want {
+			t.Errorf("tryMergeToPrefix(%s, %s) = %s, want %s", tt.a, tt.b, prefix, tt.want)
+		}
+	}
+}
+
+func TestMergeSiblings(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string // empty when the pair must not merge
+	}{
+		{"10.0.0.0/25", "10.0.0.128/25", "10.0.0.0/24"},
+		{"10.0.0.128/25", "10.0.0.0/25", "10.0.0.0/24"},
+		{"10.0.1.0/24", "10.0.2.0/24", ""},
+		{"10.0.0.0/24", "10.0.1.0/25", ""},
+		{"0.0.0.0/1", "128.0.0.0/1", "0.0.0.0/0"},
+		{"2001:db8::/33", "2001:db8:8000::/33", "2001:db8::/32"},
+		{"2001:db8:1::/48", "2001:db8:2::/48", ""},
+	}
+
+	for _, tt := range tests {
+		a, err := parseIPPrefix(tt.a)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", tt.a, err)
+		}
+		b, err := parseIPPrefix(tt.b)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", tt.b, err)
+		}
+		if !areAdjacent(a, b) {
+			t.Fatalf("%s and %s should be adjacent", tt.a, tt.b)
+		}
+
+		merged, ok := mergeSiblings(a, b)
+		if ok != (tt.want != "") {
+			t.Errorf("mergeSiblings(%s, %s) ok = %v, want %v", tt.a, tt.b, ok, tt.want != "")
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if merged.Prefix.String() != tt.want || !merged.Min.Eq(minOf(a, b)) || !merged.Max.Eq(maxOf(a, b)) {
+			t.Errorf("mergeSiblings(%s, %s) = %s [%s, %s], want %s", tt.a, tt.b, merged.Prefix, merged.Min.Hex(), merged.Max.Hex(), tt.want)
+		}
+	}
+}
+
+func minOf(a, b *IPPrefix) *uint256.Int {
+	if a.Min.Lt(b.Min) {
+		return a.Min
+	}
+	return b.Min
+}
+
+func maxOf(a, b *IPPrefix) *uint256.Int {
+	if a.Max.Gt(b.Max) {
+		return a.Max
+	}
+	return b.Max
+}
+
+func TestDefaultRouteDetection(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []string
+		includes []string
+		warn     bool
+	}{
+		{"emergent IPv4", []string{"0.0.0.0/1", "128.0.0.0/1"}, nil, true},
+		{"emergent IPv6", []string{"::/1", "8000::/1"}, nil, true},
+		{"explicit input", []string{"0.0.0.0/0", "10.0.0.0/8"}, nil, false},
+		{"explicit include", []string{"0.0.0.0/1"}, []string{"0.0.0.0/0"}, false},
+		{"no default route", []string{"0.0.0.0/1", "192.0.0.0/2"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pa := NewPrefixAggregator()
+			if err := pa.AddPrefixes(tt.prefixes); err != nil {
+				t.Fatalf("Failed to add prefixes: %v", err)
+			}
+			if err := pa.SetIncludePrefixes(tt.includes); err != nil {
+				t.Fatalf("Failed to set includes: %v", err)
+			}
+			if err := pa.Aggregate(); err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+
+			warned := false
+			for _, w := range pa.GetWarningDetails() {
+				warned = warned || w.Code == WarnDefaultRoute
+			}
+			if warned != tt.warn {
+				t.Errorf("Default route warning = %v, want %v (warnings: %v)", warned, tt.warn, pa.GetWarnings())
+			}
+
+			rejecting := NewPrefixAggregator()
+			rejecting.SetRejectDefaultRoute(true)
+			if err := rejecting.AddPrefixes(tt.prefixes); err != nil {
+				t.Fatalf("Failed to add prefixes: %v", err)
+			}
+			if err := rejecting.SetIncludePrefixes(tt.includes); err != nil {
+				t.Fatalf("Failed to set includes: %v", err)
+			}
+			err := rejecting.Aggregate()
+			if errors.Is(err, ErrDefaultRoute) != tt.warn {
+				t.Errorf("Aggregate with SetRejectDefaultRoute returned %v", err)
+			}
+		})
+	}
+}
+
+func TestNonConvergence(t *testing.T) {
+	saved := maxMergeIterations
+	maxMergeIterations = 1
+	defer func() { maxMergeIterations = saved }()
+
+	h := &recordHandler{}
+	pa := NewPrefixAggregator()
+	pa.SetLogger(slog.New(h))
+	// One pass merges the /26s into /25s, which still leaves a merge to do
+	if err := pa.AddPrefixes([]string{
+		"9.0.0.0/24", "10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26",
+	}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	err := pa.Aggregate()
+	if !errors.Is(err, ErrNonConvergence) {
+		t.Fatalf("Expected ErrNonConvergence, got %v", err)
+	}
+	var nc *NonConvergenceError
+	if !errors.As(err, &nc) {
+		t.Fatalf("Expected a *NonConvergenceError, got %T", err)
+	}
+	if nc.Iterations != 1 || nc.Family != "ipv4" || nc.Prefixes != 3 {
+		t.Errorf("Unexpected diagnostics: %+v", nc)
+	}
+	if got := fmt.Sprint(nc.Sample); got != "[10.0.0.0/25 10.0.0.128/25]" {
+		t.Errorf("Sample should start where the last pass changed the list, got %s", got)
+	}
+
+	attrs, ok := h.find(slog.LevelWarn, "aggregation did not converge")
+	if !ok {
+		t.Fatal("Expected a non-convergence warning in the log")
+	}
+	if attrs["family"].String() != "ipv4" || attrs["iterations"].Int64() != 1 {
+		t.Errorf("Unexpected log attributes: %v", attrs)
+	}
+}
+
+func TestAggregateWithin(t *testing.T) {
+	input := generateTestPrefixes(5000)
+	// 256 /24 siblings take eight merge passes to become one /16
+	for i := 0; i < 256; i++ {
+		input = append(input, fmt.Sprintf("10.99.%d.0/24", i))
+	}
+	excludes := []string{"10.99.7.0/25", input[0], input[1]}
+	build := func() *PrefixAggregator {
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefixes(input); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.SetExcludePrefixes(excludes); err != nil {
+			t.Fatalf("Failed to set exclude prefixes: %v", err)
+		}
+		return pa
+	}
+
+	full := build()
+	if err := full.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	partial := build()
+	complete, err := partial.AggregateWithin(time.Nanosecond)
+	if err != nil {
+		t.Fatalf("AggregateWithin failed: %v", err)
+	}
+	if complete {
+		t.Fatal("Expected a 1ns budget to stop merging early")
+	}
+	if len(partial.GetPrefixes()) <= len(full.GetPrefixes()) {
+		t.Errorf("Expected the partial result to be less reduced: %d prefixes, full %d", len(partial.GetPrefixes()), len(full.GetPrefixes()))
+	}
+	for _, lists := range [][2][]*IPPrefix{
+		{partial.IPv4Prefixes, full.IPv4Prefixes},
+		{partial.IPv6Prefixes, full.IPv6Prefixes},
+	} {
+		if !orderedDisjoint(lists[0]) {
+			t.Error("Expected the partial result to be sorted and disjoint")
+		}
+		if !slices.Equal(coverRanges(lists[0]), coverRanges(lists[1])) {
+			t.Error("Expected the partial result to cover exactly the addresses of the full result")
+		}
+	}
+
+	// The next Aggregate finishes merging
+	if err := partial.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got, want := partial.GetPrefixes(), full.GetPrefixes(); !slices.Equal(got, want) {
+		t.Errorf("Expected Aggregate to complete the result: %d prefixes, want %d", len(got), len(want))
+	}
+
+	// A generous budget converges
+	if complete, err := build().AggregateWithin(time.Minute); err != nil || !complete {
+		t.Errorf("Expected a complete result within a minute, got %v, %v", complete, err)
+	}
+	if _, err := build().AggregateWithin(0); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a zero budget, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package netjugo
+
+import (
+	"maps"
+	"net/netip"
+	"slices"
+)
+
+// SetTrackExclusions makes Aggregate record which result prefixes exist
+// only because an exclusion split a larger prefix, for
+// GetExclusionArtifacts. Enable it before the first Aggregate; turning it
+// off drops what was recorded.
+func (pa *PrefixAggregator) SetTrackExclusions(track bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	pa.trackExclusions = track
+	if !track {
+		pa.exclusionCauses = nil
+	}
+}
+
+// GetExclusionArtifacts maps each exclusion that split a prefix to the
+// result prefixes the split produced, in address order. A prefix split by
+// several exclusions is listed under each of them; one that was later
+// merged back into a larger prefix is not listed at all.
+func (pa *PrefixAggregator) GetExclusionArtifacts() map[string][]string {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	result := make(map[string][]string)
+	for _, list := range [][]*IPPrefix{pa.IPv4Prefixes, pa.IPv6Prefixes} {
+		for _, p := range list {
+			for _, cause := range pa.exclusionCauses[p.Prefix] {
+				key := pa.formatPrefix(cause)
+				result[key] = append(result[key], pa.formatPrefix(p.Prefix))
+			}
+		}
+	}
+	return result
+}
+
+// ExclusionEffect is what one exclusion did, as reported by
+// GetExclusionEffects
+type ExclusionEffect struct {
+	Prefix netip.Prefix
+	// Set names the exclusion set, empty for SetExcludePrefixes
+	Set string
+	// Matched reports that the exclusion overlapped a prefix when it was
+	// applied. One that did not lies outside the input, or inside space
+	// an earlier exclusion already removed, and can be deleted.
+	Matched bool
+	// PrefixesRemoved counts the prefixes the exclusion took out of the
+	// lists, and PrefixesCreated the pieces of them it left behind
+	PrefixesRemoved int
+	PrefixesCreated int
+}
+
+// GetExclusionEffects reports, for each active exclusion in the order the
+// exclusions are applied, IPv4 first, whether the last Aggregate found
+// anything to cut and how many prefixes that removed and created. An exclusion
+// skipped as too specific never matches. Counts add up over incremental
+// runs until the input is rebuilt, so Add, Aggregate, Add, Aggregate
+// reports an exclusion as matched if either run applied it.
+func (pa *PrefixAggregator) GetExclusionEffects() []ExclusionEffect {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	var effects []ExclusionEffect
+	seen := make(map[exclusionKey]struct{})
+	for _, isIPv4 := range []bool{true, false} {
+		for _, source := range pa.activeExclusions(isIPv4) {
+			for _, p := range source.prefixes {
+				key := exclusionKey{source.set, p.Prefix}
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+
+				counts, matched := pa.appliedExclusions[key]
+				effects = append(effects, ExclusionEffect{
+					Prefix:          p.Prefix,
+					Set:             source.set,
+					Matched:         matched,
+					PrefixesRemoved: counts.removed,
+					PrefixesCreated: counts.created,
+				})
+			}
+		}
+	}
+	return effects
+}
+
+// recordSplit attributes the pieces left of container by exclude to that
+// exclusion and to whatever split container before
+func (pa *PrefixAggregator) recordSplit(container, exclude *IPPrefix, pieces []*IPPrefix) {
+	if !pa.trackExclusions {
+		return
+	}
+	if pa.exclusionCauses == nil {
+		pa.exclusionCauses = make(map[netip.Prefix][]netip.Prefix)
+	}
+
+	causes := pa.exclusionCauses[container.Prefix]
+	if !slices.Contains(causes, exclude.Prefix) {
+		causes = append(slices.Clip(causes), exclude.Prefix)
+	}
+	for _, p := range pieces {
+		pa.exclusionCauses[p.Prefix] = causes
+	}
+}
+
+// pruneSplits forgets recorded pieces that are no longer in the result
+func (pa *PrefixAggregator) pruneSplits() {
+	if len(pa.exclusionCauses) == 0 {
+		return
+	}
+
+	kept := make(map[netip.Prefix][]netip.Prefix)
+	for _, list := range [][]*IPPrefix{pa.IPv4Prefixes, pa.IPv6Prefixes} {
+		for _, p := range list {
+			if causes, ok := pa.exclusionCauses[p.Prefix]; ok {
+				kept[p.Prefix] = causes
+			}
+		}
+	}
+	pa.exclusionCauses = kept
+}
+
+// cloneExclusionCauses copies the recorded splits for Clone
+func cloneExclusionCauses(causes map[netip.Prefix][]netip.Prefix) map[netip.Prefix][]netip.Prefix {
+	if causes == nil {
+		return nil
+	}
+	return maps.Clone(causes)
+}
@@ -0,0 +1,135 @@
+package netjugo
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestExclusionArtifacts(t *testing.T) {
+	pa := NewPrefixAggregator()
+	pa.SetTrackExclusions(true)
+	if err := pa.AddPrefixes([]string{"10.0.0.0/8", "192.168.0.0/16"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.0.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	artifacts := pa.GetExclusionArtifacts()
+	if len(artifacts) != 1 {
+		t.Fatalf("Expected artifacts for one exclusion, got %v", artifacts)
+	}
+	produced := artifacts["10.0.0.0/24"]
+	// Everything except the untouched 192.168.0.0/16 is a remnant of the split
+	want := slices.DeleteFunc(pa.GetPrefixes(), func(p string) bool { return p == "192.168.0.0/16" })
+	if len(produced) != 16 || !slices.Equal(produced, want) {
+		t.Errorf("Expected the 16 complement prefixes %v, got %v", want, produced)
+	}
+}
+
+func TestExclusionArtifactsNested(t *testing.T) {
+	pa := NewPrefixAggregator()
+	pa.SetTrackExclusions(true)
+	if err := pa.AddPrefix("10.0.0.0/8"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	// The second exclusion splits a remnant of the first
+	if err := pa.SetExcludePrefixes([]string{"10.0.0.0/9", "10.128.0.0/10"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	artifacts := pa.GetExclusionArtifacts()
+	for _, exclusion := range []string{"10.0.0.0/9", "10.128.0.0/10"} {
+		if got := artifacts[exclusion]; !slices.Equal(got, []string{"10.192.0.0/10"}) {
+			t.Errorf("Artifacts of %s = %v, want [10.192.0.0/10]", exclusion, got)
+		}
+	}
+
+	pa.SetTrackExclusions(false)
+	if got := pa.GetExclusionArtifacts(); len(got) != 0 {
+		t.Errorf("Expected no artifacts after turning tracking off, got %v", got)
+	}
+}
+
+func TestExclusionArtifactsUntracked(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefix("10.0.0.0/8"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.0.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := pa.GetExclusionArtifacts(); len(got) != 0 {
+		t.Errorf("Expected no artifacts without tracking, got %v", got)
+	}
+}
+
+func TestExclusionEffects(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/8", "192.0.2.0/25", "192.0.2.128/25", "198.51.100.0/24", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{
+		"10.0.0.0/10",     // matched, splits 10.0.0.0/8
+		"192.0.2.0/23",    // matched, fully contains 192.0.2.0/24
+		"203.0.113.0/24",  // outside the input
+		"10.1.0.0/16",     // inside space 10.0.0.0/10 already removed
+		"2001:db8:1::/48", // matched, IPv6
+		"2001:db9::/32",   // outside the input
+	}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.AddExclusionSet("stale", []string{"198.51.100.0/25", "100.64.0.0/10"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	want := []ExclusionEffect{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/10"), Matched: true, PrefixesRemoved: 1, PrefixesCreated: 2},
+		{Prefix: netip.MustParsePrefix("192.0.2.0/23"), Matched: true, PrefixesRemoved: 1},
+		{Prefix: netip.MustParsePrefix("203.0.113.0/24")},
+		{Prefix: netip.MustParsePrefix("10.1.0.0/16")},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/25"), Set: "stale", Matched: true, PrefixesRemoved: 1, PrefixesCreated: 1},
+		{Prefix: netip.MustParsePrefix("100.64.0.0/10"), Set: "stale"},
+		{Prefix: netip.MustParsePrefix("2001:db8:1::/48"), Matched: true, PrefixesRemoved: 1, PrefixesCreated: 16},
+		{Prefix: netip.MustParsePrefix("2001:db9::/32")},
+	}
+	if got := pa.GetExclusionEffects(); !slices.Equal(got, want) {
+		t.Errorf("Unexpected effects:\n got %+v\nwant %+v", got, want)
+	}
+
+	// After an incremental run, an exclusion that only finds its own hole
+	// is still matched, and the new input adds to the counts
+	if err := pa.AddPrefixes([]string{"203.0.113.0/26"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	effects := pa.GetExclusionEffects()
+	if e := effects[0]; !e.Matched || e.PrefixesRemoved != 1 || e.PrefixesCreated != 2 {
+		t.Errorf("Expected 10.0.0.0/10 to keep its counts, got %+v", e)
+	}
+	if e := effects[2]; !e.Matched || e.PrefixesRemoved != 1 || e.PrefixesCreated != 0 {
+		t.Errorf("Expected 203.0.113.0/24 to match the new input, got %+v", e)
+	}
+
+	if err := pa.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if effects := pa.GetExclusionEffects(); len(effects) != 0 {
+		t.Errorf("Expected no effects after Reset, got %+v", effects)
+	}
+}
@@ -0,0 +1,121 @@
+package netjugo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// ChangeError is returned by CompareToBaseline for a family whose covered
+// address space grew or shrank more than allowed. It matches
+// ErrChangeTooLarge.
+type ChangeError struct {
+	Family   string   // "ipv4" or "ipv6"
+	Baseline *big.Int // addresses the baseline covers
+	Current  *big.Int // addresses the aggregator covers
+	// ChangePct is the growth relative to the baseline, negative for a
+	// shrink, and +Inf for a family the baseline does not cover at all
+	ChangePct float64
+	LimitPct  float64 // the growth or shrink limit that was exceeded
+}
+
+func (e *ChangeError) Error() string {
+	direction := "grew"
+	if e.ChangePct < 0 {
+		direction = "shrank"
+	}
+	if math.IsInf(e.ChangePct, 1) {
+		return fmt.Sprintf("%v: %s coverage grew from nothing to %s addresses", ErrChangeTooLarge, e.Family, e.Current)
+	}
+	return fmt.Sprintf("%v: %s coverage %s %.1f%% from %s to %s addresses, limit is %g%%",
+		ErrChangeTooLarge, e.Family, direction, math.Abs(e.ChangePct), e.Baseline, e.Current, e.LimitPct)
+}
+
+func (e *ChangeError) Unwrap() error {
+	return ErrChangeTooLarge
+}
+
+// CompareToBaseline compares the address space the current prefixes cover
+// with that of baseline, usually the previous published result, family
+// by family. It returns a *ChangeError for each family that grew by more
+// than maxGrowthPct or shrank by more than maxShrinkPct percent, joined
+// if both did, so a broken upstream feed can be caught before its result
+// is published. Overlapping prefixes are counted once. A family neither
+// covers is skipped; one only the current prefixes cover always fails.
+func (pa *PrefixAggregator) CompareToBaseline(baseline *PrefixAggregator, maxGrowthPct, maxShrinkPct float64) error {
+	if baseline == nil {
+		return fmt.Errorf("%w: no baseline", ErrInvalidOption)
+	}
+	if maxGrowthPct < 0 || maxShrinkPct < 0 || math.IsNaN(maxGrowthPct) || math.IsNaN(maxShrinkPct) {
+		return fmt.Errorf("%w: change limits must not be negative, got %g%% growth and %g%% shrink", ErrInvalidOption, maxGrowthPct, maxShrinkPct)
+	}
+
+	// Take one lock at a time, so comparing an aggregator with itself
+	// cannot deadlock
+	before, err := baseline.coverage()
+	if err != nil {
+		return err
+	}
+	after, err := pa.coverage()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, family := range []string{"ipv4", "ipv6"} {
+		if err := checkChange(family, before[i], after[i], maxGrowthPct, maxShrinkPct); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// coverage returns the number of addresses the IPv4 and IPv6 prefixes
+// cover
+func (pa *PrefixAggregator) coverage() ([2]*big.Int, error) {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if pa.closed {
+		return [2]*big.Int{}, ErrClosed
+	}
+
+	var counts [2]*big.Int
+	for i, prefixes := range [][]*IPPrefix{pa.IPv4Prefixes, pa.IPv6Prefixes} {
+		counts[i] = new(big.Int)
+		for _, r := range coverRanges(prefixes) {
+			size := new(big.Int).Sub(r.max.ToBig(), r.min.ToBig())
+			counts[i].Add(counts[i], size.Add(size, big.NewInt(1)))
+		}
+	}
+	return counts, nil
+}
+
+// checkChange returns a *ChangeError if current differs from baseline by
+// more than the limits allow
+func checkChange(family string, baseline, current *big.Int, maxGrowthPct, maxShrinkPct float64) error {
+	e := &ChangeError{Family: family, Baseline: baseline, Current: current, LimitPct: maxGrowthPct}
+	switch {
+	case baseline.Sign() == 0 && current.Sign() == 0:
+		return nil
+	case baseline.Sign() == 0:
+		e.ChangePct = math.Inf(1)
+		return e
+	}
+
+	diff := new(big.Float).SetInt(new(big.Int).Sub(current, baseline))
+	pct, _ := diff.Quo(diff, new(big.Float).SetInt(baseline)).Float64()
+	e.ChangePct = pct * 100
+	if e.ChangePct < 0 {
+		e.LimitPct = maxShrinkPct
+		if -e.ChangePct > maxShrinkPct {
+			return e
+		}
+		return nil
+	}
+	if e.ChangePct > maxGrowthPct {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package netjugo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCompareToBaseline(t *testing.T) {
+	aggregated := func(prefixes ...string) *PrefixAggregator {
+		t.Helper()
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefixes(prefixes); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		return pa
+	}
+	baseline := aggregated("10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24", "2001:db8::/32")
+
+	tests := []struct {
+		name    string
+		current *PrefixAggregator
+		family  string  // of the expected ChangeError, "" for none
+		change  float64 // its ChangePct
+	}{
+		{"unchanged", aggregated("10.0.0.0/22", "2001:db8::/32"), "", 0},
+		{"within threshold", aggregated("10.0.0.0/22", "10.0.4.0/24", "2001:db8::/32"), "", 0},
+		{"overlaps counted once", aggregated("10.0.0.0/22", "10.0.1.0/24", "2001:db8::/32"), "", 0},
+		{"growth", aggregated("10.0.0.0/21", "2001:db8::/32"), "ipv4", 100},
+		{"shrink", aggregated("10.0.0.0/22", "2001:db8::/34"), "ipv6", -75},
+	}
+	for _, tt := range tests {
+		err := tt.current.CompareToBaseline(baseline, 50, 50)
+		if tt.family == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		var change *ChangeError
+		if !errors.Is(err, ErrChangeTooLarge) || !errors.As(err, &change) {
+			t.Errorf("%s: expected a ChangeError, got %v", tt.name, err)
+			continue
+		}
+		if change.Family != tt.family || change.ChangePct != tt.change || change.LimitPct != 50 {
+			t.Errorf("%s: got %s changed %g%% (limit %g%%)", tt.name, change.Family, change.ChangePct, change.LimitPct)
+		}
+	}
+
+	// Both families over the limit are both reported, and a family the
+	// baseline lacks always fails
+	err := aggregated("10.0.0.0/20", "2001:db8::/40", "192.0.2.0/24").CompareToBaseline(aggregated("10.0.0.0/22", "2001:db8::/32"), 10, 10)
+	var change *ChangeError
+	if !errors.As(err, &change) || change.Family != "ipv4" || joinedCount(err) != 2 {
+		t.Errorf("Expected an IPv4 and an IPv6 ChangeError, got %v", err)
+	}
+	err = aggregated("10.0.0.0/22", "2001:db8::/32").CompareToBaseline(aggregated("10.0.0.0/22"), 10, 10)
+	if !errors.As(err, &change) || change.Family != "ipv6" || !math.IsInf(change.ChangePct, 1) {
+		t.Errorf("Expected an IPv6 ChangeError growing from nothing, got %v", err)
+	}
+
+	if err := baseline.CompareToBaseline(baseline, 0, 0); err != nil {
+		t.Errorf("Comparing with itself failed: %v", err)
+	}
+	if err := baseline.CompareToBaseline(baseline, -1, 0); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a negative limit, got %v", err)
+	}
+	if err := baseline.CompareToBaseline(nil, 10, 10); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption without a baseline, got %v", err)
+	}
+}
+
+// joinedCount is how many errors err joins
+func joinedCount(err error) int {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return len(joined.Unwrap())
+	}
+	return 1
+}
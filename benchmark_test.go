@@ -0,0 +1,604 @@
+package netjugo
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+)
+
+func BenchmarkAggregationScaling(b *testing.B) {
+	sizes := []int{1000, 10000, 100000}
+
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("prefixes_%d", size), func(b *testing.B) {
+			// Generate test prefixes
+			prefixes := generateTestPrefixes(size)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pa := NewPrefixAggregator()
+				err := pa.AddPrefixes(prefixes)
+				if err != nil {
+					b.Fatalf("Failed to add prefixes: %v", err)
+				}
+
+				err = pa.Aggregate()
+				if err != nil {
+					b.Fatalf("Aggregation failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPrefixParsing(b *testing.B) {
+	testCases := []struct {
+		name   string
+		prefix string
+	}{
+		{"IPv4_24", "192.168.1.0/24"},
+		{"IPv4_32", "192.168.1.1/32"},
+		{"IPv6_64", "2001:db8::/64"},
+		{"IPv6_128", "2001:db8::1/128"},
+	}
+
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := parseIPPrefix(tc.prefix)
+				if err != nil {
+					b.Fatalf("Failed to parse prefix %s: %v", tc.prefix, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAddPrefix(b *testing.B) {
+	testCases := []struct {
+		name     string
+		prefixes []string
+	}{
+		{
+			"IPv4_Small",
+			[]string{"192.168.1.0/24", "192.168.2.0/24", "192.168.3.0/24"},
+		},
+		{
+			"IPv6_Small",
+			[]string{"2001:db8::/64", "2001:db8:1::/64", "2001:db8:2::/64"},
+		},
+		{
+			"Mixed_Small",
+			[]string{"192.168.1.0/24", "2001:db8::/64", "10.0.0.0/24"},
+		},
+	}
+
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pa := NewPrefixAggregator()
+				for _, prefix := range tc.prefixes {
+					err := pa.AddPrefix(prefix)
+					if err != nil {
+						b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAggregation(b *testing.B) {
+	sizes := []int{100, 1000, 10000, 50000}
+
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("Prefixes_%d", size), func(b *testing.B) {
+			// Pre-generate prefixes to avoid including generation time in benchmark
+			prefixes := generateTestPrefixes(size)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pa := NewPrefixAggregator()
+
+				// Add prefixes
+				for _, prefix := range prefixes {
+					err := pa.AddPrefix(prefix)
+					if err != nil {
+						b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+					}
+				}
+
+				// Perform aggregation
+				err := pa.Aggregate()
+				if err != nil {
+					b.Fatalf("Aggregation failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAddFewThenAggregate simulates adding a handful of prefixes to a
+// large aggregated set and re-aggregating. FullSort forgets the sorted
+// prefix before each run to show the cost without incremental merging.
+func BenchmarkAddFewThenAggregate(b *testing.B) {
+	for _, mode := range []string{"Incremental", "FullSort"} {
+		b.Run(mode, func(b *testing.B) {
+			pa := NewPrefixAggregator()
+			for i := 0; i < 100000; i++ {
+				// Every other /24, so nothing merges
+				prefix := fmt.Sprintf("%d.%d.%d.0/24", 10+i/32768, i/128%256, (i%128)*2)
+				if err := pa.AddPrefix(prefix); err != nil {
+					b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+				}
+			}
+			if err := pa.Aggregate(); err != nil {
+				b.Fatalf("Aggregation failed: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < 3; j++ {
+					n := i*3 + j
+					_ = pa.AddPrefix(fmt.Sprintf("172.%d.%d.%d/32", 16+n/65536%16, n/256%256, n%256))
+				}
+				if mode == "FullSort" {
+					pa.sortedIPv4 = 0
+				}
+				if err := pa.Aggregate(); err != nil {
+					b.Fatalf("Aggregation failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryUsage(b *testing.B) {
+	sizes := []int{1000, 10000, 100000}
+
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("Prefixes_%d", size), func(b *testing.B) {
+			prefixes := generateTestPrefixes(size)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Force GC to get clean measurement
+				runtime.GC()
+
+				var m1 runtime.MemStats
+				runtime.ReadMemStats(&m1)
+
+				pa := NewPrefixAggregator()
+				for _, prefix := range prefixes {
+					err := pa.AddPrefix(prefix)
+					if err != nil {
+						b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+					}
+				}
+
+				runtime.GC()
+				var m2 runtime.MemStats
+				runtime.ReadMemStats(&m2)
+
+				// Record memory per prefix
+				memPerPrefix := int64(m2.Alloc-m1.Alloc) / int64(size)
+				b.ReportMetric(float64(memPerPrefix), "bytes/prefix")
+			}
+		})
+	}
+}
+
+func BenchmarkMinPrefixLengthEnforcement(b *testing.B) {
+	// Test different prefix length enforcement scenarios
+	testCases := []struct {
+		name             string
+		basePrefixes     []string
+		minIPv4, minIPv6 int
+	}{
+		{
+			"Split_16_to_24",
+			[]string{"192.168.0.0/16", "10.0.0.0/16"},
+			24, 0,
+		},
+		{
+			"Split_32_to_48",
+			[]string{"2001:db8::/32"},
+			0, 48,
+		},
+		{
+			"Mixed_Splitting",
+			[]string{"192.168.0.0/16", "2001:db8::/32", "172.16.0.0/12"},
+			24, 48,
+		},
+	}
+
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pa := NewPrefixAggregator()
+				err := pa.SetMinPrefixLength(tc.minIPv4, tc.minIPv6)
+				if err != nil {
+					b.Fatalf("Failed to set min prefix length: %v", err)
+				}
+
+				for _, prefix := range tc.basePrefixes {
+					err := pa.AddPrefix(prefix)
+					if err != nil {
+						b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+					}
+				}
+
+				err = pa.Aggregate()
+				if err != nil {
+					b.Fatalf("Aggregation failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// manyExclusions returns exclusions cutting the first quarter out of
+// every step-th input prefix
+func manyExclusions(input []string, count int) []string {
+	step := len(input) / count
+	excludes := make([]string, 0, count)
+	for i := 0; i < len(input) && len(excludes) < count; i += step {
+		p := netip.MustParsePrefix(input[i])
+		excludes = append(excludes, netip.PrefixFrom(p.Addr(), min(p.Bits()+2, p.Addr().BitLen())).String())
+	}
+	return excludes
+}
+
+func BenchmarkExclusionProcessing(b *testing.B) {
+	testCases := []struct {
+		name            string
+		basePrefixes    []string
+		excludePrefixes []string
+	}{
+		{
+			"Simple_Exclusion",
+			[]string{"192.168.0.0/16"},
+			[]string{"192.168.1.0/24"},
+		},
+		{
+			"Multiple_Exclusions",
+			[]string{"10.0.0.0/8"},
+			[]string{"10.1.0.0/16", "10.2.0.0/16", "10.3.0.0/16"},
+		},
+		{
+			"IPv6_Exclusion",
+			[]string{"2001:db8::/32"},
+			[]string{"2001:db8:1::/48", "2001:db8:2::/48"},
+		},
+		{
+			"Many_Exclusions",
+			generateTestPrefixes(50000),
+			manyExclusions(generateTestPrefixes(50000), 500),
+		},
+	}
+
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pa := NewPrefixAggregator()
+
+				for _, prefix := range tc.basePrefixes {
+					err := pa.AddPrefix(prefix)
+					if err != nil {
+						b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+					}
+				}
+
+				err := pa.SetExcludePrefixes(tc.excludePrefixes)
+				if err != nil {
+					b.Fatalf("Failed to set exclude prefixes: %v", err)
+				}
+
+				err = pa.Aggregate()
+				if err != nil {
+					b.Fatalf("Aggregation failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFileIO(b *testing.B) {
+	// Create a temporary file with test prefixes
+	prefixes := generateTestPrefixes(10000)
+
+	b.Run("FileRead", func(b *testing.B) {
+		tmpfile := createTempPrefixFile(b, prefixes)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			pa := NewPrefixAggregator()
+			err := pa.AddFromFile(tmpfile)
+			if err != nil {
+				b.Fatalf("Failed to read file: %v", err)
+			}
+		}
+	})
+
+	b.Run("FileWrite", func(b *testing.B) {
+		// Pre-create aggregator with data
+		pa := NewPrefixAggregator()
+		for _, prefix := range prefixes {
+			err := pa.AddPrefix(prefix)
+			if err != nil {
+				b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+			}
+		}
+
+		// Reuse one handle so the benchmark measures writing, not file creation
+		file, err := os.Create(filepath.Join(b.TempDir(), "output.txt"))
+		if err != nil {
+			b.Fatalf("Failed to create file: %v", err)
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				b.Fatalf("Failed to rewind file: %v", err)
+			}
+			if err := file.Truncate(0); err != nil {
+				b.Fatalf("Failed to truncate file: %v", err)
+			}
+			if err := pa.WriteToWriter(file); err != nil {
+				b.Fatalf("Failed to write file: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkConcurrentAccess(b *testing.B) {
+	pa := NewPrefixAggregator()
+
+	// Pre-populate with some data
+	prefixes := generateTestPrefixes(1000)
+	for _, prefix := range prefixes {
+		err := pa.AddPrefix(prefix)
+		if err != nil {
+			b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+		}
+	}
+	err := pa.Aggregate()
+	if err != nil {
+		b.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			// Test concurrent read operations
+			stats := pa.GetStats()
+			_ = stats.TotalPrefixes
+
+			prefixList := pa.GetPrefixes()
+			_ = len(prefixList)
+
+			memStats := pa.GetMemoryStats()
+			_ = memStats.AllocBytes
+		}
+	})
+}
+
+// BenchmarkAddPrefixMemoryPolling compares AddPrefix throughput with and
+// without a goroutine polling GetMemoryStats every millisecond, as an
+// eager metrics scraper would
+func BenchmarkAddPrefixMemoryPolling(b *testing.B) {
+	prefixes := generateTestPrefixes(10000)
+
+	for _, polling := range []bool{false, true} {
+		name := "Idle"
+		if polling {
+			name = "Polling"
+		}
+		b.Run(name, func(b *testing.B) {
+			pa := NewPrefixAggregator()
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			if polling {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ticker := time.NewTicker(time.Millisecond)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-done:
+							return
+						case <-ticker.C:
+							_ = pa.GetMemoryStats()
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := pa.AddPrefix(prefixes[i%len(prefixes)]); err != nil {
+					b.Fatalf("Failed to add prefix: %v", err)
+				}
+			}
+			b.StopTimer()
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// Performance comparison benchmarks
+func BenchmarkUint256Operations(b *testing.B) {
+	// Test performance of uint256 operations used in the library
+	from, _ := parseIPPrefix("192.168.0.0/24")
+	to, _ := parseIPPrefix("192.168.1.0/24")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Test comparison operations
+		_ = from.Min.Cmp(to.Min)
+		_ = from.Max.Cmp(to.Max)
+
+		// Test arithmetic operations
+		temp := new(uint256.Int).Add(from.Min, uint256.NewInt(1))
+		_ = temp.Cmp(from.Max)
+	}
+}
+
+// Statistics calculation
+func BenchmarkStatisticsCalculation(b *testing.B) {
+	pa := NewPrefixAggregator()
+	prefixes := generateTestPrefixes(10000)
+
+	for _, prefix := range prefixes {
+		err := pa.AddPrefix(prefix)
+		if err != nil {
+			b.Fatalf("Failed to add prefix %s: %v", prefix, err)
+		}
+	}
+	err := pa.Aggregate()
+	if err != nil {
+		b.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stats := pa.GetStats()
+		_ = stats.ReductionRatio
+
+		memStats := pa.GetMemoryStats()
+		_ = memStats.AggregatorBytes
+	}
+}
+
+// BenchmarkGetPrefixes measures the string getter at 1M entries. The
+// append variant reuses its slice, so it only allocates the strings.
+func BenchmarkGetPrefixes(b *testing.B) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(generateTestPrefixes(1000000)); err != nil {
+		b.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	b.Run("GetPrefixes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = pa.GetPrefixes()
+		}
+	})
+
+	b.Run("GetPrefixesAppend", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf []string
+		for i := 0; i < b.N; i++ {
+			buf = pa.GetPrefixesAppend(buf[:0])
+		}
+	})
+
+	b.Run("AppendIPv4Prefixes", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf []string
+		for i := 0; i < b.N; i++ {
+			buf = pa.AppendIPv4Prefixes(buf[:0])
+		}
+	})
+
+	b.Run("AppendIPv4NetipPrefixes", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf []netip.Prefix
+		for i := 0; i < b.N; i++ {
+			buf = pa.AppendIPv4NetipPrefixes(buf[:0])
+		}
+	})
+
+	b.Run("WriteToWriter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := pa.WriteToWriter(io.Discard); err != nil {
+				b.Fatalf("Failed to write: %v", err)
+			}
+		}
+	})
+}
+
+// Benchmark memory pooling vs direct allocation
+func BenchmarkIPPrefixAllocation(b *testing.B) {
+	defer SetPooling(true)
+
+	for _, mode := range []struct {
+		name    string
+		pooling bool
+	}{
+		{"WithPooling", true},
+		{"WithoutPooling", false},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			SetPooling(mode.pooling)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p := acquireIPPrefix()
+				p.Prefix, _ = parseNetipPrefix("192.168.1.0/24")
+				releaseIPPrefix(p)
+			}
+		})
+	}
+}
+
+// Benchmark binary search vs linear search for finding overlaps
+func BenchmarkFindOverlapping(b *testing.B) {
+	pa := NewPrefixAggregator()
+
+	// Add many prefixes
+	for i := 0; i < 10000; i++ {
+		prefix := fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+		_ = pa.AddPrefix(prefix)
+	}
+
+	// Sort them
+	_ = pa.sortAndDeduplicateIPv4()
+
+	target, _ := parseIPPrefix("10.50.50.0/24")
+
+	b.Run("BinarySearch", func(b *testing.B) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = pa.findOverlappingPrefixes(target, pa.IPv4Prefixes)
+		}
+	})
+}
+
+// Benchmark aggregation with different dataset sizes
+func BenchmarkAggregationScalingNew(b *testing.B) {
+	sizes := []int{100, 1000, 10000}
+
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("Size_%d", size), func(b *testing.B) {
+			prefixes := make([]string, size)
+			for i := 0; i < size; i++ {
+				prefixes[i] = fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pa := NewPrefixAggregator()
+				_ = pa.AddPrefixes(prefixes)
+				_ = pa.Aggregate()
+			}
+		})
+	}
+}
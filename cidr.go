@@ -0,0 +1,177 @@
+package netjugo
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"net/netip"
+	"slices"
+
+	"github.com/holiman/uint256"
+)
+
+// maxSplitBits limits SplitPrefix to 2^20 subnets
+const maxSplitBits = 20
+
+// Adjacent reports whether a and b are the same family and one ends
+// immediately before the other starts. Host bits are ignored.
+func Adjacent(a, b netip.Prefix) bool {
+	pa, ok := prefixRange(a)
+	if !ok {
+		return false
+	}
+	pb, ok := prefixRange(b)
+	if !ok || a.Addr().Is4() != b.Addr().Is4() {
+		return false
+	}
+	return areAdjacent(pa, pb)
+}
+
+// CommonSupernet returns the longest prefix that contains both a and b.
+// It reports false if either prefix is invalid or the families differ.
+func CommonSupernet(a, b netip.Prefix) (netip.Prefix, bool) {
+	pa, ok := prefixRange(a)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	pb, ok := prefixRange(b)
+	if !ok || a.Addr().Is4() != b.Addr().Is4() {
+		return netip.Prefix{}, false
+	}
+
+	// The common prefix ends at the highest bit where the network
+	// addresses differ
+	var diff uint256.Int
+	diff.Xor(pa.Min, pb.Min)
+	bits := a.Addr().BitLen() - diff.BitLen()
+	bits = min(bits, a.Bits(), b.Bits())
+
+	return netip.PrefixFrom(a.Addr(), bits).Masked(), true
+}
+
+// ComparePrefixes orders prefixes the way the aggregator does: IPv4
+// before IPv6, then by network address, then less specific first. Host
+// bits only break ties, so prefixes with the same network stay adjacent.
+// Invalid prefixes sort first. It returns -1, 0 or +1.
+func ComparePrefixes(a, b netip.Prefix) int {
+	if c := a.Masked().Addr().Compare(b.Masked().Addr()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Bits(), b.Bits()); c != 0 {
+		return c
+	}
+	return a.Addr().Compare(b.Addr())
+}
+
+// SortPrefixes sorts prefixes into ComparePrefixes order
+func SortPrefixes(prefixes []netip.Prefix) {
+	slices.SortFunc(prefixes, ComparePrefixes)
+}
+
+// SplitPrefix divides p into its subnets of length newLen, in address
+// order. newLen must be between p.Bits() and the address length, and at
+// most 2^20 subnets are returned.
+func SplitPrefix(p netip.Prefix, newLen int) ([]netip.Prefix, error) {
+	subnets, err := ExpandPrefix(p, newLen)
+	if err != nil {
+		return nil, err
+	}
+	if newLen-p.Bits() > maxSplitBits {
+		return nil, fmt.Errorf("%w: splitting %s into /%d gives more than 2^%d prefixes", ErrInvalidOption, p, newLen, maxSplitBits)
+	}
+
+	result := make([]netip.Prefix, 0, 1<<(newLen-p.Bits()))
+	for subnet := range subnets {
+		result = append(result, subnet)
+	}
+	return result, nil
+}
+
+// ExpandPrefix is SplitPrefix without the size limit: it returns an
+// iterator over the 2^(targetBits-p.Bits()) subnets of p, in address
+// order, produced as they are consumed. Host bits of p are ignored.
+func ExpandPrefix(p netip.Prefix, targetBits int) (iter.Seq[netip.Prefix], error) {
+	ipPrefix, ok := prefixRange(p)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPrefix, p)
+	}
+
+	width := p.Addr().BitLen()
+	if targetBits < p.Bits() || targetBits > width {
+		return nil, fmt.Errorf("%w: cannot split %s into /%d", ErrInvalidPrefix, p, targetBits)
+	}
+
+	isIPv4 := p.Addr().Is4()
+	var step, hostMask, last uint256.Int
+	step.Lsh(uint256.NewInt(1), uint(width-targetBits))
+	// The last subnet is the end of p with the subnet host bits cleared.
+	// uint256 leaves room past the IPv6 range, so stepping beyond it
+	// cannot wrap.
+	hostMask.SubUint64(&step, 1)
+	last.Not(&hostMask)
+	last.And(&last, ipPrefix.Max)
+
+	return func(yield func(netip.Prefix) bool) {
+		var current uint256.Int
+		current.Set(ipPrefix.Min)
+		for current.Cmp(&last) <= 0 {
+			if !yield(netip.PrefixFrom(uint256ToAddr(&current, isIPv4), targetBits)) {
+				return
+			}
+			current.Add(&current, &step)
+		}
+	}, nil
+}
+
+// RangeToPrefixes returns the smallest list of prefixes that exactly
+// covers the addresses from first to last, inclusive, in address order.
+func RangeToPrefixes(first, last netip.Addr) ([]netip.Prefix, error) {
+	if !first.IsValid() || !last.IsValid() {
+		return nil, fmt.Errorf("%w: invalid address in range", ErrInvalidPrefix)
+	}
+	if first.Is4() != last.Is4() {
+		return nil, fmt.Errorf("%w: %s and %s are different families", ErrInvalidPrefix, first, last)
+	}
+	if last.Less(first) {
+		return nil, fmt.Errorf("%w: %s is after %s", ErrInvalidPrefix, first, last)
+	}
+
+	var minVal, maxVal uint256.Int
+	addrToUint256(first, &minVal)
+	addrToUint256(last, &maxVal)
+
+	ipPrefixes, err := createOptimalPrefixes(&minVal, &maxVal, first.Is4())
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]netip.Prefix, len(ipPrefixes))
+	for i, p := range ipPrefixes {
+		result[i] = p.Prefix
+		releaseIPPrefix(p)
+	}
+
+	return result, nil
+}
+
+// prefixRange converts a valid prefix into an unpooled IPPrefix
+func prefixRange(p netip.Prefix) (*IPPrefix, bool) {
+	if !p.IsValid() {
+		return nil, false
+	}
+	p = p.Masked()
+	minVal, maxVal, err := prefixToUint256Range(p)
+	if err != nil {
+		return nil, false
+	}
+	return &IPPrefix{Prefix: p, Min: minVal, Max: maxVal}, true
+}
+
+// uint256ToAddr is the inverse of addrToUint256
+func uint256ToAddr(v *uint256.Int, isIPv4 bool) netip.Addr {
+	b := v.Bytes32()
+	if isIPv4 {
+		return netip.AddrFrom4([4]byte(b[28:32]))
+	}
+	return netip.AddrFrom16([16]byte(b[16:32]))
+}
@@ -0,0 +1,362 @@
+package netjugo
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func TestAdjacent(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"10.0.0.0/24", "10.0.1.0/24", true},
+		{"10.0.1.0/24", "10.0.0.0/24", true},
+		{"10.0.0.0/24", "10.0.2.0/24", false},
+		{"10.0.0.0/24", "10.0.0.128/25", false},
+		{"10.0.1.0/24", "10.0.2.0/23", true},
+		{"2001:db8::/33", "2001:db8:8000::/33", true},
+		{"2001:db8::/48", "2001:db8:2::/48", false},
+		{"0.0.0.0/1", "::/1", false},
+	}
+
+	for _, tt := range tests {
+		got := Adjacent(netip.MustParsePrefix(tt.a), netip.MustParsePrefix(tt.b))
+		if got != tt.want {
+			t.Errorf("Adjacent(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if Adjacent(netip.Prefix{}, netip.MustParsePrefix("10.0.0.0/8")) {
+		t.Error("Invalid prefix should never be adjacent")
+	}
+}
+
+func TestCommonSupernet(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string // empty when no supernet exists
+	}{
+		{"10.0.0.0/24", "10.0.1.0/24", "10.0.0.0/23"},
+		{"10.0.0.0/24", "10.0.0.0/24", "10.0.0.0/24"},
+		{"10.1.2.0/24", "10.1.0.0/16", "10.1.0.0/16"},
+		{"10.0.0.0/8", "192.168.0.0/16", "0.0.0.0/0"},
+		{"192.168.1.7/32", "192.168.1.9/32", "192.168.1.0/28"},
+		{"2001:db8::/48", "2001:db8:ffff::/48", "2001:db8::/32"},
+		{"2001:db8::1/128", "2001:db8::1/128", "2001:db8::1/128"},
+		{"::/1", "8000::/1", "::/0"},
+		{"10.0.0.0/8", "2001:db8::/32", ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := CommonSupernet(netip.MustParsePrefix(tt.a), netip.MustParsePrefix(tt.b))
+		if ok != (tt.want != "") {
+			t.Errorf("CommonSupernet(%s, %s) ok = %v, want %v", tt.a, tt.b, ok, tt.want != "")
+			continue
+		}
+		if ok && got.String() != tt.want {
+			t.Errorf("CommonSupernet(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		newLen int
+		want   []string
+	}{
+		{"10.0.0.0/24", 24, []string{"10.0.0.0/24"}},
+		{"10.0.0.0/24", 26, []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26"}},
+		{"255.255.255.254/31", 32, []string{"255.255.255.254/32", "255.255.255.255/32"}},
+		{"2001:db8::/32", 34, []string{"2001:db8::/34", "2001:db8:4000::/34", "2001:db8:8000::/34", "2001:db8:c000::/34"}},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127", 128, []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128"}},
+	}
+
+	for _, tt := range tests {
+		got, err := SplitPrefix(netip.MustParsePrefix(tt.prefix), tt.newLen)
+		if err != nil {
+			t.Errorf("SplitPrefix(%s, %d) failed: %v", tt.prefix, tt.newLen, err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("SplitPrefix(%s, %d) = %v, want %v", tt.prefix, tt.newLen, got, tt.want)
+		}
+	}
+
+	errTests := []struct {
+		prefix string
+		newLen int
+		err    error
+	}{
+		{"10.0.0.0/24", 23, ErrInvalidPrefix},
+		{"10.0.0.0/24", 33, ErrInvalidPrefix},
+		{"2001:db8::/32", 129, ErrInvalidPrefix},
+		{"2001:db8::/32", 64, ErrInvalidOption},
+	}
+
+	for _, tt := range errTests {
+		if _, err := SplitPrefix(netip.MustParsePrefix(tt.prefix), tt.newLen); !errors.Is(err, tt.err) {
+			t.Errorf("SplitPrefix(%s, %d) error = %v, want %v", tt.prefix, tt.newLen, err, tt.err)
+		}
+	}
+}
+
+func TestExpandPrefix(t *testing.T) {
+	tests := []struct {
+		prefix      string
+		target      int
+		count       int
+		first, last string
+	}{
+		{"10.0.0.0/14", 24, 1 << 10, "10.0.0.0/24", "10.3.255.0/24"},
+		{"0.0.0.0/0", 20, 1 << 20, "0.0.0.0/20", "255.255.240.0/20"},
+		{"0.0.0.0/0", 0, 1, "0.0.0.0/0", "0.0.0.0/0"},
+		{"192.0.2.9/32", 32, 1, "192.0.2.9/32", "192.0.2.9/32"},
+		{"2001:db8::/32", 48, 1 << 16, "2001:db8::/48", "2001:db8:ffff::/48"},
+		{"::/0", 0, 1, "::/0", "::/0"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/120", 128, 1 << 8, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128"},
+		// Host bits are ignored
+		{"10.0.0.77/30", 31, 2, "10.0.0.76/31", "10.0.0.78/31"},
+	}
+
+	for _, tt := range tests {
+		subnets, err := ExpandPrefix(netip.MustParsePrefix(tt.prefix), tt.target)
+		if err != nil {
+			t.Errorf("ExpandPrefix(%s, %d) failed: %v", tt.prefix, tt.target, err)
+			continue
+		}
+		count := 0
+		var first, last netip.Prefix
+		for p := range subnets {
+			if count == 0 {
+				first = p
+			} else if p.Addr().Compare(last.Addr()) <= 0 {
+				t.Fatalf("ExpandPrefix(%s, %d) went from %s to %s", tt.prefix, tt.target, last, p)
+			}
+			last = p
+			count++
+		}
+		if count != tt.count || first.String() != tt.first || last.String() != tt.last {
+			t.Errorf("ExpandPrefix(%s, %d) gave %d subnets %s..%s, want %d %s..%s",
+				tt.prefix, tt.target, count, first, last, tt.count, tt.first, tt.last)
+		}
+	}
+
+	// 2^128 subnets are produced lazily, and stopping early works
+	subnets, err := ExpandPrefix(netip.MustParsePrefix("::/0"), 128)
+	if err != nil {
+		t.Fatalf("ExpandPrefix failed: %v", err)
+	}
+	var got []string
+	for p := range subnets {
+		got = append(got, p.String())
+		if len(got) == 3 {
+			break
+		}
+	}
+	if fmt.Sprint(got) != "[::/128 ::1/128 ::2/128]" {
+		t.Errorf("Unexpected first subnets of ::/0: %v", got)
+	}
+
+	for _, tt := range []struct {
+		prefix string
+		target int
+	}{{"10.0.0.0/24", 23}, {"10.0.0.0/24", 33}, {"2001:db8::/32", 129}, {"2001:db8::/32", -1}} {
+		if _, err := ExpandPrefix(netip.MustParsePrefix(tt.prefix), tt.target); !errors.Is(err, ErrInvalidPrefix) {
+			t.Errorf("ExpandPrefix(%s, %d) error = %v, want ErrInvalidPrefix", tt.prefix, tt.target, err)
+		}
+	}
+	if _, err := ExpandPrefix(netip.Prefix{}, 24); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Expected ErrInvalidPrefix for an invalid prefix, got %v", err)
+	}
+}
+
+func TestComparePrefixes(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"10.0.0.0/24", "10.0.0.0/24", 0},
+		{"10.0.0.0/24", "10.0.1.0/24", -1},
+		{"10.0.0.0/16", "10.0.0.0/24", -1},
+		{"10.0.0.0/16", "9.0.0.0/8", 1},
+		{"255.255.255.255/32", "::/0", -1},
+		{"2001:db8::/32", "2001:db8::/48", -1},
+		// Host bits only break ties between equal networks
+		{"10.0.0.1/24", "10.0.0.0/24", 1},
+		{"10.0.0.1/24", "10.0.0.0/25", -1},
+	}
+	for _, tt := range tests {
+		got := ComparePrefixes(netip.MustParsePrefix(tt.a), netip.MustParsePrefix(tt.b))
+		if got != tt.want {
+			t.Errorf("ComparePrefixes(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if got := ComparePrefixes(netip.Prefix{}, netip.MustParsePrefix("0.0.0.0/0")); got != -1 {
+		t.Errorf("Expected an invalid prefix to sort first, got %d", got)
+	}
+}
+
+func TestComparePrefixesTotalOrder(t *testing.T) {
+	// Few distinct values, so that equal networks and ties are common
+	rng := rand.New(rand.NewSource(1))
+	bases := []netip.Addr{netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("2001:db8::")}
+	random := func() netip.Prefix {
+		base := bases[rng.Intn(len(bases))].As16()
+		base[15] = byte(rng.Intn(8))
+		addr := netip.AddrFrom16(base)
+		if addr.Is4In6() {
+			addr = addr.Unmap()
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()-rng.Intn(4))
+	}
+
+	prefixes := make([]netip.Prefix, 40)
+	for i := range prefixes {
+		prefixes[i] = random()
+	}
+	for _, a := range prefixes {
+		for _, b := range prefixes {
+			ab, ba := ComparePrefixes(a, b), ComparePrefixes(b, a)
+			if ab != -ba {
+				t.Fatalf("Not antisymmetric: compare(%s, %s) = %d, compare(%s, %s) = %d", a, b, ab, b, a, ba)
+			}
+			if (ab == 0) != (a == b) {
+				t.Fatalf("compare(%s, %s) = %d, but equal is %v", a, b, ab, a == b)
+			}
+		}
+	}
+	for range 10000 {
+		a, b, c := prefixes[rng.Intn(len(prefixes))], prefixes[rng.Intn(len(prefixes))], prefixes[rng.Intn(len(prefixes))]
+		if ComparePrefixes(a, b) <= 0 && ComparePrefixes(b, c) <= 0 && ComparePrefixes(a, c) > 0 {
+			t.Fatalf("Not transitive: %s <= %s <= %s, but %s > %s", a, b, c, a, c)
+		}
+	}
+
+	SortPrefixes(prefixes)
+	if !slices.IsSortedFunc(prefixes, ComparePrefixes) {
+		t.Errorf("SortPrefixes left %v unsorted", prefixes)
+	}
+}
+
+func TestComparePrefixesMatchesResultOrder(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(generateTestPrefixes(500)); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.AddPrefixes([]string{"2001:db8::/48", "2001:db8:5::/48", "192.0.2.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	var result []netip.Prefix
+	pa.ForEachPrefix(func(p netip.Prefix) bool {
+		result = append(result, p)
+		return true
+	})
+	if !slices.IsSortedFunc(result, ComparePrefixes) {
+		t.Error("Expected the result in ComparePrefixes order")
+	}
+}
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		first, last string
+		want        []string
+	}{
+		{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}},
+		{"10.0.0.1", "10.0.0.1", []string{"10.0.0.1/32"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"192.168.0.0", "192.168.2.127", []string{"192.168.0.0/23", "192.168.2.0/25"}},
+		{"2001:db8::", "2001:db8::ffff", []string{"2001:db8::/112"}},
+		{"2001:db8::1", "2001:db8::3", []string{"2001:db8::1/128", "2001:db8::2/127"}},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", []string{"::/0"}},
+	}
+
+	for _, tt := range tests {
+		got, err := RangeToPrefixes(netip.MustParseAddr(tt.first), netip.MustParseAddr(tt.last))
+		if err != nil {
+			t.Errorf("RangeToPrefixes(%s, %s) failed: %v", tt.first, tt.last, err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("RangeToPrefixes(%s, %s) = %v, want %v", tt.first, tt.last, got, tt.want)
+		}
+	}
+
+	errTests := [][2]string{
+		{"10.0.0.2", "10.0.0.1"},
+		{"10.0.0.1", "2001:db8::1"},
+	}
+	for _, tt := range errTests {
+		if _, err := RangeToPrefixes(netip.MustParseAddr(tt[0]), netip.MustParseAddr(tt[1])); !errors.Is(err, ErrInvalidPrefix) {
+			t.Errorf("RangeToPrefixes(%s, %s) error = %v, want ErrInvalidPrefix", tt[0], tt[1], err)
+		}
+	}
+}
+
+func FuzzRangeToPrefixes(f *testing.F) {
+	f.Add([]byte{10, 0, 0, 1}, []byte{10, 0, 0, 6}, true)
+	f.Add([]byte{0, 0, 0, 0}, []byte{255, 255, 255, 255}, true)
+	f.Add([]byte{0x20, 0x01, 0x0d, 0xb8}, []byte{0x20, 0x01, 0x0d, 0xb8, 0xff}, false)
+	f.Add([]byte{}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, false)
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0}, false)
+
+	f.Fuzz(func(t *testing.T, a, b []byte, isIPv4 bool) {
+		first, last := fuzzAddr(a, isIPv4), fuzzAddr(b, isIPv4)
+		if last.Less(first) {
+			first, last = last, first
+		}
+
+		prefixes, err := RangeToPrefixes(first, last)
+		if err != nil {
+			t.Fatalf("RangeToPrefixes(%s, %s) failed: %v", first, last, err)
+		}
+		if limit := 2 * (first.BitLen() - 1); len(prefixes) > limit {
+			t.Fatalf("RangeToPrefixes(%s, %s) returned %d prefixes, at most %d are needed", first, last, len(prefixes), limit)
+		}
+
+		// Each prefix must start right after the previous one ends
+		var next uint256.Int
+		addrToUint256(first, &next)
+		for i, p := range prefixes {
+			r, ok := prefixRange(p)
+			if !ok || p != p.Masked() || p.Addr().Is4() != isIPv4 {
+				t.Fatalf("RangeToPrefixes(%s, %s): entry %d is %s", first, last, i, p)
+			}
+			if !r.Min.Eq(&next) {
+				t.Fatalf("RangeToPrefixes(%s, %s): %s leaves a gap or overlap before it", first, last, p)
+			}
+			next.AddUint64(r.Max, 1)
+		}
+
+		var end uint256.Int
+		addrToUint256(last, &end)
+		end.AddUint64(&end, 1)
+		if !next.Eq(&end) {
+			t.Fatalf("RangeToPrefixes(%s, %s) = %v does not end at %s", first, last, prefixes, last)
+		}
+	})
+}
+
+// fuzzAddr makes an address of the family from up to its length in bytes
+func fuzzAddr(b []byte, isIPv4 bool) netip.Addr {
+	if isIPv4 {
+		var a [4]byte
+		copy(a[:], b)
+		return netip.AddrFrom4(a)
+	}
+	var a [16]byte
+	copy(a[:], b)
+	return netip.AddrFrom16(a)
+}
@@ -0,0 +1,813 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rretina/netjugo"
+)
+
+func aggregateUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	_, _ = fmt.Fprintf(w, "Usage: %s aggregate [options]\n\n", progName)
+	_, _ = fmt.Fprintf(w, "Aggregates IPv4 and IPv6 CIDR prefixes with support for minimum lengths,\n")
+	_, _ = fmt.Fprintf(w, "inclusion/exclusion constraints, and optimal aggregation quality.\n\n")
+	_, _ = fmt.Fprintf(w, "Options:\n")
+	fs.PrintDefaults()
+	_, _ = fmt.Fprintf(w, "\nExamples:\n")
+	_, _ = fmt.Fprintf(w, "  %s aggregate -input prefixes.txt -output aggregated.txt -stats\n", progName)
+	_, _ = fmt.Fprintf(w, "  %s aggregate -input large.txt -min-ipv4 24 -min-ipv6 48 -verbose\n", progName)
+	_, _ = fmt.Fprintf(w, "  %s aggregate -input base.txt -include include.txt -exclude exclude.txt\n", progName)
+	_, _ = fmt.Fprintf(w, "  %s aggregate -input large.txt -output out.txt -max-lines-per-file 10000\n", progName)
+	_, _ = fmt.Fprintf(w, "  %s aggregate -input prefixes.txt -exclude-prefix '192.168.1.0/24,10.0.0.0/24'\n", progName)
+	_, _ = fmt.Fprintf(w, "  %s aggregate -input feed.txt -restrict-to allocations.txt\n", progName)
+	_, _ = fmt.Fprintf(w, "  %s aggregate -input large.txt -min-ipv4 24 -estimate\n", progName)
+	_, _ = fmt.Fprintf(w, "\nInput Format:\n")
+	_, _ = fmt.Fprintf(w, "  One IP prefix per line in CIDR notation (e.g., 192.168.1.0/24, 2001:db8::/32)\n")
+	_, _ = fmt.Fprintf(w, "  Comments (lines starting with #) and empty lines are ignored\n")
+	_, _ = fmt.Fprintf(w, "  IPv4 addresses without /xx will be treated as /32\n")
+	_, _ = fmt.Fprintf(w, "  IPv6 addresses without /xx will be treated as /128\n")
+	_, _ = fmt.Fprintf(w, "  With -format csv, prefixes are read from one CSV column (-csv-column)\n")
+}
+
+// aggregateOptions holds the flags shared by the aggregate and stats
+// commands for building and running an aggregator
+type aggregateOptions struct {
+	inputFiles  fileList
+	minIPv4Len  int
+	minIPv6Len  int
+	includeFile string
+	excludeFile string
+	includePfx  string
+	excludePfx  string
+	// restrictFile holds the prefixes the result is restricted to
+	restrictFile string
+	verbose      bool
+	// rejectDefault fails instead of warning when the result collapses
+	// to a default route the input did not contain
+	rejectDefault bool
+	pointToPoint  bool
+	maxOutput     int
+	maxMemoryMB   int
+	// inputFormat is "text" or "csv"; csvColumn is a header name or a
+	// 1-based column number
+	inputFormat string
+	csvColumn   string
+	csvHeader   bool
+	// tagInputs tags every prefix with the base name of its input file
+	tagInputs bool
+	profile   profileOptions
+	// estimate stops load before aggregating
+	estimate bool
+}
+
+// fileList is a flag that can be repeated or given a comma-separated list
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *fileList) Set(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			*l = append(*l, path)
+		}
+	}
+	return nil
+}
+
+func (o *aggregateOptions) register(fs *flag.FlagSet) {
+	fs.Var(&o.inputFiles, "input", "Input file containing IP prefixes (one per line); repeat or comma-separate to load several in parallel")
+	fs.IntVar(&o.minIPv4Len, "min-ipv4", 0, "Minimum IPv4 prefix length (0-32)")
+	fs.IntVar(&o.minIPv6Len, "min-ipv6", 0, "Minimum IPv6 prefix length (0-128)")
+	fs.StringVar(&o.includeFile, "include", "", "File containing prefixes to include")
+	fs.StringVar(&o.excludeFile, "exclude", "", "File containing prefixes to exclude")
+	fs.StringVar(&o.includePfx, "include-prefix", "", "Comma-separated list of prefixes to include")
+	fs.StringVar(&o.excludePfx, "exclude-prefix", "", "Comma-separated list of prefixes to exclude")
+	fs.StringVar(&o.restrictFile, "restrict-to", "", "File containing prefixes outside of which nothing is kept")
+	fs.BoolVar(&o.verbose, "verbose", false, "Verbose output")
+	fs.BoolVar(&o.rejectDefault, "reject-default-route", false, "Fail if the result aggregates to 0.0.0.0/0 or ::/0 without it being in the input")
+	fs.BoolVar(&o.pointToPoint, "point-to-point", false, "Accept /31 and /127 exclusions without warning, for point-to-point link allocations")
+	fs.IntVar(&o.maxOutput, "max-output", 0, "Fail if the result would exceed N prefixes (0 for no limit)")
+	fs.IntVar(&o.maxMemoryMB, "max-memory-mb", 0, "Fail cleanly if loading the input needs more than N MB (0 for no limit)")
+	fs.StringVar(&o.inputFormat, "format", "text", "Input format: text (one prefix per line) or csv")
+	fs.StringVar(&o.csvColumn, "csv-column", "", "CSV column holding the prefixes: a header name, or a 1-based number (default 1)")
+	fs.BoolVar(&o.csvHeader, "csv-header", false, "Skip the first CSV row when -csv-column is a number")
+	o.profile.register(fs)
+}
+
+// csvOptions returns the CSV options for -format csv, or nil for text
+// input
+func (o *aggregateOptions) csvOptions() (*netjugo.CSVOptions, error) {
+	switch o.inputFormat {
+	case "text":
+		if o.csvColumn != "" || o.csvHeader {
+			return nil, errors.New("-csv-column and -csv-header require -format csv")
+		}
+		return nil, nil
+	case "csv":
+	default:
+		return nil, fmt.Errorf("invalid input format %q (must be text or csv)", o.inputFormat)
+	}
+
+	opts := &netjugo.CSVOptions{Header: o.csvHeader}
+	if o.csvColumn == "" {
+		return opts, nil
+	}
+	if n, err := strconv.Atoi(o.csvColumn); err == nil {
+		if n < 1 {
+			return nil, fmt.Errorf("invalid CSV column %d (columns are numbered from 1)", n)
+		}
+		opts.Column = n - 1
+	} else {
+		opts.ColumnName = o.csvColumn
+	}
+	return opts, nil
+}
+
+// loadInput adds the input files to the aggregator, as text or as CSV
+func (o *aggregateOptions) loadInput(aggregator *netjugo.PrefixAggregator, csvOpts *netjugo.CSVOptions) ([]netjugo.ReadResult, error) {
+	if csvOpts == nil && !o.tagInputs {
+		return aggregator.AddFromFilesCount(o.inputFiles, 0)
+	}
+	results := make([]netjugo.ReadResult, 0, len(o.inputFiles))
+	for _, path := range o.inputFiles {
+		var result netjugo.ReadResult
+		var err error
+		if csvOpts != nil {
+			result, err = addCSVFile(aggregator, path, *csvOpts)
+		} else {
+			result, err = addTaggedFile(aggregator, path)
+		}
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return results, nil
+}
+
+func addCSVFile(aggregator *netjugo.PrefixAggregator, path string, opts netjugo.CSVOptions) (netjugo.ReadResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return netjugo.ReadResult{}, err
+	}
+	defer func() { _ = file.Close() }()
+	return aggregator.AddFromCSVCount(file, opts)
+}
+
+// addTaggedFile adds a text input file tagged with its base name
+func addTaggedFile(aggregator *netjugo.PrefixAggregator, path string) (netjugo.ReadResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return netjugo.ReadResult{}, err
+	}
+	defer func() { _ = file.Close() }()
+	return aggregator.AddFromReaderTaggedCount(file, filepath.Base(path))
+}
+
+func runAggregate(args []string, stdout, stderr io.Writer) error {
+	var opts aggregateOptions
+	var statsOpts statsOutputOptions
+
+	fs := newFlagSet("aggregate", stderr, aggregateUsage)
+	opts.register(fs)
+	statsOpts.register(fs)
+	watch := fs.Bool("watch", false, "Keep running and rewrite -output whenever an input file changes")
+	watchInterval := fs.Duration("watch-interval", 2*time.Second, "How often -watch checks the input files")
+	watchDebounce := fs.Duration("watch-debounce", 2*time.Second, "How long an input must stay unchanged before -watch re-aggregates")
+	outputFile := fs.String("output", "", "Output file for aggregated prefixes (default: stdout)")
+	maxLines := fs.Int("max-lines-per-file", 0, "Split output into numbered files of at most N prefixes (requires -output)")
+	showStats := fs.Bool("stats", false, "Show aggregation statistics")
+	showMemory := fs.Bool("memory", false, "Show memory usage statistics")
+	failOnWarning := fs.Bool("fail-on-warning", false, "Exit with status 5 if warnings were produced or input lines were skipped")
+	verifyFile := fs.String("verify-against", "", "Fail unless the result covers every prefix in this file")
+	deltaAgainst := fs.String("delta-against", "", "Write only the \"- prefix\" and \"+ prefix\" changes from this previous output")
+	semantic := fs.Bool("semantic", false, "With -delta-against, compare covered address space rather than prefix lists")
+	baseline := fs.String("baseline", "", "Fail if the covered address space differs from this previous output by more than -max-change")
+	maxChange := fs.Float64("max-change", 20, "With -baseline, the largest growth or shrink allowed per family, in percent")
+	top := fs.Int("top", 0, "Print the N result prefixes covering the most addresses to stderr")
+	within := fs.String("within", "", "Only output result prefixes inside this supernet; overlapping ones are reported to stderr")
+	groupBy := fs.String("group-by", "", "Print prefix and address counts per IPv4,IPv6 parent length (e.g. 8,16) to stderr")
+	failOnEmpty := fs.Bool("fail-on-empty", false, "Exit with status 3 instead of writing an empty result")
+	header := fs.Bool("header", false, "Start the output with comment lines giving the generation time, version and prefix counts")
+	sources := fs.Bool("sources", false, "Print the input files each result prefix came from to stderr")
+	reportFile := fs.String("report", "", "Write a JSON report of statistics, histogram, largest prefixes and warnings to this file")
+	estimate := fs.Bool("estimate", false, "Print an estimate of the result size from a sample of the input and exit, without aggregating")
+	version := fs.Bool("version", false, "Show version information")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *version {
+		_, _ = fmt.Fprintf(stdout, "IP Aggregator v%s, built with %s\n", netjugo.Version(), runtime.Version())
+		_, _ = fmt.Fprintln(stdout, "High-performance Go library for IP prefix aggregation")
+		_, _ = fmt.Fprintln(stdout, "Supports IPv4/IPv6, minimum prefix lengths, inclusion/exclusion")
+		return nil
+	}
+
+	if len(opts.inputFiles) == 0 {
+		return newUsageError(fs, "input file is required")
+	}
+	if *maxLines < 0 {
+		return newUsageError(fs, "-max-lines-per-file must not be negative")
+	}
+	if *top < 0 {
+		return newUsageError(fs, "-top must not be negative")
+	}
+	var groupLengths []int
+	if *groupBy != "" {
+		lengths, err := parseLengths(*groupBy)
+		if err != nil || len(lengths) != 2 {
+			return newUsageError(fs, "-group-by needs an IPv4 and an IPv6 length, e.g. 8,16")
+		}
+		groupLengths = lengths
+	}
+	if *maxLines > 0 && *outputFile == "" {
+		return newUsageError(fs, "-max-lines-per-file requires -output")
+	}
+	if *deltaAgainst != "" && *maxLines > 0 {
+		return newUsageError(fs, "-delta-against cannot be combined with -max-lines-per-file")
+	}
+	if *semantic && *deltaAgainst == "" {
+		return newUsageError(fs, "-semantic requires -delta-against")
+	}
+	if *maxChange < 0 {
+		return newUsageError(fs, "-max-change must not be negative")
+	}
+	if *sources && opts.inputFormat == "csv" {
+		return newUsageError(fs, "-sources is not supported with -format csv")
+	}
+	opts.tagInputs = *sources
+	if *watch {
+		switch {
+		case *outputFile == "":
+			return newUsageError(fs, "-watch requires -output")
+		case *maxLines > 0:
+			return newUsageError(fs, "-watch cannot be combined with -max-lines-per-file")
+		case *failOnWarning:
+			return newUsageError(fs, "-watch cannot be combined with -fail-on-warning")
+		case *watchInterval <= 0 || *watchDebounce < 0:
+			return newUsageError(fs, "-watch-interval must be positive and -watch-debounce not negative")
+		}
+	}
+	if *estimate && *watch {
+		return newUsageError(fs, "-estimate cannot be combined with -watch")
+	}
+	if err := statsOpts.validate(fs); err != nil {
+		return err
+	}
+
+	if *estimate {
+		opts.estimate = true
+		aggregator, err := opts.build(stdout, stderr)
+		if err != nil {
+			return err
+		}
+		printEstimate(stdout, aggregator.EstimateAggregation())
+		return nil
+	}
+
+	// once loads, aggregates and writes the result a single time
+	once := func() (netjugo.AggregationStats, error) {
+		var finalStats netjugo.AggregationStats
+		aggregator, err := opts.build(stdout, stderr)
+		if err != nil {
+			return finalStats, err
+		}
+
+		// Get final statistics
+		finalStats = aggregator.GetStats()
+
+		// Show warnings if not in verbose mode (verbose mode shows them real-time)
+		if !opts.verbose {
+			for _, warning := range aggregator.GetWarnings() {
+				_, _ = fmt.Fprintf(stderr, "%s\n", warning)
+			}
+		}
+
+		if *verifyFile != "" {
+			if err := verifyCoverage(aggregator, *verifyFile, stderr); err != nil {
+				return finalStats, err
+			}
+		}
+
+		output := aggregator
+		if *within != "" {
+			if output, err = filterWithin(aggregator, *within, stderr); err != nil {
+				return finalStats, err
+			}
+			finalStats.TotalPrefixes = output.GetStats().TotalPrefixes
+		}
+		if *baseline != "" {
+			if err := checkBaseline(output, *baseline, *maxChange, stderr); err != nil {
+				return finalStats, err
+			}
+		}
+
+		// Write output
+		output.SetFailOnEmptyResult(*failOnEmpty)
+		output.SetOutputHeader(*header)
+		if *deltaAgainst != "" {
+			if err := writeDelta(output, *deltaAgainst, *semantic, *outputFile, stdout); err != nil {
+				return finalStats, err
+			}
+		} else if *maxLines > 0 {
+			paths, err := output.WriteToFiles(*outputFile, *maxLines)
+			if err != nil {
+				return finalStats, withExitCode(writeExitCode(err), fmt.Errorf("failed to write output files: %w", err))
+			}
+			if opts.verbose {
+				_, _ = fmt.Fprintf(stdout, "Wrote %d aggregated prefixes to %d files\n", finalStats.TotalPrefixes, len(paths))
+			}
+		} else if *outputFile != "" {
+			write := output.WriteToFile
+			if *watch {
+				write = func(path string) error { return writeFileAtomic(path, output.WriteToWriter) }
+			}
+			if err := write(*outputFile); err != nil {
+				return finalStats, withExitCode(writeExitCode(err), fmt.Errorf("failed to write output file: %w", err))
+			}
+			if opts.verbose {
+				_, _ = fmt.Fprintf(stdout, "Wrote %d aggregated prefixes to %s\n", finalStats.TotalPrefixes, *outputFile)
+			}
+		} else {
+			if err := output.WriteToWriter(stdout); err != nil {
+				return finalStats, withExitCode(writeExitCode(err), fmt.Errorf("failed to write to stdout: %w", err))
+			}
+		}
+
+		if *showStats || opts.verbose || statsOpts.requested() {
+			if err := statsOpts.write(stderr, aggregator, *showMemory); err != nil {
+				return finalStats, withExitCode(exitOutput, err)
+			}
+		} else if *showMemory {
+			printMemoryStats(stderr, aggregator.GetMemoryStats())
+		}
+
+		if *top > 0 {
+			printTopPrefixes(stderr, aggregator.TopPrefixesBySize(*top))
+		}
+
+		if groupLengths != nil {
+			groups, err := aggregator.GroupByParent(groupLengths[0], groupLengths[1])
+			if err != nil {
+				return finalStats, withExitCode(exitValidation, fmt.Errorf("failed to group prefixes: %w", err))
+			}
+			printGroups(stderr, groups)
+		}
+
+		if *sources {
+			printSources(stderr, aggregator.GetPrefixes(), aggregator.GetResultTags())
+		}
+
+		if *reportFile != "" {
+			if err := writeReport(*reportFile, aggregator); err != nil {
+				return finalStats, withExitCode(exitOutput, err)
+			}
+		}
+
+		if *failOnWarning {
+			return finalStats, checkWarnings(aggregator)
+		}
+
+		return finalStats, nil
+	}
+
+	if !*watch {
+		_, err := once()
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return newWatcher(opts.inputFiles, *watchInterval, *watchDebounce, once, stderr).watch(ctx)
+}
+
+// writeExitCode maps a write error to its exit code: a refused empty
+// result is a validation failure, anything else an output failure
+func writeExitCode(err error) int {
+	if errors.Is(err, netjugo.ErrEmptyResult) {
+		return exitValidation
+	}
+	return exitOutput
+}
+
+// writeReport writes the aggregator's report to path as indented JSON
+func writeReport(path string, aggregator *netjugo.PrefixAggregator) error {
+	data, err := json.MarshalIndent(aggregator.GenerateReport(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// filterWithin returns an aggregator holding the result prefixes inside
+// supernet, reporting the ones that only overlap it
+func filterWithin(aggregator *netjugo.PrefixAggregator, supernet string, stderr io.Writer) (*netjugo.PrefixAggregator, error) {
+	within, straddling, err := aggregator.PrefixesWithin(supernet)
+	if err != nil {
+		return nil, withExitCode(exitValidation, fmt.Errorf("invalid -within supernet: %w", err))
+	}
+	for _, prefix := range straddling {
+		_, _ = fmt.Fprintf(stderr, "overlaps %s: %s\n", supernet, prefix)
+	}
+
+	filtered := netjugo.NewPrefixAggregator()
+	if err := filtered.AddPrefixes(within); err != nil {
+		return nil, withExitCode(exitValidation, err)
+	}
+	if err := filtered.Aggregate(); err != nil {
+		return nil, withExitCode(exitValidation, err)
+	}
+	return filtered, nil
+}
+
+// checkWarnings fails when aggregation produced warnings or input lines
+// were skipped
+func checkWarnings(aggregator *netjugo.PrefixAggregator) error {
+	stats := aggregator.GetStats()
+	warnings, skipped := stats.TotalWarnings, stats.SkippedLines
+	if warnings > 0 || skipped > 0 {
+		return withExitCode(exitWarnings, fmt.Errorf("%d warnings and %d skipped input lines", warnings, skipped))
+	}
+	return nil
+}
+
+// verifyCoverage fails, listing the missing entries, unless the result
+// covers every prefix in path
+func verifyCoverage(aggregator *netjugo.PrefixAggregator, path string, stderr io.Writer) error {
+	prefixes, err := readPrefixesFromFile(path)
+	if err != nil {
+		return withExitCode(exitInput, fmt.Errorf("failed to read verify file: %w", err))
+	}
+	missing, err := aggregator.CoversAll(prefixes)
+	if err != nil {
+		return withExitCode(exitValidation, fmt.Errorf("failed to verify coverage: %w", err))
+	}
+	for _, prefix := range missing {
+		_, _ = fmt.Fprintf(stderr, "not covered: %s\n", prefix)
+	}
+	if len(missing) > 0 {
+		return withExitCode(exitValidation, fmt.Errorf("%d of %d prefixes in %s not covered", len(missing), len(prefixes), path))
+	}
+	return nil
+}
+
+// checkBaseline fails, before anything is written, if the result covers
+// more than maxChange percent more or less address space than the previous
+// output in path. A missing file passes, as on the first run.
+func checkBaseline(output *netjugo.PrefixAggregator, path string, maxChange float64, stderr io.Writer) error {
+	baseline, err := loadAggregator(path)
+	if errors.Is(err, netjugo.ErrFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return withExitCode(exitInput, fmt.Errorf("failed to read baseline file: %w", err))
+	}
+	if err := baseline.Aggregate(); err != nil {
+		return withExitCode(exitValidation, fmt.Errorf("failed to aggregate baseline file: %w", err))
+	}
+
+	err = output.CompareToBaseline(baseline, maxChange, maxChange)
+	if errors.Is(err, netjugo.ErrChangeTooLarge) {
+		_, _ = fmt.Fprintf(stderr, "%v\n", err)
+		return withExitCode(exitValidation, fmt.Errorf("result differs too much from %s", path))
+	}
+	if err != nil {
+		return withExitCode(exitValidation, fmt.Errorf("failed to compare with baseline: %w", err))
+	}
+	return nil
+}
+
+// build creates an aggregator from the options, loads the input and
+// aggregates it, profiling both phases when requested
+func (o *aggregateOptions) build(stdout, stderr io.Writer) (*netjugo.PrefixAggregator, error) {
+	stopProfiles, err := o.profile.start()
+	if err != nil {
+		return nil, withExitCode(exitOutput, err)
+	}
+
+	aggregator, err := o.load(stdout, stderr)
+	if stopErr := stopProfiles(); stopErr != nil && err == nil {
+		err = withExitCode(exitOutput, stopErr)
+	}
+	return aggregator, err
+}
+
+func (o *aggregateOptions) load(stdout, stderr io.Writer) (*netjugo.PrefixAggregator, error) {
+	// Validate minimum prefix lengths
+	if o.minIPv4Len < 0 || o.minIPv4Len > 32 {
+		return nil, withExitCode(exitValidation, fmt.Errorf("invalid IPv4 minimum prefix length: %d (must be 0-32)", o.minIPv4Len))
+	}
+	if o.minIPv6Len < 0 || o.minIPv6Len > 128 {
+		return nil, withExitCode(exitValidation, fmt.Errorf("invalid IPv6 minimum prefix length: %d (must be 0-128)", o.minIPv6Len))
+	}
+
+	csvOpts, err := o.csvOptions()
+	if err != nil {
+		return nil, withExitCode(exitValidation, err)
+	}
+
+	aggregator := netjugo.NewPrefixAggregator()
+	aggregator.SetRejectDefaultRoute(o.rejectDefault)
+	aggregator.SetPointToPointFriendly(o.pointToPoint)
+	if err := aggregator.SetMaxResultPrefixes(o.maxOutput); err != nil {
+		return nil, withExitCode(exitValidation, err)
+	}
+	if err := aggregator.SetMemoryBudget(int64(o.maxMemoryMB) << 20); err != nil {
+		return nil, withExitCode(exitValidation, err)
+	}
+
+	// Set minimum prefix lengths
+	if o.minIPv4Len > 0 || o.minIPv6Len > 0 {
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Setting minimum prefix lengths: IPv4=%d, IPv6=%d\n", o.minIPv4Len, o.minIPv6Len)
+		}
+		if err := aggregator.SetMinPrefixLength(o.minIPv4Len, o.minIPv6Len); err != nil {
+			return nil, withExitCode(exitValidation, fmt.Errorf("failed to set minimum prefix lengths: %w", err))
+		}
+	}
+
+	// Process include prefixes
+	if o.includeFile != "" {
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Loading include prefixes from %s\n", o.includeFile)
+		}
+		includePrefixes, err := readPrefixesFromFile(o.includeFile)
+		if err != nil {
+			return nil, withExitCode(exitInput, fmt.Errorf("failed to read include file: %w", err))
+		}
+		if err := aggregator.SetIncludePrefixes(includePrefixes); err != nil {
+			return nil, withExitCode(exitValidation, fmt.Errorf("failed to set include prefixes: %w", err))
+		}
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Loaded %d include prefixes\n", len(includePrefixes))
+		}
+	}
+
+	if o.includePfx != "" {
+		prefixes := splitPrefixList(o.includePfx)
+		if err := checkPrefixList(prefixes); err != nil {
+			return nil, withExitCode(exitValidation, fmt.Errorf("invalid include prefixes: %w", err))
+		}
+		if err := aggregator.SetIncludePrefixes(prefixes); err != nil {
+			return nil, withExitCode(exitValidation, fmt.Errorf("failed to set include prefixes: %w", err))
+		}
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Added %d include prefixes from command line\n", len(prefixes))
+		}
+	}
+
+	if o.restrictFile != "" {
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Loading restrict prefixes from %s\n", o.restrictFile)
+		}
+		restrictPrefixes, err := readPrefixesFromFile(o.restrictFile)
+		if err != nil {
+			return nil, withExitCode(exitInput, fmt.Errorf("failed to read restrict file: %w", err))
+		}
+		if len(restrictPrefixes) == 0 {
+			return nil, withExitCode(exitValidation, fmt.Errorf("restrict file %s holds no prefixes", o.restrictFile))
+		}
+		if err := aggregator.SetRestrictToPrefixes(restrictPrefixes); err != nil {
+			return nil, withExitCode(exitValidation, fmt.Errorf("failed to set restrict prefixes: %w", err))
+		}
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Loaded %d restrict prefixes\n", len(restrictPrefixes))
+		}
+	}
+
+	// Process exclude prefixes
+	if o.excludeFile != "" {
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Loading exclude prefixes from %s\n", o.excludeFile)
+		}
+		excludePrefixes, excludeRanges, err := readExclusionsFromFile(o.excludeFile)
+		if err != nil {
+			return nil, withExitCode(exitInput, fmt.Errorf("failed to read exclude file: %w", err))
+		}
+		if err := aggregator.SetExcludePrefixes(excludePrefixes); err != nil {
+			return nil, withExitCode(exitValidation, fmt.Errorf("failed to set exclude prefixes: %w", err))
+		}
+		for _, r := range excludeRanges {
+			if err := aggregator.AddExcludeRange(r[0], r[1]); err != nil {
+				return nil, withExitCode(exitValidation, fmt.Errorf("invalid exclude range: %w", err))
+			}
+		}
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Loaded %d exclude prefixes and %d ranges\n", len(excludePrefixes), len(excludeRanges))
+		}
+	}
+
+	if o.excludePfx != "" {
+		prefixes := splitPrefixList(o.excludePfx)
+		if err := checkPrefixList(prefixes); err != nil {
+			return nil, withExitCode(exitValidation, fmt.Errorf("invalid exclude prefixes: %w", err))
+		}
+		if err := aggregator.SetExcludePrefixes(prefixes); err != nil {
+			return nil, withExitCode(exitValidation, fmt.Errorf("failed to set exclude prefixes: %w", err))
+		}
+		if o.verbose {
+			_, _ = fmt.Fprintf(stdout, "Added %d exclude prefixes from command line\n", len(prefixes))
+		}
+	}
+
+	// Load input prefixes
+	if o.verbose {
+		_, _ = fmt.Fprintf(stdout, "Loading prefixes from %s\n", o.inputFiles.String())
+	}
+	results, err := o.loadInput(aggregator, csvOpts)
+	if errors.Is(err, netjugo.ErrMemoryBudgetExceeded) {
+		return nil, withExitCode(exitValidation, fmt.Errorf("failed to load input file: %w", err))
+	}
+	if err != nil {
+		return nil, withExitCode(exitInput, fmt.Errorf("failed to load input file: %w", err))
+	}
+
+	if o.verbose {
+		var loaded netjugo.ReadResult
+		for i, r := range results {
+			if len(results) > 1 && r.Skipped > 0 {
+				_, _ = fmt.Fprintf(stdout, "Skipped %d lines in %s\n", r.Skipped, o.inputFiles[i])
+			}
+			loaded.Added += r.Added
+			loaded.Skipped += r.Skipped
+		}
+		_, _ = fmt.Fprintf(stdout, "Loaded %d prefixes", loaded.Added)
+		if loaded.Skipped > 0 {
+			_, _ = fmt.Fprintf(stdout, ", skipped %d lines", loaded.Skipped)
+		}
+		_, _ = fmt.Fprintln(stdout)
+
+		// Show warnings as they happen
+		aggregator.SetWarningHandler(func(msg string) {
+			_, _ = fmt.Fprintf(stderr, "%s\n", msg)
+		})
+
+		_, _ = fmt.Fprintln(stdout, "Performing aggregation...")
+	}
+	if o.estimate {
+		return aggregator, nil
+	}
+
+	if err := aggregator.Aggregate(); err != nil {
+		return nil, withExitCode(exitValidation, fmt.Errorf("aggregation failed: %w", err))
+	}
+	if o.verbose {
+		printExclusionEffects(stdout, aggregator.GetExclusionEffects())
+	}
+
+	return aggregator, nil
+}
+
+// printExclusionEffects summarizes what the exclusions did and lists the
+// ones that had no effect, which are candidates for deletion
+func printExclusionEffects(w io.Writer, effects []netjugo.ExclusionEffect) {
+	if len(effects) == 0 {
+		return
+	}
+	var matched, removed, created int
+	for _, e := range effects {
+		if e.Matched {
+			matched++
+		}
+		removed += e.PrefixesRemoved
+		created += e.PrefixesCreated
+	}
+	_, _ = fmt.Fprintf(w, "Exclusions: %d of %d matched, removing %d prefixes and creating %d\n", matched, len(effects), removed, created)
+	for _, e := range effects {
+		if e.Matched {
+			continue
+		}
+		if e.Set != "" {
+			_, _ = fmt.Fprintf(w, "  no effect: %s (exclusion set %q)\n", e.Prefix, e.Set)
+		} else {
+			_, _ = fmt.Fprintf(w, "  no effect: %s\n", e.Prefix)
+		}
+	}
+}
+
+func printStats(w io.Writer, stats netjugo.AggregationStats) {
+	_, _ = fmt.Fprintf(w, "\nAggregation Statistics:\n")
+	_, _ = fmt.Fprintf(w, "  Original prefixes: %d\n", stats.OriginalCount)
+	if stats.IncludedCount > 0 {
+		_, _ = fmt.Fprintf(w, "  Included prefixes: %d\n", stats.IncludedCount)
+	}
+	if stats.ExcludedCount > 0 {
+		_, _ = fmt.Fprintf(w, "  Excluded prefixes: %d\n", stats.ExcludedCount)
+	}
+	if stats.SkippedLines > 0 {
+		_, _ = fmt.Fprintf(w, "  Skipped lines: %d\n", stats.SkippedLines)
+		for _, reason := range netjugo.SkipReasons() {
+			if n := stats.SkippedByReason[reason]; n > 0 {
+				_, _ = fmt.Fprintf(w, "    %s: %d\n", reason, n)
+			}
+		}
+	}
+	_, _ = fmt.Fprintf(w, "  Aggregated prefixes: %d\n", stats.TotalPrefixes)
+	_, _ = fmt.Fprintf(w, "  IPv4 prefixes: %d\n", stats.IPv4PrefixCount)
+	_, _ = fmt.Fprintf(w, "  IPv6 prefixes: %d\n", stats.IPv6PrefixCount)
+	_, _ = fmt.Fprintf(w, "  Reduction ratio: %.2f%%\n", stats.ReductionRatio*100)
+	_, _ = fmt.Fprintf(w, "  Processing time: %d ms\n", stats.ProcessingTimeMs)
+	_, _ = fmt.Fprintf(w, "  Memory usage: %s\n", formatBytes(stats.MemoryUsageBytes))
+}
+
+// printEstimate writes an EstimateAggregation result in the style of
+// printStats
+func printEstimate(w io.Writer, e netjugo.EstimateResult) {
+	if e.Exact {
+		_, _ = fmt.Fprintf(w, "Estimated aggregation (exact, the whole input was merged):\n")
+	} else {
+		_, _ = fmt.Fprintf(w, "Estimated aggregation (merged %d of %d IPv4 and %d of %d IPv6 prefixes):\n",
+			e.IPv4.Sampled, e.IPv4.Input, e.IPv6.Sampled, e.IPv6.Input)
+	}
+	line := func(name string, estimate, low, high int) {
+		_, _ = fmt.Fprintf(w, "  %s: %d", name, estimate)
+		if !e.Exact {
+			_, _ = fmt.Fprintf(w, " (95%% interval %d-%d)", low, high)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	_, _ = fmt.Fprintf(w, "  Original prefixes: %d\n", e.IPv4.Input+e.IPv6.Input)
+	line("Aggregated prefixes", e.Total, e.TotalLow, e.TotalHigh)
+	line("IPv4 prefixes", e.IPv4.Estimate, e.IPv4.Low, e.IPv4.High)
+	line("IPv6 prefixes", e.IPv6.Estimate, e.IPv6.Low, e.IPv6.High)
+	_, _ = fmt.Fprintf(w, "  Reduction ratio: %.2f%%\n", e.ReductionRatio*100)
+	_, _ = fmt.Fprintf(w, "  Includes, exclusions and -restrict-to are not part of the estimate\n")
+}
+
+func printTopPrefixes(w io.Writer, top []netjugo.PrefixSize) {
+	width := 0
+	for _, p := range top {
+		width = max(width, len(p.Prefix.String()))
+	}
+
+	_, _ = fmt.Fprintf(w, "\nLargest prefixes:\n")
+	for _, p := range top {
+		_, _ = fmt.Fprintf(w, "  %-*s  %s addresses\n", width, p.Prefix, p.Addresses)
+	}
+}
+
+// printGroups lists the groups in address order, IPv4 first
+func printGroups(w io.Writer, groups map[string]netjugo.GroupStats) {
+	parents := make([]netip.Prefix, 0, len(groups))
+	width := 0
+	for key := range groups {
+		parents = append(parents, netip.MustParsePrefix(key))
+		width = max(width, len(key))
+	}
+	sort.Slice(parents, func(i, j int) bool {
+		return parents[i].Addr().Less(parents[j].Addr())
+	})
+
+	_, _ = fmt.Fprintf(w, "\nPrefixes by parent:\n")
+	for _, parent := range parents {
+		g := groups[parent.String()]
+		_, _ = fmt.Fprintf(w, "  %-*s  %d prefixes, %s addresses\n", width, parent, g.Prefixes, g.Addresses)
+	}
+}
+
+// printSources lists the input files behind each result prefix
+func printSources(w io.Writer, prefixes []string, tags map[string][]string) {
+	width := 0
+	for _, prefix := range prefixes {
+		width = max(width, len(prefix))
+	}
+
+	_, _ = fmt.Fprintf(w, "\nPrefix sources:\n")
+	for _, prefix := range prefixes {
+		_, _ = fmt.Fprintf(w, "  %-*s  %s\n", width, prefix, strings.Join(tags[prefix], ", "))
+	}
+}
+
+func printMemoryStats(w io.Writer, memStats netjugo.MemoryStats) {
+	_, _ = fmt.Fprintf(w, "\nMemory Statistics:\n")
+	_, _ = fmt.Fprintf(w, "  Aggregator memory: %s\n", formatBytes(memStats.AggregatorBytes))
+	_, _ = fmt.Fprintf(w, "    Result: %s\n", formatBytes(memStats.ResultBytes))
+	_, _ = fmt.Fprintf(w, "    Includes: %s\n", formatBytes(memStats.IncludeBytes))
+	_, _ = fmt.Fprintf(w, "    Excludes: %s\n", formatBytes(memStats.ExcludeBytes))
+	_, _ = fmt.Fprintf(w, "    Other: %s\n", formatBytes(memStats.OtherBytes))
+	_, _ = fmt.Fprintf(w, "  System allocation: %s\n", formatBytes(memStats.AllocBytes))
+	_, _ = fmt.Fprintf(w, "  Total allocated: %s\n", formatBytes(memStats.TotalAllocBytes))
+	_, _ = fmt.Fprintf(w, "  System memory: %s\n", formatBytes(memStats.SysBytes))
+	_, _ = fmt.Fprintf(w, "  GC runs: %d\n", memStats.NumGC)
+}
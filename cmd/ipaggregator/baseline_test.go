@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBaseline(t *testing.T) {
+	previous := writeTestFile(t, "previous.txt", "10.0.0.0/22\n2001:db8::/32\n")
+	output := filepath.Join(t.TempDir(), "out.txt")
+
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		code  int
+	}{
+		{"within threshold", "10.0.0.0/22\n10.0.4.0/26\n2001:db8::/32\n", nil, exitOK},
+		{"growth", "10.0.0.0/21\n2001:db8::/32\n", nil, exitValidation},
+		{"shrink", "10.0.0.0/22\n2001:db8::/33\n", nil, exitValidation},
+		{"shrink allowed", "10.0.0.0/22\n2001:db8::/33\n", []string{"-max-change", "50"}, exitOK},
+	}
+	for _, tt := range tests {
+		_ = os.Remove(output)
+		input := writeTestFile(t, "input.txt", tt.input)
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"-input", input, "-baseline", previous, "-output", output}, tt.args...)
+		if code := run(args, &stdout, &stderr); code != tt.code {
+			t.Errorf("%s: run exited %d, want %d: %s", tt.name, code, tt.code, stderr.String())
+			continue
+		}
+		_, statErr := os.Stat(output)
+		if written := statErr == nil; written != (tt.code == exitOK) {
+			t.Errorf("%s: output written is %v", tt.name, written)
+		}
+		if tt.code != exitOK && !strings.Contains(stderr.String(), "coverage") {
+			t.Errorf("%s: expected the change to be reported, got %q", tt.name, stderr.String())
+		}
+	}
+
+	// The first run has no baseline yet
+	input := writeTestFile(t, "input.txt", "10.0.0.0/8\n")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-baseline", filepath.Join(t.TempDir(), "none.txt")}, &stdout, &stderr); code != exitOK {
+		t.Errorf("run exited %d without a baseline file: %s", code, stderr.String())
+	}
+	if code := run([]string{"-input", input, "-baseline", previous, "-max-change", "-1"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("run exited %d for a negative -max-change, want %d", code, exitUsage)
+	}
+}
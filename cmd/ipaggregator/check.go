@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/rretina/netjugo"
+)
+
+func checkUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	_, _ = fmt.Fprintf(w, "Usage: %s check -input <file> [-any] <address> [address...]\n\n", progName)
+	_, _ = fmt.Fprintf(w, "Reports the prefix covering each address, or \"not covered\", one line\n")
+	_, _ = fmt.Fprintf(w, "per address in the order given. Exits non-zero unless every address is\n")
+	_, _ = fmt.Fprintf(w, "covered, or with -any, unless at least one is.\n\n")
+	_, _ = fmt.Fprintf(w, "Options:\n")
+	fs.PrintDefaults()
+}
+
+func runCheck(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("check", stderr, checkUsage)
+	inputFile := fs.String("input", "", "File containing the prefix list to check against")
+	anyCovered := fs.Bool("any", false, "Succeed if at least one address is covered")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputFile == "" {
+		return newUsageError(fs, "input file is required")
+	}
+	if fs.NArg() == 0 {
+		return newUsageError(fs, "at least one address is required")
+	}
+
+	aggregator, err := loadAggregator(*inputFile)
+	if err != nil {
+		return withExitCode(exitInput, fmt.Errorf("failed to load input file: %w", err))
+	}
+
+	covered, err := checkAddresses(aggregator.Snapshot(), fs.Args(), stdout)
+	if err != nil {
+		return withExitCode(exitValidation, err)
+	}
+
+	if *anyCovered {
+		if covered == 0 {
+			return fmt.Errorf("none of %d addresses covered", fs.NArg())
+		}
+		return nil
+	}
+	if uncovered := fs.NArg() - covered; uncovered > 0 {
+		return fmt.Errorf("%d of %d addresses not covered", uncovered, fs.NArg())
+	}
+	return nil
+}
+
+// checkAddresses writes "<address> <prefix>" or "<address> not covered"
+// for each query and returns how many were covered. Every query is parsed
+// before anything is written, so an invalid one produces no output.
+func checkAddresses(set *netjugo.AggregatedSet, queries []string, w io.Writer) (int, error) {
+	addrs := make([]netip.Addr, len(queries))
+	for i, query := range queries {
+		addr, err := netip.ParseAddr(query)
+		if err != nil {
+			return 0, fmt.Errorf("invalid address %q: %w", query, err)
+		}
+		addrs[i] = addr
+	}
+
+	covered := 0
+	for _, addr := range addrs {
+		if prefix, ok := set.LongestPrefixMatch(addr); ok {
+			_, _ = fmt.Fprintf(w, "%s %s\n", addr, prefix)
+			covered++
+		} else {
+			_, _ = fmt.Fprintf(w, "%s not covered\n", addr)
+		}
+	}
+	return covered, nil
+}
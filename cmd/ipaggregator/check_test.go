@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rretina/netjugo"
+)
+
+func TestCheckAddresses(t *testing.T) {
+	pa := netjugo.NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"203.0.113.0/24", "203.0.113.0/28", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	set := pa.Snapshot()
+
+	var out bytes.Buffer
+	covered, err := checkAddresses(set, []string{"203.0.113.9", "2001:db8::5", "198.51.100.1", "2001:db9::1", "203.0.113.200"}, &out)
+	if err != nil {
+		t.Fatalf("checkAddresses failed: %v", err)
+	}
+	if covered != 3 {
+		t.Errorf("Expected 3 covered addresses, got %d", covered)
+	}
+	expected := "203.0.113.9 203.0.113.0/28\n" +
+		"2001:db8::5 2001:db8::/32\n" +
+		"198.51.100.1 not covered\n" +
+		"2001:db9::1 not covered\n" +
+		"203.0.113.200 203.0.113.0/24\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), expected)
+	}
+
+	out.Reset()
+	if _, err := checkAddresses(set, []string{"203.0.113.9", "not-an-ip"}, &out); err == nil {
+		t.Error("Expected an error for an invalid address")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output for an invalid query, got %q", out.String())
+	}
+}
+
+func TestRunCheckAny(t *testing.T) {
+	input := writeTestFile(t, "list.txt", "203.0.113.0/24\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"check", "-input", input, "-any", "198.51.100.1", "203.0.113.9"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("run exited %d with one address covered, want %d", code, exitOK)
+	}
+	if code := run([]string{"check", "-input", input, "198.51.100.1", "203.0.113.9"}, &stdout, &stderr); code == exitOK {
+		t.Error("Expected a failure without -any when an address is not covered")
+	}
+	if code := run([]string{"check", "-input", input, "-any", "198.51.100.1"}, &stdout, &stderr); code == exitOK {
+		t.Error("Expected a failure with -any when no address is covered")
+	}
+	if code := run([]string{"check", "-input", input, "bogus"}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("run exited %d for an invalid address, want %d", code, exitValidation)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/rretina/netjugo"
+)
+
+func diffUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	_, _ = fmt.Fprintf(w, "Usage: %s diff [options] <old-file> <new-file>\n\n", progName)
+	_, _ = fmt.Fprintf(w, "Aggregates both files and prints prefixes only in the old list as \"- prefix\"\n")
+	_, _ = fmt.Fprintf(w, "and prefixes only in the new list as \"+ prefix\".\n\n")
+	_, _ = fmt.Fprintf(w, "Options:\n")
+	fs.PrintDefaults()
+}
+
+func runDiff(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("diff", stderr, diffUsage)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return newUsageError(fs, "exactly two files are required")
+	}
+
+	oldPrefixes, err := loadAggregatedPrefixes(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newPrefixes, err := loadAggregatedPrefixes(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	removed, added := diffPrefixes(oldPrefixes, newPrefixes)
+	writeDiffLines(stdout, removed, added)
+	return nil
+}
+
+// writeDiffLines writes removed as "- prefix" lines, then added as
+// "+ prefix" lines
+func writeDiffLines(w io.Writer, removed, added []string) {
+	for _, p := range removed {
+		_, _ = fmt.Fprintf(w, "- %s\n", p)
+	}
+	for _, p := range added {
+		_, _ = fmt.Fprintf(w, "+ %s\n", p)
+	}
+}
+
+func loadAggregatedPrefixes(path string) ([]string, error) {
+	aggregator, err := loadAggregator(path)
+	if err != nil {
+		return nil, withExitCode(exitInput, fmt.Errorf("failed to load %s: %w", path, err))
+	}
+	if err := aggregator.Aggregate(); err != nil {
+		return nil, withExitCode(exitValidation, fmt.Errorf("failed to aggregate %s: %w", path, err))
+	}
+	return aggregator.GetPrefixes(), nil
+}
+
+// diffPrefixes returns the entries only in oldList and only in newList,
+// each in their original order
+func diffPrefixes(oldList, newList []string) (removed, added []string) {
+	oldSet := make(map[string]bool, len(oldList))
+	for _, p := range oldList {
+		oldSet[p] = true
+	}
+	newSet := make(map[string]bool, len(newList))
+	for _, p := range newList {
+		newSet[p] = true
+	}
+
+	for _, p := range oldList {
+		if !newSet[p] {
+			removed = append(removed, p)
+		}
+	}
+	for _, p := range newList {
+		if !oldSet[p] {
+			added = append(added, p)
+		}
+	}
+	return removed, added
+}
+
+// deltaPrefixes returns what changed from previous to current. Literally,
+// that is the entries only in one of the lists, as diffPrefixes finds
+// them. Semantically, it is the address space only one of them covers, as
+// the fewest prefixes, so covering the same space with different prefixes
+// is no change.
+func deltaPrefixes(previous, current []string, semantic bool) (removed, added []string, err error) {
+	if !semantic {
+		removed, added = diffPrefixes(previous, current)
+		return removed, added, nil
+	}
+	if removed, err = coverageOutside(previous, current); err != nil {
+		return nil, nil, err
+	}
+	if added, err = coverageOutside(current, previous); err != nil {
+		return nil, nil, err
+	}
+	return removed, added, nil
+}
+
+// coverageOutside returns the address space of prefixes that other does
+// not cover, by excluding other from it
+func coverageOutside(prefixes, other []string) ([]string, error) {
+	aggregator := netjugo.NewPrefixAggregator()
+	if err := aggregator.AddPrefixes(prefixes); err != nil {
+		return nil, err
+	}
+	if err := aggregator.SetExcludePrefixes(other); err != nil {
+		return nil, err
+	}
+	if err := aggregator.Aggregate(); err != nil {
+		return nil, err
+	}
+	return aggregator.GetPrefixes(), nil
+}
+
+// writeDelta writes the delta from the output at previousPath to the
+// result of output as diff lines, to path or, if path is empty, to w. A
+// missing previous file counts as empty, so the first run adds everything.
+func writeDelta(output *netjugo.PrefixAggregator, previousPath string, semantic bool, path string, w io.Writer) error {
+	previous, err := loadAggregatedPrefixes(previousPath)
+	if err != nil && !errors.Is(err, netjugo.ErrFileNotFound) {
+		return err
+	}
+	removed, added, err := deltaPrefixes(previous, output.GetPrefixes(), semantic)
+	if err != nil {
+		return withExitCode(exitValidation, fmt.Errorf("failed to compute delta: %w", err))
+	}
+
+	write := func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		writeDiffLines(bw, removed, added)
+		return bw.Flush()
+	}
+	if path == "" {
+		err = write(w)
+	} else {
+		err = writeFileAtomic(path, write)
+	}
+	if err != nil {
+		return withExitCode(exitOutput, fmt.Errorf("failed to write delta: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDeltaPrefixes(t *testing.T) {
+	tests := []struct {
+		name           string
+		previous       []string
+		current        []string
+		semantic       bool
+		removed, added []string
+	}{
+		{"literal resplit", []string{"10.0.0.0/24"}, []string{"10.0.0.0/25", "10.0.0.128/25"}, false,
+			[]string{"10.0.0.0/24"}, []string{"10.0.0.0/25", "10.0.0.128/25"}},
+		{"semantic resplit", []string{"10.0.0.0/24"}, []string{"10.0.0.0/25", "10.0.0.128/25"}, true,
+			nil, nil},
+		{"literal growth", []string{"10.0.0.0/25", "2001:db8::/32"}, []string{"10.0.0.0/24", "2001:db8::/32"}, false,
+			[]string{"10.0.0.0/25"}, []string{"10.0.0.0/24"}},
+		{"semantic growth", []string{"10.0.0.0/25", "2001:db8::/32"}, []string{"10.0.0.0/24", "2001:db8::/32"}, true,
+			nil, []string{"10.0.0.128/25"}},
+		{"semantic shrink", []string{"10.0.0.0/23"}, []string{"10.0.1.0/24", "192.0.2.0/24"}, true,
+			[]string{"10.0.0.0/24"}, []string{"192.0.2.0/24"}},
+		{"semantic from nothing", nil, []string{"192.0.2.0/24"}, true,
+			nil, []string{"192.0.2.0/24"}},
+	}
+	for _, tt := range tests {
+		removed, added, err := deltaPrefixes(tt.previous, tt.current, tt.semantic)
+		if err != nil {
+			t.Errorf("%s: deltaPrefixes failed: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(removed, tt.removed) || !slices.Equal(added, tt.added) {
+			t.Errorf("%s: got removed %v, added %v, want %v, %v", tt.name, removed, added, tt.removed, tt.added)
+		}
+	}
+}
+
+func TestRunDeltaAgainst(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/24\n192.0.2.0/24\n")
+	previous := writeTestFile(t, "previous.txt", "10.0.0.0/25\n10.0.0.128/25\n198.51.100.0/24\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-delta-against", previous, "-semantic"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d: %s", code, stderr.String())
+	}
+	if want := "- 198.51.100.0/24\n+ 192.0.2.0/24\n"; stdout.String() != want {
+		t.Errorf("Unexpected delta:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+
+	// A missing previous output makes everything an addition
+	output := filepath.Join(t.TempDir(), "delta.txt")
+	stdout.Reset()
+	args := []string{"-input", input, "-delta-against", filepath.Join(t.TempDir(), "none.txt"), "-output", output}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d: %s", code, stderr.String())
+	}
+	if data, _ := os.ReadFile(output); string(data) != "+ 10.0.0.0/24\n+ 192.0.2.0/24\n" {
+		t.Errorf("Unexpected delta file %q", data)
+	}
+
+	for _, args := range [][]string{
+		{"-input", input, "-semantic"},
+		{"-input", input, "-delta-against", previous, "-output", output, "-max-lines-per-file", "10"},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitUsage {
+			t.Errorf("%v: run exited %d, want %d", args, code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"net/netip"
+
+	"github.com/rretina/netjugo"
+)
+
+func expandUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	_, _ = fmt.Fprintf(w, "Usage: %s expand -length <bits> <prefix> [prefix...]\n\n", progName)
+	_, _ = fmt.Fprintf(w, "Writes the subnets of the given length that make up each prefix, one per\n")
+	_, _ = fmt.Fprintf(w, "line in address order. They are streamed, so large expansions start at once.\n\n")
+	_, _ = fmt.Fprintf(w, "Options:\n")
+	fs.PrintDefaults()
+	_, _ = fmt.Fprintf(w, "\nExamples:\n")
+	_, _ = fmt.Fprintf(w, "  %s expand -length 24 10.0.0.0/14\n", progName)
+}
+
+func runExpand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("expand", stderr, expandUsage)
+	length := fs.Int("length", -1, "Prefix length of the subnets to write")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *length < 0 {
+		return newUsageError(fs, "-length is required")
+	}
+	if fs.NArg() == 0 {
+		return newUsageError(fs, "at least one prefix is required")
+	}
+
+	// Check every prefix before writing anything
+	expansions := make([]iter.Seq[netip.Prefix], fs.NArg())
+	for i, arg := range fs.Args() {
+		p, err := netip.ParsePrefix(arg)
+		if err != nil {
+			return withExitCode(exitValidation, fmt.Errorf("invalid prefix %q: %w", arg, err))
+		}
+		if expansions[i], err = netjugo.ExpandPrefix(p, *length); err != nil {
+			return withExitCode(exitValidation, err)
+		}
+	}
+
+	w := bufio.NewWriter(stdout)
+	var buf []byte
+	for _, subnets := range expansions {
+		for p := range subnets {
+			buf = append(p.AppendTo(buf[:0]), '\n')
+			if _, err := w.Write(buf); err != nil {
+				return withExitCode(exitOutput, fmt.Errorf("failed to write subnets: %w", err))
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return withExitCode(exitOutput, fmt.Errorf("failed to write subnets: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+
+	"github.com/rretina/netjugo"
+)
+
+// stdin is where filter reads addresses, replaced in tests
+var stdin io.Reader = os.Stdin
+
+func filterUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	_, _ = fmt.Fprintf(w, "Usage: %s filter -input <file> [-matching | -not-matching]\n\n", progName)
+	_, _ = fmt.Fprintf(w, "Reads one address per line from stdin and writes the lines whose address\n")
+	_, _ = fmt.Fprintf(w, "is covered by the prefix list, or with -not-matching those it is not.\n")
+	_, _ = fmt.Fprintf(w, "IPv4-mapped IPv6 addresses are matched as IPv4. Lines that are not\n")
+	_, _ = fmt.Fprintf(w, "addresses are never written; their count is reported on stderr.\n\n")
+	_, _ = fmt.Fprintf(w, "Options:\n")
+	fs.PrintDefaults()
+	_, _ = fmt.Fprintf(w, "\nExamples:\n")
+	_, _ = fmt.Fprintf(w, "  zcat access.log.gz | cut -f1 | %s filter -input blocklist.txt\n", progName)
+}
+
+func runFilter(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("filter", stderr, filterUsage)
+	inputFile := fs.String("input", "", "File containing the prefix list to filter against")
+	matching := fs.Bool("matching", false, "Write the lines whose address is covered (the default)")
+	notMatching := fs.Bool("not-matching", false, "Write the lines whose address is not covered")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputFile == "" {
+		return newUsageError(fs, "input file is required")
+	}
+	if *matching && *notMatching {
+		return newUsageError(fs, "-matching and -not-matching cannot be combined")
+	}
+	if fs.NArg() > 0 {
+		return newUsageError(fs, "addresses are read from stdin, not the command line")
+	}
+
+	aggregator, err := loadAggregator(*inputFile)
+	if err != nil {
+		return withExitCode(exitInput, fmt.Errorf("failed to load input file: %w", err))
+	}
+	if err := aggregator.Aggregate(); err != nil {
+		return withExitCode(exitValidation, fmt.Errorf("failed to aggregate input file: %w", err))
+	}
+
+	_, skipped, err := filterAddresses(aggregator.BuildIndex(), stdin, stdout, !*notMatching)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		_, _ = fmt.Fprintf(stderr, "filter: skipped %d lines that are not addresses\n", skipped)
+	}
+	return nil
+}
+
+// filterAddresses copies the lines of r whose address idx covers to w, or
+// with matching unset those it does not cover, and returns how many lines
+// were written and how many were not addresses
+func filterAddresses(idx *netjugo.PrefixIndex, r io.Reader, w io.Writer, matching bool) (written, skipped int, err error) {
+	scanner := bufio.NewScanner(r)
+	bw := bufio.NewWriter(w)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		field := bytes.TrimSpace(line)
+		if len(field) == 0 {
+			continue
+		}
+		addr, err := netip.ParseAddr(string(field))
+		if err != nil {
+			skipped++
+			continue
+		}
+		if idx.Contains(addr.Unmap()) != matching {
+			continue
+		}
+
+		_, _ = bw.Write(line)
+		if err := bw.WriteByte('\n'); err != nil {
+			return written, skipped, withExitCode(exitOutput, fmt.Errorf("failed to write matches: %w", err))
+		}
+		written++
+	}
+	if err := scanner.Err(); err != nil {
+		return written, skipped, withExitCode(exitInput, fmt.Errorf("failed to read addresses: %w", err))
+	}
+	if err := bw.Flush(); err != nil {
+		return written, skipped, withExitCode(exitOutput, fmt.Errorf("failed to write matches: %w", err))
+	}
+	return written, skipped, nil
+}
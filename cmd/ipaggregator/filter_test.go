@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/rretina/netjugo"
+)
+
+func TestFilterAddresses(t *testing.T) {
+	list, err := netjugo.Generate(netjugo.GenerateOptions{Count: 2000, Seed: 7, IPv6Ratio: 0.3, IPv4Lengths: []int{8, 16, 24}, IPv6Lengths: []int{16, 32}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	pa := netjugo.NewPrefixAggregator()
+	if err := pa.AddPrefixes(list); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	idx, set := pa.BuildIndex(), pa.Snapshot()
+
+	// A candidate stream of random addresses, the odd blank or junk line
+	// and IPv4-mapped addresses, with the expected output built from the
+	// Snapshot lookup
+	rng := rand.New(rand.NewPCG(7, 0))
+	var input, matches, misses strings.Builder
+	for i := 0; i < 20000; i++ {
+		var addr netip.Addr
+		if i%3 == 0 {
+			var b [16]byte
+			b[0] = 0x20
+			b[1] = byte(rng.IntN(256))
+			for j := 2; j < 16; j++ {
+				b[j] = byte(rng.IntN(256))
+			}
+			addr = netip.AddrFrom16(b)
+		} else {
+			addr = netip.AddrFrom4([4]byte{byte(rng.IntN(256)), byte(rng.IntN(256)), byte(rng.IntN(256)), byte(rng.IntN(256))})
+		}
+		line := addr.String()
+		if i%1000 == 1 && addr.Is4() {
+			line = "::ffff:" + line
+		}
+		fmt.Fprintf(&input, "%s\n", line)
+		if set.Contains(addr) {
+			fmt.Fprintf(&matches, "%s\n", line)
+		} else {
+			fmt.Fprintf(&misses, "%s\n", line)
+		}
+		if i%5000 == 0 {
+			input.WriteString("\nnot-an-address\n")
+		}
+	}
+	if matches.Len() == 0 || misses.Len() == 0 {
+		t.Fatal("Expected the stream to have both covered and uncovered addresses")
+	}
+
+	for _, tt := range []struct {
+		matching bool
+		want     string
+	}{{true, matches.String()}, {false, misses.String()}} {
+		var out bytes.Buffer
+		written, skipped, err := filterAddresses(idx, strings.NewReader(input.String()), &out, tt.matching)
+		if err != nil {
+			t.Fatalf("matching %v: filterAddresses failed: %v", tt.matching, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("matching %v: output differs from the Snapshot lookups", tt.matching)
+		}
+		if written != strings.Count(tt.want, "\n") || skipped != 4 {
+			t.Errorf("matching %v: got %d written and %d skipped", tt.matching, written, skipped)
+		}
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	input := writeTestFile(t, "blocklist.txt", "203.0.113.0/25\n203.0.113.128/25\n2001:db8::/32\n")
+	defer func(r io.Reader) { stdin = r }(stdin)
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "203.0.113.9\n2001:db8::5\n"},
+		{[]string{"--matching"}, "203.0.113.9\n2001:db8::5\n"},
+		{[]string{"--not-matching"}, "198.51.100.1\n"},
+	} {
+		stdin = strings.NewReader("203.0.113.9\n198.51.100.1\nbogus\n2001:db8::5\n")
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"filter", "-input", input}, tt.args...)
+		if code := run(args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("%v: run exited %d: %s", tt.args, code, stderr.String())
+		}
+		if stdout.String() != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, stdout.String(), tt.want)
+		}
+		if !strings.Contains(stderr.String(), "skipped 1 lines") {
+			t.Errorf("%v: expected the skipped line to be reported, got %q", tt.args, stderr.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"filter", "-input", input, "-matching", "-not-matching"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("run exited %d for both modes, want %d", code, exitUsage)
+	}
+}
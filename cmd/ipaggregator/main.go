@@ -1,238 +1,261 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/rretina/netjugo"
 )
 
-func main() {
-	// Command line flags
-	var (
-		inputFile   = flag.String("input", "", "Input file containing IP prefixes (one per line)")
-		outputFile  = flag.String("output", "", "Output file for aggregated prefixes (default: stdout)")
-		minIPv4Len  = flag.Int("min-ipv4", 0, "Minimum IPv4 prefix length (0-32)")
-		minIPv6Len  = flag.Int("min-ipv6", 0, "Minimum IPv6 prefix length (0-128)")
-		includeFile = flag.String("include", "", "File containing prefixes to include")
-		excludeFile = flag.String("exclude", "", "File containing prefixes to exclude")
-		includePfx  = flag.String("include-prefix", "", "Comma-separated list of prefixes to include")
-		excludePfx  = flag.String("exclude-prefix", "", "Comma-separated list of prefixes to exclude")
-		showStats   = flag.Bool("stats", false, "Show aggregation statistics")
-		showMemory  = flag.Bool("memory", false, "Show memory usage statistics")
-		verbose     = flag.Bool("verbose", false, "Verbose output")
-		version     = flag.Bool("version", false, "Show version information")
-	)
+// command is a single ipaggregator subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) error
+}
 
-	flag.Usage = func() {
-		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
-		_, _ = fmt.Fprintf(os.Stderr, "IP Prefix Aggregation Tool\n")
-		_, _ = fmt.Fprintf(os.Stderr, "Aggregates IPv4 and IPv6 CIDR prefixes with support for minimum lengths,\n")
-		_, _ = fmt.Fprintf(os.Stderr, "inclusion/exclusion constraints, and optimal aggregation quality.\n\n")
-		_, _ = fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-		_, _ = fmt.Fprintf(os.Stderr, "\nExamples:\n")
-		_, _ = fmt.Fprintf(os.Stderr, "  %s -input prefixes.txt -output aggregated.txt -stats\n", os.Args[0])
-		_, _ = fmt.Fprintf(os.Stderr, "  %s -input large.txt -min-ipv4 24 -min-ipv6 48 -verbose\n", os.Args[0])
-		_, _ = fmt.Fprintf(os.Stderr, "  %s -input base.txt -include include.txt -exclude exclude.txt\n", os.Args[0])
-		_, _ = fmt.Fprintf(os.Stderr, "  %s -input prefixes.txt -exclude-prefix '192.168.1.0/24,10.0.0.0/24'\n", os.Args[0])
-		_, _ = fmt.Fprintf(os.Stderr, "\nInput Format:\n")
-		_, _ = fmt.Fprintf(os.Stderr, "  One IP prefix per line in CIDR notation (e.g., 192.168.1.0/24, 2001:db8::/32)\n")
-		_, _ = fmt.Fprintf(os.Stderr, "  Comments (lines starting with #) and empty lines are ignored\n")
-		_, _ = fmt.Fprintf(os.Stderr, "  IPv4 addresses without /xx will be treated as /32\n")
-		_, _ = fmt.Fprintf(os.Stderr, "  IPv6 addresses without /xx will be treated as /128\n")
-	}
+var commands = []command{
+	{"aggregate", "Aggregate prefixes from a file (default command)", runAggregate},
+	{"check", "Check whether addresses are covered by a prefix list", runCheck},
+	{"filter", "Write the addresses from stdin that a prefix list covers", runFilter},
+	{"diff", "Show prefixes that differ between two aggregated lists", runDiff},
+	{"stats", "Print aggregation statistics without writing output", runStats},
+	{"generate", "Write a reproducible synthetic prefix list", runGenerate},
+	{"expand", "Write the subnets of a given length that make up a prefix", runExpand},
+}
 
-	flag.Parse()
+var progName = filepath.Base(os.Args[0])
 
-	if *version {
-		fmt.Println("IP Aggregator v1.0.0")
-		fmt.Println("High-performance Go library for IP prefix aggregation")
-		fmt.Println("Supports IPv4/IPv6, minimum prefix lengths, inclusion/exclusion")
-		return
-	}
+// Process exit codes
+const (
+	exitOK         = 0
+	exitUsage      = 1 // invalid usage or general failure
+	exitInput      = 2 // input file missing or unreadable
+	exitValidation = 3 // invalid prefixes, settings or aggregation failure
+	exitOutput     = 4 // output could not be written
+	exitWarnings   = 5 // warnings or skipped lines with -fail-on-warning
+)
 
-	if *inputFile == "" {
-		_, _ = fmt.Fprintf(os.Stderr, "Error: input file is required\n\n")
-		flag.Usage()
-		os.Exit(1)
-	}
+// exitError attaches a process exit code to an error
+type exitError struct {
+	code int
+	err  error
+}
 
-	// Validate minimum prefix lengths
-	if *minIPv4Len < 0 || *minIPv4Len > 32 {
-		log.Fatalf("Invalid IPv4 minimum prefix length: %d (must be 0-32)", *minIPv4Len)
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
 	}
-	if *minIPv6Len < 0 || *minIPv6Len > 128 {
-		log.Fatalf("Invalid IPv6 minimum prefix length: %d (must be 0-128)", *minIPv6Len)
+	return &exitError{code: code, err: err}
+}
+
+// exitCode maps an error returned by a command to a process exit code
+func exitCode(err error) int {
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
 	}
+	return exitUsage
+}
 
-	// Create aggregator
-	aggregator := netjugo.NewPrefixAggregator()
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	// Set minimum prefix lengths
-	if *minIPv4Len > 0 || *minIPv6Len > 0 {
-		if *verbose {
-			fmt.Printf("Setting minimum prefix lengths: IPv4=%d, IPv6=%d\n", *minIPv4Len, *minIPv6Len)
-		}
-		if err := aggregator.SetMinPrefixLength(*minIPv4Len, *minIPv6Len); err != nil {
-			log.Fatalf("Failed to set minimum prefix lengths: %v", err)
-		}
+// run dispatches to a subcommand and returns the process exit code. When
+// the first argument is a flag (or missing) the aggregate command is used,
+// which keeps the original flat flag interface working.
+func run(args []string, stdout, stderr io.Writer) int {
+	name := "aggregate"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	// Process include prefixes
-	if *includeFile != "" {
-		if *verbose {
-			fmt.Printf("Loading include prefixes from %s\n", *includeFile)
-		}
-		includePrefixes, err := readPrefixesFromFile(*includeFile)
-		if err != nil {
-			log.Fatalf("Failed to read include file: %v", err)
-		}
-		if err := aggregator.SetIncludePrefixes(includePrefixes); err != nil {
-			log.Fatalf("Failed to set include prefixes: %v", err)
-		}
-		if *verbose {
-			fmt.Printf("Loaded %d include prefixes\n", len(includePrefixes))
-		}
+	if name == "help" {
+		usage(stderr)
+		return exitOK
 	}
 
-	if *includePfx != "" {
-		prefixes := strings.Split(*includePfx, ",")
-		for i := range prefixes {
-			prefixes[i] = strings.TrimSpace(prefixes[i])
-		}
-		if err := aggregator.SetIncludePrefixes(prefixes); err != nil {
-			log.Fatalf("Failed to set include prefixes: %v", err)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
 		}
-		if *verbose {
-			fmt.Printf("Added %d include prefixes from command line\n", len(prefixes))
+		if err := cmd.run(args, stdout, stderr); err != nil {
+			var ue *usageError
+			switch {
+			case errors.Is(err, flag.ErrHelp):
+				return exitOK
+			case errors.As(err, &ue):
+				_, _ = fmt.Fprintf(stderr, "Error: %s\n\n", ue.msg)
+				ue.fs.Usage()
+				return exitUsage
+			default:
+				printError(stderr, err)
+				return exitCode(err)
+			}
 		}
+		return exitOK
 	}
 
-	// Process exclude prefixes
-	if *excludeFile != "" {
-		if *verbose {
-			fmt.Printf("Loading exclude prefixes from %s\n", *excludeFile)
-		}
-		excludePrefixes, err := readPrefixesFromFile(*excludeFile)
-		if err != nil {
-			log.Fatalf("Failed to read exclude file: %v", err)
-		}
-		if err := aggregator.SetExcludePrefixes(excludePrefixes); err != nil {
-			log.Fatalf("Failed to set exclude prefixes: %v", err)
-		}
-		if *verbose {
-			fmt.Printf("Loaded %d exclude prefixes\n", len(excludePrefixes))
-		}
+	_, _ = fmt.Fprintf(stderr, "Error: unknown command %q\n\n", name)
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "Usage: %s <command> [options]\n\n", progName)
+	_, _ = fmt.Fprintf(w, "IP Prefix Aggregation Tool\n\n")
+	_, _ = fmt.Fprintf(w, "Commands:\n")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
+	_, _ = fmt.Fprintf(w, "\nRun '%s <command> -h' for command options.\n", progName)
+	_, _ = fmt.Fprintf(w, "Without a command, options are passed to 'aggregate'.\n")
+	_, _ = fmt.Fprintf(w, "\nExit Codes:\n")
+	_, _ = fmt.Fprintf(w, "  %d  success\n", exitOK)
+	_, _ = fmt.Fprintf(w, "  %d  usage error or general failure\n", exitUsage)
+	_, _ = fmt.Fprintf(w, "  %d  input file missing or unreadable\n", exitInput)
+	_, _ = fmt.Fprintf(w, "  %d  invalid prefixes, settings or aggregation failure\n", exitValidation)
+	_, _ = fmt.Fprintf(w, "  %d  output could not be written\n", exitOutput)
+	_, _ = fmt.Fprintf(w, "  %d  warnings or skipped lines with -fail-on-warning\n", exitWarnings)
+}
 
-	if *excludePfx != "" {
-		prefixes := strings.Split(*excludePfx, ",")
-		for i := range prefixes {
-			prefixes[i] = strings.TrimSpace(prefixes[i])
-		}
-		if err := aggregator.SetExcludePrefixes(prefixes); err != nil {
-			log.Fatalf("Failed to set exclude prefixes: %v", err)
-		}
-		if *verbose {
-			fmt.Printf("Added %d exclude prefixes from command line\n", len(prefixes))
+// usageError reports invalid command line usage; the command's usage
+// text is printed after the message
+type usageError struct {
+	msg string
+	fs  *flag.FlagSet
+}
+
+// printError writes err to w, followed by one line per entry when it
+// wraps a *netjugo.MultiError
+func printError(w io.Writer, err error) {
+	_, _ = fmt.Fprintf(w, "Error: %v\n", err)
+	var me *netjugo.MultiError
+	if errors.As(err, &me) && len(me.Errors) > 1 {
+		for _, e := range me.Errors {
+			_, _ = fmt.Fprintf(w, "  %v\n", e)
 		}
 	}
+}
 
-	// Load input prefixes
-	if *verbose {
-		fmt.Printf("Loading prefixes from %s\n", *inputFile)
-	}
-	if err := aggregator.AddFromFile(*inputFile); err != nil {
-		log.Fatalf("Failed to load input file: %v", err)
-	}
+func newUsageError(fs *flag.FlagSet, format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...), fs: fs}
+}
 
-	initialStats := aggregator.GetStats()
-	if *verbose {
-		fmt.Printf("Loaded %d prefixes (%d IPv4, %d IPv6)\n",
-			initialStats.OriginalCount, initialStats.IPv4PrefixCount, initialStats.IPv6PrefixCount)
-	}
+func (e *usageError) Error() string {
+	return e.msg
+}
 
-	// Set up warning handler for verbose mode
-	if *verbose {
-		aggregator.SetWarningHandler(func(msg string) {
-			if _, err := fmt.Fprintf(os.Stderr, "%s\n", msg); err != nil {
-				log.Printf("Failed to write warning: %v", err)
-			}
-		})
-	}
+// newFlagSet creates a subcommand flag set that reports errors instead of
+// exiting, so commands can be invoked directly from tests
+func newFlagSet(name string, stderr io.Writer, usageFn func(fs *flag.FlagSet)) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usageFn(fs) }
+	return fs
+}
 
-	// Perform aggregation
-	if *verbose {
-		fmt.Println("Performing aggregation...")
-	}
-	if err := aggregator.Aggregate(); err != nil {
-		log.Fatalf("Aggregation failed: %v", err)
+// loadAggregator reads a prefix file into a new aggregator
+func loadAggregator(path string) (*netjugo.PrefixAggregator, error) {
+	aggregator := netjugo.NewPrefixAggregator()
+	if err := aggregator.AddFromFile(path); err != nil {
+		return nil, err
 	}
+	return aggregator, nil
+}
 
-	// Get final statistics
-	finalStats := aggregator.GetStats()
+func readPrefixesFromFile(filename string) ([]string, error) {
+	// Create a temporary aggregator to leverage the existing file reading logic
+	tempAggregator, err := loadAggregator(filename)
+	if err != nil {
+		return nil, err
+	}
+	return tempAggregator.GetPrefixes(), nil
+}
 
-	// Show warnings if not in verbose mode (verbose mode shows them real-time)
-	if !*verbose {
-		warnings := aggregator.GetWarnings()
-		for _, warning := range warnings {
-			if _, err := fmt.Fprintf(os.Stderr, "%s\n", warning); err != nil {
-				log.Printf("Failed to write warning: %v", err)
-			}
+// readExclusionsFromFile is readPrefixesFromFile for exclusion files, which
+// may also hold "start-end" address ranges, one per line
+func readExclusionsFromFile(filename string) (prefixes []string, ranges [][2]string, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("%w: %s", netjugo.ErrFileNotFound, filename)
 		}
+		return nil, nil, fmt.Errorf("failed to read file %s: %w", filename, err)
 	}
 
-	// Write output
-	if *outputFile != "" {
-		if err := aggregator.WriteToFile(*outputFile); err != nil {
-			log.Fatalf("Failed to write output file: %v", err)
-		}
-		if *verbose {
-			fmt.Printf("Wrote %d aggregated prefixes to %s\n", finalStats.TotalPrefixes, *outputFile)
-		}
-	} else {
-		// Write to stdout
-		if err := aggregator.WriteToWriter(os.Stdout); err != nil {
-			log.Fatalf("Failed to write to stdout: %v", err)
+	var rest strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		if start, end, ok := parseExclusionRange(line); ok {
+			ranges = append(ranges, [2]string{start, end})
+			continue
 		}
+		rest.WriteString(line)
+		rest.WriteByte('\n')
 	}
 
-	// Show statistics
-	if *showStats || *verbose {
-		_, _ = fmt.Fprintf(os.Stderr, "\nAggregation Statistics:\n")
-		_, _ = fmt.Fprintf(os.Stderr, "  Original prefixes: %d\n", finalStats.OriginalCount)
-		_, _ = fmt.Fprintf(os.Stderr, "  Aggregated prefixes: %d\n", finalStats.TotalPrefixes)
-		_, _ = fmt.Fprintf(os.Stderr, "  IPv4 prefixes: %d\n", finalStats.IPv4PrefixCount)
-		_, _ = fmt.Fprintf(os.Stderr, "  IPv6 prefixes: %d\n", finalStats.IPv6PrefixCount)
-		_, _ = fmt.Fprintf(os.Stderr, "  Reduction ratio: %.2f%%\n", finalStats.ReductionRatio*100)
-		_, _ = fmt.Fprintf(os.Stderr, "  Processing time: %d ms\n", finalStats.ProcessingTimeMs)
-		_, _ = fmt.Fprintf(os.Stderr, "  Memory usage: %s\n", formatBytes(finalStats.MemoryUsageBytes))
+	tempAggregator := netjugo.NewPrefixAggregator()
+	if err := tempAggregator.AddFromReader(strings.NewReader(rest.String())); err != nil {
+		return nil, nil, err
+	}
+	return tempAggregator.GetPrefixes(), ranges, nil
+}
+
+// parseExclusionRange returns the ends of a "start-end" line. Comments are
+// cut first, where the loader cuts them: at a # starting the line or
+// following whitespace. Only a single dash between two addresses makes a
+// range; any other line is left to the loader.
+func parseExclusionRange(line string) (start, end string, ok bool) {
+	line = strings.TrimSpace(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+			break
+		}
 	}
 
-	// Show memory statistics
-	if *showMemory {
-		memStats := aggregator.GetMemoryStats()
-		_, _ = fmt.Fprintf(os.Stderr, "\nMemory Statistics:\n")
-		_, _ = fmt.Fprintf(os.Stderr, "  Aggregator memory: %s\n", formatBytes(memStats.AggregatorBytes))
-		_, _ = fmt.Fprintf(os.Stderr, "  System allocation: %s\n", formatBytes(memStats.AllocBytes))
-		_, _ = fmt.Fprintf(os.Stderr, "  Total allocated: %s\n", formatBytes(memStats.TotalAllocBytes))
-		_, _ = fmt.Fprintf(os.Stderr, "  System memory: %s\n", formatBytes(memStats.SysBytes))
-		_, _ = fmt.Fprintf(os.Stderr, "  GC runs: %d\n", memStats.NumGC)
+	start, end, ok = strings.Cut(line, "-")
+	if !ok || strings.Contains(end, "-") {
+		return "", "", false
 	}
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if _, err := netip.ParseAddr(start); err != nil {
+		return "", "", false
+	}
+	if _, err := netip.ParseAddr(end); err != nil {
+		return "", "", false
+	}
+	return start, end, true
 }
 
-func readPrefixesFromFile(filename string) ([]string, error) {
-	// Create a temporary aggregator to leverage the existing file reading logic
-	tempAggregator := netjugo.NewPrefixAggregator()
-	if err := tempAggregator.AddFromFile(filename); err != nil {
-		return nil, err
+// checkPrefixList reports every invalid entry in prefixes at once, so a
+// command-line list with several typos can be fixed in one go
+func checkPrefixList(prefixes []string) error {
+	return netjugo.NewPrefixAggregator().AddPrefixes(prefixes)
+}
+
+func splitPrefixList(list string) []string {
+	prefixes := strings.Split(list, ",")
+	for i := range prefixes {
+		prefixes[i] = strings.TrimSpace(prefixes[i])
 	}
-	return tempAggregator.GetPrefixes(), nil
+	return prefixes
 }
 
 func formatBytes(bytes int64) string {
@@ -0,0 +1,686 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestRunAggregate(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "192.168.0.0/24\n192.168.1.0/24\n2001:db8::/32\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := runAggregate([]string{"-input", input}, &stdout, &stderr); err != nil {
+		t.Fatalf("runAggregate failed: %v (stderr: %s)", err, stderr.String())
+	}
+
+	expected := "192.168.0.0/23\n2001:db8::/32\n"
+	if stdout.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", stdout.String(), expected)
+	}
+}
+
+func TestRunDefaultsToAggregate(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/25\n10.0.0.128/25\n")
+	output := filepath.Join(t.TempDir(), "out.txt")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-output", output}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if string(data) != "10.0.0.0/24\n" {
+		t.Errorf("Unexpected output file content: %q", data)
+	}
+}
+
+func TestRunAggregateChunkedOutput(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/24\n10.0.2.0/24\n10.0.4.0/24\n")
+	output := filepath.Join(t.TempDir(), "out.txt")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-input", input, "-output", output, "-max-lines-per-file", "2"}
+	if err := runAggregate(args, &stdout, &stderr); err != nil {
+		t.Fatalf("runAggregate failed: %v (stderr: %s)", err, stderr.String())
+	}
+
+	dir := filepath.Dir(output)
+	for name, want := range map[string]string{
+		"out-001.txt": "10.0.0.0/24\n10.0.2.0/24\n",
+		"out-002.txt": "10.0.4.0/24\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"missing input", nil, "input file is required"},
+		{"unknown command", []string{"bogus"}, "unknown command"},
+		{"chunking needs output", []string{"-input", "x.txt", "-max-lines-per-file", "5"}, "requires -output"},
+		{"diff needs two files", []string{"diff", "one.txt"}, "exactly two files"},
+		{"check needs addresses", []string{"check", "-input", "x.txt"}, "at least one address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code == 0 {
+				t.Errorf("Expected non-zero exit code")
+			}
+			if !strings.Contains(stderr.String(), tt.want) {
+				t.Errorf("Expected stderr to contain %q, got:\n%s", tt.want, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	for _, args := range [][]string{{"help"}, {"aggregate", "-h"}, {"check", "-h"}, {"diff", "-h"}, {"stats", "-h"}, {"generate", "-h"}, {"expand", "-h"}} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != 0 {
+			t.Errorf("run(%v) exited %d", args, code)
+		}
+		if !strings.Contains(stderr.String(), "Usage:") {
+			t.Errorf("run(%v) printed no usage text", args)
+		}
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	input := writeTestFile(t, "list.txt", "203.0.113.0/24\n2001:db8::/32\n")
+
+	var stdout, stderr bytes.Buffer
+	err := runCheck([]string{"-input", input, "203.0.113.9", "2001:db8::5"}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("runCheck failed: %v", err)
+	}
+	expected := "203.0.113.9 203.0.113.0/24\n2001:db8::5 2001:db8::/32\n"
+	if stdout.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", stdout.String(), expected)
+	}
+
+	stdout.Reset()
+	err = runCheck([]string{"-input", input, "198.51.100.1"}, &stdout, &stderr)
+	if err == nil {
+		t.Error("Expected an error for an uncovered address")
+	}
+	if stdout.String() != "198.51.100.1 not covered\n" {
+		t.Errorf("Unexpected output: %q", stdout.String())
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	oldFile := writeTestFile(t, "old.txt", "10.0.0.0/24\n10.0.1.0/24\n192.168.0.0/24\n")
+	newFile := writeTestFile(t, "new.txt", "10.0.0.0/23\n172.16.0.0/12\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := runDiff([]string{oldFile, newFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("runDiff failed: %v", err)
+	}
+
+	expected := "- 192.168.0.0/24\n+ 172.16.0.0/12\n"
+	if stdout.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", stdout.String(), expected)
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/24\n10.0.1.0/24\nbogus\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := runStats([]string{"-input", input}, &stdout, &stderr); err != nil {
+		t.Fatalf("runStats failed: %v", err)
+	}
+
+	for _, want := range []string{"Original prefixes: 2", "Aggregated prefixes: 1", "Skipped lines: 1\n    parse-error: 1\n"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected stats output to contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	valid := writeTestFile(t, "valid.txt", "192.168.0.0/16\n")
+	withJunk := writeTestFile(t, "junk.txt", "192.168.0.0/16\nnot-a-prefix\n")
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	unwritable := filepath.Join(t.TempDir(), "no-such-dir", "out.txt")
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"success", []string{"-input", valid, "-output", filepath.Join(t.TempDir(), "out.txt")}, exitOK},
+		{"usage", []string{"-no-such-flag"}, exitUsage},
+		{"missing input", []string{"-input", missing}, exitInput},
+		{"missing exclude file", []string{"-input", valid, "-exclude", missing}, exitInput},
+		{"invalid min length", []string{"-input", valid, "-min-ipv4", "40"}, exitValidation},
+		{"invalid exclude prefix", []string{"-input", valid, "-exclude-prefix", "10.0.0.0/99"}, exitValidation},
+		{"result too large", []string{"-input", valid, "-exclude-prefix", "192.168.0.1/32", "-max-output", "5"}, exitValidation},
+		{"negative max output", []string{"-input", valid, "-max-output", "-1"}, exitValidation},
+		{"negative memory budget", []string{"-input", valid, "-max-memory-mb", "-1"}, exitValidation},
+		{"within memory budget", []string{"-input", valid, "-max-memory-mb", "64"}, exitOK},
+		{"result within limit", []string{"-input", valid, "-exclude-prefix", "192.168.0.0/17", "-max-output", "5"}, exitOK},
+		{"output failure", []string{"-input", valid, "-output", unwritable}, exitOutput},
+		{"warning without flag", []string{"-input", valid, "-exclude-prefix", "192.168.1.1/32"}, exitOK},
+		{"warning with flag", []string{"-input", valid, "-exclude-prefix", "192.168.1.1/32", "-fail-on-warning"}, exitWarnings},
+		{"skipped line with flag", []string{"-input", withJunk, "-fail-on-warning"}, exitWarnings},
+		{"clean with flag", []string{"-input", valid, "-fail-on-warning"}, exitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != tt.code {
+				t.Errorf("run(%v) = %d, want %d (stderr: %s)", tt.args, code, tt.code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunVerboseLoadCounts(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/24\nnot-a-prefix\n10.0.1.0/24\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-verbose"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Loaded 2 prefixes, skipped 1 lines") {
+		t.Errorf("Expected load counts in verbose output, got:\n%s", stdout.String())
+	}
+}
+
+func TestRunMultipleInputs(t *testing.T) {
+	a := writeTestFile(t, "a.txt", "10.0.0.0/24\n")
+	b := writeTestFile(t, "b.txt", "10.0.1.0/24\n")
+	c := writeTestFile(t, "c.txt", "10.0.2.0/23\n")
+
+	for _, args := range [][]string{
+		{"-input", a, "-input", b, "-input", c},
+		{"-input", a + "," + b + ", " + c},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("run(%v) exited %d (stderr: %s)", args, code, stderr.String())
+		}
+		if stdout.String() != "10.0.0.0/22\n" {
+			t.Errorf("run(%v) output %q, want %q", args, stdout.String(), "10.0.0.0/22\n")
+		}
+	}
+}
+
+func TestRunVerifyAgainst(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/24\n10.0.1.0/24\n")
+	feed := writeTestFile(t, "feed.txt", "10.0.0.0/23\n10.0.1.7\n")
+	wider := writeTestFile(t, "wider.txt", "10.0.0.0/23\n10.0.2.0/24\n192.0.2.1\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-verify-against", feed}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"-input", input, "-verify-against", wider}, &stdout, &stderr); code != exitValidation {
+		t.Fatalf("run exited %d, want %d (stderr: %s)", code, exitValidation, stderr.String())
+	}
+	for _, want := range []string{"not covered: 10.0.2.0/24", "not covered: 192.0.2.1/32", "2 of 3 prefixes"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected stderr to contain %q, got:\n%s", want, stderr.String())
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output after a failed verification, got:\n%s", stdout.String())
+	}
+}
+
+func TestRunExcludeRanges(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.20.0.0/16\n")
+	excludes := writeTestFile(t, "excludes.txt", "# abuse report - 2024\n10.20.30.40 - 10.20.31.7\n10.20.128.0/17\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := runAggregate([]string{"-input", input, "-exclude", excludes}, &stdout, &stderr); err != nil {
+		t.Fatalf("runAggregate failed: %v (stderr: %s)", err, stderr.String())
+	}
+
+	expected := "10.20.0.0/20\n10.20.16.0/21\n10.20.24.0/22\n10.20.28.0/23\n10.20.30.0/27\n10.20.30.32/29\n" +
+		"10.20.31.8/29\n10.20.31.16/28\n10.20.31.32/27\n10.20.31.64/26\n10.20.31.128/25\n10.20.32.0/19\n10.20.64.0/18\n"
+	if stdout.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", stdout.String(), expected)
+	}
+
+	// Comments and extra columns, dashes and all, load as they always did
+	for _, tt := range []struct{ name, content, want string }{
+		{"inline comment", "10.20.0.1 # corp-net\n10.20.30.40 - 10.20.31.7 # abuse-desk\n10.20.128.0/17\n",
+			"10.20.0.0/32\n10.20.0.2/31\n10.20.0.4/30\n10.20.0.8/29\n10.20.0.16/28\n10.20.0.32/27\n10.20.0.64/26\n10.20.0.128/25\n" +
+				"10.20.1.0/24\n10.20.2.0/23\n10.20.4.0/22\n10.20.8.0/21\n10.20.16.0/21\n10.20.24.0/22\n10.20.28.0/23\n10.20.30.0/27\n10.20.30.32/29\n" +
+				"10.20.31.8/29\n10.20.31.16/28\n10.20.31.32/27\n10.20.31.64/26\n10.20.31.128/25\n10.20.32.0/19\n10.20.64.0/18\n"},
+		{"dated column", "10.20.128.0/17 added 2024-01-01\n10.20.0.0/17\n", ""},
+	} {
+		file := writeTestFile(t, "excludes.txt", tt.content)
+		stdout.Reset()
+		stderr.Reset()
+		if code := run([]string{"-input", input, "-exclude", file}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("%s: run exited %d: %s", tt.name, code, stderr.String())
+		}
+		if stdout.String() != tt.want {
+			t.Errorf("%s: unexpected output:\n%s\nwant:\n%s", tt.name, stdout.String(), tt.want)
+		}
+	}
+
+	bad := writeTestFile(t, "bad.txt", "10.20.31.7-10.20.30.40\n")
+	if code := run([]string{"-input", input, "-exclude", bad}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("run exited %d, want %d for a reversed range", code, exitValidation)
+	}
+}
+
+func TestRunRestrictTo(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "0.0.0.0/1\n128.0.0.0/1\n2001:db8::/32\n")
+	allowed := writeTestFile(t, "allocations.txt", "# our allocations\n10.1.0.0/16\n172.16.0.0/16\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := runAggregate([]string{"-input", input, "-restrict-to", allowed, "-exclude-prefix", "10.1.0.0/17"}, &stdout, &stderr); err != nil {
+		t.Fatalf("runAggregate failed: %v (stderr: %s)", err, stderr.String())
+	}
+	if want := "10.1.128.0/17\n172.16.0.0/16\n"; stdout.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+
+	empty := writeTestFile(t, "empty.txt", "# nothing yet\n")
+	if code := run([]string{"-input", input, "-restrict-to", empty}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("run exited %d, want %d for an empty restrict file", code, exitValidation)
+	}
+}
+
+func TestRunPointToPoint(t *testing.T) {
+	input := writeTestFile(t, "links.txt", "10.255.0.0/24\n")
+	for _, tt := range []struct {
+		args []string
+		warn bool
+	}{{nil, true}, {[]string{"-point-to-point"}, false}} {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"-input", input, "-exclude-prefix", "10.255.0.4/31"}, tt.args...)
+		if code := run(args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("%v: run exited %d: %s", tt.args, code, stderr.String())
+		}
+		if warned := strings.Contains(stderr.String(), "more specific than recommended"); warned != tt.warn {
+			t.Errorf("%v: warned is %v, stderr %q", tt.args, warned, stderr.String())
+		}
+	}
+}
+
+func TestRunExclusionEffects(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/8\n192.0.2.0/24\n")
+	output := filepath.Join(t.TempDir(), "out.txt")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-input", input, "-output", output, "-verbose", "-exclude-prefix", "10.0.0.0/9,192.0.2.0/24,203.0.113.0/24"}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d: %s", code, stderr.String())
+	}
+	want := "Exclusions: 2 of 3 matched, removing 2 prefixes and creating 1\n  no effect: 203.0.113.0/24\n"
+	if !strings.Contains(stdout.String(), want) {
+		t.Errorf("Expected %q in the verbose output:\n%s", want, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run(args[:5], &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "Exclusions:") {
+		t.Errorf("Expected no exclusion summary without exclusions:\n%s", stdout.String())
+	}
+}
+
+func TestRunEstimate(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/25\n10.0.0.128/25\n192.0.2.0/24\n2001:db8::/32\n")
+	output := filepath.Join(t.TempDir(), "out.txt")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-estimate", "-output", output}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{"exact", "Original prefixes: 4\n", "Aggregated prefixes: 3\n", "IPv4 prefixes: 2\n", "Reduction ratio: 25.00%"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected %q in the estimate:\n%s", want, stdout.String())
+		}
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Expected -estimate not to write the output, got %v", err)
+	}
+
+	if code := run([]string{"-input", input, "-estimate", "-watch", "-output", output}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("run exited %d for -estimate with -watch, want %d", code, exitUsage)
+	}
+}
+
+func TestRunWithin(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "203.0.113.0/28\n203.0.113.64/26\n198.51.0.0/16\n2001:db8::/32\n")
+
+	var stdout, stderr bytes.Buffer
+	if err := runAggregate([]string{"-input", input, "-within", "203.0.113.0/24"}, &stdout, &stderr); err != nil {
+		t.Fatalf("runAggregate failed: %v (stderr: %s)", err, stderr.String())
+	}
+	if stdout.String() != "203.0.113.0/28\n203.0.113.64/26\n" {
+		t.Errorf("Unexpected output:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if err := runAggregate([]string{"-input", input, "-within", "198.51.100.0/24"}, &stdout, &stderr); err != nil {
+		t.Fatalf("runAggregate failed: %v (stderr: %s)", err, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output, got:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "overlaps 198.51.100.0/24: 198.51.0.0/16") {
+		t.Errorf("Expected the straddling prefix on stderr, got:\n%s", stderr.String())
+	}
+
+	if code := run([]string{"-input", input, "-within", "bogus"}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("run exited %d, want %d for an invalid supernet", code, exitValidation)
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/24\n10.0.1.0/24\nbogus\n2001:db8::/32\n")
+	report := filepath.Join(t.TempDir(), "report.json")
+
+	var stdout, stderr bytes.Buffer
+	if err := runAggregate([]string{"-input", input, "-report", report}, &stdout, &stderr); err != nil {
+		t.Fatalf("runAggregate failed: %v (stderr: %s)", err, stderr.String())
+	}
+
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	var doc struct {
+		Stats struct {
+			TotalPrefixes int `json:"total_prefixes"`
+		} `json:"stats"`
+		Top          []struct{ Prefix string } `json:"top_prefixes"`
+		SkippedLines int                       `json:"skipped_lines"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Report is not valid JSON: %v\n%s", err, data)
+	}
+	if doc.Stats.TotalPrefixes != 2 || doc.SkippedLines != 1 || len(doc.Top) != 2 || doc.Top[0].Prefix != "2001:db8::/32" {
+		t.Errorf("Unexpected report:\n%s", data)
+	}
+}
+
+func TestRunTopPrefixes(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "192.168.1.0/24\n10.0.0.0/8\n172.16.0.0/16\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-top", "2"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	expected := "\nLargest prefixes:\n  10.0.0.0/8     16777216 addresses\n  172.16.0.0/16  65536 addresses\n"
+	if stderr.String() != expected {
+		t.Errorf("Unexpected stderr:\n%q\nwant:\n%q", stderr.String(), expected)
+	}
+}
+
+func TestRunGroupBy(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "11.0.0.0/16\n10.0.0.0/24\n10.1.0.0/16\n2001:db8::/48\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-group-by", "8,16"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	expected := "\nPrefixes by parent:\n" +
+		"  10.0.0.0/8  2 prefixes, 65792 addresses\n" +
+		"  11.0.0.0/8  1 prefixes, 65536 addresses\n" +
+		"  2001::/16   1 prefixes, 1208925819614629174706176 addresses\n"
+	if stderr.String() != expected {
+		t.Errorf("Unexpected stderr:\n%q\nwant:\n%q", stderr.String(), expected)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"-input", input, "-group-by", "24,16"}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("run exited %d for a grouping longer than a result, want %d", code, exitValidation)
+	}
+	if code := run([]string{"-input", input, "-group-by", "8"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("run exited %d for a single length, want %d", code, exitUsage)
+	}
+}
+
+func TestRunReportsEveryInvalidPrefix(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "192.168.0.0/16\n")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-input", input, "-exclude-prefix", "10.0.0.0/99, 192.168.1.0/24, bogus"}
+	if code := run(args, &stdout, &stderr); code != exitValidation {
+		t.Fatalf("run exited %d, want %d", code, exitValidation)
+	}
+	for _, want := range []string{"2 invalid entries", "entry 0: ", "entry 2: "} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected stderr to contain %q, got:\n%s", want, stderr.String())
+		}
+	}
+}
+
+func TestRunDefaultRoute(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "0.0.0.0/1\n128.0.0.0/1\n")
+
+	// The warning is shown without -verbose
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "default route 0.0.0.0/0") {
+		t.Errorf("Expected a default route warning, got stderr:\n%s", stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"-input", input, "-reject-default-route"}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("run exited %d, want %d", code, exitValidation)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output for a rejected result, got %q", stdout.String())
+	}
+}
+
+func TestRunFailOnEmpty(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "192.168.1.0/24\n")
+	output := filepath.Join(t.TempDir(), "out.txt")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-exclude-prefix", "192.168.1.0/24"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "result is empty") {
+		t.Errorf("Expected an empty result warning, got stderr:\n%s", stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"-input", input, "-exclude-prefix", "192.168.1.0/24", "-fail-on-empty", "-output", output}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("run exited %d, want %d", code, exitValidation)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Expected no output file for a refused result, stat returned %v", err)
+	}
+}
+
+func TestRunOutputHeader(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/25\n10.0.0.128/25\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-header"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "# Generated by netjugo ") || lines[1] != "# IPv4 prefixes: 2 in, 1 out" || lines[4] != "10.0.0.0/24" {
+		t.Errorf("Unexpected output with -header:\n%s", stdout.String())
+	}
+}
+
+func TestRunCSVInput(t *testing.T) {
+	input := writeTestFile(t, "feed.csv", "id,cidr,note\n1,10.0.0.0/25,\"a, b\"\n2,10.0.0.128/25,c\n3,bogus,d\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-format", "csv", "-csv-column", "CIDR"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	if stdout.String() != "10.0.0.0/24\n" {
+		t.Errorf("Unexpected output by column name: %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-input", input, "-format", "csv", "-csv-column", "2", "-csv-header"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	if stdout.String() != "10.0.0.0/24\n" {
+		t.Errorf("Unexpected output by column number: %q", stdout.String())
+	}
+
+	for _, args := range [][]string{
+		{"-format", "xml"},
+		{"-format", "csv", "-csv-column", "0"},
+		{"-csv-column", "cidr"},
+		{"-format", "csv", "-csv-column", "prefix"},
+	} {
+		code := run(append([]string{"-input", input}, args...), &stdout, &stderr)
+		if code != exitValidation && code != exitInput {
+			t.Errorf("%v: run exited %d, want an error", args, code)
+		}
+	}
+}
+
+func TestRunSources(t *testing.T) {
+	feedA := writeTestFile(t, "feed-a.txt", "10.0.0.0/24\n192.0.2.0/24\n")
+	feedB := writeTestFile(t, "feed-b.txt", "10.0.1.0/24\nbogus\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", feedA + "," + feedB, "-sources", "-verbose"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	for _, want := range []string{"10.0.0.0/23   feed-a.txt, feed-b.txt\n", "192.0.2.0/24  feed-a.txt\n"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected %q in stderr, got:\n%s", want, stderr.String())
+		}
+	}
+	if !strings.Contains(stdout.String(), "Skipped 1 lines in "+feedB) {
+		t.Errorf("Expected the skipped line to be reported per file, got:\n%s", stdout.String())
+	}
+}
+
+func TestRunProfiles(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/25\n10.0.0.128/25\n")
+	dir := t.TempDir()
+	cpu := filepath.Join(dir, "cpu.pprof")
+	mem := filepath.Join(dir, "mem.pprof")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-input", input, "-cpuprofile", cpu, "-memprofile", mem}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	for _, path := range []string{cpu, mem} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("Expected non-empty profile at %s (err: %v)", path, err)
+		}
+	}
+
+	// An unwritable profile path fails before the input is read
+	stdout.Reset()
+	stderr.Reset()
+	args = []string{"-input", input, "-memprofile", filepath.Join(dir, "missing", "mem.pprof")}
+	if code := run(args, &stdout, &stderr); code != exitOutput {
+		t.Errorf("run exited %d, want %d", code, exitOutput)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output after profile failure, got %q", stdout.String())
+	}
+}
+
+func TestRunGenerate(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "generated.txt")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"generate", "-count", "50", "-seed", "9", "-ipv6-ratio", "0.5", "-output", output}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 50 {
+		t.Errorf("Expected 50 lines, got %d", lines)
+	}
+
+	// The same seed reproduces the same list on stdout
+	if code := run(args[:len(args)-2], &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	if stdout.String() != string(data) {
+		t.Error("Same seed produced different output")
+	}
+
+	stderr.Reset()
+	if code := run([]string{"generate", "-ipv4-lengths", "24,x"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected usage error for bad lengths, got exit %d", code)
+	}
+	if code := run([]string{"generate", "-overlap", "2"}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("Expected validation error for bad overlap, got exit %d", code)
+	}
+}
+
+func TestRunExpand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"expand", "-length", "24", "10.0.0.0/22", "2001:db8::/127"}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("Expected a validation error for a length outside a family, got exit %d", code)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected nothing written before the error, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"expand", "-length", "24", "10.0.0.0/22", "192.0.2.0/24"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+	want := "10.0.0.0/24\n10.0.1.0/24\n10.0.2.0/24\n10.0.3.0/24\n192.0.2.0/24\n"
+	if stdout.String() != want {
+		t.Errorf("Unexpected output %q, want %q", stdout.String(), want)
+	}
+
+	for _, args := range [][]string{{"expand", "10.0.0.0/22"}, {"expand", "-length", "24"}} {
+		if code := run(args, &stdout, &stderr); code != exitUsage {
+			t.Errorf("%v: run exited %d, want %d", args, code, exitUsage)
+		}
+	}
+	if code := run([]string{"expand", "-length", "24", "bogus"}, &stdout, &stderr); code != exitValidation {
+		t.Errorf("Expected a validation error for an invalid prefix, got exit %d", code)
+	}
+}
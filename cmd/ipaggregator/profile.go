@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/rretina/netjugo/internal/profile"
+)
+
+// profileOptions holds the pprof flags
+type profileOptions struct {
+	cpuProfile string
+	memProfile string
+}
+
+func (o *profileOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.cpuProfile, "cpuprofile", "", "Write a CPU profile of the load and aggregate phases to file")
+	fs.StringVar(&o.memProfile, "memprofile", "", "Write a heap profile taken after the aggregate phase to file")
+}
+
+// start begins the requested profiles; the returned function must always
+// be called to stop them
+func (o *profileOptions) start() (func() error, error) {
+	return profile.Start(o.cpuProfile, o.memProfile)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+func statsUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	_, _ = fmt.Fprintf(w, "Usage: %s stats [options]\n\n", progName)
+	_, _ = fmt.Fprintf(w, "Aggregates the input and prints statistics without writing the result.\n\n")
+	_, _ = fmt.Fprintf(w, "Options:\n")
+	fs.PrintDefaults()
+}
+
+func runStats(args []string, stdout, stderr io.Writer) error {
+	var opts aggregateOptions
+	var statsOpts statsOutputOptions
+
+	fs := newFlagSet("stats", stderr, statsUsage)
+	opts.register(fs)
+	statsOpts.register(fs)
+	showMemory := fs.Bool("memory", false, "Show memory usage statistics")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(opts.inputFiles) == 0 {
+		return newUsageError(fs, "input file is required")
+	}
+	if err := statsOpts.validate(fs); err != nil {
+		return err
+	}
+
+	aggregator, err := opts.build(stderr, stderr)
+	if err != nil {
+		return err
+	}
+
+	return withExitCode(exitOutput, statsOpts.write(stdout, aggregator, *showMemory))
+}
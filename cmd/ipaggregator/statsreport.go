@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rretina/netjugo"
+)
+
+// familyCounts is a per-family prefix count in the JSON stats output
+type familyCounts struct {
+	IPv4  int `json:"ipv4"`
+	IPv6  int `json:"ipv6"`
+	Total int `json:"total"`
+}
+
+// memoryReport is the JSON form of netjugo.MemoryStats
+type memoryReport struct {
+	AggregatorBytes int64 `json:"aggregator_bytes"`
+	ResultBytes     int64 `json:"result_bytes"`
+	IncludeBytes    int64 `json:"include_bytes"`
+	ExcludeBytes    int64 `json:"exclude_bytes"`
+	OtherBytes      int64 `json:"other_bytes"`
+	AllocBytes      int64 `json:"alloc_bytes"`
+	TotalAllocBytes int64 `json:"total_alloc_bytes"`
+	SysBytes        int64 `json:"sys_bytes"`
+	NumGC           int64 `json:"num_gc"`
+}
+
+// statsReport is the machine-readable summary written by -stats-format json
+type statsReport struct {
+	Version          string       `json:"version"`
+	Original         familyCounts `json:"original"`
+	Final            familyCounts `json:"final"`
+	Included         int          `json:"included"`
+	Excluded         int          `json:"excluded"`
+	ReductionRatio   float64      `json:"reduction_ratio"`
+	ProcessingTimeMs int64        `json:"processing_time_ms"`
+	MemoryUsageBytes int64        `json:"memory_usage_bytes"`
+	Warnings         int          `json:"warnings"`
+	SkippedLines     int          `json:"skipped_lines"`
+	// SkippedByReason holds the non-zero skip counts by reason
+	SkippedByReason map[string]int `json:"skipped_by_reason,omitempty"`
+	Memory          *memoryReport  `json:"memory,omitempty"`
+}
+
+func newStatsReport(stats netjugo.AggregationStats, memStats *netjugo.MemoryStats, warnings int) statsReport {
+	report := statsReport{
+		Version: netjugo.Version(),
+		Original: familyCounts{
+			IPv4:  stats.OriginalIPv4Count,
+			IPv6:  stats.OriginalIPv6Count,
+			Total: stats.OriginalCount,
+		},
+		Final: familyCounts{
+			IPv4:  stats.IPv4PrefixCount,
+			IPv6:  stats.IPv6PrefixCount,
+			Total: stats.TotalPrefixes,
+		},
+		Included:         stats.IncludedCount,
+		Excluded:         stats.ExcludedCount,
+		ReductionRatio:   stats.ReductionRatio,
+		ProcessingTimeMs: stats.ProcessingTimeMs,
+		MemoryUsageBytes: stats.MemoryUsageBytes,
+		Warnings:         warnings,
+		SkippedLines:     stats.SkippedLines,
+	}
+	for _, reason := range netjugo.SkipReasons() {
+		if n := stats.SkippedByReason[reason]; n > 0 {
+			if report.SkippedByReason == nil {
+				report.SkippedByReason = make(map[string]int)
+			}
+			report.SkippedByReason[reason.String()] = n
+		}
+	}
+
+	if memStats != nil {
+		report.Memory = &memoryReport{
+			AggregatorBytes: memStats.AggregatorBytes,
+			ResultBytes:     memStats.ResultBytes,
+			IncludeBytes:    memStats.IncludeBytes,
+			ExcludeBytes:    memStats.ExcludeBytes,
+			OtherBytes:      memStats.OtherBytes,
+			AllocBytes:      memStats.AllocBytes,
+			TotalAllocBytes: memStats.TotalAllocBytes,
+			SysBytes:        memStats.SysBytes,
+			NumGC:           memStats.NumGC,
+		}
+	}
+
+	return report
+}
+
+// formatStatsJSON renders the stats as a single-line JSON object
+func formatStatsJSON(stats netjugo.AggregationStats, memStats *netjugo.MemoryStats, warnings int) ([]byte, error) {
+	data, err := json.Marshal(newStatsReport(stats, memStats, warnings))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode stats: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// statsOutputOptions selects how and where statistics are written
+type statsOutputOptions struct {
+	format string
+	output string
+}
+
+func (o *statsOutputOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.format, "stats-format", "text", "Statistics format: text or json")
+	fs.StringVar(&o.output, "stats-output", "", "Write statistics to this file instead of stderr")
+}
+
+func (o *statsOutputOptions) validate(fs *flag.FlagSet) error {
+	if o.format != "text" && o.format != "json" {
+		return newUsageError(fs, "invalid -stats-format %q (must be text or json)", o.format)
+	}
+	return nil
+}
+
+// requested reports whether the options alone ask for statistics output
+func (o *statsOutputOptions) requested() bool {
+	return o.format == "json" || o.output != ""
+}
+
+// write renders the statistics to the configured output, falling back to w
+func (o *statsOutputOptions) write(w io.Writer, aggregator *netjugo.PrefixAggregator, showMemory bool) error {
+	if o.output != "" {
+		file, err := os.Create(o.output)
+		if err != nil {
+			return fmt.Errorf("failed to create stats output file: %w", err)
+		}
+		defer func(file *os.File) {
+			_ = file.Close()
+		}(file)
+		w = file
+	}
+
+	stats := aggregator.GetStats()
+	var memStats *netjugo.MemoryStats
+	if showMemory {
+		m := aggregator.GetMemoryStats()
+		memStats = &m
+	}
+
+	if o.format == "json" {
+		data, err := formatStatsJSON(stats, memStats, stats.TotalWarnings)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write stats: %w", err)
+		}
+		return nil
+	}
+
+	printStats(w, stats)
+	if memStats != nil {
+		printMemoryStats(w, *memStats)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rretina/netjugo"
+)
+
+func TestFormatStatsJSONSchema(t *testing.T) {
+	stats := netjugo.AggregationStats{
+		IPv4PrefixCount:   2,
+		IPv6PrefixCount:   1,
+		TotalPrefixes:     3,
+		OriginalCount:     10,
+		OriginalIPv4Count: 7,
+		OriginalIPv6Count: 3,
+		SkippedLines:      4,
+		ReductionRatio:    0.7,
+		ProcessingTimeMs:  12,
+		MemoryUsageBytes:  2048,
+	}
+
+	data, err := formatStatsJSON(stats, nil, 5)
+	if err != nil {
+		t.Fatalf("formatStatsJSON failed: %v", err)
+	}
+	if bytes.Count(data, []byte("\n")) != 1 {
+		t.Errorf("Expected a single JSON line, got %q", data)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+
+	for _, key := range []string{"original", "final"} {
+		counts, ok := decoded[key].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Expected %q to be an object, got %T", key, decoded[key])
+		}
+		for _, field := range []string{"ipv4", "ipv6", "total"} {
+			if _, ok := counts[field].(float64); !ok {
+				t.Errorf("Expected %s.%s to be a number, got %v", key, field, counts[field])
+			}
+		}
+	}
+
+	expected := map[string]float64{
+		"reduction_ratio":    0.7,
+		"processing_time_ms": 12,
+		"memory_usage_bytes": 2048,
+		"warnings":           5,
+		"skipped_lines":      4,
+		"included":           0,
+		"excluded":           0,
+	}
+	for key, want := range expected {
+		if got, ok := decoded[key].(float64); !ok || got != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, decoded[key])
+		}
+	}
+
+	if decoded["version"] != netjugo.Version() {
+		t.Errorf("Expected version %q, got %v", netjugo.Version(), decoded["version"])
+	}
+	if _, ok := decoded["memory"]; ok {
+		t.Error("Memory section should be omitted when memory stats were not requested")
+	}
+	if decoded["original"].(map[string]interface{})["ipv4"].(float64) != 7 {
+		t.Errorf("Unexpected original.ipv4: %v", decoded["original"])
+	}
+
+	data, err = formatStatsJSON(stats, &netjugo.MemoryStats{AggregatorBytes: 99}, 0)
+	if err != nil {
+		t.Fatalf("formatStatsJSON failed: %v", err)
+	}
+	var withMemory statsReport
+	if err := json.Unmarshal(data, &withMemory); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if withMemory.Memory == nil || withMemory.Memory.AggregatorBytes != 99 {
+		t.Errorf("Expected memory section with aggregator_bytes 99, got %+v", withMemory.Memory)
+	}
+}
+
+func TestRunAggregateJSONStatsFile(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/24\n10.0.1.0/24\nbogus\n2001:db8::/32\n")
+	statsFile := filepath.Join(t.TempDir(), "stats.json")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-input", input, "-stats-format", "json", "-stats-output", statsFile, "-memory"}
+	if err := runAggregate(args, &stdout, &stderr); err != nil {
+		t.Fatalf("runAggregate failed: %v", err)
+	}
+
+	data, err := os.ReadFile(statsFile)
+	if err != nil {
+		t.Fatalf("Failed to read stats file: %v", err)
+	}
+
+	var report statsReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Stats file is not valid JSON: %v", err)
+	}
+	if report.Original.IPv4 != 2 || report.Original.IPv6 != 1 || report.Final.IPv4 != 1 {
+		t.Errorf("Unexpected counts: %+v", report)
+	}
+	if report.SkippedLines != 1 || report.SkippedByReason["parse-error"] != 1 || len(report.SkippedByReason) != 1 {
+		t.Errorf("Expected 1 skipped line, a parse error, got %d %v", report.SkippedLines, report.SkippedByReason)
+	}
+	if report.Memory == nil {
+		t.Fatal("Expected memory section with -memory")
+	}
+	m := report.Memory
+	if m.ResultBytes <= 0 || m.ResultBytes+m.IncludeBytes+m.ExcludeBytes+m.OtherBytes != m.AggregatorBytes {
+		t.Errorf("Expected the breakdown to add up to aggregator_bytes, got %+v", m)
+	}
+}
+
+func TestRunAggregateInvalidStatsFormat(t *testing.T) {
+	input := writeTestFile(t, "input.txt", "10.0.0.0/24\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-stats-format", "xml"}, &stdout, &stderr); code == 0 {
+		t.Error("Expected non-zero exit code for invalid -stats-format")
+	}
+}
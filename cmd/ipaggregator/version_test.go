@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/rretina/netjugo"
+)
+
+func TestRunVersion(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-version"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d: %s", code, stderr.String())
+	}
+	want := "IP Aggregator v" + netjugo.Version() + ", built with " + runtime.Version() + "\n"
+	if !strings.HasPrefix(stdout.String(), want) {
+		t.Errorf("Got %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestVersionLdflags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the CLI")
+	}
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	bin := filepath.Join(t.TempDir(), "ipaggregator")
+	build := exec.Command(gotool, "build", "-o", bin, "-ldflags", "-X github.com/rretina/netjugo.version=9.8.7-test", ".")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+	out, err := exec.Command(bin, "-version").Output()
+	if err != nil {
+		t.Fatalf("-version failed: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "IP Aggregator v9.8.7-test, built with go") {
+		t.Errorf("Expected the stamped version, got %q", out)
+	}
+}
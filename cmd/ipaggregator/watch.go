@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/rretina/netjugo"
+)
+
+// clock is the time source of the watch loop, replaced in tests
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// fileState is what the watcher compares between polls
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// watcher polls the input files and calls run once they have changed and
+// stayed unchanged for the debounce interval, so a file that is still
+// being written is not read half-way
+type watcher struct {
+	paths    []string
+	interval time.Duration
+	debounce time.Duration
+	clock    clock
+	stat     func(path string) (os.FileInfo, error)
+	run      func() (netjugo.AggregationStats, error)
+	log      io.Writer
+
+	last      []fileState
+	changedAt time.Time
+	pending   bool
+	missing   string
+	cycles    int
+}
+
+func newWatcher(paths []string, interval, debounce time.Duration, run func() (netjugo.AggregationStats, error), log io.Writer) *watcher {
+	return &watcher{
+		paths:    paths,
+		interval: interval,
+		debounce: debounce,
+		clock:    realClock{},
+		stat:     os.Stat,
+		run:      run,
+		log:      log,
+	}
+}
+
+// watch runs once, then polls every interval until ctx is done. Failed
+// cycles are logged and leave the previous output in place.
+func (w *watcher) watch(ctx context.Context) error {
+	w.start()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-w.clock.After(w.interval):
+			w.poll(now)
+		}
+	}
+}
+
+// start takes the first snapshot and runs the first cycle, if every input
+// exists
+func (w *watcher) start() {
+	now := w.clock.Now()
+	w.last = w.snapshot()
+	w.pending = true
+	w.changedAt = now.Add(-w.debounce)
+	w.poll(now)
+}
+
+// poll handles one tick: it records a change, or runs a cycle once the
+// last change is older than the debounce interval and every input exists
+func (w *watcher) poll(now time.Time) {
+	if current := w.snapshot(); !slices.Equal(current, w.last) {
+		w.last = current
+		w.changedAt = now
+		w.pending = true
+	}
+	if !w.pending || now.Sub(w.changedAt) < w.debounce {
+		return
+	}
+
+	for i, state := range w.last {
+		if !state.exists {
+			if w.missing != w.paths[i] {
+				_, _ = fmt.Fprintf(w.log, "watch: waiting for %s to reappear\n", w.paths[i])
+				w.missing = w.paths[i]
+			}
+			return
+		}
+	}
+	w.missing = ""
+	w.pending = false
+
+	w.cycles++
+	stats, err := w.run()
+	if err != nil {
+		_, _ = fmt.Fprintf(w.log, "watch: cycle %d failed, keeping the previous output: %v\n", w.cycles, err)
+		return
+	}
+	_, _ = fmt.Fprintf(w.log, "watch: cycle %d: %d prefixes aggregated to %d, %d lines skipped, %d ms\n",
+		w.cycles, stats.OriginalCount, stats.TotalPrefixes, stats.SkippedLines, stats.ProcessingTimeMs)
+}
+
+func (w *watcher) snapshot() []fileState {
+	states := make([]fileState, len(w.paths))
+	for i, path := range w.paths {
+		if info, err := w.stat(path); err == nil {
+			states[i] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return states
+}
+
+// writeFileAtomic writes path through a temporary file in the same
+// directory, renamed into place once complete, so readers never see a
+// partial file
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rretina/netjugo"
+)
+
+type fakeClock struct {
+	now   time.Time
+	ticks chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time                       { return c.now }
+func (c *fakeClock) After(time.Duration) <-chan time.Time { return c.ticks }
+
+type fakeInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i fakeInfo) Size() int64        { return i.size }
+func (i fakeInfo) ModTime() time.Time { return time.Time{} }
+
+// fakeInputs is a set of input files and sizes for the watcher to stat
+type fakeInputs map[string]int64
+
+func (f fakeInputs) stat(path string) (os.FileInfo, error) {
+	size, ok := f[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return fakeInfo{size: size}, nil
+}
+
+func TestWatcherPoll(t *testing.T) {
+	inputs := fakeInputs{"feed.txt": 10}
+	start := time.Unix(1000, 0)
+	clock := &fakeClock{now: start}
+	var log bytes.Buffer
+	runs := 0
+	fail := false
+
+	w := newWatcher([]string{"feed.txt"}, time.Second, 2*time.Second, func() (netjugo.AggregationStats, error) {
+		runs++
+		if fail {
+			return netjugo.AggregationStats{}, errors.New("bad input")
+		}
+		return netjugo.AggregationStats{TotalPrefixes: 1}, nil
+	}, &log)
+	w.clock, w.stat = clock, inputs.stat
+
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	expectRuns := func(step string, want int) {
+		t.Helper()
+		if runs != want {
+			t.Fatalf("%s: expected %d runs, got %d", step, want, runs)
+		}
+	}
+
+	w.start()
+	expectRuns("start", 1)
+	w.poll(at(1))
+	expectRuns("unchanged", 1)
+
+	// Rapid successive writes restart the debounce interval
+	inputs["feed.txt"] = 20
+	w.poll(at(2))
+	inputs["feed.txt"] = 30
+	w.poll(at(3))
+	w.poll(at(4))
+	expectRuns("still settling", 1)
+	w.poll(at(5))
+	expectRuns("settled", 2)
+
+	// A missing input is waited for, and logged once
+	delete(inputs, "feed.txt")
+	w.poll(at(6))
+	w.poll(at(8))
+	w.poll(at(9))
+	expectRuns("missing", 2)
+	if n := strings.Count(log.String(), "waiting for feed.txt"); n != 1 {
+		t.Errorf("Expected one waiting message, got %d:\n%s", n, log.String())
+	}
+	inputs["feed.txt"] = 30
+	w.poll(at(10))
+	w.poll(at(12))
+	expectRuns("reappeared", 3)
+
+	// A failed cycle is logged and does not stop the watcher
+	fail = true
+	inputs["feed.txt"] = 40
+	w.poll(at(13))
+	w.poll(at(15))
+	expectRuns("failing", 4)
+	if !strings.Contains(log.String(), "cycle 4 failed, keeping the previous output: bad input") {
+		t.Errorf("Expected the failure to be logged, got:\n%s", log.String())
+	}
+	if !strings.Contains(log.String(), "cycle 3: ") {
+		t.Errorf("Expected successful cycles to log their stats, got:\n%s", log.String())
+	}
+}
+
+func TestWatcherLoop(t *testing.T) {
+	input := writeTestFile(t, "feed.txt", "10.0.0.0/25\n10.0.0.128/25\n")
+	output := filepath.Join(t.TempDir(), "out.txt")
+	clock := &fakeClock{now: time.Now(), ticks: make(chan time.Time)}
+	cycles := make(chan struct{}, 10)
+
+	var stderr bytes.Buffer
+	w := newWatcher([]string{input}, time.Second, 0, func() (netjugo.AggregationStats, error) {
+		defer func() { cycles <- struct{}{} }()
+		pa := netjugo.NewPrefixAggregator()
+		if err := pa.AddFromFile(input); err != nil {
+			return netjugo.AggregationStats{}, err
+		}
+		stats, err := pa.AggregateStats()
+		if err != nil {
+			return stats, err
+		}
+		return stats, writeFileAtomic(output, pa.WriteToWriter)
+	}, &stderr)
+	w.clock = clock
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.watch(ctx) }()
+
+	<-cycles
+	if data, _ := os.ReadFile(output); string(data) != "10.0.0.0/24\n" {
+		t.Errorf("Unexpected first output %q", data)
+	}
+
+	if err := os.WriteFile(input, []byte("192.0.2.0/24\n"), 0o644); err != nil {
+		t.Fatalf("Failed to rewrite input: %v", err)
+	}
+	clock.ticks <- time.Now()
+	<-cycles
+	if data, _ := os.ReadFile(output); string(data) != "192.0.2.0/24\n" {
+		t.Errorf("Unexpected output after the change %q", data)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watch returned %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(output))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the output file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestRunWatchUsage(t *testing.T) {
+	input := writeTestFile(t, "feed.txt", "10.0.0.0/24\n")
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{
+		{"-input", input, "-watch"},
+		{"-input", input, "-watch", "-output", "out.txt", "-max-lines-per-file", "10"},
+		{"-input", input, "-watch", "-output", "out.txt", "-watch-interval", "0s"},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitUsage {
+			t.Errorf("%v: run exited %d, want %d", args, code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Command perftest measures aggregation time and memory for a prefix file
+// and fails when configured thresholds are exceeded, so it can be used as
+// a performance regression gate.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/rretina/netjugo"
+	"github.com/rretina/netjugo/internal/profile"
+)
+
+// Process exit codes
+const (
+	exitOK        = 0
+	exitError     = 1 // invalid usage or the run failed
+	exitThreshold = 2 // a configured threshold was exceeded
+)
+
+// config holds the perftest settings
+type config struct {
+	input       string
+	minIPv4Len  int
+	minIPv6Len  int
+	maxSeconds  float64
+	maxMemoryMB float64
+	jsonOutput  bool
+	cpuProfile  string
+	memProfile  string
+}
+
+// result is the outcome of a single measured run
+type result struct {
+	Input            string   `json:"input"`
+	InputPrefixes    int      `json:"input_prefixes"`
+	OutputPrefixes   int      `json:"output_prefixes"`
+	ReductionRatio   float64  `json:"reduction_ratio"`
+	LoadSeconds      float64  `json:"load_seconds"`
+	AggregateSeconds float64  `json:"aggregate_seconds"`
+	TotalSeconds     float64  `json:"total_seconds"`
+	MemoryMB         float64  `json:"memory_mb"`
+	Violations       []string `json:"violations"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg, err := parseFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	stopProfiles, err := profile.Start(cfg.cpuProfile, cfg.memProfile)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	res, err := measure(cfg)
+	if stopErr := stopProfiles(); stopErr != nil && err == nil {
+		err = stopErr
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	res.Violations = checkThresholds(res, cfg)
+
+	if cfg.jsonOutput {
+		err = writeJSON(stdout, res)
+	} else {
+		err = writeText(stdout, res)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	if len(res.Violations) > 0 {
+		return exitThreshold
+	}
+	return exitOK
+}
+
+func parseFlags(args []string, stderr io.Writer) (config, error) {
+	var cfg config
+
+	fs := flag.NewFlagSet("perftest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&cfg.input, "input", "", "Input file containing IP prefixes (required)")
+	fs.IntVar(&cfg.minIPv4Len, "min-ipv4", 0, "Minimum IPv4 prefix length (0-32)")
+	fs.IntVar(&cfg.minIPv6Len, "min-ipv6", 0, "Minimum IPv6 prefix length (0-128)")
+	fs.Float64Var(&cfg.maxSeconds, "max-seconds", 0, "Fail if load and aggregation take longer than this (0 disables)")
+	fs.Float64Var(&cfg.maxMemoryMB, "max-memory-mb", 0, "Fail if memory obtained from the OS exceeds this many MB (0 disables)")
+	fs.BoolVar(&cfg.jsonOutput, "json", false, "Print results as JSON")
+	fs.StringVar(&cfg.cpuProfile, "cpuprofile", "", "Write a CPU profile of the load and aggregate phases to file")
+	fs.StringVar(&cfg.memProfile, "memprofile", "", "Write a heap profile taken after the aggregate phase to file")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if cfg.input == "" {
+		return cfg, errors.New("input file is required")
+	}
+	if cfg.maxSeconds < 0 || cfg.maxMemoryMB < 0 {
+		return cfg, errors.New("thresholds must not be negative")
+	}
+	return cfg, nil
+}
+
+// measure loads and aggregates the input, timing both phases
+func measure(cfg config) (result, error) {
+	res := result{Input: cfg.input}
+
+	aggregator := netjugo.NewPrefixAggregator()
+	if cfg.minIPv4Len > 0 || cfg.minIPv6Len > 0 {
+		if err := aggregator.SetMinPrefixLength(cfg.minIPv4Len, cfg.minIPv6Len); err != nil {
+			return res, fmt.Errorf("failed to set minimum prefix lengths: %w", err)
+		}
+	}
+
+	start := time.Now()
+	if err := aggregator.AddFromFile(cfg.input); err != nil {
+		return res, fmt.Errorf("failed to load input: %w", err)
+	}
+	loaded := time.Now()
+
+	if err := aggregator.Aggregate(); err != nil {
+		return res, fmt.Errorf("aggregation failed: %w", err)
+	}
+	done := time.Now()
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	stats := aggregator.GetStats()
+	res.InputPrefixes = stats.OriginalCount
+	res.OutputPrefixes = stats.TotalPrefixes
+	res.ReductionRatio = stats.ReductionRatio
+	res.LoadSeconds = loaded.Sub(start).Seconds()
+	res.AggregateSeconds = done.Sub(loaded).Seconds()
+	res.TotalSeconds = done.Sub(start).Seconds()
+	res.MemoryMB = float64(ms.Sys) / (1024 * 1024)
+
+	return res, nil
+}
+
+// checkThresholds returns a description of every configured threshold
+// the result exceeds
+func checkThresholds(res result, cfg config) []string {
+	violations := []string{}
+	if cfg.maxSeconds > 0 && res.TotalSeconds > cfg.maxSeconds {
+		violations = append(violations, fmt.Sprintf("total time %.2fs exceeds limit %.2fs", res.TotalSeconds, cfg.maxSeconds))
+	}
+	if cfg.maxMemoryMB > 0 && res.MemoryMB > cfg.maxMemoryMB {
+		violations = append(violations, fmt.Sprintf("memory %.1f MB exceeds limit %.1f MB", res.MemoryMB, cfg.maxMemoryMB))
+	}
+	return violations
+}
+
+func writeJSON(w io.Writer, res result) error {
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+func writeText(w io.Writer, res result) error {
+	_, _ = fmt.Fprintf(w, "Input:            %s\n", res.Input)
+	_, _ = fmt.Fprintf(w, "Input prefixes:   %d\n", res.InputPrefixes)
+	_, _ = fmt.Fprintf(w, "Output prefixes:  %d\n", res.OutputPrefixes)
+	_, _ = fmt.Fprintf(w, "Reduction ratio:  %.2f%%\n", res.ReductionRatio*100)
+	_, _ = fmt.Fprintf(w, "Load time:        %.3fs\n", res.LoadSeconds)
+	_, _ = fmt.Fprintf(w, "Aggregate time:   %.3fs\n", res.AggregateSeconds)
+	_, _ = fmt.Fprintf(w, "Total time:       %.3fs\n", res.TotalSeconds)
+	_, _ = fmt.Fprintf(w, "Memory:           %.1f MB\n", res.MemoryMB)
+
+	if len(res.Violations) == 0 {
+		_, err := fmt.Fprintln(w, "Result:           PASS")
+		return err
+	}
+	_, _ = fmt.Fprintln(w, "Result:           FAIL")
+	for _, v := range res.Violations {
+		_, _ = fmt.Fprintf(w, "  - %s\n", v)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prefixes.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestMeasure(t *testing.T) {
+	input := writeTestFile(t, "10.0.0.0/25\n10.0.0.128/25\n2001:db8::/48\n")
+
+	res, err := measure(config{input: input, minIPv6Len: 32})
+	if err != nil {
+		t.Fatalf("measure failed: %v", err)
+	}
+	if res.InputPrefixes != 3 || res.OutputPrefixes != 2 {
+		t.Errorf("Expected 3 input and 2 output prefixes, got %d and %d", res.InputPrefixes, res.OutputPrefixes)
+	}
+	if res.TotalSeconds < res.AggregateSeconds || res.MemoryMB <= 0 {
+		t.Errorf("Implausible measurements: %+v", res)
+	}
+
+	if _, err := measure(config{input: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("Expected an error for a missing input file")
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	res := result{TotalSeconds: 2, MemoryMB: 100}
+
+	tests := []struct {
+		name string
+		cfg  config
+		want int
+	}{
+		{"disabled", config{}, 0},
+		{"within limits", config{maxSeconds: 5, maxMemoryMB: 200}, 0},
+		{"too slow", config{maxSeconds: 1}, 1},
+		{"too much memory", config{maxMemoryMB: 50}, 1},
+		{"both", config{maxSeconds: 1, maxMemoryMB: 50}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkThresholds(res, tt.cfg); len(got) != tt.want {
+				t.Errorf("Expected %d violations, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	input := writeTestFile(t, "192.168.0.0/24\n192.168.1.0/24\n")
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"pass", []string{"-input", input}, exitOK},
+		{"missing input flag", nil, exitError},
+		{"missing input file", []string{"-input", filepath.Join(t.TempDir(), "missing.txt")}, exitError},
+		{"memory threshold", []string{"-input", input, "-max-memory-mb", "0.001"}, exitThreshold},
+		{"bad profile path", []string{"-input", input, "-cpuprofile", filepath.Join(t.TempDir(), "no", "cpu.pprof")}, exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != tt.code {
+				t.Errorf("run(%v) = %d, want %d (stderr: %s)", tt.args, code, tt.code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	input := writeTestFile(t, "192.168.0.0/24\n192.168.1.0/24\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-json", "-max-seconds", "60"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("run exited %d (stderr: %s)", code, stderr.String())
+	}
+
+	var res result
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	if res.InputPrefixes != 2 || res.OutputPrefixes != 1 || len(res.Violations) != 0 {
+		t.Errorf("Unexpected result: %+v", res)
+	}
+}
+
+func TestRunText(t *testing.T) {
+	input := writeTestFile(t, "192.168.0.0/24\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", input, "-max-memory-mb", "0.001"}, &stdout, &stderr); code != exitThreshold {
+		t.Fatalf("run exited %d, want %d", code, exitThreshold)
+	}
+	for _, want := range []string{"Input prefixes:   1", "Result:           FAIL", "exceeds limit"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package netjugo
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AggregateOptions overrides the aggregator's settings for one
+// ComputeAggregate call. The zero value keeps every setting.
+type AggregateOptions struct {
+	// MinPrefixLenIPv4 and MinPrefixLenIPv6 replace the configured minimum
+	// lengths when SetMinLengths is true
+	SetMinLengths    bool
+	MinPrefixLenIPv4 int
+	MinPrefixLenIPv6 int
+	// Include and Exclude replace the configured include and exclude
+	// prefixes when non-nil; an empty slice clears them
+	Include []string
+	Exclude []string
+	// ExclusionSets, when non-nil, names the exclusion sets to apply; the
+	// others are skipped
+	ExclusionSets []string
+}
+
+// ComputeAggregate aggregates a copy of the current input under opts and
+// returns the result, leaving the aggregator itself untouched, so several
+// scenarios can be computed from one load. Like any change of settings
+// after Aggregate, overriding them on an aggregated input needs
+// SetRetainOriginals. Warnings still go to the configured handler and
+// logger.
+func (pa *PrefixAggregator) ComputeAggregate(opts AggregateOptions) (*AggregatedSet, error) {
+	c := pa.Clone()
+	defer func() {
+		_ = c.Reset()
+	}()
+
+	if opts.SetMinLengths && (opts.MinPrefixLenIPv4 != c.MinPrefixLenIPv4 || opts.MinPrefixLenIPv6 != c.MinPrefixLenIPv6) {
+		if err := c.SetMinPrefixLength(opts.MinPrefixLenIPv4, opts.MinPrefixLenIPv6); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Include != nil {
+		if err := c.SetIncludePrefixes(opts.Include); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Exclude != nil {
+		if err := c.SetExcludePrefixes(opts.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ExclusionSets != nil {
+		if err := c.selectExclusionSets(opts.ExclusionSets); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.Aggregate(); err != nil {
+		return nil, err
+	}
+	return c.Snapshot(), nil
+}
+
+// selectExclusionSets enables exactly the named sets
+func (pa *PrefixAggregator) selectExclusionSets(names []string) error {
+	for _, name := range names {
+		if pa.findExclusionSet(name) < 0 {
+			return fmt.Errorf("%w: unknown exclusion set %q", ErrInvalidOption, name)
+		}
+	}
+	for _, name := range pa.ExclusionSets() {
+		if err := pa.setExclusionSetEnabled(name, slices.Contains(names, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package netjugo
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func setPrefixes(s *AggregatedSet) string {
+	var list []string
+	s.ForEach(func(p netip.Prefix) bool {
+		list = append(list, p.String())
+		return true
+	})
+	return strings.Join(list, ",")
+}
+
+func TestComputeAggregate(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/25", "10.0.0.128/25", "10.0.1.0/24", "192.168.0.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.AddExclusionSet("lab", []string{"192.168.0.0/24"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+	before := pa.GetPrefixes()
+
+	a, err := pa.ComputeAggregate(AggregateOptions{Exclude: []string{"10.0.1.0/24"}})
+	if err != nil {
+		t.Fatalf("ComputeAggregate failed: %v", err)
+	}
+	if got := setPrefixes(a); got != "10.0.0.0/24" {
+		t.Errorf("Scenario A = %s, want 10.0.0.0/24", got)
+	}
+
+	b, err := pa.ComputeAggregate(AggregateOptions{SetMinLengths: true, MinPrefixLenIPv4: 16, ExclusionSets: []string{}})
+	if err != nil {
+		t.Fatalf("ComputeAggregate failed: %v", err)
+	}
+	if got := setPrefixes(b); got != "10.0.0.0/16,192.168.0.0/16" {
+		t.Errorf("Scenario B = %s, want 10.0.0.0/16,192.168.0.0/16", got)
+	}
+
+	// The stored input and settings are untouched
+	if after := pa.GetPrefixes(); !slices.Equal(after, before) {
+		t.Errorf("Stored prefixes changed: %v, was %v", after, before)
+	}
+	if pa.MinPrefixLenIPv4 != 0 {
+		t.Errorf("Stored minimum length changed to %d", pa.MinPrefixLenIPv4)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := strings.Join(pa.GetPrefixes(), ","); got != "10.0.0.0/23" {
+		t.Errorf("Aggregate after ComputeAggregate = %s, want 10.0.0.0/23", got)
+	}
+
+	if _, err := pa.ComputeAggregate(AggregateOptions{ExclusionSets: []string{"missing"}}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for an unknown set, got %v", err)
+	}
+	// Overriding settings on a merged input needs the originals
+	if _, err := pa.ComputeAggregate(AggregateOptions{Exclude: []string{"10.0.0.0/25"}}); !errors.Is(err, ErrOriginalsNotRetained) {
+		t.Errorf("Expected ErrOriginalsNotRetained, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package netjugo
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+)
+
+// ConstraintIssue is a likely configuration error in the include or
+// exclude prefixes, found by ValidateConstraints
+type ConstraintIssue struct {
+	Code WarningCode
+	// Kind is "include" or "exclusion"
+	Kind   string
+	Prefix netip.Prefix
+	// Set names the exclusion set the prefix belongs to, if any
+	Set     string
+	Message string
+}
+
+// SetValidateConstraints makes Aggregate run ValidateConstraints before
+// applying the constraints and add each issue as a warning
+func (pa *PrefixAggregator) SetValidateConstraints(validate bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	pa.validateConstraints = validate
+	pa.dirty = true
+}
+
+// ValidateConstraints checks the include and exclude prefixes, including
+// enabled exclusion sets, against the input. It reports constraints of a
+// family the input has no prefixes of, exclusions that overlap no input
+// prefix, and prefixes listed twice in the same list.
+//
+// The input checks need the input as added: they use the retained
+// originals if there are any, and are skipped once Aggregate has applied
+// the exclusions to the working lists without them.
+func (pa *PrefixAggregator) ValidateConstraints() []ConstraintIssue {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return pa.constraintIssues()
+}
+
+// warnConstraintIssues adds the issues from ValidateConstraints as
+// warnings under SetValidateConstraints
+func (pa *PrefixAggregator) warnConstraintIssues() {
+	if !pa.validateConstraints {
+		return
+	}
+	for _, issue := range pa.constraintIssues() {
+		pa.addWarning(Warning{Code: issue.Code, Message: issue.Message, Prefix: issue.Prefix, Set: issue.Set})
+	}
+}
+
+func (pa *PrefixAggregator) constraintIssues() []ConstraintIssue {
+	ipv4, ipv6, release, ok := pa.constraintInput()
+	defer release()
+
+	var issues []ConstraintIssue
+	families := []struct {
+		isIPv4   bool
+		input    []*IPPrefix
+		includes []*IPPrefix
+	}{
+		{true, ipv4, pa.IncludeIPv4},
+		{false, ipv6, pa.IncludeIPv6},
+	}
+	for _, f := range families {
+		// A family with no input at all is only suspicious if the other
+		// family has some
+		wrongFamily := ok && len(f.input) == 0 && len(ipv4)+len(ipv6) > 0
+		var ranges []coverRange
+		if ok && !wrongFamily {
+			ranges = coverRanges(f.input)
+		}
+
+		issues = checkConstraintList(issues, "include", exclusionSource{prefixes: f.includes}, wrongFamily, nil)
+		for _, source := range pa.activeExclusions(f.isIPv4) {
+			issues = checkConstraintList(issues, "exclusion", source, wrongFamily, ranges)
+		}
+	}
+	return issues
+}
+
+// constraintInput returns the input prefixes the constraints are checked
+// against, rebuilt from the retained originals when there are any, and a
+// func to release them. ok is false when the input is no longer available.
+func (pa *PrefixAggregator) constraintInput() (ipv4, ipv6 []*IPPrefix, release func(), ok bool) {
+	if pa.retainOriginals && !pa.originalsIncomplete && len(pa.originals) > 0 {
+		ipv4, ipv6, err := rebuildOriginals(pa.originals)
+		if err != nil {
+			return nil, nil, func() {}, false
+		}
+		return ipv4, ipv6, func() {
+			for _, list := range [][]*IPPrefix{ipv4, ipv6} {
+				for _, p := range list {
+					releaseIPPrefix(p)
+				}
+			}
+		}, true
+	}
+	if pa.aggregated {
+		return nil, nil, func() {}, false
+	}
+	return pa.IPv4Prefixes, pa.IPv6Prefixes, func() {}, true
+}
+
+// checkConstraintList appends the issues of one include or exclude list.
+// ranges is the merged input of the family, or nil to skip the overlap
+// check.
+func checkConstraintList(issues []ConstraintIssue, kind string, source exclusionSource, wrongFamily bool, ranges []coverRange) []ConstraintIssue {
+	seen := make(map[netip.Prefix]int, len(source.prefixes))
+	for _, p := range source.prefixes {
+		prefix := p.Prefix.Masked()
+		seen[prefix]++
+
+		issue := ConstraintIssue{Kind: kind, Prefix: p.Prefix, Set: source.set}
+		switch {
+		case seen[prefix] == 2:
+			issue.Code = WarnDuplicateConstraint
+			issue.Message = fmt.Sprintf("WARNING: %s %s%s is listed more than once", kind, p.Prefix, source.describe())
+		case seen[prefix] > 2:
+			continue
+		case wrongFamily:
+			family := "IPv6"
+			if p.Prefix.Addr().Is4() {
+				family = "IPv4"
+			}
+			issue.Code = WarnConstraintFamily
+			issue.Message = fmt.Sprintf("WARNING: %s %s%s is %s, but the input has no %s prefixes. Check that it is in the right file.", kind, p.Prefix, source.describe(), family, family)
+		case ranges != nil && !overlapsRanges(ranges, p):
+			issue.Code = WarnConstraintOutsideInput
+			issue.Message = fmt.Sprintf("WARNING: %s %s%s overlaps no input prefix", kind, p.Prefix, source.describe())
+		default:
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// overlapsRanges reports whether p shares any address with the sorted,
+// disjoint ranges
+func overlapsRanges(ranges []coverRange, p *IPPrefix) bool {
+	i := sort.Search(len(ranges), func(i int) bool {
+		return !ranges[i].max.Lt(p.Min)
+	})
+	return i < len(ranges) && !ranges[i].min.Gt(p.Max)
+}
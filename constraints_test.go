@@ -0,0 +1,92 @@
+package netjugo
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestValidateConstraints(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/16", "10.1.0.0/16", "192.168.0.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetIncludePrefixes([]string{"172.16.0.0/12", "172.16.0.0/12", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to set include prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{
+		"10.0.1.0/24",     // overlaps the input
+		"203.0.113.0/24",  // outside the input
+		"2001:db8:1::/48", // IPv6 in an IPv4 run
+	}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.AddExclusionSet("lab", []string{"10.1.0.0/24", "10.1.0.0/24"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+
+	want := []ConstraintIssue{
+		{Code: WarnDuplicateConstraint, Kind: "include", Prefix: netip.MustParsePrefix("172.16.0.0/12")},
+		{Code: WarnConstraintOutsideInput, Kind: "exclusion", Prefix: netip.MustParsePrefix("203.0.113.0/24")},
+		{Code: WarnDuplicateConstraint, Kind: "exclusion", Prefix: netip.MustParsePrefix("10.1.0.0/24"), Set: "lab"},
+		{Code: WarnConstraintFamily, Kind: "include", Prefix: netip.MustParsePrefix("2001:db8::/32")},
+		{Code: WarnConstraintFamily, Kind: "exclusion", Prefix: netip.MustParsePrefix("2001:db8:1::/48")},
+	}
+
+	issues := pa.ValidateConstraints()
+	if len(issues) != len(want) {
+		t.Fatalf("Expected %d issues, got %d: %v", len(want), len(issues), issues)
+	}
+	for i, issue := range issues {
+		if issue.Code != want[i].Code || issue.Kind != want[i].Kind || issue.Prefix != want[i].Prefix || issue.Set != want[i].Set {
+			t.Errorf("Issue %d = %+v, want %+v", i, issue, want[i])
+		}
+		if issue.Message == "" {
+			t.Errorf("Issue %d has no message", i)
+		}
+	}
+
+	// Aggregate only reports them once asked to
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	for _, w := range pa.GetWarningDetails() {
+		if w.Code == WarnDuplicateConstraint || w.Code == WarnConstraintOutsideInput || w.Code == WarnConstraintFamily {
+			t.Errorf("Unexpected constraint warning without SetValidateConstraints: %s", w.Message)
+		}
+	}
+}
+
+func TestValidateConstraintsAtAggregate(t *testing.T) {
+	pa := NewPrefixAggregator()
+	pa.SetRetainOriginals(true)
+	pa.SetValidateConstraints(true)
+	if err := pa.AddPrefixes([]string{"10.0.0.0/16", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.0.0/24", "198.51.100.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	details := pa.GetWarningDetails()
+	if len(details) != 1 || details[0].Code != WarnConstraintOutsideInput || details[0].Prefix != netip.MustParsePrefix("198.51.100.0/24") {
+		t.Fatalf("Expected one outside-input warning, got %v", details)
+	}
+
+	// The retained originals still hold the excluded space after Aggregate,
+	// so a later run does not flag the exclusion that was applied
+	if err := pa.SetExcludePrefixes([]string{"10.0.0.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if warnings := pa.GetWarnings(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings after the fix, got %v", warnings)
+	}
+	if issues := pa.ValidateConstraints(); len(issues) != 0 {
+		t.Errorf("Expected no issues after Aggregate, got %v", issues)
+	}
+}
@@ -0,0 +1,122 @@
+package netjugo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/holiman/uint256"
+)
+
+// coverRange is a run of consecutive addresses covered by one or more
+// prefixes
+type coverRange struct {
+	min, max uint256.Int
+}
+
+// Covers reports whether every address in prefixStr is covered by the
+// current prefixes, even when that takes several adjacent entries. Use
+// CoversAll to check many prefixes at once.
+func (pa *PrefixAggregator) Covers(prefixStr string) (bool, error) {
+	missing, err := pa.CoversAll([]string{prefixStr})
+	if err != nil {
+		return false, err
+	}
+	return len(missing) == 0, nil
+}
+
+// CoversAll returns the prefixes, in the order given, that are not fully
+// covered by the current prefixes. If any prefix cannot be parsed, a
+// *MultiError listing every invalid entry is returned instead.
+func (pa *PrefixAggregator) CoversAll(prefixes []string) (missing []string, err error) {
+	queries := make([]*IPPrefix, len(prefixes))
+	defer func() {
+		for _, q := range queries {
+			if q != nil {
+				releaseIPPrefix(q)
+			}
+		}
+	}()
+
+	var failed []*EntryError
+	for i, prefixStr := range prefixes {
+		q, err := parseIPPrefix(prefixStr)
+		if err != nil {
+			err = fmt.Errorf("failed to parse prefix %q: %w", prefixStr, err)
+			failed = append(failed, &EntryError{Index: i, Input: prefixStr, Err: err})
+			continue
+		}
+		queries[i] = q
+	}
+	if len(failed) > 0 {
+		return nil, &MultiError{Errors: failed}
+	}
+
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if pa.closed {
+		return nil, ErrClosed
+	}
+
+	covered := make([]bool, len(queries))
+	sweepCoverage(coverRanges(pa.IPv4Prefixes), queries, true, covered)
+	sweepCoverage(coverRanges(pa.IPv6Prefixes), queries, false, covered)
+
+	for i, ok := range covered {
+		if !ok {
+			missing = append(missing, prefixes[i])
+		}
+	}
+	return missing, nil
+}
+
+// coverRanges merges prefixes into sorted, disjoint ranges, joining
+// entries that overlap or touch
+func coverRanges(prefixes []*IPPrefix) []coverRange {
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	sorted := append([]*IPPrefix(nil), prefixes...)
+	sortPrefixes(sorted)
+
+	ranges := make([]coverRange, 0, len(sorted))
+	var next uint256.Int
+	for _, p := range sorted {
+		if n := len(ranges); n > 0 {
+			last := &ranges[n-1]
+			next.AddUint64(&last.max, 1)
+			if !p.Min.Gt(&next) {
+				if p.Max.Gt(&last.max) {
+					last.max.Set(p.Max)
+				}
+				continue
+			}
+		}
+		ranges = append(ranges, coverRange{min: *p.Min, max: *p.Max})
+	}
+	return ranges
+}
+
+// sweepCoverage marks the queries of one family that fall inside a single
+// range. Ranges never touch, so a query spanning two of them has a gap.
+func sweepCoverage(ranges []coverRange, queries []*IPPrefix, isIPv4 bool, covered []bool) {
+	order := make([]int, 0, len(queries))
+	for i, q := range queries {
+		if q.Prefix.Addr().Is4() == isIPv4 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return queries[order[a]].Min.Lt(queries[order[b]].Min)
+	})
+
+	j := 0
+	for _, i := range order {
+		q := queries[i]
+		for j < len(ranges) && ranges[j].max.Lt(q.Min) {
+			j++
+		}
+		covered[i] = j < len(ranges) && !ranges[j].min.Gt(q.Min) && !ranges[j].max.Lt(q.Max)
+	}
+}
@@ -0,0 +1,88 @@
+package netjugo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCovers(t *testing.T) {
+	pa := NewPrefixAggregator()
+	// Unaggregated on purpose: coverage must span adjacent and overlapping
+	// entries, in any order
+	if err := pa.AddPrefixes([]string{
+		"10.0.1.0/24", "10.0.0.0/24", "10.0.0.128/25",
+		"192.168.0.0/24", "192.168.2.0/24",
+		"2001:db8::/33", "2001:db8:8000::/33",
+	}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   bool
+	}{
+		{"10.0.0.0/24", true},
+		{"10.0.0.0/23", true},
+		{"10.0.1.200", true},
+		{"10.0.0.0/22", false},
+		{"192.168.0.0/22", false},
+		{"192.168.1.0/24", false},
+		{"2001:db8::/32", true},
+		{"2001:db8::/31", false},
+		// The IPv4-mapped form belongs to the other family
+		{"::ffff:10.0.0.0/120", false},
+	}
+
+	for _, tt := range tests {
+		got, err := pa.Covers(tt.prefix)
+		if err != nil {
+			t.Fatalf("Covers(%q) failed: %v", tt.prefix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Covers(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	if _, err := pa.Covers("10.0.0.0/40"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Expected ErrInvalidPrefix, got %v", err)
+	}
+}
+
+func TestCoversAll(t *testing.T) {
+	feed := generateTestPrefixes(5000)
+
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(feed); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	missing, err := pa.CoversAll(feed)
+	if err != nil {
+		t.Fatalf("CoversAll failed: %v", err)
+	}
+	if len(missing) != 0 {
+		t.Errorf("Aggregated result misses %d feed prefixes, first %s", len(missing), missing[0])
+	}
+
+	// Missing entries come back in input order, as given
+	missing, err = pa.CoversAll([]string{"203.0.113.0/24", feed[0], "2001:db8:ffff::1", feed[1]})
+	if err != nil {
+		t.Fatalf("CoversAll failed: %v", err)
+	}
+	if got := strings.Join(missing, ","); got != "203.0.113.0/24,2001:db8:ffff::1" {
+		t.Errorf("Unexpected missing list: %s", got)
+	}
+
+	_, err = pa.CoversAll([]string{"bogus", feed[0], "10.0.0.0/99"})
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("Expected a MultiError with 2 entries, got %v", err)
+	}
+	if multi.Errors[0].Index != 0 || multi.Errors[1].Index != 2 {
+		t.Errorf("Unexpected entry indexes: %d, %d", multi.Errors[0].Index, multi.Errors[1].Index)
+	}
+}
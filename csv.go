@@ -0,0 +1,136 @@
+package netjugo
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSVOptions selects where AddFromCSV finds the prefixes
+type CSVOptions struct {
+	// Column is the zero-based index of the prefix column, used when
+	// ColumnName is empty
+	Column int
+	// ColumnName selects the column by its header, in any case, and
+	// implies Header
+	ColumnName string
+	// Header skips the first row
+	Header bool
+	// Comma is the field delimiter; ',' if zero
+	Comma rune
+}
+
+// AddFromCSV adds the prefixes in one column of CSV input. Each cell is
+// read like a line of AddFromReader, so bare addresses are accepted and
+// comments stripped. Rows without a valid prefix in the column, including
+// malformed rows, are skipped and counted like skipped lines.
+func (pa *PrefixAggregator) AddFromCSV(r io.Reader, opts CSVOptions) error {
+	_, err := pa.AddFromCSVCount(r, opts)
+	return err
+}
+
+// AddFromCSVCount is AddFromCSV, also returning how many prefixes were
+// added and how many rows were skipped
+func (pa *PrefixAggregator) AddFromCSVCount(r io.Reader, opts CSVOptions) (ReadResult, error) {
+	var result ReadResult
+	if opts.Column < 0 {
+		return result, fmt.Errorf("%w: CSV column must not be negative, got %d", ErrInvalidOption, opts.Column)
+	}
+	comma := opts.Comma
+	if comma == 0 {
+		comma = ','
+	}
+	if comma == '"' || comma == '\r' || comma == '\n' || !utf8.ValidRune(comma) || comma == utf8.RuneError {
+		return result, fmt.Errorf("%w: invalid CSV delimiter %q", ErrInvalidOption, comma)
+	}
+
+	logger, metrics := pa.observers()
+	defer func() {
+		metrics.AddPrefixesLoaded(result.Added)
+	}()
+	pa.mu.RLock()
+	syntax := pa.syntax
+	pa.mu.RUnlock()
+
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	column := opts.Column
+	if opts.Header || opts.ColumnName != "" {
+		header, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("%w: CSV header: %v", ErrInvalidFormat, err)
+		}
+		if opts.ColumnName != "" {
+			if column = csvColumn(header, opts.ColumnName); column < 0 {
+				return result, fmt.Errorf("%w: no column %q in CSV header", ErrInvalidFormat, opts.ColumnName)
+			}
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			pa.countSkippedLine(SkipMalformedRecord)
+			skippedLine(logger, metrics, "", "", parseErr.Line, "", SkipMalformedRecord, err)
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("error reading CSV input: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if column >= len(record) {
+			pa.countSkippedLine(SkipMalformedRecord)
+			skippedLine(logger, metrics, "", "", line, strings.Join(record, string(comma)), SkipMalformedRecord,
+				fmt.Errorf("%w: row has %d fields, no column %d", ErrInvalidFormat, len(record), column))
+			result.Skipped++
+			continue
+		}
+		cell := record[column]
+		prefix, text, kind := syntax.parse(cell)
+		if kind != linePrefix {
+			pa.countSkippedLine(SkipParseError)
+			skippedLine(logger, metrics, "", "", line, cell, SkipParseError, nil)
+			result.Skipped++
+			continue
+		}
+		if err := pa.addPrefixText(prefix, text, ""); err != nil {
+			if errors.Is(err, ErrMemoryBudgetExceeded) || errors.Is(err, ErrClosed) {
+				return result, fmt.Errorf("line %d: %w", line, err)
+			}
+			pa.countSkippedLine(SkipParseError)
+			skippedLine(logger, metrics, "", "", line, cell, SkipParseError, err)
+			result.Skipped++
+			continue
+		}
+		result.Added++
+	}
+	return result, nil
+}
+
+// csvColumn returns the index of the named header cell, or -1
+func csvColumn(header []string, name string) int {
+	for i, cell := range header {
+		if i == 0 {
+			cell = strings.TrimPrefix(cell, "\ufeff")
+		}
+		if strings.EqualFold(strings.TrimSpace(cell), name) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,133 @@
+package netjugo
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const threatFeedCSV = "\ufeffid,source,CIDR,comment\n" +
+	`1,"Feed A, daily",192.0.2.0/24,"scanner, many ports"` + "\n" +
+	`2,Feed B,198.51.100.7,"bare address"` + "\n" +
+	`3,Feed B,"203.0.113.0/25",` + "\n" +
+	`4,Feed C,203.0.113.128/25,"multi` + "\n" + `line, quoted"` + "\n" +
+	`5,Feed C,not-a-prefix,bad` + "\n" +
+	`6,short row` + "\n" +
+	`7,Feed D,2001:db8::/32,"ok"` + "\n"
+
+func TestAddFromCSV(t *testing.T) {
+	pa := NewPrefixAggregator()
+	result, err := pa.AddFromCSVCount(strings.NewReader(threatFeedCSV), CSVOptions{ColumnName: "cidr"})
+	if err != nil {
+		t.Fatalf("AddFromCSVCount failed: %v", err)
+	}
+	if result.Added != 5 || result.Skipped != 2 {
+		t.Errorf("Expected 5 added and 2 skipped, got %+v", result)
+	}
+	if skipped := pa.GetStats().SkippedLines; skipped != 2 {
+		t.Errorf("Expected 2 skipped lines in stats, got %d", skipped)
+	}
+
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	want := []string{"192.0.2.0/24", "198.51.100.7/32", "203.0.113.0/24", "2001:db8::/32"}
+	if got := pa.GetPrefixes(); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// The same column by index, with the header skipped
+	byIndex := NewPrefixAggregator()
+	result, err = byIndex.AddFromCSVCount(strings.NewReader(threatFeedCSV), CSVOptions{Column: 2, Header: true})
+	if err != nil || result.Added != 5 || result.Skipped != 2 {
+		t.Errorf("By index: got %+v, %v; want 5 added and 2 skipped", result, err)
+	}
+}
+
+func TestAddFromCSVDelimiter(t *testing.T) {
+	pa := NewPrefixAggregator()
+	input := "10.0.0.0/24;a\n10.0.1.0/24;\"b;c\"\n"
+	if err := pa.AddFromCSV(strings.NewReader(input), CSVOptions{Comma: ';'}); err != nil {
+		t.Fatalf("AddFromCSV failed: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := pa.GetPrefixes(); !slices.Equal(got, []string{"10.0.0.0/23"}) {
+		t.Errorf("Expected [10.0.0.0/23], got %v", got)
+	}
+}
+
+func TestAddFromCSVMalformed(t *testing.T) {
+	pa := NewPrefixAggregator()
+	// A bare quote inside an unquoted field is a parse error for that row
+	input := "10.0.0.0/24,a\n10.0.1.0/24,b\"c\n10.0.2.0/24,d\n"
+	result, err := pa.AddFromCSVCount(strings.NewReader(input), CSVOptions{})
+	if err != nil {
+		t.Fatalf("AddFromCSVCount failed: %v", err)
+	}
+	if result.Added != 2 || result.Skipped != 1 {
+		t.Errorf("Expected 2 added and 1 skipped, got %+v", result)
+	}
+}
+
+func TestAddFromCSVOptions(t *testing.T) {
+	pa := NewPrefixAggregator()
+	tests := []struct {
+		name string
+		opts CSVOptions
+		want error
+	}{
+		{"unknown column", CSVOptions{ColumnName: "prefix"}, ErrInvalidFormat},
+		{"negative column", CSVOptions{Column: -1}, ErrInvalidOption},
+		{"quote delimiter", CSVOptions{Comma: '"'}, ErrInvalidOption},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pa.AddFromCSV(strings.NewReader(threatFeedCSV), tt.opts); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// An empty input has no header to look the column up in, and nothing
+	// to add
+	if err := pa.AddFromCSV(strings.NewReader(""), CSVOptions{ColumnName: "cidr"}); err != nil {
+		t.Errorf("Expected no error for empty input, got %v", err)
+	}
+}
+
+func TestSkipReasons(t *testing.T) {
+	pa := NewPrefixAggregator()
+	// The feed has a cell that is not a prefix and a row without the column
+	if _, err := pa.AddFromCSVCount(strings.NewReader(threatFeedCSV), CSVOptions{ColumnName: "cidr"}); err != nil {
+		t.Fatalf("AddFromCSVCount failed: %v", err)
+	}
+	if err := pa.AddFromReader(strings.NewReader("10.0.0.0/8\nnot a prefix\n10.0.0.300/24\n")); err != nil {
+		t.Fatalf("AddFromReader failed: %v", err)
+	}
+
+	stats := pa.GetStats()
+	want := map[SkipReason]int{SkipParseError: 3, SkipMalformedRecord: 1}
+	total := 0
+	for _, reason := range SkipReasons() {
+		if got := stats.SkippedByReason[reason]; got != want[reason] {
+			t.Errorf("Expected %d lines skipped for %s, got %d", want[reason], reason, got)
+		}
+		total += stats.SkippedByReason[reason]
+	}
+	if total != stats.SkippedLines {
+		t.Errorf("Expected the reasons to sum to %d skipped lines, got %d", stats.SkippedLines, total)
+	}
+
+	if err := pa.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if got := pa.GetStats().SkippedByReason; got != [len(got)]int{} {
+		t.Errorf("Expected Reset to clear the reasons, got %v", got)
+	}
+	if got := SkipReason(99).String(); got != "SkipReason(99)" {
+		t.Errorf("Unexpected name for an unknown reason: %q", got)
+	}
+}
@@ -0,0 +1,60 @@
+package netjugo
+
+import (
+	"net/netip"
+	"unsafe"
+)
+
+// SetDedupOnIngest makes AddPrefix and the loaders drop a prefix that was
+// already added, host bits ignored, instead of keeping every copy until
+// Aggregate merges them. A feed that repeats the same prefixes millions of
+// times then only holds its distinct prefixes. Dropped copies are counted
+// in AggregationStats.DuplicatesRemoved rather than OriginalCount.
+//
+// The price is a hash set of every distinct prefix added, about 40 bytes
+// each on top of the prefix, and a lookup per add, so input without much
+// duplication loads faster and smaller without it. AddFromFiles parses
+// each file completely before adding it, so only the aggregator, not the
+// parse, is bounded.
+func (pa *PrefixAggregator) SetDedupOnIngest(enabled bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	pa.dedupOnIngest = enabled
+	pa.ingested = nil
+	if !enabled {
+		return
+	}
+	// Prefixes added before now count as seen; after Aggregate they are
+	// the result, which covers everything added
+	for _, list := range [][]*IPPrefix{pa.IPv4Prefixes, pa.IPv6Prefixes} {
+		for _, p := range list {
+			pa.dropDuplicate(p.Prefix)
+		}
+	}
+	pa.duplicatesRemoved = 0
+}
+
+// dropDuplicate reports whether dedup on ingest drops p as already added,
+// and records p otherwise. The caller holds the write lock.
+func (pa *PrefixAggregator) dropDuplicate(p netip.Prefix) bool {
+	if !pa.dedupOnIngest {
+		return false
+	}
+	key := p.Masked()
+	if _, ok := pa.ingested[key]; ok {
+		pa.duplicatesRemoved++
+		return true
+	}
+	if pa.ingested == nil {
+		pa.ingested = make(map[netip.Prefix]struct{})
+	}
+	pa.ingested[key] = struct{}{}
+	return false
+}
+
+// ingestedMemory estimates the dedup set: each key plus a share of the
+// hash table's control bytes and free slots
+func (pa *PrefixAggregator) ingestedMemory() int64 {
+	return int64(len(pa.ingested)) * (int64(unsafe.Sizeof(netip.Prefix{})) + 8)
+}
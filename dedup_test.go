@@ -0,0 +1,64 @@
+package netjugo
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDedupOnIngest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "honeypot.txt")
+	if err := os.WriteFile(path, []byte(strings.Repeat("198.51.100.7/32\n", 100000)), 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	single := NewPrefixAggregator()
+	single.SetDedupOnIngest(true)
+	if err := single.AddPrefix("198.51.100.7/32"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+
+	pa := NewPrefixAggregator()
+	pa.SetDedupOnIngest(true)
+	if err := pa.AddFromFile(path); err != nil {
+		t.Fatalf("Failed to load file: %v", err)
+	}
+	if got, want := pa.GetMemoryStats().AggregatorBytes, single.GetMemoryStats().AggregatorBytes; got != want {
+		t.Errorf("Expected the memory of one prefix, %d bytes, got %d", want, got)
+	}
+	stats := pa.GetStats()
+	if stats.DuplicatesRemoved != 99999 || stats.OriginalCount != 1 {
+		t.Errorf("Expected 99999 duplicates removed and 1 original, got %d and %d", stats.DuplicatesRemoved, stats.OriginalCount)
+	}
+
+	// Host bits are ignored, and prefixes added before enabling count as seen
+	later := NewPrefixAggregator()
+	if err := later.AddPrefixes([]string{"10.0.0.0/24", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	later.SetDedupOnIngest(true)
+	if err := later.AddPrefixes([]string{"10.0.0.1/24", "2001:db8::/32", "10.0.1.0/24", "10.0.1.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if got := later.GetStats().DuplicatesRemoved; got != 3 {
+		t.Errorf("Expected 3 duplicates removed, got %d", got)
+	}
+	if err := later.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got, want := later.GetPrefixes(), []string{"10.0.0.0/23", "2001:db8::/32"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if err := later.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if err := later.AddPrefix("10.0.0.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if stats := later.GetStats(); stats.DuplicatesRemoved != 0 || stats.OriginalCount != 1 {
+		t.Errorf("Expected Reset to forget the seen prefixes, got %+v", stats)
+	}
+}
@@ -0,0 +1,179 @@
+package netjugo
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+const allOnesIPv6 = "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"
+
+// Every case comes as an IPv4 and IPv6 pair, so an asymmetry between the
+// families shows up as one half of a pair failing
+func TestEdgeCasePrefixes(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []string
+		exclude    []string
+		min4, min6 int
+		want       []string
+	}{
+		{"v4 all-ones host", []string{"255.255.255.255/32"}, nil, 0, 0, []string{"255.255.255.255/32"}},
+		{"v6 all-ones host", []string{allOnesIPv6 + "/128"}, nil, 0, 0, []string{allOnesIPv6 + "/128"}},
+		{"v4 unspecified host", []string{"0.0.0.0"}, nil, 0, 0, []string{"0.0.0.0/32"}},
+		{"v6 unspecified host", []string{"::"}, nil, 0, 0, []string{"::/128"}},
+		{"v4 default route", []string{"0.0.0.0/0"}, nil, 0, 0, []string{"0.0.0.0/0"}},
+		{"v6 default route", []string{"::/0"}, nil, 0, 0, []string{"::/0"}},
+
+		{"v4 top pair merges", []string{"255.255.255.254/32", "255.255.255.255/32"}, nil, 0, 0, []string{"255.255.255.254/31"}},
+		{"v6 top pair merges", []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/128", allOnesIPv6 + "/128"}, nil, 0, 0,
+			[]string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127"}},
+		{"v4 bottom pair merges", []string{"0.0.0.0/32", "0.0.0.1/32"}, nil, 0, 0, []string{"0.0.0.0/31"}},
+		{"v6 bottom pair merges", []string{"::/128", "::1/128"}, nil, 0, 0, []string{"::/127"}},
+		{"v4 halves merge", []string{"0.0.0.0/1", "128.0.0.0/1"}, nil, 0, 0, []string{"0.0.0.0/0"}},
+		{"v6 halves merge", []string{"::/1", "8000::/1"}, nil, 0, 0, []string{"::/0"}},
+		{"v4 ends stay apart", []string{"0.0.0.0/32", "255.255.255.255/32"}, nil, 0, 0, []string{"0.0.0.0/32", "255.255.255.255/32"}},
+		{"v6 ends stay apart", []string{"::/128", allOnesIPv6 + "/128"}, nil, 0, 0, []string{"::/128", allOnesIPv6 + "/128"}},
+
+		{"v4 default exclusion", []string{"10.0.0.0/8", "0.0.0.0/0", "2001:db8::/32"}, []string{"0.0.0.0/0"}, 0, 0, []string{"2001:db8::/32"}},
+		{"v6 default exclusion", []string{"2001:db8::/32", "::/0", "10.0.0.0/8"}, []string{"::/0"}, 0, 0, []string{"10.0.0.0/8"}},
+		{"v4 default exclusion of a half", []string{"0.0.0.0/1"}, []string{"0.0.0.0/0"}, 0, 0, nil},
+		{"v6 default exclusion of a half", []string{"::/1"}, []string{"::/0"}, 0, 0, nil},
+		{"v4 top host exclusion", []string{"255.255.255.252/30"}, []string{"255.255.255.255/32"}, 0, 0,
+			[]string{"255.255.255.252/31", "255.255.255.254/32"}},
+		{"v6 top host exclusion", []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/126"}, []string{allOnesIPv6 + "/128"}, 0, 0,
+			[]string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/127", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/128"}},
+		{"v4 bottom host exclusion", []string{"0.0.0.0/30"}, []string{"0.0.0.0/32"}, 0, 0, []string{"0.0.0.1/32", "0.0.0.2/31"}},
+		{"v6 bottom host exclusion", []string{"::/126"}, []string{"::/128"}, 0, 0, []string{"::1/128", "::2/127"}},
+
+		{"v4 all-ones rounds down", []string{"255.255.255.255/32"}, nil, 24, 48, []string{"255.255.255.0/24"}},
+		{"v6 all-ones rounds down", []string{allOnesIPv6 + "/128"}, nil, 24, 48, []string{"ffff:ffff:ffff::/48"}},
+		{"v4 all-ones rounds to a half", []string{"255.255.255.255/32"}, nil, 1, 1, []string{"128.0.0.0/1"}},
+		{"v6 all-ones rounds to a half", []string{allOnesIPv6 + "/128"}, nil, 1, 1, []string{"8000::/1"}},
+		{"v4 unspecified rounds down", []string{"0.0.0.0/32"}, nil, 24, 48, []string{"0.0.0.0/24"}},
+		{"v6 unspecified rounds down", []string{"::/128"}, nil, 24, 48, []string{"::/48"}},
+		{"v4 host-length minimum", []string{"255.255.255.255/32", "0.0.0.0/32"}, nil, 32, 128, []string{"0.0.0.0/32", "255.255.255.255/32"}},
+		{"v6 host-length minimum", []string{allOnesIPv6 + "/128", "::/128"}, nil, 32, 128, []string{"::/128", allOnesIPv6 + "/128"}},
+	}
+
+	for _, tt := range tests {
+		pa := NewPrefixAggregator()
+		if err := pa.SetMinPrefixLength(tt.min4, tt.min6); err != nil {
+			t.Fatalf("%s: SetMinPrefixLength failed: %v", tt.name, err)
+		}
+		if err := pa.AddPrefixes(tt.input); err != nil {
+			t.Fatalf("%s: Failed to add prefixes: %v", tt.name, err)
+		}
+		if err := pa.SetExcludePrefixes(tt.exclude); err != nil {
+			t.Fatalf("%s: Failed to set exclusions: %v", tt.name, err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("%s: Failed to aggregate: %v", tt.name, err)
+		}
+		if got := pa.GetPrefixes(); !slices.Equal(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEdgeCaseFullRangeExclusion(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       []string
+	}{
+		{"0.0.0.0", "255.255.255.255", []string{"::/0"}},
+		{"::", allOnesIPv6, []string{"0.0.0.0/0"}},
+	}
+	for _, tt := range tests {
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefixes([]string{"0.0.0.0/0", "::/0"}); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.AddExcludeRange(tt.start, tt.end); err != nil {
+			t.Fatalf("AddExcludeRange(%s, %s) failed: %v", tt.start, tt.end, err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if got := pa.GetPrefixes(); !slices.Equal(got, tt.want) {
+			t.Errorf("Excluding %s-%s: got %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ first, last, want string }{
+		{"0.0.0.0", "255.255.255.255", "0.0.0.0/0"},
+		{"::", allOnesIPv6, "::/0"},
+		{"255.255.255.255", "255.255.255.255", "255.255.255.255/32"},
+		{allOnesIPv6, allOnesIPv6, allOnesIPv6 + "/128"},
+	} {
+		got, err := RangeToPrefixes(netip.MustParseAddr(tt.first), netip.MustParseAddr(tt.last))
+		if err != nil || len(got) != 1 || got[0].String() != tt.want {
+			t.Errorf("RangeToPrefixes(%s, %s) = %v, %v, want [%s]", tt.first, tt.last, got, err, tt.want)
+		}
+	}
+}
+
+func TestEdgeCaseLookups(t *testing.T) {
+	edges := []netip.Addr{
+		netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("255.255.255.255"),
+		netip.MustParseAddr("::"), netip.MustParseAddr(allOnesIPv6),
+	}
+	tests := []struct {
+		input []string
+		want  []bool
+	}{
+		{[]string{"0.0.0.0/0"}, []bool{true, true, false, false}},
+		{[]string{"::/0"}, []bool{false, false, true, true}},
+		{[]string{"0.0.0.0/32", allOnesIPv6 + "/128"}, []bool{true, false, false, true}},
+		{[]string{"255.255.255.255/32", "::/128"}, []bool{false, true, true, false}},
+	}
+	for _, tt := range tests {
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefixes(tt.input); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		set, idx := pa.Snapshot(), pa.BuildIndex()
+		for i, addr := range edges {
+			if set.Contains(addr) != tt.want[i] || idx.Contains(addr) != tt.want[i] {
+				t.Errorf("%v: Contains(%s) = %v on the snapshot and %v on the index, want %v",
+					tt.input, addr, set.Contains(addr), idx.Contains(addr), tt.want[i])
+			}
+		}
+	}
+}
+
+func TestIPv4RangeRoundTrip(t *testing.T) {
+	for _, base := range []string{"0.0.0.0", "0.0.0.1", "127.255.255.255", "128.0.0.0", "255.255.255.255"} {
+		addr := netip.MustParseAddr(base)
+		for bits := 0; bits <= 32; bits++ {
+			want := netip.PrefixFrom(addr, bits).Masked()
+
+			minVal, maxVal, err := prefixToUint256Range(netip.PrefixFrom(addr, bits))
+			if err != nil {
+				t.Fatalf("prefixToUint256Range(%s/%d) failed: %v", base, bits, err)
+			}
+			got, err := uint256RangeToPrefix(minVal, maxVal, true)
+			if err != nil {
+				t.Fatalf("uint256RangeToPrefix for %s/%d failed: %v", base, bits, err)
+			}
+			if got != want {
+				t.Fatalf("%s/%d: round trip gave %s, want %s", base, bits, got, want)
+			}
+		}
+	}
+
+	// Values past 32 bits are not IPv4 addresses, including those whose
+	// low 64 bits would read as one
+	for _, shift := range []uint{32, 64, 96} {
+		tooLarge := new(uint256.Int).Lsh(uint256.NewInt(1), shift)
+		if _, err := uint256RangeToPrefix(tooLarge, tooLarge, true); !errors.Is(err, ErrInvalidPrefix) {
+			t.Errorf("Expected ErrInvalidPrefix for 2^%d, got %v", shift, err)
+		}
+	}
+}
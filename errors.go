@@ -1,6 +1,11 @@
 package netjugo
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
 
 var (
 	ErrInvalidPrefix        = errors.New("invalid IP prefix")
@@ -9,4 +14,94 @@ var (
 	ErrNilPointer           = errors.New("nil pointer reference")
 	ErrFileNotFound         = errors.New("file not found")
 	ErrInvalidFormat        = errors.New("invalid file format")
+	ErrInvalidOption        = errors.New("invalid option")
+	ErrDefaultRoute         = errors.New("aggregation produced a default route")
+	ErrResultTooLarge       = errors.New("result exceeds the maximum number of prefixes")
+	ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+	ErrOriginalsNotRetained = errors.New("original prefixes were not retained")
+	ErrNonConvergence       = errors.New("aggregation did not converge")
+	ErrEmptyResult          = errors.New("result is empty")
+	ErrClosed               = errors.New("aggregator is closed")
+	ErrIncludeTooSpecific   = errors.New("include prefix is more specific than the minimum length")
+	ErrExclusionFailed      = errors.New("exclusion could not be applied")
+	ErrChangeTooLarge       = errors.New("result changed more than allowed")
 )
+
+// EntryError describes one entry of a list that could not be added
+type EntryError struct {
+	Index int    // position in the input slice
+	Input string // the entry as given
+	Err   error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("entry %d: %v", e.Index, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError collects the failures from a batch operation such as
+// AddPrefixes. It works with errors.Is and errors.As through each entry.
+type MultiError struct {
+	Errors []*EntryError
+	// Added is how many entries of the batch were added despite the
+	// failures, for batches that add
+	Added int
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	return fmt.Sprintf("%d invalid entries, first: %v", len(e.Errors), e.Errors[0])
+}
+
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, entry := range e.Errors {
+		errs[i] = entry
+	}
+	return errs
+}
+
+// MemoryBudgetError is returned once loading crosses the budget set with
+// SetMemoryBudget. It matches ErrMemoryBudgetExceeded.
+type MemoryBudgetError struct {
+	Budget   int64 // configured budget in bytes
+	Usage    int64 // estimated usage when the check failed
+	Prefixes int   // prefixes held when the check failed
+}
+
+func (e *MemoryBudgetError) Error() string {
+	return fmt.Sprintf("%v: estimated %d bytes with %d prefixes, budget is %d bytes",
+		ErrMemoryBudgetExceeded, e.Usage, e.Prefixes, e.Budget)
+}
+
+func (e *MemoryBudgetError) Unwrap() error {
+	return ErrMemoryBudgetExceeded
+}
+
+// NonConvergenceError is returned when merging a family's prefixes is
+// still making changes after the iteration limit. It matches
+// ErrNonConvergence.
+type NonConvergenceError struct {
+	Iterations int            // merge passes run
+	Family     string         // "ipv4" or "ipv6"
+	Prefixes   int            // list length after the last pass
+	Sample     []netip.Prefix // up to 10 prefixes from where the last pass still changed the list
+}
+
+func (e *NonConvergenceError) Error() string {
+	sample := make([]string, len(e.Sample))
+	for i, p := range e.Sample {
+		sample[i] = p.String()
+	}
+	return fmt.Sprintf("%v after %d iterations: %d %s prefixes, still changing near %s",
+		ErrNonConvergence, e.Iterations, e.Prefixes, e.Family, strings.Join(sample, ", "))
+}
+
+func (e *NonConvergenceError) Unwrap() error {
+	return ErrNonConvergence
+}
@@ -0,0 +1,118 @@
+package netjugo
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestErrorTaxonomy runs one failure path per sentinel through the public
+// API and checks that errors.Is matches it, as documented in docs/api.md
+func TestErrorTaxonomy(t *testing.T) {
+	// aggregated returns an aggregator with prefixes added and setup applied
+	aggregated := func(t *testing.T, prefixes []string, setup func(pa *PrefixAggregator) error) error {
+		t.Helper()
+		pa := NewPrefixAggregator()
+		if err := setup(pa); err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+		if err := pa.AddPrefixes(prefixes); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		return pa.Aggregate()
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T) error
+		want error
+	}{
+		{"invalid prefix", func(t *testing.T) error {
+			return NewPrefixAggregator().AddPrefix("10.0.0.0/33")
+		}, ErrInvalidPrefix},
+		{"invalid batch entry", func(t *testing.T) error {
+			return NewPrefixAggregator().AddPrefixes([]string{"10.0.0.0/24", "bogus"})
+		}, ErrInvalidPrefix},
+		{"invalid minimum length", func(t *testing.T) error {
+			return NewPrefixAggregator().SetMinPrefixLength(33, 0)
+		}, ErrInvalidMinPrefixLen},
+		{"missing file", func(t *testing.T) error {
+			return NewPrefixAggregator().AddFromFile(filepath.Join(t.TempDir(), "missing.txt"))
+		}, ErrFileNotFound},
+		{"unknown CSV column", func(t *testing.T) error {
+			return NewPrefixAggregator().AddFromCSV(strings.NewReader("cidr\n10.0.0.0/24\n"), CSVOptions{ColumnName: "prefix"})
+		}, ErrInvalidFormat},
+		{"invalid option", func(t *testing.T) error {
+			return NewPrefixAggregator().SetMaxResultPrefixes(-1)
+		}, ErrInvalidOption},
+		{"default route", func(t *testing.T) error {
+			return aggregated(t, []string{"0.0.0.0/1", "128.0.0.0/1"}, func(pa *PrefixAggregator) error {
+				pa.SetRejectDefaultRoute(true)
+				return nil
+			})
+		}, ErrDefaultRoute},
+		{"result too large", func(t *testing.T) error {
+			return aggregated(t, []string{"10.0.0.0/16"}, func(pa *PrefixAggregator) error {
+				if err := pa.SetMaxResultPrefixes(4); err != nil {
+					return err
+				}
+				return pa.SetExcludePrefixes([]string{"10.0.0.0/24"})
+			})
+		}, ErrResultTooLarge},
+		{"memory budget", func(t *testing.T) error {
+			pa := NewPrefixAggregator()
+			if err := pa.SetMemoryBudget(1); err != nil {
+				t.Fatalf("Failed to set memory budget: %v", err)
+			}
+			// The first check happens on the 10,000th add
+			var input strings.Builder
+			for i := 0; i < memoryCheckInterval; i++ {
+				fmt.Fprintf(&input, "10.%d.%d.0/24\n", i/256, i%256)
+			}
+			return pa.AddFromReader(strings.NewReader(input.String()))
+		}, ErrMemoryBudgetExceeded},
+		{"originals not retained", func(t *testing.T) error {
+			return NewPrefixAggregator().Reaggregate()
+		}, ErrOriginalsNotRetained},
+		{"non-convergence", func(t *testing.T) error {
+			saved := maxMergeIterations
+			maxMergeIterations = 1
+			defer func() { maxMergeIterations = saved }()
+			return aggregated(t, []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26"},
+				func(*PrefixAggregator) error { return nil })
+		}, ErrNonConvergence},
+		{"empty result", func(t *testing.T) error {
+			pa := NewPrefixAggregator()
+			pa.SetFailOnEmptyResult(true)
+			return pa.WriteToWriter(&strings.Builder{})
+		}, ErrEmptyResult},
+		{"closed", func(t *testing.T) error {
+			pa := NewPrefixAggregator()
+			if err := pa.Close(); err != nil {
+				t.Fatalf("Failed to close: %v", err)
+			}
+			return pa.Aggregate()
+		}, ErrClosed},
+		{"include too specific", func(t *testing.T) error {
+			return aggregated(t, []string{"10.0.0.0/24"}, func(pa *PrefixAggregator) error {
+				if err := pa.SetIncludeRounding(IncludeReject); err != nil {
+					return err
+				}
+				if err := pa.SetMinPrefixLength(24, 0); err != nil {
+					return err
+				}
+				return pa.SetIncludePrefixes([]string{"192.0.2.0/25"})
+			})
+		}, ErrIncludeTooSpecific},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(t); !errors.Is(err, tt.want) {
+				t.Errorf("Expected an error matching %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package netjugo
+
+import (
+	"math"
+	"slices"
+)
+
+// estimateSampleTarget is roughly how many prefixes per family
+// EstimateAggregation merges; smaller inputs are merged in full
+const estimateSampleTarget = 50000
+
+// estimateMergeDepth is how many levels of merging a sampled address
+// block leaves room for above the typical prefix length
+const estimateMergeDepth = 8
+
+// estimateMinBlocks is the fewest address blocks a sample is drawn from,
+// so that their spread says something about the error
+const estimateMinBlocks = 16
+
+// FamilyEstimate is the estimated aggregation result for one family
+type FamilyEstimate struct {
+	// Input counts the prefixes loaded and Sampled the ones merged
+	Input   int
+	Sampled int
+	// Estimate is the expected result size and Low to High the 95%
+	// confidence interval around it. They are equal when the whole
+	// input was merged.
+	Estimate int
+	Low      int
+	High     int
+}
+
+// EstimateResult is returned by EstimateAggregation
+type EstimateResult struct {
+	IPv4 FamilyEstimate
+	IPv6 FamilyEstimate
+	// Total, TotalLow and TotalHigh combine the families
+	Total     int
+	TotalLow  int
+	TotalHigh int
+	// ReductionRatio is the estimated reduction relative to the loaded
+	// prefixes, as in AggregationStats
+	ReductionRatio float64
+	// Exact reports that the input was small enough to merge in full
+	Exact bool
+}
+
+// EstimateAggregation predicts how many prefixes Aggregate would leave,
+// without changing the aggregator. It merges a sample of the loaded
+// prefixes, all of those in every k-th address block, after rounding
+// them to the minimum lengths, and scales the result up to the whole
+// input. Includes, exclusions and the restriction are not applied. Small
+// inputs, and those whose merges span most of the address space, are
+// merged in full, which gives the exact count.
+func (pa *PrefixAggregator) EstimateAggregation() EstimateResult {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if pa.closed {
+		return EstimateResult{}
+	}
+
+	var r EstimateResult
+	var variance [2]float64
+	r.IPv4, variance[0] = pa.estimateFamily(pa.IPv4Prefixes, true, pa.MinPrefixLenIPv4)
+	r.IPv6, variance[1] = pa.estimateFamily(pa.IPv6Prefixes, false, pa.MinPrefixLenIPv6)
+
+	r.Total = r.IPv4.Estimate + r.IPv6.Estimate
+	margin := int(math.Ceil(1.96 * math.Sqrt(variance[0]+variance[1])))
+	r.TotalLow, r.TotalHigh = max(r.Total-margin, 0), r.Total+margin
+	r.Exact = r.IPv4.Sampled == r.IPv4.Input && r.IPv6.Sampled == r.IPv6.Input
+	if input := r.IPv4.Input + r.IPv6.Input; input > 0 {
+		r.ReductionRatio = max(1.0-float64(r.Total)/float64(input), 0)
+	}
+	return r
+}
+
+// estimateFamily estimates the merge of one family's prefixes and returns
+// the estimate with the variance of its count
+func (pa *PrefixAggregator) estimateFamily(prefixes []*IPPrefix, isIPv4 bool, minLen int) (FamilyEstimate, float64) {
+	family, width := "IPv6", 128
+	if isIPv4 {
+		family, width = "IPv4", 32
+	}
+	effectiveBits := func(p *IPPrefix) int {
+		if minLen > 0 {
+			return min(p.Prefix.Bits(), minLen)
+		}
+		return p.Prefix.Bits()
+	}
+
+	// Merges rarely reach far above the length most prefixes have, so
+	// sampling whole blocks some levels above it keeps them in the sample.
+	// The few prefixes shorter than a block can span several and are
+	// always merged.
+	var lengths [129]int
+	for _, p := range prefixes {
+		lengths[effectiveBits(p)]++
+	}
+	bucketBits, seen := 0, 0
+	for bits, n := range lengths {
+		if seen += n; seen*20 >= len(prefixes) {
+			bucketBits = max(bits-estimateMergeDepth, 0)
+			break
+		}
+	}
+	bucket := func(p *IPPrefix) (uint64, bool) {
+		switch {
+		case effectiveBits(p) < bucketBits:
+			return 0, false
+		case bucketBits == 0:
+			return 0, true
+		case isIPv4:
+			return p.Min.Uint64() >> (width - bucketBits), true
+		default:
+			return p.Min[1] >> (64 - min(bucketBits, 64)), true
+		}
+	}
+
+	// Take every k-th occupied block, in address order
+	keys := make([]uint64, 0, len(prefixes))
+	for _, p := range prefixes {
+		if key, ok := bucket(p); ok {
+			keys = append(keys, key)
+		}
+	}
+	bucketed := len(keys)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	stride := max((bucketed+estimateSampleTarget-1)/estimateSampleTarget, 1)
+	stride = max(min(stride, len(keys)/estimateMinBlocks), 1)
+	selected := keys[:0]
+	for i := stride / 2; i < len(keys); i += stride {
+		selected = append(selected, keys[i])
+	}
+
+	// Per sampled block, the prefixes going in and coming out
+	counts := make([][2]int, len(selected))
+	sample := make([]*IPPrefix, 0, min(len(prefixes), 2*estimateSampleTarget))
+	for _, p := range prefixes {
+		if key, ok := bucket(p); ok {
+			i, found := slices.BinarySearch(selected, key)
+			if !found {
+				continue
+			}
+			counts[i][0]++
+		}
+		sample = append(sample, cloneIPPrefix(p))
+	}
+
+	e := FamilyEstimate{Input: len(prefixes), Sampled: len(sample)}
+	sortPrefixes(sample)
+	err := pa.enforceMinPrefixLength(&sample, minLen, family, nil)
+	if err == nil {
+		err = pa.aggregatePrefixes(&sample)
+	}
+	defer func() {
+		for _, p := range sample {
+			releaseIPPrefix(p)
+		}
+	}()
+	if err != nil {
+		// Sampling cannot help with input Aggregate would reject; report
+		// no reduction
+		e.Estimate, e.Low, e.High = len(prefixes), len(prefixes), len(prefixes)
+		return e, 0
+	}
+
+	exact := 0
+	for _, p := range sample {
+		key, ok := bucket(p)
+		if i, found := slices.BinarySearch(selected, key); ok && found {
+			counts[i][1]++
+		} else {
+			exact++
+		}
+	}
+
+	// A ratio estimate of the blocked part: the sampled blocks' output per
+	// input prefix, applied to every prefix in a block
+	var in, out int
+	for _, c := range counts {
+		in, out = in+c[0], out+c[1]
+	}
+	if in == 0 || in == bucketed {
+		e.Estimate = exact + out
+		e.Low, e.High = e.Estimate, e.Estimate
+		return e, 0
+	}
+	ratio := float64(out) / float64(in)
+	var residuals float64
+	for _, c := range counts {
+		d := float64(c[1]) - ratio*float64(c[0])
+		residuals += d * d
+	}
+	m := float64(len(counts))
+	variance := 0.0
+	if m > 1 {
+		// Var(N*R) with the finite population correction
+		meanIn := float64(in) / m
+		variance = float64(bucketed) * float64(bucketed) * (1 - float64(in)/float64(bucketed)) *
+			residuals / (m - 1) / (m * meanIn * meanIn)
+	}
+
+	e.Estimate = exact + int(math.Round(ratio*float64(bucketed)))
+	margin := int(math.Ceil(1.96 * math.Sqrt(variance)))
+	e.Low, e.High = max(e.Estimate-margin, exact), e.Estimate+margin
+	return e, variance
+}
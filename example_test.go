@@ -0,0 +1,119 @@
+package netjugo_test
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/rretina/netjugo"
+)
+
+func Example() {
+	pa := netjugo.NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"192.168.0.0/24", "192.168.1.0/24", "10.0.0.1", "2001:db8::/33", "2001:db8:8000::/33"}); err != nil {
+		log.Fatal(err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		log.Fatal(err)
+	}
+	for _, prefix := range pa.GetPrefixes() {
+		fmt.Println(prefix)
+	}
+	// Output:
+	// 10.0.0.1/32
+	// 192.168.0.0/23
+	// 2001:db8::/32
+}
+
+func ExamplePrefixAggregator_AddPrefixes() {
+	// The valid entries are added; the others are listed in the error
+	pa := netjugo.NewPrefixAggregator()
+	err := pa.AddPrefixes([]string{"10.0.0.0/25", "not-a-prefix", "10.0.0.128/25"})
+	var batch *netjugo.MultiError
+	if errors.As(err, &batch) {
+		for _, e := range batch.Errors {
+			fmt.Printf("entry %d %q: invalid prefix %v\n", e.Index, e.Input, errors.Is(e, netjugo.ErrInvalidPrefix))
+		}
+		fmt.Printf("%d added\n", batch.Added)
+	}
+	if err := pa.Aggregate(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(pa.GetPrefixes())
+	// Output:
+	// entry 1 "not-a-prefix": invalid prefix true
+	// 2 added
+	// [10.0.0.0/24]
+}
+
+func ExamplePrefixAggregator_SetMinPrefixLength() {
+	// Prefixes more specific than the minimum length are widened to it
+	pa := netjugo.NewPrefixAggregator()
+	if err := pa.SetMinPrefixLength(24, 48); err != nil {
+		log.Fatal(err)
+	}
+	if err := pa.AddPrefixes([]string{"198.51.100.7", "198.51.100.200/30", "2001:db8:0:1::/64"}); err != nil {
+		log.Fatal(err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(pa.GetPrefixes())
+	// Output:
+	// [198.51.100.0/24 2001:db8::/48]
+}
+
+func ExamplePrefixAggregator_SetExcludePrefixes() {
+	// Excluding part of a prefix splits it into the pieces around the hole
+	pa := netjugo.NewPrefixAggregator()
+	if err := pa.AddPrefix("10.0.0.0/16"); err != nil {
+		log.Fatal(err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.64.0/18"}); err != nil {
+		log.Fatal(err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(pa.GetPrefixes())
+	// Output:
+	// [10.0.0.0/18 10.0.128.0/17]
+}
+
+func ExamplePrefixAggregator_GetStats() {
+	pa := netjugo.NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24", "2001:db8::/48"}); err != nil {
+		log.Fatal(err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Timing and memory vary between runs; the counts do not
+	stats := pa.GetStats()
+	fmt.Printf("%d prefixes in, %d out (%d IPv4, %d IPv6)\n", stats.OriginalCount, stats.TotalPrefixes, stats.IPv4PrefixCount, stats.IPv6PrefixCount)
+	fmt.Printf("reduced by %.0f%%\n", stats.ReductionRatio*100)
+	// Output:
+	// 5 prefixes in, 2 out (1 IPv4, 1 IPv6)
+	// reduced by 60%
+}
+
+func ExamplePrefixAggregator_WriteToWriter() {
+	pa := netjugo.NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"2001:db8::/48", "203.0.113.0/25", "203.0.113.128/25", "192.0.2.0/24"}); err != nil {
+		log.Fatal(err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// IPv4 comes first, each family in address order
+	if err := pa.WriteToWriter(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// 192.0.2.0/24
+	// 203.0.113.0/24
+	// 2001:db8::/48
+}
@@ -116,7 +116,12 @@ func main() {
 	fmt.Printf("  System allocation: %.2f KB\n", float64(memStats.AllocBytes)/1024)
 
 	// Write results to file
-	outputFile := "/tmp/aggregated_prefixes.txt"
+	tmp, err := os.CreateTemp("", "aggregated_prefixes-*.txt")
+	if err != nil {
+		log.Fatalf("Failed to create output file: %v", err)
+	}
+	outputFile := tmp.Name()
+	_ = tmp.Close()
 	fmt.Printf("\nWriting results to %s...\n", outputFile)
 	if err := aggregator.WriteToFile(outputFile); err != nil {
 		log.Fatalf("Failed to write results: %v", err)
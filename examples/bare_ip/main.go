@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
@@ -44,8 +45,15 @@ func main() {
 	}
 
 	// Add prefixes
+	// AddPrefixes adds every valid entry and reports each invalid one
 	if err := pa.AddPrefixes(prefixes); err != nil {
-		log.Fatalf("Failed to add prefixes: %v", err)
+		var me *netjugo.MultiError
+		if !errors.As(err, &me) {
+			log.Fatalf("Failed to add prefixes: %v", err)
+		}
+		for _, e := range me.Errors {
+			fmt.Printf("  Skipped entry %d %q: %v\n", e.Index, e.Input, e.Err)
+		}
 	}
 
 	// Perform aggregation
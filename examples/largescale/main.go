@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"runtime"
 	"time"
 
@@ -16,21 +17,35 @@ func main() {
 	// Create a new prefix aggregator
 	aggregator := netjugo.NewPrefixAggregator()
 
-	// Check if large dataset exists
-	datasetPath := ".samples/large-dataset-prefixes.txt"
-
-	fmt.Printf("Attempting to load large dataset from: %s\n", datasetPath)
-
 	// Monitor memory before loading
 	var m1 runtime.MemStats
 	runtime.GC()
 	runtime.ReadMemStats(&m1)
 	fmt.Printf("Memory before loading: %.2f MB\n", float64(m1.Alloc)/(1024*1024))
 
-	// Load the dataset
+	// Load the dataset given on the command line, or generate one
 	start := time.Now()
-	if err := aggregator.AddFromFile(datasetPath); err != nil {
-		log.Fatalf("Failed to load dataset: %v", err)
+	if len(os.Args) > 1 {
+		fmt.Printf("Loading dataset from: %s\n", os.Args[1])
+		if err := aggregator.AddFromFile(os.Args[1]); err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+	} else {
+		fmt.Println("No dataset given, generating 1M synthetic prefixes")
+		prefixes, err := netjugo.Generate(netjugo.GenerateOptions{
+			Count:        1000000,
+			Seed:         1,
+			IPv6Ratio:    0.2,
+			IPv4Lengths:  []int{16, 20, 24},
+			IPv6Lengths:  []int{32, 48},
+			OverlapRatio: 0.3,
+		})
+		if err != nil {
+			log.Fatalf("Failed to generate dataset: %v", err)
+		}
+		if err := aggregator.AddPrefixes(prefixes); err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
 	}
 	loadTime := time.Since(start)
 
@@ -59,21 +74,7 @@ func main() {
 		fmt.Printf("  Memory usage within 1GB limit (%.2f MB used)\n", actualMemoryMB)
 	}
 
-	// For demonstration, we'll aggregate a smaller subset to avoid excessive time
-	fmt.Println("\nNote: For this demo, aggregating first 100k prefixes to avoid long runtime")
-
-	// Create a new aggregator for the subset
-	subsetAggregator := netjugo.NewPrefixAggregator()
-	subsetPath := ".testdata/large_dataset.txt"
-
-	if err := subsetAggregator.AddFromFile(subsetPath); err != nil {
-		log.Printf("Sample file not found, proceeding with full dataset aggregation...")
-		// Use the full aggregator
-		performAggregation(aggregator, "Full Dataset")
-	} else {
-		// Use subset for demo
-		performAggregation(subsetAggregator, "100k Sample")
-	}
+	performAggregation(aggregator, "Full Dataset")
 
 	fmt.Println("\nLarge scale processing completed successfully!")
 }
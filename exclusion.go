@@ -3,7 +3,7 @@ package netjugo
 import (
 	"fmt"
 	"net/netip"
-	"sort"
+	"slices"
 
 	"github.com/holiman/uint256"
 )
@@ -18,17 +18,131 @@ const (
 	// RecommendedMinExclusionIPv4 and RecommendedMinExclusionIPv6 prefix lengths for optimal aggregation
 	RecommendedMinExclusionIPv4 = 30 // /30 for IPv4
 	RecommendedMinExclusionIPv6 = 64 // /64 for IPv6
+
+	// PointToPointExclusionIPv4 and PointToPointExclusionIPv6 replace the
+	// recommended lengths under SetPointToPointFriendly, so /31 (RFC 3021)
+	// and /127 (RFC 6164) link exclusions do not warn
+	PointToPointExclusionIPv4 = 31
+	PointToPointExclusionIPv6 = 127
+)
+
+// recommendedExclusionLen is the longest exclusion of a family that does
+// not warn as too specific
+func (pa *PrefixAggregator) recommendedExclusionLen(isIPv4 bool) int {
+	switch {
+	case isIPv4 && pa.pointToPoint:
+		return PointToPointExclusionIPv4
+	case isIPv4:
+		return RecommendedMinExclusionIPv4
+	case pa.pointToPoint:
+		return PointToPointExclusionIPv6
+	default:
+		return RecommendedMinExclusionIPv6
+	}
+}
+
+// maxExclusionLenIPv4 and maxExclusionLenIPv6 are the limits Aggregate
+// enforces, variables so tests can tighten them
+var (
+	maxExclusionLenIPv4 = MinExclusionLenIPv4
+	maxExclusionLenIPv6 = MinExclusionLenIPv6
 )
 
 func (pa *PrefixAggregator) processInclusions() error {
-	// Add include prefixes to main prefix lists
-	pa.IPv4Prefixes = append(pa.IPv4Prefixes, pa.IncludeIPv4...)
-	pa.IPv6Prefixes = append(pa.IPv6Prefixes, pa.IncludeIPv6...)
+	// Add copies of the include prefixes to the main prefix lists so the
+	// main lists own their entries and can release them to the pool
+	for _, p := range pa.IncludeIPv4 {
+		pa.IPv4Prefixes = append(pa.IPv4Prefixes, cloneIPPrefix(p))
+	}
+	for _, p := range pa.IncludeIPv6 {
+		pa.IPv6Prefixes = append(pa.IPv6Prefixes, cloneIPPrefix(p))
+	}
+
+	return nil
+}
+
+// checkIncludeRounding warns about, or under IncludeReject fails on,
+// include prefixes more specific than the minimum prefix length
+func (pa *PrefixAggregator) checkIncludeRounding() error {
+	if pa.includeRounding == IncludeExact {
+		return nil
+	}
+	for _, f := range []struct {
+		includes []*IPPrefix
+		minLen   int
+	}{{pa.IncludeIPv4, pa.MinPrefixLenIPv4}, {pa.IncludeIPv6, pa.MinPrefixLenIPv6}} {
+		if f.minLen == 0 {
+			continue
+		}
+		for _, p := range f.includes {
+			if p.Prefix.Bits() <= f.minLen {
+				continue
+			}
+			if pa.includeRounding == IncludeReject {
+				return fmt.Errorf("%w: %s is longer than /%d", ErrIncludeTooSpecific, p.Prefix, f.minLen)
+			}
+			rounded, _ := p.Prefix.Addr().Prefix(f.minLen)
+			pa.addWarning(Warning{
+				Code:    WarnIncludeRounded,
+				Message: fmt.Sprintf("include %s rounded up to %s by the minimum prefix length", p.Prefix, rounded),
+				Prefix:  p.Prefix,
+				Related: rounded,
+			})
+		}
+	}
+	return nil
+}
 
+// checkResultSize enforces SetMaxResultPrefixes on the current lists
+func (pa *PrefixAggregator) checkResultSize() error {
+	ipv4, ipv6 := len(pa.IPv4Prefixes), len(pa.IPv6Prefixes)
+	if pa.maxResultPrefixes > 0 && ipv4+ipv6 > pa.maxResultPrefixes {
+		return fmt.Errorf("%w: reached %d prefixes (%d IPv4, %d IPv6), limit is %d",
+			ErrResultTooLarge, ipv4+ipv6, ipv4, ipv6, pa.maxResultPrefixes)
+	}
 	return nil
 }
 
+// protectInclusions adds the include prefixes back after exclusions have
+// run and re-aggregates, so no exclusion can leave a hole in them
+func (pa *PrefixAggregator) protectInclusions() error {
+	if err := pa.restoreIncludes(&pa.IPv4Prefixes, pa.IncludeIPv4, pa.MinPrefixLenIPv4); err != nil {
+		return err
+	}
+	return pa.restoreIncludes(&pa.IPv6Prefixes, pa.IncludeIPv6, pa.MinPrefixLenIPv6)
+}
+
+func (pa *PrefixAggregator) restoreIncludes(prefixes *[]*IPPrefix, includes []*IPPrefix, minLen int) error {
+	if len(includes) == 0 {
+		return nil
+	}
+
+	sorted := len(*prefixes)
+	for _, p := range includes {
+		include := cloneIPPrefix(p)
+		// Match the rounding the include got on its way in
+		if minLen > 0 && pa.includeRounding != IncludeExact {
+			rounded, err := roundUpToMinLength(include, minLen)
+			if err != nil {
+				releaseIPPrefix(include)
+				return err
+			}
+			if rounded != include {
+				releaseIPPrefix(include)
+			}
+			include = rounded
+		}
+		*prefixes = append(*prefixes, include)
+	}
+
+	mergePending(*prefixes, sorted)
+	return pa.aggregatePrefixes(prefixes)
+}
+
 func (pa *PrefixAggregator) processExclusionsNew() error {
+	pa.skippedExclusions = 0
+	pa.warnExcludesOverlappingIncludes()
+
 	if err := pa.processExclusionsIPv4New(); err != nil {
 		return fmt.Errorf("failed to process IPv4 exclusions: %w", err)
 	}
@@ -41,77 +155,163 @@ func (pa *PrefixAggregator) processExclusionsNew() error {
 }
 
 func (pa *PrefixAggregator) processExclusionsIPv4New() error {
-	if len(pa.ExcludeIPv4) == 0 {
-		return nil
+	if err := pa.ensureExclusionOrder(&pa.IPv4Prefixes); err != nil {
+		return err
 	}
 
-	for _, excludePrefix := range pa.ExcludeIPv4 {
-		// Check minimum exclusion prefix length
-		if excludePrefix.Prefix.Bits() > MinExclusionLenIPv4 {
-			// Skip exclusions that are too specific
-			continue
-		}
+	for _, source := range pa.activeExclusions(true) {
+		for _, excludePrefix := range source.prefixes {
+			// Check minimum exclusion prefix length
+			if excludePrefix.Prefix.Bits() > maxExclusionLenIPv4 {
+				pa.skipExclusion(source, excludePrefix, WarnExclusionTooSpecific,
+					fmt.Sprintf("is more specific than the /%d limit", maxExclusionLenIPv4))
+				continue
+			}
 
-		// Warn if exclusion is more specific than recommended
-		if excludePrefix.Prefix.Bits() > RecommendedMinExclusionIPv4 {
-			pa.addWarning(fmt.Sprintf("WARNING: IPv4 exclusion %s is more specific than recommended /%d. This may significantly impact aggregation efficiency.",
-				excludePrefix.Prefix.String(), RecommendedMinExclusionIPv4))
-		}
+			// Warn if exclusion is more specific than recommended
+			if recommended := pa.recommendedExclusionLen(true); excludePrefix.Prefix.Bits() > recommended {
+				pa.addWarning(Warning{
+					Code: WarnSpecificExclusion,
+					Message: fmt.Sprintf("WARNING: IPv4 exclusion %s%s is more specific than recommended /%d. This may significantly impact aggregation efficiency.",
+						excludePrefix.Prefix.String(), source.describe(), recommended),
+					Prefix: excludePrefix.Prefix,
+					Set:    source.set,
+				})
+			}
 
-		overlapping := pa.findOverlappingPrefixes(excludePrefix, pa.IPv4Prefixes)
+			overlapping := pa.findOverlappingPrefixes(excludePrefix, pa.IPv4Prefixes)
 
-		if len(overlapping) == 0 {
-			continue
-		}
+			if len(overlapping) == 0 {
+				if !pa.exclusionApplied(source, excludePrefix) {
+					pa.skipExclusion(source, excludePrefix, WarnExclusionNoEffect, "overlaps no remaining prefix")
+				}
+				continue
+			}
 
-		// Process based on whether exclusion is larger or smaller than overlapping prefixes
-		newPrefixes, err := pa.processExclusionNew(excludePrefix, overlapping, true)
-		if err != nil {
-			return fmt.Errorf("failed to process exclusion %s: %w", excludePrefix.Prefix.String(), err)
-		}
+			// Process based on whether exclusion is larger or smaller than overlapping prefixes
+			newPrefixes, err := pa.processExclusionNew(excludePrefix, overlapping, true)
+			if err != nil {
+				return fmt.Errorf("%w: %s%s: %w", ErrExclusionFailed, excludePrefix.Prefix.String(), source.describe(), err)
+			}
+
+			pa.IPv4Prefixes = pa.replacePrefixesInList(pa.IPv4Prefixes, overlapping, newPrefixes)
+			survived := releaseReplaced(overlapping, newPrefixes)
+			pa.markExclusionApplied(source, excludePrefix, len(overlapping)-survived, len(newPrefixes)-survived)
 
-		pa.IPv4Prefixes = pa.replacePrefixesInList(pa.IPv4Prefixes, overlapping, newPrefixes)
+			// Splitting is where results explode, so check after each step
+			if err := pa.checkResultSize(); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
 func (pa *PrefixAggregator) processExclusionsIPv6New() error {
-	if len(pa.ExcludeIPv6) == 0 {
-		return nil
+	if err := pa.ensureExclusionOrder(&pa.IPv6Prefixes); err != nil {
+		return err
 	}
 
-	for _, excludePrefix := range pa.ExcludeIPv6 {
-		// Check minimum exclusion prefix length
-		if excludePrefix.Prefix.Bits() > MinExclusionLenIPv6 {
-			// Skip exclusions that are too specific
-			continue
-		}
+	for _, source := range pa.activeExclusions(false) {
+		for _, excludePrefix := range source.prefixes {
+			// Check minimum exclusion prefix length
+			if excludePrefix.Prefix.Bits() > maxExclusionLenIPv6 {
+				pa.skipExclusion(source, excludePrefix, WarnExclusionTooSpecific,
+					fmt.Sprintf("is more specific than the /%d limit", maxExclusionLenIPv6))
+				continue
+			}
 
-		// Warn if exclusion is more specific than recommended
-		if excludePrefix.Prefix.Bits() > RecommendedMinExclusionIPv6 {
-			pa.addWarning(fmt.Sprintf("WARNING: IPv6 exclusion %s is more specific than recommended /%d. This may significantly impact aggregation efficiency.",
-				excludePrefix.Prefix.String(), RecommendedMinExclusionIPv6))
-		}
+			// Warn if exclusion is more specific than recommended
+			if recommended := pa.recommendedExclusionLen(false); excludePrefix.Prefix.Bits() > recommended {
+				pa.addWarning(Warning{
+					Code: WarnSpecificExclusion,
+					Message: fmt.Sprintf("WARNING: IPv6 exclusion %s%s is more specific than recommended /%d. This may significantly impact aggregation efficiency.",
+						excludePrefix.Prefix.String(), source.describe(), recommended),
+					Prefix: excludePrefix.Prefix,
+					Set:    source.set,
+				})
+			}
 
-		overlapping := pa.findOverlappingPrefixes(excludePrefix, pa.IPv6Prefixes)
+			overlapping := pa.findOverlappingPrefixes(excludePrefix, pa.IPv6Prefixes)
 
-		if len(overlapping) == 0 {
-			continue
-		}
+			if len(overlapping) == 0 {
+				if !pa.exclusionApplied(source, excludePrefix) {
+					pa.skipExclusion(source, excludePrefix, WarnExclusionNoEffect, "overlaps no remaining prefix")
+				}
+				continue
+			}
 
-		// Process based on whether exclusion is larger or smaller than overlapping prefixes
-		newPrefixes, err := pa.processExclusionNew(excludePrefix, overlapping, false)
-		if err != nil {
-			return fmt.Errorf("failed to process exclusion %s: %w", excludePrefix.Prefix.String(), err)
-		}
+			// Process based on whether exclusion is larger or smaller than overlapping prefixes
+			newPrefixes, err := pa.processExclusionNew(excludePrefix, overlapping, false)
+			if err != nil {
+				return fmt.Errorf("%w: %s%s: %w", ErrExclusionFailed, excludePrefix.Prefix.String(), source.describe(), err)
+			}
 
-		pa.IPv6Prefixes = pa.replacePrefixesInList(pa.IPv6Prefixes, overlapping, newPrefixes)
+			pa.IPv6Prefixes = pa.replacePrefixesInList(pa.IPv6Prefixes, overlapping, newPrefixes)
+			survived := releaseReplaced(overlapping, newPrefixes)
+			pa.markExclusionApplied(source, excludePrefix, len(overlapping)-survived, len(newPrefixes)-survived)
+
+			// Splitting is where results explode, so check after each step
+			if err := pa.checkResultSize(); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// exclusionKey identifies an exclusion by its set and prefix
+type exclusionKey struct {
+	set    string
+	prefix netip.Prefix
+}
+
+func (pa *PrefixAggregator) exclusionApplied(source exclusionSource, p *IPPrefix) bool {
+	_, ok := pa.appliedExclusions[exclusionKey{source.set, p.Prefix}]
+	return ok
+}
+
+// exclusionCounts is how many prefixes an exclusion removed from the
+// lists and how many it put back as pieces of them
+type exclusionCounts struct {
+	removed, created int
+}
+
+func (pa *PrefixAggregator) markExclusionApplied(source exclusionSource, p *IPPrefix, removed, created int) {
+	if pa.appliedExclusions == nil {
+		pa.appliedExclusions = make(map[exclusionKey]exclusionCounts)
+	}
+	key := exclusionKey{source.set, p.Prefix}
+	counts := pa.appliedExclusions[key]
+	counts.removed += removed
+	counts.created += created
+	pa.appliedExclusions[key] = counts
+}
+
+// skipExclusion counts and warns about an exclusion that was not applied.
+// An exclusion SetValidateConstraints already reported as outside the
+// input is only counted.
+func (pa *PrefixAggregator) skipExclusion(source exclusionSource, p *IPPrefix, code WarningCode, reason string) {
+	family := "IPv6"
+	if p.Prefix.Addr().Is4() {
+		family = "IPv4"
+	}
+	pa.skippedExclusions++
+	if code == WarnExclusionNoEffect && slices.ContainsFunc(pa.warnings, func(w Warning) bool {
+		return (w.Code == WarnConstraintOutsideInput || w.Code == WarnConstraintFamily) && w.Prefix == p.Prefix && w.Set == source.set
+	}) {
+		return
+	}
+	pa.addWarning(Warning{
+		Code:    code,
+		Message: fmt.Sprintf("WARNING: %s exclusion %s%s %s and was skipped", family, p.Prefix, source.describe(), reason),
+		Prefix:  p.Prefix,
+		Set:     source.set,
+	})
+}
+
 func (pa *PrefixAggregator) processExclusionNew(excludePrefix *IPPrefix, overlappingPrefixes []*IPPrefix, isIPv4 bool) ([]*IPPrefix, error) {
 	var result []*IPPrefix
 
@@ -133,6 +333,7 @@ func (pa *PrefixAggregator) processExclusionNew(excludePrefix *IPPrefix, overlap
 			if err != nil {
 				return nil, fmt.Errorf("failed to create complement: %w", err)
 			}
+			pa.recordSplit(overlapping, excludePrefix, complement)
 			result = append(result, complement...)
 		} else if overlaps(excludePrefix, overlapping) {
 			// Partial overlap - need to trim
@@ -140,6 +341,7 @@ func (pa *PrefixAggregator) processExclusionNew(excludePrefix *IPPrefix, overlap
 			if err != nil {
 				return nil, fmt.Errorf("failed to trim overlap: %w", err)
 			}
+			pa.recordSplit(overlapping, excludePrefix, trimmed)
 			result = append(result, trimmed...)
 		} else {
 			// No overlap - keep the original
@@ -162,7 +364,7 @@ func (pa *PrefixAggregator) createComplement(container, exclude *IPPrefix, isIPv
 	// Before exclusion: from container.Min to exclude.Min - 1
 	if container.Min.Cmp(exclude.Min) < 0 {
 		beforeMax := new(uint256.Int).Sub(exclude.Min, uint256.NewInt(1))
-		beforePrefixes, err := pa.createOptimalPrefixes(container.Min, beforeMax, isIPv4)
+		beforePrefixes, err := createOptimalPrefixes(container.Min, beforeMax, isIPv4)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create prefixes before exclusion: %w", err)
 		}
@@ -172,7 +374,7 @@ func (pa *PrefixAggregator) createComplement(container, exclude *IPPrefix, isIPv
 	// After exclusion: from exclude.Max + 1 to container.Max
 	if exclude.Max.Cmp(container.Max) < 0 {
 		afterMin := new(uint256.Int).Add(exclude.Max, uint256.NewInt(1))
-		afterPrefixes, err := pa.createOptimalPrefixes(afterMin, container.Max, isIPv4)
+		afterPrefixes, err := createOptimalPrefixes(afterMin, container.Max, isIPv4)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create prefixes after exclusion: %w", err)
 		}
@@ -184,7 +386,7 @@ func (pa *PrefixAggregator) createComplement(container, exclude *IPPrefix, isIPv
 
 // createOptimalPrefixes creates the minimal set of CIDR prefixes that
 // cover the range from min to max (inclusive)
-func (pa *PrefixAggregator) createOptimalPrefixes(minVal, maxVal *uint256.Int, isIPv4 bool) ([]*IPPrefix, error) {
+func createOptimalPrefixes(minVal, maxVal *uint256.Int, isIPv4 bool) ([]*IPPrefix, error) {
 	var result []*IPPrefix
 
 	// Current position in the range
@@ -194,7 +396,7 @@ func (pa *PrefixAggregator) createOptimalPrefixes(minVal, maxVal *uint256.Int, i
 		// Find the largest prefix that:
 		// 1. Starts at 'current'
 		// 2. Doesn't exceed 'max'
-		prefix, prefixMax, err := pa.findLargestValidPrefix(current, maxVal, isIPv4)
+		prefix, prefixMax, err := findLargestValidPrefix(current, maxVal, isIPv4)
 		if err != nil {
 			return nil, fmt.Errorf("failed to find valid prefix: %w", err)
 		}
@@ -215,31 +417,10 @@ func (pa *PrefixAggregator) createOptimalPrefixes(minVal, maxVal *uint256.Int, i
 
 // findLargestValidPrefix finds the largest CIDR prefix that starts at 'start'
 // and doesn't exceed 'maxAllowed'
-func (pa *PrefixAggregator) findLargestValidPrefix(start, maxAllowed *uint256.Int, isIPv4 bool) (*IPPrefix, *uint256.Int, error) {
-	// Convert start to IP address
-	var addr netip.Addr
+func findLargestValidPrefix(start, maxAllowed *uint256.Int, isIPv4 bool) (*IPPrefix, *uint256.Int, error) {
+	addr := uint256ToAddr(start, isIPv4)
 	var err error
 
-	if isIPv4 {
-		// Extract the lower 32 bits for IPv4
-		ipBytes := make([]byte, 4)
-		startBytes := start.Bytes32()
-		copy(ipBytes, startBytes[28:32])
-		addr, _ = netip.AddrFromSlice(ipBytes)
-		if !addr.IsValid() {
-			return nil, nil, fmt.Errorf("failed to create valid IPv4 address")
-		}
-	} else {
-		// Use all 128 bits for IPv6
-		ipBytes := make([]byte, 16)
-		startBytes := start.Bytes32()
-		copy(ipBytes, startBytes[16:32])
-		addr, _ = netip.AddrFromSlice(ipBytes)
-		if !addr.IsValid() {
-			return nil, nil, fmt.Errorf("failed to create valid IPv6 address")
-		}
-	}
-
 	// Try different prefix lengths, starting from the most general
 	maxBits := 128
 	if isIPv4 {
@@ -248,7 +429,7 @@ func (pa *PrefixAggregator) findLargestValidPrefix(start, maxAllowed *uint256.In
 
 	// Find the number of trailing zeros in start - this determines
 	// the maximum prefix length we can use
-	trailingZeros := pa.countTrailingZeros(start, isIPv4)
+	trailingZeros := countTrailingZeros(start, isIPv4)
 
 	// Start with the largest possible prefix
 	for prefixLen := 0; prefixLen <= maxBits; prefixLen++ {
@@ -285,7 +466,7 @@ func (pa *PrefixAggregator) findLargestValidPrefix(start, maxAllowed *uint256.In
 	hostBits := maxBits
 	prefix, err := addr.Prefix(hostBits)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create host prefix: %w", err)
+		return nil, nil, fmt.Errorf("%w: failed to create host prefix: %w", ErrInvalidPrefix, err)
 	}
 
 	result := acquireIPPrefix()
@@ -296,7 +477,7 @@ func (pa *PrefixAggregator) findLargestValidPrefix(start, maxAllowed *uint256.In
 }
 
 // countTrailingZeros counts the number of trailing zero bits
-func (pa *PrefixAggregator) countTrailingZeros(n *uint256.Int, isIPv4 bool) int {
+func countTrailingZeros(n *uint256.Int, isIPv4 bool) int {
 	if n.IsZero() {
 		if isIPv4 {
 			return 32
@@ -345,12 +526,40 @@ func (pa *PrefixAggregator) trimOverlapNew(original, exclude *IPPrefix, isIPv4 b
 	}
 
 	if resultMin.Cmp(resultMax) <= 0 {
-		return pa.createOptimalPrefixes(resultMin, resultMax, isIPv4)
+		return createOptimalPrefixes(resultMin, resultMax, isIPv4)
 	}
 
 	return []*IPPrefix{}, nil
 }
 
+// ensureExclusionOrder sorts and merges list if it is not already sorted
+// and disjoint, which findOverlappingPrefixes relies on. The merge phase
+// leaves it that way, so a repair means a code path broke the order.
+func (pa *PrefixAggregator) ensureExclusionOrder(list *[]*IPPrefix) error {
+	if orderedDisjoint(*list) {
+		return nil
+	}
+	if pa.logger != nil {
+		pa.logger.Debug("repairing unordered prefix list before exclusions", "prefixes", len(*list))
+	}
+	sortPrefixes(*list)
+	return pa.aggregatePrefixes(list)
+}
+
+// orderedDisjoint reports whether each prefix of list starts after the
+// previous one ends
+func orderedDisjoint(list []*IPPrefix) bool {
+	for i := 1; i < len(list); i++ {
+		if list[i].Min.Cmp(list[i-1].Max) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// findOverlappingPrefixes returns the prefixes of prefixList overlapping
+// target. prefixList must be sorted and disjoint, see ensureExclusionOrder;
+// replacePrefixesInList keeps it that way between exclusions.
 func (pa *PrefixAggregator) findOverlappingPrefixes(target *IPPrefix, prefixList []*IPPrefix) []*IPPrefix {
 	if len(prefixList) == 0 {
 		return nil
@@ -399,29 +608,54 @@ func (pa *PrefixAggregator) findOverlappingPrefixes(target *IPPrefix, prefixList
 	return overlapping
 }
 
+// releaseReplaced returns the entries of replaced that did not survive
+// into kept back to the pool, and counts the ones that did
+func releaseReplaced(replaced, kept []*IPPrefix) (survivors int) {
+	for _, old := range replaced {
+		survived := false
+		for _, p := range kept {
+			if p == old {
+				survived = true
+				break
+			}
+		}
+		if !survived {
+			releaseIPPrefix(old)
+		} else {
+			survivors++
+		}
+	}
+	return survivors
+}
+
+// replacePrefixesInList swaps toReplace, the result of
+// findOverlappingPrefixes, for newPrefixes. toReplace is a contiguous run
+// of the sorted list and newPrefixes lie within it, so only newPrefixes
+// are sorted and spliced in.
 func (pa *PrefixAggregator) replacePrefixesInList(originalList []*IPPrefix, toReplace []*IPPrefix, newPrefixes []*IPPrefix) []*IPPrefix {
-	// Create a set of prefixes to remove for efficient lookup
-	toRemove := make(map[*IPPrefix]bool)
-	for _, prefix := range toReplace {
-		toRemove[prefix] = true
+	sortPrefixes(newPrefixes)
+	if len(toReplace) == 0 {
+		return originalList
 	}
 
-	var result []*IPPrefix
+	start, found := slices.BinarySearchFunc(originalList, toReplace[0], compareIPPrefix)
+	end := start + len(toReplace)
+	if found && end <= len(originalList) && slices.Equal(originalList[start:end], toReplace) {
+		return slices.Replace(originalList, start, end, newPrefixes...)
+	}
 
-	// Add prefixes that are not being replaced
+	// Not a run of the list after all: filter and sort the whole list
+	toRemove := make(map[*IPPrefix]bool, len(toReplace))
+	for _, prefix := range toReplace {
+		toRemove[prefix] = true
+	}
+	result := make([]*IPPrefix, 0, len(originalList)-len(toReplace)+len(newPrefixes))
 	for _, prefix := range originalList {
 		if !toRemove[prefix] {
 			result = append(result, prefix)
 		}
 	}
-
-	// Add new prefixes
 	result = append(result, newPrefixes...)
-
-	// Sort to maintain order
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Min.Cmp(result[j].Min) < 0
-	})
-
+	sortPrefixes(result)
 	return result
 }
@@ -1,6 +1,13 @@
 package netjugo
 
 import (
+	"errors"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -110,6 +117,62 @@ func TestCompleteExclusion(t *testing.T) {
 	}
 }
 
+func TestCompleteExclusionWrite(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefix("192.168.1.0/24"); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+		if err := pa.SetExcludePrefixes([]string{"192.168.1.0/24"}); err != nil {
+			t.Fatalf("Failed to set exclude prefixes: %v", err)
+		}
+		pa.SetFailOnEmptyResult(fail)
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+
+		details := pa.GetWarningDetails()
+		if len(details) != 1 || details[0].Code != WarnEmptyResult {
+			t.Errorf("fail=%v: expected one %s warning, got %v", fail, WarnEmptyResult, details)
+		}
+
+		var buf strings.Builder
+		err := pa.WriteToWriter(&buf)
+		path := filepath.Join(t.TempDir(), "out.txt")
+		fileErr := pa.WriteToFile(path)
+		_, statErr := os.Stat(path)
+
+		if !fail {
+			if err != nil || fileErr != nil {
+				t.Fatalf("Expected an empty write to succeed, got %v, %v", err, fileErr)
+			}
+			if buf.Len() != 0 || statErr != nil {
+				t.Errorf("Expected an empty output file, got %q, %v", buf.String(), statErr)
+			}
+			continue
+		}
+
+		if !errors.Is(err, ErrEmptyResult) || !errors.Is(fileErr, ErrEmptyResult) {
+			t.Fatalf("Expected ErrEmptyResult, got %v, %v", err, fileErr)
+		}
+		if !os.IsNotExist(statErr) {
+			t.Errorf("Expected no output file, stat returned %v", statErr)
+		}
+		if _, err := pa.WriteToFiles(filepath.Join(t.TempDir(), "out-%d.txt"), 10); !errors.Is(err, ErrEmptyResult) {
+			t.Errorf("Expected ErrEmptyResult from WriteToFiles, got %v", err)
+		}
+	}
+
+	// Nothing added is not an empty result worth a warning
+	pa := NewPrefixAggregator()
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if warnings := pa.GetWarnings(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings without input, got %v", warnings)
+	}
+}
+
 func TestPartialExclusion(t *testing.T) {
 	pa := NewPrefixAggregator()
 
@@ -431,6 +494,131 @@ func TestExclusionWarnings(t *testing.T) {
 	}
 }
 
+func TestSkippedExclusionWarnings(t *testing.T) {
+	// Tighten the limits the way a future release might
+	defer func(v4, v6 int) { maxExclusionLenIPv4, maxExclusionLenIPv6 = v4, v6 }(maxExclusionLenIPv4, maxExclusionLenIPv6)
+	maxExclusionLenIPv4, maxExclusionLenIPv6 = 30, 64
+
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"192.168.0.0/16", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"192.168.1.1/32", "2001:db8::1/128", "172.16.0.0/24", "192.168.2.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	codes := make(map[string]WarningCode)
+	for _, w := range pa.GetWarningDetails() {
+		if w.Code == WarnExclusionTooSpecific || w.Code == WarnExclusionNoEffect {
+			codes[w.Prefix.String()] = w.Code
+		}
+	}
+	want := map[string]WarningCode{
+		"192.168.1.1/32":  WarnExclusionTooSpecific,
+		"2001:db8::1/128": WarnExclusionTooSpecific,
+		"172.16.0.0/24":   WarnExclusionNoEffect,
+	}
+	if !maps.Equal(codes, want) {
+		t.Errorf("Expected skipped exclusion warnings %v, got %v", want, codes)
+	}
+	if got := pa.GetStats().SkippedExclusions; got != 3 {
+		t.Errorf("Expected 3 skipped exclusions in stats, got %d", got)
+	}
+	// The too-specific exclusions were not applied
+	if covered, _ := pa.Covers("192.168.1.1"); !covered {
+		t.Error("Expected the skipped exclusion to leave its address in the result")
+	}
+}
+
+func TestNoEffectExclusionWarning(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	// The second exclusion only covers space the first already removed
+	if err := pa.SetExcludePrefixes([]string{"10.0.0.0/25", "10.0.0.0/26", "172.16.0.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	var skipped []string
+	for _, w := range pa.GetWarningDetails() {
+		if w.Code == WarnExclusionNoEffect {
+			skipped = append(skipped, w.Prefix.String())
+		}
+	}
+	if want := []string{"10.0.0.0/26", "172.16.0.0/24"}; !slices.Equal(skipped, want) {
+		t.Errorf("Expected no-effect warnings for %v, got %v", want, skipped)
+	}
+	if got := pa.GetStats().SkippedExclusions; got != 2 {
+		t.Errorf("Expected 2 skipped exclusions in stats, got %d", got)
+	}
+
+	// An exclusion already reported by SetValidateConstraints is counted
+	// but not reported twice
+	validated := NewPrefixAggregator()
+	validated.SetValidateConstraints(true)
+	if err := validated.AddPrefixes([]string{"10.0.0.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := validated.SetExcludePrefixes([]string{"172.16.0.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := validated.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	warnings := validated.GetWarningDetails()
+	if len(warnings) != 1 || warnings[0].Code != WarnConstraintOutsideInput {
+		t.Errorf("Expected only the outside-input warning, got %v", warnings)
+	}
+	if got := validated.GetStats().SkippedExclusions; got != 1 {
+		t.Errorf("Expected 1 skipped exclusion in stats, got %d", got)
+	}
+}
+
+func TestExclusionOrderRepaired(t *testing.T) {
+	h := &recordHandler{}
+	pa := NewPrefixAggregator()
+	pa.SetLogger(slog.New(h))
+	if err := pa.SetExcludePrefixes([]string{"10.0.1.0/25", "192.0.2.0/26"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+
+	// Hand the exclusion phase a list out of order and with a nested
+	// prefix, which the merge phase would never produce
+	for _, s := range []string{"192.0.2.0/24", "10.0.1.0/24", "172.16.0.0/16", "10.0.0.0/16"} {
+		p, err := parseIPPrefix(s)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", s, err)
+		}
+		pa.IPv4Prefixes = append(pa.IPv4Prefixes, p)
+	}
+	if err := pa.processExclusionsNew(); err != nil {
+		t.Fatalf("processExclusionsNew failed: %v", err)
+	}
+
+	if _, ok := h.find(slog.LevelDebug, "repairing unordered prefix list before exclusions"); !ok {
+		t.Error("Expected the unordered list to be reported")
+	}
+	var got []string
+	for _, p := range pa.IPv4Prefixes {
+		got = append(got, p.Prefix.String())
+	}
+	want := []string{
+		"10.0.0.0/24", "10.0.1.128/25", "10.0.2.0/23", "10.0.4.0/22", "10.0.8.0/21", "10.0.16.0/20",
+		"10.0.32.0/19", "10.0.64.0/18", "10.0.128.0/17", "172.16.0.0/16",
+		"192.0.2.64/26", "192.0.2.128/25",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
 func TestWarningHandlerConcurrency(t *testing.T) {
 	pa := NewPrefixAggregator()
 
@@ -473,3 +661,402 @@ func TestWarningHandlerConcurrency(t *testing.T) {
 		t.Errorf("Expected %d warnings in GetWarnings(), got %d", len(exclusions), len(warnings))
 	}
 }
+
+func TestIncludePrefixesOwnedSeparately(t *testing.T) {
+	pa := NewPrefixAggregator()
+
+	if err := pa.AddPrefix("192.168.0.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.SetIncludePrefixes([]string{"192.168.1.0/24"}); err != nil {
+		t.Fatalf("Failed to set include prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	// The include merged into 192.168.0.0/23 and its working copy went back
+	// to the pool; the configured include itself must be untouched.
+	if got := pa.IncludeIPv4[0].Prefix.String(); got != "192.168.1.0/24" {
+		t.Errorf("Include prefix was modified by aggregation: %s", got)
+	}
+	for _, p := range pa.IPv4Prefixes {
+		if p == pa.IncludeIPv4[0] {
+			t.Error("Result list shares an entry with the include list")
+		}
+	}
+
+	// Re-aggregating must apply the include again without corruption
+	if err := pa.AddPrefix("192.168.2.0/23"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to re-aggregate: %v", err)
+	}
+	result := pa.GetIPv4Prefixes()
+	if len(result) != 1 || result[0] != "192.168.0.0/22" {
+		t.Errorf("Expected [192.168.0.0/22], got %v", result)
+	}
+}
+
+func TestStatsWithIncludesNotNegative(t *testing.T) {
+	// One input prefix and three disjoint includes: four results from a
+	// single original used to give a reduction ratio of -300%
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefix("10.0.0.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.SetIncludePrefixes([]string{"172.16.0.0/24", "192.168.0.0/24", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to set includes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.0.128/25"}); err != nil {
+		t.Fatalf("Failed to set excludes: %v", err)
+	}
+
+	stats := pa.GetStats()
+	if stats.IncludedCount != 3 || stats.ExcludedCount != 1 {
+		t.Errorf("Expected 3 included and 1 excluded before aggregation, got %d and %d", stats.IncludedCount, stats.ExcludedCount)
+	}
+
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	stats = pa.GetStats()
+	if stats.TotalPrefixes != 4 {
+		t.Fatalf("Expected 4 prefixes, got %d: %v", stats.TotalPrefixes, pa.GetPrefixes())
+	}
+	if stats.ReductionRatio < 0 {
+		t.Errorf("Reduction ratio must not be negative, got %f", stats.ReductionRatio)
+	}
+	if stats.ReductionRatio != 0 {
+		t.Errorf("Expected 0 reduction for 4 inputs and 4 results, got %f", stats.ReductionRatio)
+	}
+}
+
+func TestConstraintOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order ConstraintOrder
+		want  []string
+	}{
+		{"excludes win", ExcludesWin, []string{
+			"10.0.0.0/8", "192.168.0.0/24", "192.168.2.0/23", "192.168.4.0/22",
+			"192.168.8.0/21", "192.168.16.0/20", "192.168.32.0/19", "192.168.64.0/18", "192.168.128.0/17",
+		}},
+		{"includes win", IncludesWin, []string{"10.0.0.0/8", "192.168.0.0/16"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pa := NewPrefixAggregator()
+			if err := pa.SetConstraintOrder(tt.order); err != nil {
+				t.Fatalf("Failed to set constraint order: %v", err)
+			}
+			// The exclusion still applies to input outside the include
+			if err := pa.AddPrefixes([]string{"10.0.0.0/8", "172.16.5.0/24"}); err != nil {
+				t.Fatalf("Failed to add prefixes: %v", err)
+			}
+			if err := pa.SetIncludePrefixes([]string{"192.168.0.0/16"}); err != nil {
+				t.Fatalf("Failed to set includes: %v", err)
+			}
+			if err := pa.SetExcludePrefixes([]string{"192.168.1.0/24", "172.16.0.0/16"}); err != nil {
+				t.Fatalf("Failed to set excludes: %v", err)
+			}
+			if err := pa.Aggregate(); err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+
+			got := pa.GetPrefixes()
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := NewPrefixAggregator().SetConstraintOrder(ConstraintOrder(7)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for an unknown order, got %v", err)
+	}
+}
+
+func TestIncludeRounding(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   IncludeRounding
+		want     []string
+		warnings int
+		err      error
+	}{
+		{"expand", IncludeExpand, []string{"10.0.0.0/24", "203.0.113.0/24"}, 1, nil},
+		{"exact", IncludeExact, []string{"10.0.0.0/24", "203.0.113.128/25"}, 0, nil},
+		{"reject", IncludeReject, nil, 0, ErrIncludeTooSpecific},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pa := NewPrefixAggregator()
+			if err := pa.SetIncludeRounding(tt.policy); err != nil {
+				t.Fatalf("Failed to set include rounding: %v", err)
+			}
+			if err := pa.SetMinPrefixLength(24, 0); err != nil {
+				t.Fatalf("Failed to set min prefix length: %v", err)
+			}
+			if err := pa.AddPrefix("10.0.0.0/25"); err != nil {
+				t.Fatalf("Failed to add prefix: %v", err)
+			}
+			if err := pa.SetIncludePrefixes([]string{"203.0.113.128/25"}); err != nil {
+				t.Fatalf("Failed to set includes: %v", err)
+			}
+			err := pa.Aggregate()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Aggregate returned %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got := pa.GetPrefixes(); !slices.Equal(got, tt.want) {
+				t.Errorf("Got %v, want %v", got, tt.want)
+			}
+
+			var rounded []Warning
+			for _, w := range pa.GetWarningDetails() {
+				if w.Code == WarnIncludeRounded {
+					rounded = append(rounded, w)
+				}
+			}
+			if len(rounded) != tt.warnings {
+				t.Fatalf("Expected %d rounding warnings, got %v", tt.warnings, rounded)
+			}
+			if tt.warnings > 0 && (rounded[0].Prefix.String() != "203.0.113.128/25" || rounded[0].Related.String() != "203.0.113.0/24") {
+				t.Errorf("Unexpected rounding warning: %+v", rounded[0])
+			}
+
+			// Prefixes added to the result keep the include as it was
+			if err := pa.AddPrefix("198.51.100.7/32"); err != nil {
+				t.Fatalf("Failed to add prefix: %v", err)
+			}
+			if err := pa.Aggregate(); err != nil {
+				t.Fatalf("Failed to re-aggregate: %v", err)
+			}
+			want := append([]string{}, tt.want[0], "198.51.100.0/24", tt.want[1])
+			if got := pa.GetPrefixes(); !slices.Equal(got, want) {
+				t.Errorf("After re-aggregating got %v, want %v", got, want)
+			}
+		})
+	}
+
+	if err := NewPrefixAggregator().SetIncludeRounding(IncludeRounding(7)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for an unknown policy, got %v", err)
+	}
+}
+
+func TestExcludeOverlapsIncludeWarning(t *testing.T) {
+	overlapWarnings := func(includes, excludes []string) []Warning {
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefix("10.0.0.0/8"); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+		if err := pa.SetIncludePrefixes(includes); err != nil {
+			t.Fatalf("Failed to set includes: %v", err)
+		}
+		if err := pa.SetExcludePrefixes(excludes); err != nil {
+			t.Fatalf("Failed to set excludes: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+
+		var found []Warning
+		for _, w := range pa.GetWarningDetails() {
+			if w.Code == WarnExcludeOverlapsInclude {
+				found = append(found, w)
+			}
+		}
+		return found
+	}
+
+	got := overlapWarnings(
+		[]string{"192.168.0.0/16", "2001:db8::/32"},
+		[]string{"192.168.1.0/24", "10.1.0.0/16", "2001:db8:1::/48"},
+	)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 overlap warnings, got %v", got)
+	}
+	if got[0].Prefix.String() != "192.168.1.0/24" || got[0].Related.String() != "192.168.0.0/16" {
+		t.Errorf("Unexpected IPv4 warning: %+v", got[0])
+	}
+	if !strings.Contains(got[0].Message, "192.168.1.0/24") || !strings.Contains(got[0].Message, "192.168.0.0/16") {
+		t.Errorf("Expected warning to name both prefixes, got %q", got[0].Message)
+	}
+	if got[1].Prefix.String() != "2001:db8:1::/48" || got[1].Related.String() != "2001:db8::/32" {
+		t.Errorf("Unexpected IPv6 warning: %+v", got[1])
+	}
+
+	// Disjoint constraints, including across families, give no warning
+	if got := overlapWarnings([]string{"192.168.0.0/24"}, []string{"192.168.1.0/24", "::/0"}); len(got) != 0 {
+		t.Errorf("Expected no overlap warnings for disjoint constraints, got %v", got)
+	}
+}
+
+func TestMaxResultPrefixes(t *testing.T) {
+	aggregate := func(limit int) (*PrefixAggregator, error) {
+		pa := NewPrefixAggregator()
+		if err := pa.SetMaxResultPrefixes(limit); err != nil {
+			t.Fatalf("Failed to set limit: %v", err)
+		}
+		if err := pa.AddPrefixes([]string{"10.0.0.0/8", "2001:db8::/32"}); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		// Excluding a /32 from a /8 leaves 24 IPv4 prefixes
+		if err := pa.SetExcludePrefixes([]string{"10.0.0.1/32"}); err != nil {
+			t.Fatalf("Failed to set excludes: %v", err)
+		}
+		return pa, pa.Aggregate()
+	}
+
+	_, err := aggregate(10)
+	if !errors.Is(err, ErrResultTooLarge) {
+		t.Fatalf("Expected ErrResultTooLarge, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "(24 IPv4, 1 IPv6)") {
+		t.Errorf("Expected per-family counts in error, got %v", err)
+	}
+
+	pa, err := aggregate(25)
+	if err != nil {
+		t.Fatalf("Expected a result at the limit to pass, got %v", err)
+	}
+	if stats := pa.GetStats(); stats.TotalPrefixes != 25 {
+		t.Errorf("Expected 25 prefixes, got %d", stats.TotalPrefixes)
+	}
+
+	if _, err := aggregate(0); err != nil {
+		t.Errorf("Expected no limit by default, got %v", err)
+	}
+	if err := NewPrefixAggregator().SetMaxResultPrefixes(-1); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a negative limit, got %v", err)
+	}
+}
+
+func TestAddExcludeRange(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.20.0.0/16", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.AddExcludeRange("10.20.30.40", "10.20.31.7"); err != nil {
+		t.Fatalf("AddExcludeRange failed: %v", err)
+	}
+	if err := pa.AddExcludeRange("2001:db8::ff", "2001:db8::1:0"); err != nil {
+		t.Fatalf("AddExcludeRange failed: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	set := pa.Snapshot()
+	first, last := netip.MustParseAddr("10.20.30.40"), netip.MustParseAddr("10.20.31.7")
+	for addr := first; addr.Compare(last) <= 0; addr = addr.Next() {
+		if set.Contains(addr) {
+			t.Fatalf("%s inside the excluded range survived", addr)
+		}
+	}
+	for _, addr := range []string{"10.20.30.39", "10.20.31.8", "2001:db8::fe", "2001:db8::1:1"} {
+		if !set.Contains(netip.MustParseAddr(addr)) {
+			t.Errorf("%s just outside the excluded range was removed", addr)
+		}
+	}
+	for _, addr := range []string{"2001:db8::ff", "2001:db8::8000", "2001:db8::1:0"} {
+		if set.Contains(netip.MustParseAddr(addr)) {
+			t.Errorf("%s inside the excluded range survived", addr)
+		}
+	}
+
+	// A failing range adds nothing and leaves the result current
+	excludes := len(pa.ExcludeIPv4) + len(pa.ExcludeIPv6)
+	for _, tt := range [][2]string{
+		{"10.0.0.9", "10.0.0.1"},
+		{"10.0.0.1", "2001:db8::1"},
+		{"10.0.0.1/24", "10.0.0.9"},
+		{"10.0.0.1", "bogus"},
+	} {
+		if err := pa.AddExcludeRange(tt[0], tt[1]); !errors.Is(err, ErrInvalidPrefix) {
+			t.Errorf("AddExcludeRange(%q, %q): expected ErrInvalidPrefix, got %v", tt[0], tt[1], err)
+		}
+	}
+	if got := len(pa.ExcludeIPv4) + len(pa.ExcludeIPv6); got != excludes || pa.dirty || pa.reconfigured {
+		t.Errorf("Failed ranges left %d exclusions, dirty %v, reconfigured %v; want %d, clean", got, pa.dirty, pa.reconfigured, excludes)
+	}
+}
+
+func TestNetipConstraints(t *testing.T) {
+	input := []string{"10.0.0.0/16", "192.168.0.0/24", "2001:db8::/32"}
+	includes := []string{"172.16.0.0/24", "2001:db8:1::/48"}
+	excludes := []string{"10.0.5.0/24", "192.168.0.7/31", "2001:db8:ff::/48"}
+
+	aggregate := func(t *testing.T, configure func(pa *PrefixAggregator) error) ([]string, []string) {
+		t.Helper()
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefixes(input); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := configure(pa); err != nil {
+			t.Fatalf("Failed to set constraints: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		return pa.GetPrefixes(), pa.GetWarnings()
+	}
+	parse := func(strs []string) []netip.Prefix {
+		prefixes := make([]netip.Prefix, len(strs))
+		for i, s := range strs {
+			prefixes[i] = netip.MustParsePrefix(s)
+		}
+		return prefixes
+	}
+
+	wantPrefixes, wantWarnings := aggregate(t, func(pa *PrefixAggregator) error {
+		if err := pa.SetIncludePrefixes(includes); err != nil {
+			return err
+		}
+		return pa.SetExcludePrefixes(excludes)
+	})
+	gotPrefixes, gotWarnings := aggregate(t, func(pa *PrefixAggregator) error {
+		if err := pa.SetIncludeNetipPrefixes(parse(includes)); err != nil {
+			return err
+		}
+		return pa.SetExcludeNetipPrefixes(parse(excludes))
+	})
+	if !slices.Equal(gotPrefixes, wantPrefixes) || !slices.Equal(gotWarnings, wantWarnings) {
+		t.Errorf("Set*NetipPrefixes: got %v %v, want %v %v", gotPrefixes, gotWarnings, wantPrefixes, wantWarnings)
+	}
+
+	// The Add variants extend the lists, so stale entries replaced by the
+	// Set variants must not survive
+	gotPrefixes, gotWarnings = aggregate(t, func(pa *PrefixAggregator) error {
+		if err := pa.SetExcludeNetipPrefixes(parse([]string{"10.0.0.0/8"})); err != nil {
+			return err
+		}
+		if err := pa.SetExcludeNetipPrefixes(parse(excludes[:1])); err != nil {
+			return err
+		}
+		if err := pa.AddExcludeNetipPrefixes(parse(excludes[1:])); err != nil {
+			return err
+		}
+		if err := pa.AddIncludeNetipPrefixes(parse(includes[:1])); err != nil {
+			return err
+		}
+		return pa.AddIncludeNetipPrefixes(parse(includes[1:]))
+	})
+	if !slices.Equal(gotPrefixes, wantPrefixes) || !slices.Equal(gotWarnings, wantWarnings) {
+		t.Errorf("Add*NetipPrefixes: got %v %v, want %v %v", gotPrefixes, gotWarnings, wantPrefixes, wantWarnings)
+	}
+
+	pa := NewPrefixAggregator()
+	if err := pa.SetExcludeNetipPrefixes([]netip.Prefix{{}}); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Expected ErrInvalidPrefix for the zero prefix, got %v", err)
+	}
+	if err := pa.AddIncludeNetipPrefixes([]netip.Prefix{netip.PrefixFrom(netip.MustParseAddr("10.0.0.0"), 33)}); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Expected ErrInvalidPrefix for an out-of-range length, got %v", err)
+	}
+}
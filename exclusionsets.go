@@ -0,0 +1,190 @@
+package netjugo
+
+import "fmt"
+
+// exclusionSet is a named group of exclusions that can be switched on and
+// off between runs
+type exclusionSet struct {
+	name    string
+	ipv4    []*IPPrefix
+	ipv6    []*IPPrefix
+	enabled bool
+}
+
+// exclusionSource is a list of exclusions and the set it came from; set is
+// empty for the prefixes given to SetExcludePrefixes
+type exclusionSource struct {
+	set      string
+	prefixes []*IPPrefix
+}
+
+// AddExclusionSet adds a named, enabled set of exclusions that Aggregate
+// applies alongside SetExcludePrefixes. An existing set of the same name
+// is replaced.
+func (pa *PrefixAggregator) AddExclusionSet(name string, prefixes []string) error {
+	set := &exclusionSet{name: name, enabled: true}
+	for _, prefixStr := range prefixes {
+		ipPrefix, err := parseIPPrefix(prefixStr)
+		if err != nil {
+			releaseExclusionSet(set)
+			return fmt.Errorf("failed to parse prefix %q in exclusion set %q: %w", prefixStr, name, err)
+		}
+
+		if ipPrefix.Prefix.Addr().Is4() {
+			set.ipv4 = append(set.ipv4, ipPrefix)
+		} else {
+			set.ipv6 = append(set.ipv6, ipPrefix)
+		}
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		releaseExclusionSet(set)
+		return ErrClosed
+	}
+
+	if i := pa.findExclusionSet(name); i >= 0 {
+		if old := pa.exclusionSets[i]; old.enabled && samePrefixes(set.ipv4, old.ipv4) && samePrefixes(set.ipv6, old.ipv6) {
+			releaseExclusionSet(set)
+			return nil
+		}
+		releaseExclusionSet(pa.exclusionSets[i])
+		pa.exclusionSets[i] = set
+	} else {
+		pa.exclusionSets = append(pa.exclusionSets, set)
+	}
+
+	pa.reconfigure()
+	return nil
+}
+
+// EnableExclusionSet makes Aggregate apply the named set
+func (pa *PrefixAggregator) EnableExclusionSet(name string) error {
+	return pa.setExclusionSetEnabled(name, true)
+}
+
+// DisableExclusionSet makes Aggregate skip the named set. After an
+// Aggregate this needs SetRetainOriginals, like any other change to the
+// constraints; otherwise toggle sets on a Clone taken before aggregating.
+func (pa *PrefixAggregator) DisableExclusionSet(name string) error {
+	return pa.setExclusionSetEnabled(name, false)
+}
+
+// RemoveExclusionSet deletes the named set
+func (pa *PrefixAggregator) RemoveExclusionSet(name string) error {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	i := pa.findExclusionSet(name)
+	if i < 0 {
+		return fmt.Errorf("%w: unknown exclusion set %q", ErrInvalidOption, name)
+	}
+
+	// A disabled set has no part in the result
+	enabled := pa.exclusionSets[i].enabled
+	releaseExclusionSet(pa.exclusionSets[i])
+	pa.exclusionSets = append(pa.exclusionSets[:i], pa.exclusionSets[i+1:]...)
+	if enabled {
+		pa.reconfigure()
+	}
+	return nil
+}
+
+// ExclusionSets returns the names of all exclusion sets in the order they
+// were added
+func (pa *PrefixAggregator) ExclusionSets() []string {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	names := make([]string, len(pa.exclusionSets))
+	for i, set := range pa.exclusionSets {
+		names[i] = set.name
+	}
+	return names
+}
+
+func (pa *PrefixAggregator) setExclusionSetEnabled(name string, enabled bool) error {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	i := pa.findExclusionSet(name)
+	if i < 0 {
+		return fmt.Errorf("%w: unknown exclusion set %q", ErrInvalidOption, name)
+	}
+
+	if pa.exclusionSets[i].enabled != enabled {
+		pa.exclusionSets[i].enabled = enabled
+		pa.reconfigure()
+	}
+	return nil
+}
+
+func (pa *PrefixAggregator) findExclusionSet(name string) int {
+	for i, set := range pa.exclusionSets {
+		if set.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// activeExclusions returns the exclusions of one family that Aggregate
+// applies: the plain exclude list followed by each enabled set
+func (pa *PrefixAggregator) activeExclusions(isIPv4 bool) []exclusionSource {
+	sources := make([]exclusionSource, 0, 1+len(pa.exclusionSets))
+	if isIPv4 {
+		sources = append(sources, exclusionSource{prefixes: pa.ExcludeIPv4})
+	} else {
+		sources = append(sources, exclusionSource{prefixes: pa.ExcludeIPv6})
+	}
+
+	for _, set := range pa.exclusionSets {
+		if !set.enabled {
+			continue
+		}
+		prefixes := set.ipv6
+		if isIPv4 {
+			prefixes = set.ipv4
+		}
+		if len(prefixes) > 0 {
+			sources = append(sources, exclusionSource{set: set.name, prefixes: prefixes})
+		}
+	}
+	return sources
+}
+
+// describe returns a suffix naming the set, for warning messages
+func (s exclusionSource) describe() string {
+	if s.set == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (exclusion set %q)", s.set)
+}
+
+func cloneExclusionSet(set *exclusionSet) *exclusionSet {
+	return &exclusionSet{
+		name:    set.name,
+		ipv4:    clonePrefixSlice(set.ipv4),
+		ipv6:    clonePrefixSlice(set.ipv6),
+		enabled: set.enabled,
+	}
+}
+
+func releaseExclusionSet(set *exclusionSet) {
+	for _, p := range set.ipv4 {
+		releaseIPPrefix(p)
+	}
+	for _, p := range set.ipv6 {
+		releaseIPPrefix(p)
+	}
+}
@@ -0,0 +1,138 @@
+package netjugo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExclusionSetsToggle(t *testing.T) {
+	base := NewPrefixAggregator()
+	base.SetRetainOriginals(true)
+	if err := base.AddPrefixes([]string{"10.0.0.0/16", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := base.AddExclusionSet("maintenance", []string{"10.0.0.0/17"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+	if err := base.AddExclusionSet("legal", []string{"10.0.128.0/18", "2001:db8:8000::/33"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+	if got := base.ExclusionSets(); strings.Join(got, ",") != "maintenance,legal" {
+		t.Errorf("Unexpected set names: %v", got)
+	}
+
+	tests := []struct {
+		name     string
+		disabled []string
+		want     []string
+	}{
+		{"all enabled", nil, []string{"10.0.192.0/18", "2001:db8::/33"}},
+		{"legal disabled", []string{"legal"}, []string{"10.0.128.0/17", "2001:db8::/32"}},
+		{"all disabled", []string{"maintenance", "legal"}, []string{"10.0.0.0/16", "2001:db8::/32"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pa := base.Clone()
+			for _, name := range tt.disabled {
+				if err := pa.DisableExclusionSet(name); err != nil {
+					t.Fatalf("Failed to disable %q: %v", name, err)
+				}
+			}
+			if err := pa.Aggregate(); err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+			if got := pa.GetPrefixes(); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The clones leave the original untouched
+	if got := base.GetPrefixes(); strings.Join(got, ",") != "10.0.0.0/16,2001:db8::/32" {
+		t.Errorf("Original aggregator changed: %v", got)
+	}
+
+	// With originals retained, toggling after Aggregate recomputes the
+	// result, so a disabled set gives its space back
+	pa := base.Clone()
+	if err := pa.DisableExclusionSet("maintenance"); err != nil {
+		t.Fatalf("Failed to disable set: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := pa.GetIPv4Prefixes(); strings.Join(got, ",") != "10.0.0.0/17,10.0.192.0/18" {
+		t.Errorf("Expected only the legal set to apply, got %v", got)
+	}
+	if err := pa.EnableExclusionSet("maintenance"); err != nil {
+		t.Fatalf("Failed to enable set: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := pa.GetIPv4Prefixes(); strings.Join(got, ",") != "10.0.192.0/18" {
+		t.Errorf("Expected re-enabled set to apply, got %v", got)
+	}
+}
+
+func TestExclusionSetWarnings(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefix("10.0.0.0/16"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.SetIncludePrefixes([]string{"192.168.0.0/16"}); err != nil {
+		t.Fatalf("Failed to set includes: %v", err)
+	}
+	if err := pa.AddExclusionSet("partners", []string{"10.0.0.1/32", "192.168.5.0/24"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	warnings := pa.GetWarningDetails()
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %v", warnings)
+	}
+	for _, w := range warnings {
+		if w.Set != "partners" || !strings.Contains(w.Message, `exclusion set "partners"`) {
+			t.Errorf("Expected warning to name the set, got %+v", w)
+		}
+	}
+
+	if stats := pa.GetStats(); stats.ExcludedCount != 2 {
+		t.Errorf("Expected 2 excluded prefixes in stats, got %d", stats.ExcludedCount)
+	}
+}
+
+func TestExclusionSetErrors(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddExclusionSet("bad", []string{"10.0.0.0/24", "nope"}); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Expected ErrInvalidPrefix, got %v", err)
+	}
+	if len(pa.ExclusionSets()) != 0 {
+		t.Error("A set that failed to parse should not be added")
+	}
+
+	for name, fn := range map[string]func(string) error{
+		"enable":  pa.EnableExclusionSet,
+		"disable": pa.DisableExclusionSet,
+		"remove":  pa.RemoveExclusionSet,
+	} {
+		if err := fn("missing"); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s of unknown set: expected ErrInvalidOption, got %v", name, err)
+		}
+	}
+
+	if err := pa.AddExclusionSet("tmp", []string{"10.0.0.0/24"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+	if err := pa.RemoveExclusionSet("tmp"); err != nil {
+		t.Errorf("Failed to remove set: %v", err)
+	}
+	if len(pa.ExclusionSets()) != 0 {
+		t.Errorf("Expected no sets after removal, got %v", pa.ExclusionSets())
+	}
+}
@@ -0,0 +1,163 @@
+package netjugo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// parsedFile holds the prefixes a worker parsed from one file before they
+// are merged into the aggregator
+type parsedFile struct {
+	prefixes []*IPPrefix
+	// texts holds each prefix as written in minimal change mode
+	texts  []string
+	result ReadResult
+	err    error
+}
+
+// text returns the line prefix i was read from, if it was kept
+func (f *parsedFile) text(i int) string {
+	if i < len(f.texts) {
+		return f.texts[i]
+	}
+	return ""
+}
+
+// AddFromFiles loads several files in parallel; see AddFromFilesCount
+func (pa *PrefixAggregator) AddFromFiles(paths []string, concurrency int) error {
+	_, err := pa.AddFromFilesCount(paths, concurrency)
+	return err
+}
+
+// AddFromFilesCount parses the files with up to concurrency workers
+// (GOMAXPROCS when concurrency is not positive) and adds their prefixes
+// under a single lock, in path order. Lines that cannot be parsed are
+// skipped as in AddFromFile, and the result for each file is returned in
+// path order. If any file cannot be read, nothing is added; if the memory
+// budget is crossed, the prefixes added before it are kept.
+func (pa *PrefixAggregator) AddFromFilesCount(paths []string, concurrency int) ([]ReadResult, error) {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	concurrency = min(concurrency, len(paths))
+
+	logger, metrics := pa.observers()
+	pa.mu.RLock()
+	keepText, syntax := pa.minimalChange, pa.syntax
+	pa.mu.RUnlock()
+
+	files := make([]parsedFile, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				files[i] = parseFile(paths[i], logger, metrics, keepText, syntax)
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	results := make([]ReadResult, len(files))
+	for i, f := range files {
+		if f.err != nil {
+			releaseParsedFiles(files)
+			return nil, f.err
+		}
+		results[i] = f.result
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	for i := range files {
+		for j, p := range files[i].prefixes {
+			if err := pa.addParsed(p, files[i].text(j)); err != nil {
+				files[i].prefixes = files[i].prefixes[j+1:]
+				releaseParsedFiles(files[i:])
+				return nil, fmt.Errorf("%s: %w", paths[i], err)
+			}
+		}
+		// parseFile only skips lines that are not prefixes
+		pa.skippedLines += files[i].result.Skipped
+		pa.skippedByReason[SkipParseError] += files[i].result.Skipped
+	}
+
+	added := 0
+	for _, r := range results {
+		added += r.Added
+	}
+	metrics.AddPrefixesLoaded(added)
+	return results, nil
+}
+
+// parseFile reads one file into unshared prefixes, without touching the
+// aggregator; keepText keeps the prefixes as written as well
+func parseFile(path string, logger *slog.Logger, metrics Metrics, keepText bool, syntax lineSyntax) parsedFile {
+	var f parsedFile
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			f.err = fmt.Errorf("%w: %s", ErrFileNotFound, path)
+		} else {
+			f.err = fmt.Errorf("failed to open file %s: %w", path, err)
+		}
+		return f
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line, text, kind := syntax.parse(scanner.Text())
+		if kind == lineIgnored {
+			continue
+		}
+
+		if kind == lineInvalid {
+			skippedLine(logger, metrics, path, "", lineNumber, scanner.Text(), SkipParseError, nil)
+			f.result.Skipped++
+			continue
+		}
+		p, err := parseIPPrefix(line)
+		if err != nil {
+			skippedLine(logger, metrics, path, "", lineNumber, scanner.Text(), SkipParseError, err)
+			f.result.Skipped++
+			continue
+		}
+
+		f.prefixes = append(f.prefixes, p)
+		if keepText {
+			f.texts = append(f.texts, text)
+		}
+		f.result.Added++
+	}
+
+	if err := scanner.Err(); err != nil {
+		f.err = fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return f
+}
+
+func releaseParsedFiles(files []parsedFile) {
+	for _, f := range files {
+		for _, p := range f.prefixes {
+			releaseIPPrefix(p)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package netjugo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSplitFiles writes lines round-robin into n files and returns their
+// paths
+func writeSplitFiles(tb testing.TB, lines []string, n int) []string {
+	tb.Helper()
+	dir := tb.TempDir()
+	parts := make([]strings.Builder, n)
+	for i, line := range lines {
+		parts[i%n].WriteString(line)
+		parts[i%n].WriteByte('\n')
+	}
+
+	paths := make([]string, n)
+	for i := range parts {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("feed-%d.txt", i))
+		if err := os.WriteFile(paths[i], []byte(parts[i].String()), 0o644); err != nil {
+			tb.Fatalf("Failed to write %s: %v", paths[i], err)
+		}
+	}
+	return paths
+}
+
+func TestAddFromFiles(t *testing.T) {
+	lines := generateTestPrefixes(2000)
+	// Two bad lines and a comment, landing in files 3, 0, 1 and 2
+	lines = append(lines[:3], append([]string{"bogus", "also-bogus", "# comment", "10.0.0.0/40"}, lines[3:]...)...)
+	paths := writeSplitFiles(t, lines, 4)
+
+	sequential := NewPrefixAggregator()
+	for _, path := range paths {
+		if err := sequential.AddFromFile(path); err != nil {
+			t.Fatalf("AddFromFile failed: %v", err)
+		}
+	}
+
+	for _, concurrency := range []int{0, 1, 3, 16} {
+		pa := NewPrefixAggregator()
+		results, err := pa.AddFromFilesCount(paths, concurrency)
+		if err != nil {
+			t.Fatalf("AddFromFilesCount(concurrency %d) failed: %v", concurrency, err)
+		}
+		if len(results) != len(paths) {
+			t.Fatalf("Expected %d results, got %d", len(paths), len(results))
+		}
+
+		added, skipped := 0, 0
+		for _, r := range results {
+			added += r.Added
+			skipped += r.Skipped
+		}
+		if results[3].Skipped != 1 || results[0].Skipped != 1 || skipped != 3 {
+			t.Errorf("Unexpected per-file skips: %+v", results)
+		}
+
+		got, want := pa.GetStats(), sequential.GetStats()
+		if added != want.OriginalCount || got.OriginalCount != want.OriginalCount || got.SkippedLines != want.SkippedLines {
+			t.Errorf("Concurrency %d: got %d added (stats %+v), want stats %+v", concurrency, added, got, want)
+		}
+		if strings.Join(pa.GetPrefixes(), ",") != strings.Join(sequential.GetPrefixes(), ",") {
+			t.Errorf("Concurrency %d: prefixes differ from sequential loading", concurrency)
+		}
+	}
+}
+
+func TestAddFromFilesMissing(t *testing.T) {
+	paths := writeSplitFiles(t, []string{"10.0.0.0/24", "10.0.1.0/24"}, 2)
+	paths = append(paths, filepath.Join(t.TempDir(), "missing.txt"))
+
+	pa := NewPrefixAggregator()
+	if err := pa.AddFromFiles(paths, 2); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("Expected ErrFileNotFound, got %v", err)
+	}
+	if stats := pa.GetStats(); stats.OriginalCount != 0 {
+		t.Errorf("Expected nothing to be added when a file is missing, got %d", stats.OriginalCount)
+	}
+}
+
+func BenchmarkAddFromFiles(b *testing.B) {
+	paths := writeSplitFiles(b, generateTestPrefixes(200000), 16)
+
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pa := NewPrefixAggregator()
+			for _, path := range paths {
+				if err := pa.AddFromFile(path); err != nil {
+					b.Fatalf("AddFromFile failed: %v", err)
+				}
+			}
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := NewPrefixAggregator().AddFromFiles(paths, 0); err != nil {
+				b.Fatalf("AddFromFiles failed: %v", err)
+			}
+		}
+	})
+}
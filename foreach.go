@@ -0,0 +1,91 @@
+package netjugo
+
+import "net/netip"
+
+// forEachChunk is how many prefixes the ForEach methods copy under one
+// read lock
+const forEachChunk = 256
+
+// ForEachPrefix calls fn for every current prefix, in the order and with
+// the prefixes GetPrefixes returns, without building the whole list.
+// Iteration stops early if fn returns false.
+//
+// The read lock is only held while copying the next few hundred
+// prefixes, not while fn runs, so fn may call any method of the
+// aggregator. Adding prefixes, Aggregate or Reset from fn replaces the
+// result being read: the prefixes already copied are still passed to fn,
+// then iteration stops. The output order in effect when iteration starts
+// is kept throughout.
+func (pa *PrefixAggregator) ForEachPrefix(fn func(netip.Prefix) bool) {
+	buf := make([]netip.Prefix, 0, forEachChunk)
+	c := pa.newResultCursor()
+	for c.next(func(_ resultView, p *IPPrefix) {
+		buf = append(buf, p.Prefix)
+	}) {
+		for _, p := range buf {
+			if !fn(p) {
+				return
+			}
+		}
+		buf = buf[:0]
+	}
+}
+
+// ForEachPrefixString is ForEachPrefix for the prefixes as GetPrefixes
+// renders them
+func (pa *PrefixAggregator) ForEachPrefixString(fn func(prefix string) bool) {
+	buf := make([]string, 0, forEachChunk)
+	c := pa.newResultCursor()
+	for c.next(func(view resultView, p *IPPrefix) {
+		buf = pa.appendViewString(buf, view, p)
+	}) {
+		for _, s := range buf {
+			if !fn(s) {
+				return
+			}
+		}
+		buf = buf[:0]
+	}
+}
+
+// resultCursor reads a result view in chunks, taking the read lock for
+// each chunk
+type resultCursor struct {
+	pa      *PrefixAggregator
+	view    resultView
+	changes uint64
+	list    int
+	pos     int
+}
+
+func (pa *PrefixAggregator) newResultCursor() *resultCursor {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return &resultCursor{pa: pa, view: pa.resultView(), changes: pa.changes}
+}
+
+// next calls read under the read lock for up to forEachChunk of the
+// remaining prefixes. It returns false, without calling read, at the end
+// of the view or once the lists have changed and the view points at
+// released prefixes.
+func (c *resultCursor) next(read func(view resultView, p *IPPrefix)) bool {
+	c.pa.mu.RLock()
+	defer c.pa.mu.RUnlock()
+
+	if c.pa.changes != c.changes {
+		return false
+	}
+	n := 0
+	for ; c.list < len(c.view.lists) && n < forEachChunk; c.list, c.pos = c.list+1, 0 {
+		list := c.view.lists[c.list]
+		for ; c.pos < len(list) && n < forEachChunk; c.pos++ {
+			read(c.view, list[c.pos])
+			n++
+		}
+		if c.pos < len(list) {
+			break
+		}
+	}
+	return n > 0
+}
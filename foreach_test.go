@@ -0,0 +1,106 @@
+package netjugo
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestForEachPrefix(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(generateTestPrefixes(2000)); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	want := pa.GetPrefixes()
+	if len(want) <= forEachChunk*2 {
+		t.Fatalf("Expected more than %d prefixes to span several chunks, got %d", forEachChunk*2, len(want))
+	}
+
+	var strs []string
+	pa.ForEachPrefixString(func(s string) bool {
+		strs = append(strs, s)
+		return true
+	})
+	if !slices.Equal(strs, want) {
+		t.Errorf("ForEachPrefixString visited %d prefixes, want the %d GetPrefixes returns", len(strs), len(want))
+	}
+
+	var prefixes []string
+	pa.ForEachPrefix(func(p netip.Prefix) bool {
+		prefixes = append(prefixes, p.String())
+		return true
+	})
+	if !slices.Equal(prefixes, want) {
+		t.Errorf("ForEachPrefix visited %d prefixes, want the %d GetPrefixes returns", len(prefixes), len(want))
+	}
+
+	// Early termination, including across a chunk boundary
+	for _, limit := range []int{1, forEachChunk, forEachChunk + 1} {
+		count := 0
+		pa.ForEachPrefixString(func(string) bool {
+			count++
+			return count < limit
+		})
+		if count != limit {
+			t.Errorf("Expected ForEachPrefixString to stop after %d prefixes, visited %d", limit, count)
+		}
+	}
+}
+
+func TestForEachPrefixEmpty(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	pa.ForEachPrefix(func(p netip.Prefix) bool {
+		t.Errorf("Unexpected prefix %s in an empty aggregator", p)
+		return true
+	})
+	pa.ForEachPrefixString(func(s string) bool {
+		t.Errorf("Unexpected prefix %s in an empty aggregator", s)
+		return true
+	})
+}
+
+func TestForEachPrefixMutation(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(generateTestPrefixes(2000)); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	// Mutating from fn neither deadlocks nor reads released prefixes;
+	// iteration ends with the chunk already copied
+	count := 0
+	pa.ForEachPrefix(func(netip.Prefix) bool {
+		if count++; count == 1 {
+			if err := pa.AddPrefix("198.51.100.0/24"); err != nil {
+				t.Fatalf("Failed to add prefix: %v", err)
+			}
+			if err := pa.Aggregate(); err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+		}
+		return true
+	})
+	if count != forEachChunk {
+		t.Errorf("Expected iteration to stop after the first chunk of %d, visited %d", forEachChunk, count)
+	}
+
+	// Read-only calls from fn do not end iteration
+	count = 0
+	pa.ForEachPrefixString(func(string) bool {
+		count++
+		_ = pa.GetStats()
+		return true
+	})
+	if total := len(pa.GetPrefixes()); count != total {
+		t.Errorf("Expected %d prefixes with read-only calls from fn, visited %d", total, count)
+	}
+}
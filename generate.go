@@ -0,0 +1,140 @@
+package netjugo
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+)
+
+// GenerateOptions controls the synthetic prefix lists produced by Generate
+type GenerateOptions struct {
+	// Count is the number of prefixes to generate
+	Count int
+	// Seed makes the output reproducible; the same options always give
+	// the same list
+	Seed uint64
+	// IPv6Ratio is the fraction of prefixes that are IPv6 (0 to 1)
+	IPv6Ratio float64
+	// IPv4Lengths and IPv6Lengths are drawn from uniformly; repeat a
+	// length to weight it. They default to /24 and /48.
+	IPv4Lengths []int
+	IPv6Lengths []int
+	// OverlapRatio is the fraction of prefixes derived from an earlier
+	// one, either as a subnet of it or as its sibling, so that they
+	// aggregate (0 to 1)
+	OverlapRatio float64
+}
+
+// Generate returns a deterministic pseudo-random list of prefixes for
+// tests and load testing. Prefixes are masked and may repeat.
+func Generate(opts GenerateOptions) ([]string, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	ipv4Lengths := opts.IPv4Lengths
+	if len(ipv4Lengths) == 0 {
+		ipv4Lengths = []int{24}
+	}
+	ipv6Lengths := opts.IPv6Lengths
+	if len(ipv6Lengths) == 0 {
+		ipv6Lengths = []int{48}
+	}
+
+	rng := rand.New(rand.NewPCG(opts.Seed, 0x6e65746a75676f)) // "netjugo"
+	var ipv4, ipv6 []netip.Prefix
+	result := make([]string, 0, opts.Count)
+
+	for i := 0; i < opts.Count; i++ {
+		isIPv6 := rng.Float64() < opts.IPv6Ratio
+		earlier, lengths := ipv4, ipv4Lengths
+		if isIPv6 {
+			earlier, lengths = ipv6, ipv6Lengths
+		}
+
+		var p netip.Prefix
+		if len(earlier) > 0 && rng.Float64() < opts.OverlapRatio {
+			p = relatedPrefix(rng, earlier[rng.IntN(len(earlier))])
+		} else {
+			p = randomPrefix(rng, isIPv6, lengths[rng.IntN(len(lengths))])
+		}
+
+		if isIPv6 {
+			ipv6 = append(ipv6, p)
+		} else {
+			ipv4 = append(ipv4, p)
+		}
+		result = append(result, p.String())
+	}
+
+	return result, nil
+}
+
+func (opts GenerateOptions) validate() error {
+	if opts.Count < 0 {
+		return fmt.Errorf("%w: count must not be negative, got %d", ErrInvalidOption, opts.Count)
+	}
+	if opts.IPv6Ratio < 0 || opts.IPv6Ratio > 1 {
+		return fmt.Errorf("%w: IPv6 ratio must be between 0 and 1, got %g", ErrInvalidOption, opts.IPv6Ratio)
+	}
+	if opts.OverlapRatio < 0 || opts.OverlapRatio > 1 {
+		return fmt.Errorf("%w: overlap ratio must be between 0 and 1, got %g", ErrInvalidOption, opts.OverlapRatio)
+	}
+	for _, l := range opts.IPv4Lengths {
+		if l < 0 || l > 32 {
+			return fmt.Errorf("%w: IPv4 prefix length must be 0-32, got %d", ErrInvalidOption, l)
+		}
+	}
+	for _, l := range opts.IPv6Lengths {
+		if l < 0 || l > 128 {
+			return fmt.Errorf("%w: IPv6 prefix length must be 0-128, got %d", ErrInvalidOption, l)
+		}
+	}
+	return nil
+}
+
+// randomPrefix returns a prefix of the given length at a random address
+func randomPrefix(rng *rand.Rand, isIPv6 bool, bits int) netip.Prefix {
+	var addr netip.Addr
+	if isIPv6 {
+		var b [16]byte
+		fillRandom(rng, b[:])
+		addr = netip.AddrFrom16(b)
+	} else {
+		var b [4]byte
+		fillRandom(rng, b[:])
+		addr = netip.AddrFrom4(b)
+	}
+	return netip.PrefixFrom(addr, bits).Masked()
+}
+
+// relatedPrefix returns either a random subnet of base or its sibling
+func relatedPrefix(rng *rand.Rand, base netip.Prefix) netip.Prefix {
+	bits, width := base.Bits(), base.Addr().BitLen()
+	b := base.Addr().AsSlice()
+
+	if bits > 0 && (bits == width || rng.IntN(2) == 0) {
+		// Flip the last network bit to get the other half of the parent
+		b[(bits-1)/8] ^= 0x80 >> ((bits - 1) % 8)
+		addr, _ := netip.AddrFromSlice(b)
+		return netip.PrefixFrom(addr, bits)
+	}
+
+	// Randomise the host bits and pick a longer length (up to 8 more bits)
+	random := make([]byte, len(b))
+	fillRandom(rng, random)
+	for i := range b {
+		n := min(max(bits-8*i, 0), 8)
+		keep := byte(uint16(0xff00) >> n)
+		b[i] = b[i]&keep | random[i]&^keep
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	newLen := bits + 1 + rng.IntN(min(8, width-bits))
+	return netip.PrefixFrom(addr, newLen).Masked()
+}
+
+func fillRandom(rng *rand.Rand, b []byte) {
+	for i := range b {
+		b[i] = byte(rng.Uint32())
+	}
+}
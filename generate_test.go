@@ -0,0 +1,108 @@
+package netjugo
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	opts := GenerateOptions{Count: 500, Seed: 42, IPv6Ratio: 0.3, OverlapRatio: 0.5}
+
+	first, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	second, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if strings.Join(first, ",") != strings.Join(second, ",") {
+		t.Error("Same options produced different output")
+	}
+
+	opts.Seed = 43
+	other, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if strings.Join(first, ",") == strings.Join(other, ",") {
+		t.Error("Different seeds produced identical output")
+	}
+}
+
+func TestGenerateShape(t *testing.T) {
+	prefixes, err := Generate(GenerateOptions{
+		Count:       2000,
+		Seed:        1,
+		IPv6Ratio:   0.25,
+		IPv4Lengths: []int{16, 24, 24},
+		IPv6Lengths: []int{64},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(prefixes) != 2000 {
+		t.Fatalf("Expected 2000 prefixes, got %d", len(prefixes))
+	}
+
+	ipv6 := 0
+	for _, s := range prefixes {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			t.Fatalf("Generated invalid prefix %q: %v", s, err)
+		}
+		if p != p.Masked() {
+			t.Errorf("Generated prefix %s has host bits set", s)
+		}
+		if p.Addr().Is6() {
+			ipv6++
+			if p.Bits() != 64 {
+				t.Errorf("Unexpected IPv6 length in %s", s)
+			}
+		} else if p.Bits() != 16 && p.Bits() != 24 {
+			t.Errorf("Unexpected IPv4 length in %s", s)
+		}
+	}
+	if ipv6 < 400 || ipv6 > 600 {
+		t.Errorf("Expected about 500 IPv6 prefixes, got %d", ipv6)
+	}
+}
+
+func TestGenerateOverlapAggregates(t *testing.T) {
+	aggregated := func(overlap float64) int {
+		prefixes, err := Generate(GenerateOptions{Count: 1000, Seed: 7, OverlapRatio: overlap})
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefixes(prefixes); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		return pa.GetStats().TotalPrefixes
+	}
+
+	none, most := aggregated(0), aggregated(0.8)
+	if most >= none/2 {
+		t.Errorf("Expected overlap to aggregate well: %d prefixes without overlap, %d with", none, most)
+	}
+}
+
+func TestGenerateInvalidOptions(t *testing.T) {
+	invalid := []GenerateOptions{
+		{Count: -1},
+		{Count: 1, IPv6Ratio: 1.5},
+		{Count: 1, OverlapRatio: -0.1},
+		{Count: 1, IPv4Lengths: []int{33}},
+		{Count: 1, IPv6Lengths: []int{129}},
+	}
+	for _, opts := range invalid {
+		if _, err := Generate(opts); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Generate(%+v) error = %v, want ErrInvalidOption", opts, err)
+		}
+	}
+}
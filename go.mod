@@ -2,4 +2,4 @@ module github.com/rretina/netjugo
 
 go 1.24
 
-require github.com/holiman/uint256 v1.3.2 // indirect
+require github.com/holiman/uint256 v1.3.2
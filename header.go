@@ -0,0 +1,47 @@
+package netjugo
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+)
+
+// SetOutputHeader makes WriteToFile, WriteToWriter and WriteSplit start
+// their output with "#" comment lines giving the generation time, library
+// version, per-family input and output counts and the minimum prefix
+// lengths. The loaders skip comment lines, so the output still reads back
+// as the same prefixes. WriteToFiles never writes a header, to keep its
+// per-file line limit exact.
+func (pa *PrefixAggregator) SetOutputHeader(enabled bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	pa.outputHeader = enabled
+}
+
+// headerLines is how many lines writeHeader writes
+func (pa *PrefixAggregator) headerLines() int {
+	if !pa.outputHeader {
+		return 0
+	}
+	return 4
+}
+
+// writeHeader writes the output header for r, if enabled
+func (pa *PrefixAggregator) writeHeader(w *bufio.Writer, r *renderedResult) error {
+	if !pa.outputHeader {
+		return nil
+	}
+	now := time.Now
+	if pa.now != nil {
+		now = pa.now
+	}
+
+	_, _ = fmt.Fprintf(w, "# Generated by netjugo %s at %s\n", Version(), now().UTC().Format(time.RFC3339))
+	_, _ = fmt.Fprintf(w, "# IPv4 prefixes: %d in, %d out\n", pa.originalIPv4, r.counts[0])
+	_, _ = fmt.Fprintf(w, "# IPv6 prefixes: %d in, %d out\n", pa.originalCount-pa.originalIPv4, r.counts[1])
+	if _, err := fmt.Fprintf(w, "# Minimum prefix length: IPv4 /%d, IPv6 /%d\n", pa.MinPrefixLenIPv4, pa.MinPrefixLenIPv6); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	return nil
+}
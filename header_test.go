@@ -0,0 +1,75 @@
+package netjugo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputHeader(t *testing.T) {
+	pa := NewPrefixAggregator()
+	pa.now = func() time.Time { return time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*3600)) }
+	if err := pa.AddPrefixes([]string{"10.0.0.0/25", "10.0.0.128/25", "192.0.2.0/24", "2001:db8::/48"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetMinPrefixLength(24, 48); err != nil {
+		t.Fatalf("Failed to set min prefix length: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := pa.WriteToWriter(&buf); err != nil {
+		t.Fatalf("WriteToWriter failed: %v", err)
+	}
+	if strings.HasPrefix(buf.String(), "#") {
+		t.Errorf("Expected no header by default, got:\n%s", buf.String())
+	}
+
+	pa.SetOutputHeader(true)
+	buf.Reset()
+	if err := pa.WriteToWriter(&buf); err != nil {
+		t.Fatalf("WriteToWriter failed: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "header", "output.golden"))
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("Output mismatch:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// The header reads back as nothing
+	back := NewPrefixAggregator()
+	if err := back.AddFromReader(&buf); err != nil {
+		t.Fatalf("Failed to read the output back: %v", err)
+	}
+	if err := back.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := back.GetPrefixes(); !slices.Equal(got, pa.GetPrefixes()) || back.GetStats().SkippedLines != 0 {
+		t.Errorf("Expected %v back with no skipped lines, got %v", pa.GetPrefixes(), got)
+	}
+
+	// WriteSplit heads each family's output; WriteToFiles writes none
+	var v4, v6 bytes.Buffer
+	if err := pa.WriteSplit(&v4, &v6); err != nil {
+		t.Fatalf("WriteSplit failed: %v", err)
+	}
+	header, _, _ := strings.Cut(string(want), "10.0.0.0/24")
+	if !strings.HasPrefix(v4.String(), header) || !strings.HasPrefix(v6.String(), header) {
+		t.Errorf("Expected both families to start with the header, got:\n%s\n%s", v4.String(), v6.String())
+	}
+	paths, err := pa.WriteToFiles(filepath.Join(t.TempDir(), "out.txt"), 10)
+	if err != nil {
+		t.Fatalf("WriteToFiles failed: %v", err)
+	}
+	if data, _ := os.ReadFile(paths[0]); strings.HasPrefix(string(data), "#") {
+		t.Errorf("Expected no header from WriteToFiles, got:\n%s", data)
+	}
+}
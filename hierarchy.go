@@ -0,0 +1,161 @@
+package netjugo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"sort"
+)
+
+// PrefixNode is one prefix in the tree returned by GetHierarchy
+type PrefixNode struct {
+	Prefix netip.Prefix
+	// Synthetic marks a grouping parent that is not itself a result prefix
+	Synthetic bool
+	// Addresses is the number of result addresses in the subtree: the size
+	// of Prefix for a result prefix, the sum over Children for a synthetic
+	// parent
+	Addresses *big.Int
+	Children  []*PrefixNode
+}
+
+// HierarchyOptions adds synthetic grouping parents to GetHierarchy
+type HierarchyOptions struct {
+	// IPv4Levels and IPv6Levels are prefix lengths at which to group result
+	// prefixes under a common parent, for example 8 and 16. A level only
+	// applies to results more specific than it.
+	IPv4Levels []int
+	IPv6Levels []int
+}
+
+// GetHierarchy returns the current prefixes as a forest nested by
+// containment, IPv4 roots first and each level in address order. After
+// Aggregate the result prefixes never overlap, so without grouping
+// levels every node is a root.
+func (pa *PrefixAggregator) GetHierarchy(opts HierarchyOptions) ([]*PrefixNode, error) {
+	for _, level := range opts.IPv4Levels {
+		if level < 0 || level > 32 {
+			return nil, fmt.Errorf("%w: IPv4 grouping level must be 0-32, got %d", ErrInvalidOption, level)
+		}
+	}
+	for _, level := range opts.IPv6Levels {
+		if level < 0 || level > 128 {
+			return nil, fmt.Errorf("%w: IPv6 grouping level must be 0-128, got %d", ErrInvalidOption, level)
+		}
+	}
+
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if pa.closed {
+		return nil, ErrClosed
+	}
+
+	roots := buildHierarchy(pa.IPv4Prefixes, opts.IPv4Levels)
+	return append(roots, buildHierarchy(pa.IPv6Prefixes, opts.IPv6Levels)...), nil
+}
+
+// buildHierarchy nests one family's prefixes, and the grouping parents
+// they need, with a stack over address order
+func buildHierarchy(prefixes []*IPPrefix, levels []int) []*PrefixNode {
+	seen := make(map[netip.Prefix]bool, len(prefixes))
+	nodes := make([]*PrefixNode, 0, len(prefixes))
+	for _, p := range prefixes {
+		prefix := p.Prefix.Masked()
+		if !seen[prefix] {
+			seen[prefix] = true
+			nodes = append(nodes, &PrefixNode{Prefix: prefix, Addresses: prefixSize(prefix)})
+		}
+	}
+	for _, p := range prefixes {
+		for _, level := range levels {
+			if level >= p.Prefix.Bits() {
+				continue
+			}
+			parent, _ := p.Prefix.Addr().Prefix(level)
+			if !seen[parent] {
+				seen[parent] = true
+				nodes = append(nodes, &PrefixNode{Prefix: parent, Synthetic: true})
+			}
+		}
+	}
+
+	// Parents sort before the prefixes they contain
+	sort.Slice(nodes, func(i, j int) bool {
+		return ComparePrefixes(nodes[i].Prefix, nodes[j].Prefix) < 0
+	})
+
+	var roots, stack []*PrefixNode
+	for _, n := range nodes {
+		for len(stack) > 0 && !prefixContains(stack[len(stack)-1].Prefix, n.Prefix) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, n)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, n)
+		}
+		stack = append(stack, n)
+	}
+
+	for _, n := range roots {
+		sumAddresses(n)
+	}
+	return roots
+}
+
+// sumAddresses fills in Addresses for synthetic parents below and at n
+func sumAddresses(n *PrefixNode) *big.Int {
+	total := new(big.Int)
+	for _, c := range n.Children {
+		total.Add(total, sumAddresses(c))
+	}
+	if n.Synthetic {
+		n.Addresses = total
+	}
+	return n.Addresses
+}
+
+// prefixContains reports whether outer contains inner, both masked
+func prefixContains(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+// prefixSize returns the number of addresses in p
+func prefixSize(p netip.Prefix) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+}
+
+// WriteHierarchyDOT writes a tree from GetHierarchy as a Graphviz digraph,
+// labelling each node with its address count and drawing synthetic
+// parents dashed
+func WriteHierarchyDOT(w io.Writer, roots []*PrefixNode) error {
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintln(bw, "digraph prefixes {")
+	_, _ = fmt.Fprintln(bw, "  node [shape=box];")
+
+	var walk func(n *PrefixNode)
+	walk = func(n *PrefixNode) {
+		style := ""
+		if n.Synthetic {
+			style = ", style=dashed"
+		}
+		_, _ = fmt.Fprintf(bw, "  %q [label=\"%s\\n%s addresses\"%s];\n", n.Prefix, n.Prefix, n.Addresses, style)
+		for _, c := range n.Children {
+			_, _ = fmt.Fprintf(bw, "  %q -> %q;\n", n.Prefix, c.Prefix)
+			walk(c)
+		}
+	}
+	for _, n := range roots {
+		walk(n)
+	}
+
+	_, _ = fmt.Fprintln(bw, "}")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write hierarchy: %w", err)
+	}
+	return nil
+}
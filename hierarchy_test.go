@@ -0,0 +1,119 @@
+package netjugo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// renderHierarchy prints a tree one node per line, indented by depth
+func renderHierarchy(nodes []*PrefixNode, depth int, b *strings.Builder) {
+	for _, n := range nodes {
+		mark := ""
+		if n.Synthetic {
+			mark = "*"
+		}
+		fmt.Fprintf(b, "%s%s%s %s\n", strings.Repeat("  ", depth), n.Prefix, mark, n.Addresses)
+		renderHierarchy(n.Children, depth+1, b)
+	}
+}
+
+func TestGetHierarchy(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{
+		"10.0.0.0/24", "10.0.2.0/24", "10.1.0.0/16", "10.200.0.0/24",
+		"192.168.0.0/16", "2001:db8::/48", "2001:db8:2::/48",
+	}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	// Aggregated results do not overlap, so they are all roots
+	roots, err := pa.GetHierarchy(HierarchyOptions{})
+	if err != nil {
+		t.Fatalf("GetHierarchy failed: %v", err)
+	}
+	if len(roots) != 7 {
+		t.Errorf("Expected 7 roots without grouping, got %d", len(roots))
+	}
+
+	roots, err = pa.GetHierarchy(HierarchyOptions{IPv4Levels: []int{8, 16}, IPv6Levels: []int{32}})
+	if err != nil {
+		t.Fatalf("GetHierarchy failed: %v", err)
+	}
+	var b strings.Builder
+	renderHierarchy(roots, 0, &b)
+
+	expected := `10.0.0.0/8* 66304
+  10.0.0.0/16* 512
+    10.0.0.0/24 256
+    10.0.2.0/24 256
+  10.1.0.0/16 65536
+  10.200.0.0/16* 256
+    10.200.0.0/24 256
+192.0.0.0/8* 65536
+  192.168.0.0/16 65536
+2001:db8::/32* 2417851639229258349412352
+  2001:db8::/48 1208925819614629174706176
+  2001:db8:2::/48 1208925819614629174706176
+`
+	if b.String() != expected {
+		t.Errorf("Unexpected hierarchy:\n%s\nwant:\n%s", b.String(), expected)
+	}
+
+	if _, err := pa.GetHierarchy(HierarchyOptions{IPv4Levels: []int{33}}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for an IPv4 level of 33, got %v", err)
+	}
+}
+
+func TestGetHierarchyNestsOverlappingInput(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.1.2.0/24", "10.0.0.0/8", "10.1.0.0/16"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	roots, err := pa.GetHierarchy(HierarchyOptions{})
+	if err != nil {
+		t.Fatalf("GetHierarchy failed: %v", err)
+	}
+	var b strings.Builder
+	renderHierarchy(roots, 0, &b)
+
+	expected := "10.0.0.0/8 16777216\n  10.1.0.0/16 65536\n    10.1.2.0/24 256\n"
+	if b.String() != expected {
+		t.Errorf("Unexpected hierarchy:\n%s\nwant:\n%s", b.String(), expected)
+	}
+}
+
+func TestWriteHierarchyDOT(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.2.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	roots, err := pa.GetHierarchy(HierarchyOptions{IPv4Levels: []int{16}})
+	if err != nil {
+		t.Fatalf("GetHierarchy failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHierarchyDOT(&buf, roots); err != nil {
+		t.Fatalf("WriteHierarchyDOT failed: %v", err)
+	}
+
+	expected := `digraph prefixes {
+  node [shape=box];
+  "10.0.0.0/16" [label="10.0.0.0/16\n512 addresses", style=dashed];
+  "10.0.0.0/16" -> "10.0.0.0/24";
+  "10.0.0.0/24" [label="10.0.0.0/24\n256 addresses"];
+  "10.0.0.0/16" -> "10.0.2.0/24";
+  "10.0.2.0/24" [label="10.0.2.0/24\n256 addresses"];
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected DOT output:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
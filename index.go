@@ -0,0 +1,261 @@
+package netjugo
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"net/netip"
+)
+
+// PrefixIndex is an immutable level- and path-compressed binary trie over
+// a set of prefixes, for high-rate membership queries. A jump table on
+// the leading address bits replaces the top levels of the trie, chains of
+// single-child nodes below it are collapsed, and each family's nodes sit
+// in one flat slice. It is safe for concurrent use and independent of the
+// aggregator it was built from.
+type PrefixIndex struct {
+	ipv4 prefixTrie
+	ipv6 prefixTrie
+	n    int
+}
+
+// maxJumpBits caps the jump table at 64k entries per family
+const maxJumpBits = 16
+
+type prefixTrie struct {
+	nodes []indexNode
+	// jump is indexed by the first jumpBits bits of an address
+	jump     []jumpEntry
+	jumpBits uint8
+}
+
+// indexNode is a trie node covering key/bits. Node 0 is the root, so a
+// zero child means there is none.
+type indexNode struct {
+	key    [2]uint64
+	child  [2]uint32
+	bits   uint8
+	prefix bool
+}
+
+// jumpEntry resumes a lookup below the jump table: next is the first node
+// of at least jumpBits bits on the path, and best the most specific
+// prefix above it. Either is -1 if there is none.
+type jumpEntry struct {
+	next int32
+	best int32
+}
+
+// BuildIndex returns a PrefixIndex over the current prefixes. Like
+// Snapshot, it is usually called after Aggregate.
+func (pa *PrefixAggregator) BuildIndex() *PrefixIndex {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return &PrefixIndex{
+		ipv4: buildTrie(pa.IPv4Prefixes),
+		ipv6: buildTrie(pa.IPv6Prefixes),
+		n:    len(pa.IPv4Prefixes) + len(pa.IPv6Prefixes),
+	}
+}
+
+// Len returns the number of prefixes the index was built from
+func (idx *PrefixIndex) Len() int {
+	return idx.n
+}
+
+// Contains reports whether addr is covered by any prefix in the index
+func (idx *PrefixIndex) Contains(addr netip.Addr) bool {
+	_, ok := idx.lookup(addr, false)
+	return ok
+}
+
+// Lookup returns the most specific prefix in the index that covers addr
+func (idx *PrefixIndex) Lookup(addr netip.Addr) (netip.Prefix, bool) {
+	return idx.lookup(addr, true)
+}
+
+func (idx *PrefixIndex) lookup(addr netip.Addr, longest bool) (netip.Prefix, bool) {
+	if !addr.IsValid() {
+		return netip.Prefix{}, false
+	}
+
+	t := &idx.ipv6
+	if addr.Is4() {
+		t = &idx.ipv4
+	}
+	if len(t.nodes) == 0 {
+		return netip.Prefix{}, false
+	}
+
+	key := addrKey(addr)
+	e := t.jump[key[0]>>(64-t.jumpBits)]
+	best, next := int(e.best), int(e.next)
+	if best >= 0 && !longest {
+		next = -1
+	}
+
+	for i := next; i >= 0; {
+		n := &t.nodes[i]
+		if maskKey(key, int(n.bits)) != n.key {
+			break
+		}
+		if n.prefix {
+			best = i
+			if !longest {
+				break
+			}
+		}
+		if int(n.bits) == addr.BitLen() {
+			break
+		}
+		c := n.child[keyBit(key, int(n.bits))]
+		if c == 0 {
+			break
+		}
+		i = int(c)
+	}
+
+	if best < 0 {
+		return netip.Prefix{}, false
+	}
+	return t.nodes[best].toPrefix(addr.Is4()), true
+}
+
+// buildTrie inserts prefixes into a new trie and fills its jump table,
+// sized to about one entry per prefix
+func buildTrie(prefixes []*IPPrefix) prefixTrie {
+	if len(prefixes) == 0 {
+		return prefixTrie{}
+	}
+
+	nodes := make([]indexNode, 1, 2*len(prefixes))
+	for _, p := range prefixes {
+		masked := p.Prefix.Masked()
+		nodes = insertTrie(nodes, addrKey(masked.Addr()), masked.Bits())
+	}
+
+	t := prefixTrie{nodes: nodes, jumpBits: uint8(min(maxJumpBits, bits.Len(uint(len(prefixes)))))}
+	t.jump = make([]jumpEntry, 1<<t.jumpBits)
+	for v := range t.jump {
+		t.jump[v] = t.walkJump([2]uint64{uint64(v) << (64 - t.jumpBits), 0})
+	}
+	return t
+}
+
+// walkJump follows key through the nodes shorter than jumpBits, which
+// depend only on the bits the jump table is indexed by
+func (t *prefixTrie) walkJump(key [2]uint64) jumpEntry {
+	e := jumpEntry{next: -1, best: -1}
+	for i := 0; ; {
+		n := &t.nodes[i]
+		if n.bits >= t.jumpBits {
+			e.next = int32(i)
+			return e
+		}
+		if maskKey(key, int(n.bits)) != n.key {
+			return e
+		}
+		if n.prefix {
+			e.best = int32(i)
+		}
+		c := n.child[keyBit(key, int(n.bits))]
+		if c == 0 {
+			return e
+		}
+		i = int(c)
+	}
+}
+
+// insertTrie adds key/length below the root, splitting a collapsed edge
+// where the new prefix branches off it
+func insertTrie(nodes []indexNode, key [2]uint64, length int) []indexNode {
+	i := 0
+	for {
+		n := nodes[i]
+		if int(n.bits) == length {
+			nodes[i].prefix = true
+			return nodes
+		}
+
+		b := keyBit(key, int(n.bits))
+		c := int(n.child[b])
+		if c == 0 {
+			nodes = append(nodes, indexNode{key: key, bits: uint8(length), prefix: true})
+			nodes[i].child[b] = uint32(len(nodes) - 1)
+			return nodes
+		}
+
+		child := nodes[c]
+		common := min(commonBits(key, child.key), length, int(child.bits))
+		if common == int(child.bits) {
+			i = c
+			continue
+		}
+
+		// The new prefix and the child diverge, or the new prefix sits on
+		// the edge above the child: insert a node at the split point
+		split := indexNode{key: maskKey(key, common), bits: uint8(common), prefix: common == length}
+		split.child[keyBit(child.key, common)] = uint32(c)
+		nodes = append(nodes, split)
+		s := len(nodes) - 1
+		nodes[i].child[b] = uint32(s)
+		if common == length {
+			return nodes
+		}
+		nodes = append(nodes, indexNode{key: key, bits: uint8(length), prefix: true})
+		nodes[s].child[keyBit(key, common)] = uint32(len(nodes) - 1)
+		return nodes
+	}
+}
+
+func (n *indexNode) toPrefix(isIPv4 bool) netip.Prefix {
+	if isIPv4 {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n.key[0]>>32))
+		return netip.PrefixFrom(netip.AddrFrom4(b), int(n.bits))
+	}
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], n.key[0])
+	binary.BigEndian.PutUint64(b[8:], n.key[1])
+	return netip.PrefixFrom(netip.AddrFrom16(b), int(n.bits))
+}
+
+// addrKey left-aligns addr in 128 bits, so both families share the bit
+// helpers below
+func addrKey(addr netip.Addr) [2]uint64 {
+	if addr.Is4() {
+		b := addr.As4()
+		return [2]uint64{uint64(binary.BigEndian.Uint32(b[:])) << 32, 0}
+	}
+	b := addr.As16()
+	return [2]uint64{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
+
+// keyBit returns bit i of key, counting from the most significant
+func keyBit(key [2]uint64, i int) int {
+	if i < 64 {
+		return int(key[0] >> (63 - i) & 1)
+	}
+	return int(key[1] >> (127 - i) & 1)
+}
+
+// maskKey keeps the first length bits of key
+func maskKey(key [2]uint64, length int) [2]uint64 {
+	switch {
+	case length == 0:
+		return [2]uint64{}
+	case length <= 64:
+		return [2]uint64{key[0] & (^uint64(0) << (64 - length)), 0}
+	case length < 128:
+		return [2]uint64{key[0], key[1] & (^uint64(0) << (128 - length))}
+	}
+	return key
+}
+
+// commonBits returns the length of the common leading bits of a and b
+func commonBits(a, b [2]uint64) int {
+	if a[0] != b[0] {
+		return bits.LeadingZeros64(a[0] ^ b[0])
+	}
+	return 64 + bits.LeadingZeros64(a[1]^b[1])
+}
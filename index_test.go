@@ -0,0 +1,161 @@
+package netjugo
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestPrefixIndexLookup(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{
+		"10.0.0.0/8", "10.1.0.0/16", "10.1.2.3/32", "192.168.0.0/24",
+		"2001:db8::/32", "2001:db8:1::/48", "2001:db8:1::1/128",
+	}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	idx := pa.BuildIndex()
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"10.9.9.9", "10.0.0.0/8"},
+		{"10.1.9.9", "10.1.0.0/16"},
+		{"10.1.2.3", "10.1.2.3/32"},
+		{"10.1.2.4", "10.1.0.0/16"},
+		{"192.168.0.255", "192.168.0.0/24"},
+		{"192.168.1.0", ""},
+		{"11.0.0.0", ""},
+		{"2001:db8:ffff::1", "2001:db8::/32"},
+		{"2001:db8:1::1", "2001:db8:1::1/128"},
+		{"2001:db8:1::2", "2001:db8:1::/48"},
+		{"2001:db9::", ""},
+		{"::ffff:10.0.0.1", ""},
+	}
+
+	for _, tt := range tests {
+		addr := netip.MustParseAddr(tt.addr)
+		got, ok := idx.Lookup(addr)
+		if tt.want == "" {
+			if ok || idx.Contains(addr) {
+				t.Errorf("Lookup(%s) = %s, want no match", tt.addr, got)
+			}
+			continue
+		}
+		if !ok || got.String() != tt.want || !idx.Contains(addr) {
+			t.Errorf("Lookup(%s) = %s, %v, want %s", tt.addr, got, ok, tt.want)
+		}
+	}
+
+	if idx.Len() != 7 {
+		t.Errorf("Len() = %d, want 7", idx.Len())
+	}
+	if _, ok := NewPrefixAggregator().BuildIndex().Lookup(netip.MustParseAddr("10.0.0.1")); ok {
+		t.Error("Expected no match in an empty index")
+	}
+}
+
+func TestPrefixIndexDefaultRoute(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"0.0.0.0/0", "10.0.0.0/8", "::/0"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	idx := pa.BuildIndex()
+
+	for addr, want := range map[string]string{
+		"1.2.3.4":  "0.0.0.0/0",
+		"10.0.0.1": "10.0.0.0/8",
+		"2001::1":  "::/0",
+	} {
+		if got, ok := idx.Lookup(netip.MustParseAddr(addr)); !ok || got.String() != want {
+			t.Errorf("Lookup(%s) = %s, %v, want %s", addr, got, ok, want)
+		}
+	}
+}
+
+// Nested and overlapping random prefixes must give the same answers as
+// the binary search in AggregatedSet
+func TestPrefixIndexMatchesSnapshot(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	pa := NewPrefixAggregator()
+	for i := 0; i < 3000; i++ {
+		var addr netip.Addr
+		var length int
+		if i%2 == 0 {
+			addr = netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+			length = 8 + rng.Intn(25)
+		} else {
+			addr = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(rng.Intn(4)), byte(rng.Intn(256)), 15: byte(rng.Intn(256))})
+			length = 32 + rng.Intn(97)
+		}
+		if err := pa.AddPrefix(netip.PrefixFrom(addr, length).Masked().String()); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+	}
+	idx := pa.BuildIndex()
+	set := pa.Snapshot()
+
+	for i := 0; i < 20000; i++ {
+		var addr netip.Addr
+		if i%2 == 0 {
+			addr = netip.AddrFrom4([4]byte{10, byte(rng.Intn(5)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		} else {
+			addr = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(rng.Intn(5)), byte(rng.Intn(256)), 15: byte(rng.Intn(256))})
+		}
+		got, gotOK := idx.Lookup(addr)
+		want, wantOK := set.LongestPrefixMatch(addr)
+		if got != want || gotOK != wantOK {
+			t.Fatalf("Lookup(%s) = %s, %v; snapshot gives %s, %v", addr, got, gotOK, want, wantOK)
+		}
+		if idx.Contains(addr) != wantOK {
+			t.Fatalf("Contains(%s) = %v, want %v", addr, !wantOK, wantOK)
+		}
+	}
+}
+
+func benchmarkLookupSetup(b *testing.B) (*PrefixAggregator, []netip.Addr) {
+	b.Helper()
+
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(generateTestPrefixes(100000)); err != nil {
+		b.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		b.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	// Half the queries hit a result prefix, half are random
+	rng := rand.New(rand.NewSource(2))
+	prefixes := pa.GetPrefixes()
+	addrs := make([]netip.Addr, 4096)
+	for i := range addrs {
+		if i%2 == 0 {
+			addrs[i] = netip.MustParsePrefix(prefixes[rng.Intn(len(prefixes))]).Addr()
+		} else {
+			addrs[i] = netip.AddrFrom4([4]byte{byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		}
+	}
+	return pa, addrs
+}
+
+func BenchmarkLookup(b *testing.B) {
+	pa, addrs := benchmarkLookupSetup(b)
+
+	b.Run("Index", func(b *testing.B) {
+		idx := pa.BuildIndex()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			idx.Lookup(addrs[i%len(addrs)])
+		}
+	})
+
+	b.Run("Snapshot", func(b *testing.B) {
+		set := pa.Snapshot()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			set.LongestPrefixMatch(addrs[i%len(addrs)])
+		}
+	})
+}
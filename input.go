@@ -0,0 +1,190 @@
+package netjugo
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// InputFormat selects how AddFromReader and the file readers take a
+// prefix from a line
+type InputFormat int
+
+const (
+	// InputFirstColumn also accepts lines with more columns after an
+	// explicit prefix, as in routing table exports, and reads only the
+	// first. A bare address followed by more columns is skipped, as the
+	// next column may be a netmask. This is the default.
+	InputFirstColumn InputFormat = iota
+	// InputStrict skips every line that is not just a prefix or address
+	InputStrict
+)
+
+// lineSyntax is how input lines are read, copied from the aggregator for
+// each read
+type lineSyntax struct {
+	format InputFormat
+	// comments are the markers starting a comment, on a line of its own
+	// or after whitespace
+	comments []string
+	// headers are first columns that mark a header line, in any case
+	headers []string
+}
+
+func defaultLineSyntax() lineSyntax {
+	return lineSyntax{
+		comments: []string{"#"},
+		headers:  []string{"network", "prefix", "cidr"},
+	}
+}
+
+func (s lineSyntax) clone() lineSyntax {
+	s.comments = slices.Clone(s.comments)
+	s.headers = slices.Clone(s.headers)
+	return s
+}
+
+// SetInputFormat selects whether AddFromReader and the file readers take
+// an explicit prefix from the first column of a wider line
+// (InputFirstColumn, the default) or skip such lines (InputStrict)
+func (pa *PrefixAggregator) SetInputFormat(format InputFormat) error {
+	if format != InputFirstColumn && format != InputStrict {
+		return fmt.Errorf("%w: unknown input format %d", ErrInvalidOption, format)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.syntax.format = format
+	return nil
+}
+
+// SetCommentPrefixes replaces the comment markers of the readers, "#" by
+// default. A marker at the start of a line makes it a comment; after
+// whitespace it ends the line, as in "10.0.0.0/8 ; corp net". An empty
+// list turns comments off.
+func (pa *PrefixAggregator) SetCommentPrefixes(prefixes []string) error {
+	if err := checkSyntaxWords("comment prefix", prefixes); err != nil {
+		return err
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.syntax.comments = slices.Clone(prefixes)
+	return nil
+}
+
+// SetHeaderWords replaces the words that mark a header line when they are
+// its first column, in any case; "network", "prefix" and "cidr" by
+// default. An empty list turns header skipping off.
+func (pa *PrefixAggregator) SetHeaderWords(words []string) error {
+	if err := checkSyntaxWords("header word", words); err != nil {
+		return err
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.syntax.headers = slices.Clone(words)
+	return nil
+}
+
+func checkSyntaxWords(kind string, words []string) error {
+	for _, w := range words {
+		if w == "" || strings.IndexFunc(w, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("%w: %s %q must be non-empty and without whitespace", ErrInvalidOption, kind, w)
+		}
+	}
+	return nil
+}
+
+type lineKind int
+
+const (
+	linePrefix  lineKind = iota // a prefix to parse
+	lineIgnored                 // blank, comment or header
+	lineInvalid                 // cannot be a prefix
+)
+
+// parse classifies one line of input and returns the prefix to parse,
+// with /32 or /128 added to bare addresses, and the prefix as written
+func (s lineSyntax) parse(raw string) (prefix, text string, kind lineKind) {
+	line := s.stripComment(strings.TrimSpace(raw))
+	if line == "" {
+		return "", "", lineIgnored
+	}
+
+	first, more := line, false
+	if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+		first, more = line[:i], true
+	}
+	if s.isHeader(first) {
+		return "", "", lineIgnored
+	}
+
+	// Take an explicit prefix from the first column of a wider line
+	if more {
+		if s.format == InputStrict || !strings.Contains(first, "/") {
+			return "", "", lineInvalid
+		}
+		line = first
+	}
+
+	// Handle lines that might be missing CIDR notation
+	if !strings.Contains(line, "/") {
+		// Try to add /32 for IPv4 addresses or /128 for IPv6 addresses
+		if strings.Contains(line, ":") {
+			return line + "/128", line, linePrefix
+		} else if strings.Count(line, ".") == 3 {
+			return line + "/32", line, linePrefix
+		}
+		return "", "", lineInvalid
+	}
+
+	return line, line, linePrefix
+}
+
+// stripComment cuts line at the first comment marker that starts it or
+// follows whitespace
+func (s lineSyntax) stripComment(line string) string {
+	end := len(line)
+	for _, marker := range s.comments {
+		for i := 0; i < end; {
+			j := strings.Index(line[i:end], marker)
+			if j < 0 {
+				break
+			}
+			j += i
+			if r, _ := utf8.DecodeLastRuneInString(line[:j]); j == 0 || unicode.IsSpace(r) {
+				end = j
+				break
+			}
+			i = j + 1
+		}
+	}
+	return strings.TrimSpace(line[:end])
+}
+
+func (s lineSyntax) isHeader(word string) bool {
+	for _, h := range s.headers {
+		if strings.EqualFold(word, h) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,11 @@
 package netjugo
 
 import (
+	"bytes"
+	"math/rand"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -98,16 +102,11 @@ func TestFullWorkflowIntegration(t *testing.T) {
 	}
 
 	// Step 10: Test file I/O
-	tempFile := "/tmp/integration_test_output.txt"
+	tempFile := filepath.Join(t.TempDir(), "integration_test_output.txt")
 	err = pa.WriteToFile(tempFile)
 	if err != nil {
 		t.Fatalf("Failed to write to file: %v", err)
 	}
-	defer func() {
-		if err := os.Remove(tempFile); err != nil {
-			t.Logf("Failed to remove temp file: %v", err)
-		}
-	}()
 
 	// Read back and verify
 	pa2 := NewPrefixAggregator()
@@ -342,3 +341,54 @@ func TestPerformanceRequirementsIntegration(t *testing.T) {
 		}
 	})
 }
+
+// TestDeterministicOutput aggregates the same input, shuffled differently,
+// on fresh aggregators and expects byte-identical output every time
+func TestDeterministicOutput(t *testing.T) {
+	var input, excludes []string
+	for i, s := range generateTestPrefixes(3000) {
+		input = append(input, s)
+		p := netip.MustParsePrefix(s)
+		if i%7 == 0 && p.Bits()+4 <= p.Addr().BitLen() {
+			// Exclusions inside input prefixes leave fragments behind
+			excludes = append(excludes, netip.PrefixFrom(p.Addr(), p.Bits()+4).String())
+		}
+		if i%11 == 0 {
+			// The same network again, given with host bits set
+			input = append(input, netip.PrefixFrom(p.Addr().Next(), p.Bits()).String())
+		}
+	}
+
+	var want []byte
+	for run := range 5 {
+		rng := rand.New(rand.NewSource(int64(run)))
+		rng.Shuffle(len(input), func(i, j int) { input[i], input[j] = input[j], input[i] })
+		rng.Shuffle(len(excludes), func(i, j int) { excludes[i], excludes[j] = excludes[j], excludes[i] })
+
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefixes(input); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.SetExcludePrefixes(excludes); err != nil {
+			t.Fatalf("Failed to set exclude prefixes: %v", err)
+		}
+		if err := pa.SetIncludePrefixes([]string{"198.51.100.0/24", "2001:db8:ffff::/48"}); err != nil {
+			t.Fatalf("Failed to set include prefixes: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := pa.WriteToWriter(&buf); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		if run == 0 {
+			want = buf.Bytes()
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Fatalf("Run %d wrote different output than run 0 (%d bytes vs %d)", run, buf.Len(), len(want))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package profile wraps runtime/pprof for the command line tools.
+package profile
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Start creates the requested profile files and begins CPU profiling. An
+// empty path disables that profile. Both files are created up front so a
+// bad path fails before any work is done. The returned function stops CPU
+// profiling and writes the heap profile after a final GC; it must always
+// be called.
+func Start(cpuPath, memPath string) (func() error, error) {
+	var cpuFile, memFile *os.File
+
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		cpuFile = f
+	}
+
+	if memPath != "" {
+		f, err := os.Create(memPath)
+		if err != nil {
+			if cpuFile != nil {
+				_ = cpuFile.Close()
+			}
+			return nil, fmt.Errorf("failed to create memory profile: %w", err)
+		}
+		memFile = f
+	}
+
+	if cpuFile != nil {
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			_ = cpuFile.Close()
+			if memFile != nil {
+				_ = memFile.Close()
+			}
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+	}
+
+	stop := func() error {
+		var firstErr error
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				firstErr = fmt.Errorf("failed to write CPU profile: %w", err)
+			}
+		}
+		if memFile != nil {
+			runtime.GC() // report live heap only
+			err := pprof.WriteHeapProfile(memFile)
+			if closeErr := memFile.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to write memory profile: %w", err)
+			}
+		}
+		return firstErr
+	}
+
+	return stop, nil
+}
@@ -2,7 +2,11 @@ package netjugo
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -69,6 +73,123 @@ invalid-prefix
 	if len(prefixes) != 2 {
 		t.Errorf("Expected 2 valid prefixes to be loaded, got %d", len(prefixes))
 	}
+
+	if skipped := pa.GetStats().SkippedLines; skipped != 1 {
+		t.Errorf("Expected 1 skipped line, got %d", skipped)
+	}
+}
+
+func TestAddFromReaderCount(t *testing.T) {
+	pa := NewPrefixAggregator()
+
+	// Comments and blank lines are neither added nor skipped
+	input := "# header\n192.168.1.0/24\n\ninvalid-prefix\n10.0.0.1\n2001:db8::/32\nfoo.bar\n"
+	result, err := pa.AddFromReaderCount(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("AddFromReaderCount failed: %v", err)
+	}
+	if result.Added != 3 || result.Skipped != 2 {
+		t.Errorf("Expected 3 added and 2 skipped, got %+v", result)
+	}
+
+	// Counts are per call, while the stats accumulate
+	result, err = pa.AddFromReaderCount(strings.NewReader("bad\n172.16.0.0/12\n"))
+	if err != nil {
+		t.Fatalf("AddFromReaderCount failed: %v", err)
+	}
+	if result.Added != 1 || result.Skipped != 1 {
+		t.Errorf("Expected 1 added and 1 skipped, got %+v", result)
+	}
+	if skipped := pa.GetStats().SkippedLines; skipped != 3 {
+		t.Errorf("Expected 3 skipped lines in stats, got %d", skipped)
+	}
+
+	path := filepath.Join(t.TempDir(), "prefixes.txt")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	result, err = NewPrefixAggregator().AddFromFileCount(path)
+	if err != nil || result.Added != 3 || result.Skipped != 2 {
+		t.Errorf("AddFromFileCount = %+v, %v; want 3 added and 2 skipped", result, err)
+	}
+}
+
+func TestStatsCountPerFamily(t *testing.T) {
+	pa := NewPrefixAggregator()
+
+	input := `# header comment
+prefix
+10.0.0.0/24
+10.0.1.0/24
+2001:db8::/32
+999.1.1.1
+not-an-ip
+`
+	if err := pa.AddFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to add from reader: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	stats := pa.GetStats()
+	if stats.OriginalIPv4Count != 2 || stats.OriginalIPv6Count != 1 {
+		t.Errorf("Expected original counts 2/1, got %d/%d", stats.OriginalIPv4Count, stats.OriginalIPv6Count)
+	}
+	if stats.SkippedLines != 2 {
+		t.Errorf("Expected 2 skipped lines, got %d", stats.SkippedLines)
+	}
+
+	if err := pa.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if stats := pa.GetStats(); stats.SkippedLines != 0 || stats.OriginalIPv4Count != 0 {
+		t.Errorf("Reset should clear counters, got %+v", stats)
+	}
+}
+
+func TestStatsCachedAfterAggregate(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(generateTestPrefixes(1000)); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.AddFromReaderTagged(strings.NewReader("192.0.2.0/24\n"), "feed"); err != nil {
+		t.Fatalf("Failed to add tagged input: %v", err)
+	}
+
+	stats, err := pa.AggregateStats()
+	if err != nil {
+		t.Fatalf("AggregateStats failed: %v", err)
+	}
+	if got := pa.GetStats(); got != stats {
+		t.Errorf("GetStats = %+v, want the AggregateStats result %+v", got, stats)
+	}
+	if got := pa.GetStats(); got != stats {
+		t.Errorf("Repeated GetStats = %+v, want %+v", got, stats)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _ = pa.GetStats() }); allocs != 0 {
+		t.Errorf("Expected GetStats to return the cached stats without allocating, got %v allocs", allocs)
+	}
+
+	// Every kind of change is seen by the next GetStats
+	if err := pa.AddFromReader(strings.NewReader("bogus\n")); err != nil {
+		t.Fatalf("Failed to add from reader: %v", err)
+	}
+	if got := pa.GetStats().SkippedLines; got != stats.SkippedLines+1 {
+		t.Errorf("Expected a skipped line to update the stats, got %d", got)
+	}
+	if err := pa.SetIncludePrefixes([]string{"198.51.100.0/24"}); err != nil {
+		t.Fatalf("Failed to set include prefixes: %v", err)
+	}
+	if got := pa.GetStats().IncludedCount; got != 1 {
+		t.Errorf("Expected a new include to update the stats, got %d", got)
+	}
+	if err := pa.AddPrefix("203.0.113.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if got := pa.GetStats().OriginalCount; got != stats.OriginalCount+1 {
+		t.Errorf("Expected an added prefix to update the stats, got %d", got)
+	}
 }
 
 func TestAddFromFile(t *testing.T) {
@@ -161,6 +282,162 @@ func TestWriteToWriter(t *testing.T) {
 	}
 }
 
+// limitWriter accepts limit bytes, then fails like a full disk
+type limitWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+var errDiskFull = errors.New("no space left on device")
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if room := w.limit - w.buf.Len(); len(p) > room {
+		w.buf.Write(p[:room])
+		return room, errDiskFull
+	}
+	return w.buf.Write(p)
+}
+
+func TestWriteToWriterN(t *testing.T) {
+	pa := NewPrefixAggregator()
+	for i := 0; i < 1000; i++ {
+		if err := pa.AddPrefix(fmt.Sprintf("10.%d.%d.0/24", i/100, 2*(i%100))); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+	}
+
+	for _, header := range []bool{false, true} {
+		pa.SetOutputHeader(header)
+		var full bytes.Buffer
+		if n, err := pa.WriteToWriterN(&full); err != nil || n != 1000 {
+			t.Fatalf("header %v: expected 1000 prefixes written, got %d, %v", header, n, err)
+		}
+
+		// Fail part-way through the 700th prefix, past the first buffer flush
+		lines := strings.SplitAfter(full.String(), "\n")
+		skip := 0
+		if header {
+			skip = 4
+		}
+		limit := len(strings.Join(lines[:skip+699], "")) + 3
+		w := &limitWriter{limit: limit}
+		n, err := pa.WriteToWriterN(w)
+		if !errors.Is(err, errDiskFull) {
+			t.Fatalf("header %v: expected the writer's error, got %v", header, err)
+		}
+		if n != 699 {
+			t.Errorf("header %v: expected 699 prefixes written, got %d", header, n)
+		}
+		if !strings.Contains(err.Error(), "(699 of 1000 prefixes written)") {
+			t.Errorf("header %v: expected the position in the error, got %v", header, err)
+		}
+		if err := pa.WriteToWriter(&limitWriter{limit: limit}); err == nil || !strings.Contains(err.Error(), "699 of 1000") {
+			t.Errorf("header %v: expected WriteToWriter to report the position, got %v", header, err)
+		}
+	}
+}
+
+func TestWriteSplit(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/25", "10.0.0.128/25", "2001:db8::/48", "192.0.2.0/24", "2001:db8:1::/48"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	var v4, v6, all bytes.Buffer
+	if err := pa.WriteSplit(&v4, &v6); err != nil {
+		t.Fatalf("WriteSplit failed: %v", err)
+	}
+	if got := v4.String(); got != "10.0.0.0/24\n192.0.2.0/24\n" {
+		t.Errorf("Unexpected IPv4 output %q", got)
+	}
+	if got := v6.String(); got != "2001:db8::/47\n" {
+		t.Errorf("Unexpected IPv6 output %q", got)
+	}
+	if err := pa.WriteToWriter(&all); err != nil {
+		t.Fatalf("WriteToWriter failed: %v", err)
+	}
+	if all.String() != v4.String()+v6.String() {
+		t.Errorf("Split output %q + %q differs from WriteToWriter %q", v4.String(), v6.String(), all.String())
+	}
+
+	// A nil writer skips its family
+	v6.Reset()
+	if err := pa.WriteSplit(nil, &v6); err != nil {
+		t.Fatalf("WriteSplit with a nil IPv4 writer failed: %v", err)
+	}
+	if got := v6.String(); got != "2001:db8::/47\n" {
+		t.Errorf("Unexpected IPv6 output %q", got)
+	}
+	if err := pa.WriteSplit(nil, nil); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption without writers, got %v", err)
+	}
+}
+
+func TestGetPrefixesAppend(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "2001:db8::/32", "192.168.0.0/16"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	want := strings.Join(pa.GetPrefixes(), ",")
+
+	buf := pa.GetPrefixesAppend([]string{"kept"})
+	if got := strings.Join(buf, ","); got != "kept,"+want {
+		t.Errorf("GetPrefixesAppend = %s, want kept,%s", got, want)
+	}
+
+	// Reusing the buffer does not grow it again
+	capacity := cap(buf)
+	buf = pa.GetPrefixesAppend(buf[:0])
+	if got := strings.Join(buf, ","); got != want {
+		t.Errorf("GetPrefixesAppend = %s, want %s", got, want)
+	}
+	if cap(buf) != capacity {
+		t.Errorf("Buffer was reallocated: cap %d, was %d", cap(buf), capacity)
+	}
+}
+
+func TestAppendFamilyPrefixes(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.1.0/24", "2001:db8::/32", "192.168.0.0/16"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	if got := pa.AppendIPv4Prefixes([]string{"kept"}); !slices.Equal(got, append([]string{"kept"}, pa.GetIPv4Prefixes()...)) {
+		t.Errorf("AppendIPv4Prefixes = %v, want kept followed by %v", got, pa.GetIPv4Prefixes())
+	}
+	if got := pa.AppendIPv6Prefixes(nil); !slices.Equal(got, pa.GetIPv6Prefixes()) {
+		t.Errorf("AppendIPv6Prefixes = %v, want %v", got, pa.GetIPv6Prefixes())
+	}
+
+	var v4, v6 []string
+	for _, p := range pa.AppendIPv4NetipPrefixes(nil) {
+		v4 = append(v4, p.String())
+	}
+	for _, p := range pa.AppendIPv6NetipPrefixes(nil) {
+		v6 = append(v6, p.String())
+	}
+	if !slices.Equal(v4, pa.GetIPv4Prefixes()) || !slices.Equal(v6, pa.GetIPv6Prefixes()) {
+		t.Errorf("Netip appends = %v %v, want %v %v", v4, v6, pa.GetIPv4Prefixes(), pa.GetIPv6Prefixes())
+	}
+
+	// A reused buffer makes the netip variant allocation free
+	buf := pa.AppendIPv4NetipPrefixes(nil)
+	if allocs := testing.AllocsPerRun(100, func() {
+		buf = pa.AppendIPv4NetipPrefixes(buf[:0])
+	}); allocs != 0 {
+		t.Errorf("Expected no allocations with a reused buffer, got %v", allocs)
+	}
+}
+
 func TestWriteToFile(t *testing.T) {
 	pa := NewPrefixAggregator()
 
@@ -206,6 +483,84 @@ func TestWriteToFile(t *testing.T) {
 	}
 }
 
+func TestWriteToFiles(t *testing.T) {
+	pa := NewPrefixAggregator()
+	for i := 0; i < 6; i++ {
+		if err := pa.AddPrefix(fmt.Sprintf("10.%d.0.0/24", i*2)); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+	}
+	if err := pa.AddPrefix("2001:db8::/32"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+
+	var expected bytes.Buffer
+	if err := pa.WriteToWriter(&expected); err != nil {
+		t.Fatalf("Failed to write to writer: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		maxPerFile int
+		files      int
+	}{
+		{"remainder", 3, 3},
+		{"exact multiple", 7, 1},
+		{"one per file", 1, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := filepath.Join(t.TempDir(), "out.txt")
+			paths, err := pa.WriteToFiles(pattern, tt.maxPerFile)
+			if err != nil {
+				t.Fatalf("WriteToFiles failed: %v", err)
+			}
+			if len(paths) != tt.files {
+				t.Fatalf("Expected %d files, got %d: %v", tt.files, len(paths), paths)
+			}
+			if filepath.Base(paths[0]) != "out-001.txt" {
+				t.Errorf("Unexpected first file name %s", paths[0])
+			}
+
+			var combined bytes.Buffer
+			for _, path := range paths {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Failed to read %s: %v", path, err)
+				}
+				if lines := strings.Count(string(data), "\n"); lines > tt.maxPerFile {
+					t.Errorf("%s has %d lines, limit is %d", path, lines, tt.maxPerFile)
+				}
+				combined.Write(data)
+			}
+			if combined.String() != expected.String() {
+				t.Errorf("Chunks differ from WriteToWriter output:\n%s\nwant:\n%s", combined.String(), expected.String())
+			}
+		})
+	}
+}
+
+func TestWriteToFilesPattern(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.2.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	pattern := filepath.Join(t.TempDir(), "part%d.lst")
+	paths, err := pa.WriteToFiles(pattern, 1)
+	if err != nil {
+		t.Fatalf("WriteToFiles failed: %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[1]) != "part2.lst" {
+		t.Errorf("Unexpected paths %v", paths)
+	}
+
+	if _, err := pa.WriteToFiles(pattern, 0); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for zero maxPerFile, got %v", err)
+	}
+}
+
 func TestRoundTripFileIO(t *testing.T) {
 	// Test writing to file and reading back
 	pa1 := NewPrefixAggregator()
@@ -299,3 +654,187 @@ func TestCommentAndEmptyLineHandling(t *testing.T) {
 		t.Errorf("Expected %d prefixes, got %d", expected, len(prefixes))
 	}
 }
+
+func TestIPv6FormatGolden(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "ipv6_format", "input.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read input: %v", err)
+	}
+
+	tests := []struct {
+		format IPv6Format
+		golden string
+	}{
+		{IPv6Compressed, "compressed.golden"},
+		{IPv6Expanded, "expanded.golden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			pa := NewPrefixAggregator()
+			if err := pa.AddFromReader(bytes.NewReader(input)); err != nil {
+				t.Fatalf("Failed to add prefixes: %v", err)
+			}
+			if err := pa.SetIPv6Format(tt.format); err != nil {
+				t.Fatalf("SetIPv6Format failed: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := pa.WriteToWriter(&buf); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+
+			want, err := os.ReadFile(filepath.Join("testdata", "ipv6_format", tt.golden))
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+			if buf.String() != string(want) {
+				t.Errorf("Output mismatch:\n%s\nwant:\n%s", buf.String(), want)
+			}
+			if got := strings.Join(pa.GetIPv6Prefixes(), "\n"); !strings.Contains(string(want), got) {
+				t.Errorf("GetIPv6Prefixes rendered differently from the writer: %s", got)
+			}
+		})
+	}
+}
+
+func TestSetIPv6FormatInvalid(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.SetIPv6Format(IPv6Format(42)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestRoutingTableExport(t *testing.T) {
+	path := filepath.Join("testdata", "routing_table", "export.txt")
+
+	pa := NewPrefixAggregator()
+	result, err := pa.AddFromFileCount(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	// The header is ignored and the bare address with a netmask column is
+	// skipped; the next hops are never read as prefixes
+	if result.Added != 6 || result.Skipped != 1 {
+		t.Errorf("Expected 6 added and 1 skipped, got %+v", result)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	want := []string{"10.1.0.0/16", "10.2.0.0/23", "198.51.100.0/24", "203.0.113.0/24", "2001:db8:100::/48"}
+	if got := pa.GetPrefixes(); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// The file readers take the same column
+	files := NewPrefixAggregator()
+	if err := files.AddFromFiles([]string{path}, 1); err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if err := files.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := files.GetPrefixes(); !slices.Equal(got, want) {
+		t.Errorf("AddFromFiles: expected %v, got %v", want, got)
+	}
+
+	// Minimal change mode keeps only the prefix column of untouched lines
+	minimal := NewPrefixAggregator()
+	minimal.SetMinimalChange(true)
+	if err := minimal.AddFromFile(path); err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if err := minimal.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := minimal.GetPrefixes(); !slices.Equal(got, []string{"10.1.0.0/16", "10.2.0.0/23", "203.0.113.0/24", "2001:db8:100::/48", "198.51.100.0/24"}) {
+		t.Errorf("Minimal change: got %v", got)
+	}
+}
+
+func TestInputFormatStrict(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.SetInputFormat(InputStrict); err != nil {
+		t.Fatalf("Failed to set input format: %v", err)
+	}
+
+	input := "network\n10.1.0.0/16 192.0.2.1\n10.2.0.0/24\n192.0.2.1\n"
+	result, err := pa.AddFromReaderCount(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("AddFromReaderCount failed: %v", err)
+	}
+	if result.Added != 2 || result.Skipped != 1 {
+		t.Errorf("Expected 2 added and 1 skipped, got %+v", result)
+	}
+	if err := pa.SetInputFormat(InputFormat(7)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestCommentPrefixes(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.SetCommentPrefixes([]string{"#", ";", "//"}); err != nil {
+		t.Fatalf("Failed to set comment prefixes: %v", err)
+	}
+
+	input := strings.Join([]string{
+		"# hash comment",
+		"; semicolon comment",
+		"// slash comment",
+		"10.0.0.0/8 ; corp net",
+		"172.16.0.0/12 # lab",
+		"192.168.0.0/16 // home",
+		"192.0.2.1 ; bare address",
+		"2001:db8::/32\t// tab before the marker",
+		"198.51.100.0/24;no space", // a marker inside the token is not a comment
+	}, "\n")
+	result, err := pa.AddFromReaderCount(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("AddFromReaderCount failed: %v", err)
+	}
+	if result.Added != 5 || result.Skipped != 1 {
+		t.Errorf("Expected 5 added and 1 skipped, got %+v", result)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "172.16.0.0/12", "192.0.2.1/32", "192.168.0.0/16", "2001:db8::/32"}
+	if got := pa.GetPrefixes(); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// Only configured markers start comments
+	strict := NewPrefixAggregator()
+	result, err = strict.AddFromReaderCount(strings.NewReader("; not a comment by default\n10.0.0.0/8 # still one\n"))
+	if err != nil {
+		t.Fatalf("AddFromReaderCount failed: %v", err)
+	}
+	if result.Added != 1 || result.Skipped != 1 {
+		t.Errorf("Default markers: expected 1 added and 1 skipped, got %+v", result)
+	}
+
+	if err := pa.SetCommentPrefixes([]string{""}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for an empty marker, got %v", err)
+	}
+}
+
+func TestHeaderWords(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.SetHeaderWords([]string{"Route", "Destination"}); err != nil {
+		t.Fatalf("Failed to set header words: %v", err)
+	}
+
+	input := "ROUTE  Gateway\ndestination\n10.0.0.0/8\nnetwork\n"
+	result, err := pa.AddFromReaderCount(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("AddFromReaderCount failed: %v", err)
+	}
+	// "network" is no longer a header word, so it is a skipped line
+	if result.Added != 1 || result.Skipped != 1 {
+		t.Errorf("Expected 1 added and 1 skipped, got %+v", result)
+	}
+
+	if err := pa.SetHeaderWords([]string{"next hop"}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a word with a space, got %v", err)
+	}
+}
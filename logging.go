@@ -0,0 +1,75 @@
+package netjugo
+
+import (
+	"log/slog"
+	"net/netip"
+	"time"
+)
+
+// SetLogger sends structured diagnostics to logger: warnings at Warn
+// level, in addition to the warning handler and GetWarnings; skipped
+// input lines at Debug level; and Aggregate phase timings at Info level.
+// Warnings and phases are logged with the aggregator locked, so the
+// handler must not call back into it. A nil logger turns logging off.
+func (pa *PrefixAggregator) SetLogger(logger *slog.Logger) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.logger = logger
+}
+
+// logWarning logs a recorded warning with its code, prefixes and set
+func (pa *PrefixAggregator) logWarning(w Warning) {
+	if pa.logger == nil {
+		return
+	}
+
+	attrs := []any{"code", string(w.Code), "prefix", w.Prefix.String(), "family", prefixFamily(w.Prefix)}
+	if w.Related.IsValid() {
+		attrs = append(attrs, "related", w.Related.String())
+	}
+	if w.Set != "" {
+		attrs = append(attrs, "set", w.Set)
+	}
+	pa.logger.Warn(w.Message, attrs...)
+}
+
+// logPhase logs the time since *start for one Aggregate phase and starts
+// timing the next
+func (pa *PrefixAggregator) logPhase(phase string, start *time.Time) {
+	if pa.logger == nil {
+		return
+	}
+
+	now := time.Now()
+	pa.logger.Info("aggregation phase complete", "phase", phase, "duration", now.Sub(*start))
+	*start = now
+}
+
+// skippedLine reports an input line that could not be added; source is
+// the file name, empty for a reader, tag the AddFromReaderTagged tag, and
+// err is nil for a line that is not a prefix at all
+func skippedLine(logger *slog.Logger, metrics Metrics, source, tag string, lineNumber int, line string, reason SkipReason, err error) {
+	metrics.IncSkippedLine()
+	if logger == nil {
+		return
+	}
+
+	attrs := []any{"line", lineNumber, "text", line, "reason", reason.String()}
+	if source != "" {
+		attrs = append(attrs, "file", source)
+	}
+	if tag != "" {
+		attrs = append(attrs, "tag", tag)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+	logger.Debug("skipped input line", attrs...)
+}
+
+func prefixFamily(p netip.Prefix) string {
+	if p.Addr().Is4() {
+		return "ipv4"
+	}
+	return "ipv6"
+}
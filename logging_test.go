@@ -0,0 +1,125 @@
+package netjugo
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordHandler keeps every record it is given
+type recordHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler      { return h }
+
+// find returns the attributes of the first record at level with message
+func (h *recordHandler) find(level slog.Level, message string) (map[string]slog.Value, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, r := range h.records {
+		if r.Level != level || r.Message != message {
+			continue
+		}
+		attrs := make(map[string]slog.Value)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value
+			return true
+		})
+		return attrs, true
+	}
+	return nil, false
+}
+
+func TestSetLogger(t *testing.T) {
+	h := &recordHandler{}
+	pa := NewPrefixAggregator()
+	pa.SetLogger(slog.New(h))
+
+	var handled []string
+	pa.SetWarningHandler(func(msg string) {
+		handled = append(handled, msg)
+	})
+
+	input := "10.0.0.0/16\nnot-a-prefix\n2001:db8::/32\n10.0.0.0/40\n"
+	if err := pa.AddFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to read input: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.1.1/32"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	// Warnings still reach the handler and GetWarnings
+	if len(handled) != 1 || len(pa.GetWarnings()) != 1 {
+		t.Fatalf("Expected 1 warning through the handler and GetWarnings, got %d and %d", len(handled), len(pa.GetWarnings()))
+	}
+
+	attrs, ok := h.find(slog.LevelWarn, handled[0])
+	if !ok {
+		t.Fatalf("No Warn record for %q", handled[0])
+	}
+	for key, want := range map[string]string{"code": string(WarnSpecificExclusion), "prefix": "10.0.1.1/32", "family": "ipv4"} {
+		if got := attrs[key].String(); got != want {
+			t.Errorf("Warning attribute %s = %q, want %q", key, got, want)
+		}
+	}
+
+	var skipped []int64
+	h.mu.Lock()
+	for _, r := range h.records {
+		if r.Level == slog.LevelDebug && r.Message == "skipped input line" {
+			r.Attrs(func(a slog.Attr) bool {
+				if a.Key == "line" {
+					skipped = append(skipped, a.Value.Int64())
+				}
+				return true
+			})
+		}
+	}
+	h.mu.Unlock()
+	if len(skipped) != 2 || skipped[0] != 2 || skipped[1] != 4 {
+		t.Errorf("Expected Debug records for lines 2 and 4, got %v", skipped)
+	}
+
+	attrs, ok = h.find(slog.LevelInfo, "aggregation phase complete")
+	if !ok || attrs["phase"].String() != "inclusions" || attrs["duration"].Kind() != slog.KindDuration {
+		t.Errorf("Expected an Info record for the first phase with a duration, got %v", attrs)
+	}
+	attrs, ok = h.find(slog.LevelInfo, "aggregation complete")
+	if !ok || attrs["ipv4_prefixes"].Int64() == 0 || attrs["ipv6_prefixes"].Int64() != 1 {
+		t.Errorf("Expected an Info record with result sizes, got %v", attrs)
+	}
+}
+
+func TestSetLoggerFiles(t *testing.T) {
+	h := &recordHandler{}
+	pa := NewPrefixAggregator()
+	pa.SetLogger(slog.New(h))
+
+	paths := writeSplitFiles(t, []string{"10.0.0.0/24", "bogus"}, 1)
+	if err := pa.AddFromFiles(paths, 0); err != nil {
+		t.Fatalf("Failed to add files: %v", err)
+	}
+
+	attrs, ok := h.find(slog.LevelDebug, "skipped input line")
+	if !ok || attrs["file"].String() != paths[0] || attrs["line"].Int64() != 2 {
+		t.Errorf("Expected a Debug record naming the file and line, got %v", attrs)
+	}
+}
@@ -0,0 +1,366 @@
+package netjugo
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/holiman/uint256"
+)
+
+func TestMemoryEfficiency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping memory efficiency test in short mode")
+	}
+
+	pa := NewPrefixAggregator()
+
+	// Force garbage collection to get clean baseline
+	runtime.GC()
+	runtime.GC()
+
+	var m1 runtime.MemStats
+	runtime.ReadMemStats(&m1)
+	baseline := m1.Alloc
+
+	// Add a known number of prefixes
+	testPrefixes := []string{
+		"192.168.0.0/16",
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"2001:db8::/32",
+		"2001:0db8:0001::/48",
+	}
+
+	for _, prefix := range testPrefixes {
+		err := pa.AddPrefix(prefix)
+		if err != nil {
+			t.Fatalf("Failed to add prefix %s: %v", prefix, err)
+		}
+	}
+
+	err := pa.Aggregate()
+	if err != nil {
+		t.Fatalf("Aggregation failed: %v", err)
+	}
+
+	memStats := pa.GetMemoryStats()
+	calculatedMemory := pa.calculateMemoryUsage().total()
+
+	runtime.GC()
+	runtime.GC()
+
+	var m2 runtime.MemStats
+	runtime.ReadMemStats(&m2)
+	actualUsed := m2.Alloc - baseline
+
+	t.Logf("Memory efficiency test:")
+	t.Logf("  Prefixes: %d", len(testPrefixes))
+	t.Logf("  Calculated aggregator memory: %d bytes", calculatedMemory)
+	t.Logf("  Runtime stats aggregator memory: %d bytes", memStats.AggregatorBytes)
+	t.Logf("  Actual memory increase: %d bytes", actualUsed)
+	t.Logf("  System allocation: %d bytes", memStats.AllocBytes)
+
+	// Verify the calculated memory is reasonable
+	if calculatedMemory <= 0 {
+		t.Error("Calculated memory usage should be positive")
+	}
+
+	// Memory should be efficient for small datasets
+	expectedMaxMemory := int64(10 * 1024) // 10KB should be plenty for 5 prefixes
+	if calculatedMemory > expectedMaxMemory {
+		t.Errorf("Memory usage %d bytes seems excessive for %d prefixes", calculatedMemory, len(testPrefixes))
+	}
+}
+
+func TestPoolStatistics(t *testing.T) {
+	before := NewPrefixAggregator().GetMemoryStats()
+
+	p := acquireIPPrefix()
+	releaseIPPrefix(p)
+
+	after := NewPrefixAggregator().GetMemoryStats()
+	if after.PoolGets-before.PoolGets < 1 {
+		t.Errorf("Expected PoolGets to increase, before=%d after=%d", before.PoolGets, after.PoolGets)
+	}
+	if after.PoolPuts-before.PoolPuts < 1 {
+		t.Errorf("Expected PoolPuts to increase, before=%d after=%d", before.PoolPuts, after.PoolPuts)
+	}
+	if after.PoolMisses > after.PoolGets {
+		t.Errorf("PoolMisses (%d) cannot exceed PoolGets (%d)", after.PoolMisses, after.PoolGets)
+	}
+}
+
+func TestDisablePooling(t *testing.T) {
+	SetPooling(false)
+	defer SetPooling(true)
+
+	before := NewPrefixAggregator().GetMemoryStats()
+
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.1.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	after := pa.GetMemoryStats()
+	if after.PoolGets != before.PoolGets || after.PoolPuts != before.PoolPuts {
+		t.Errorf("Pool was used while disabled: gets %d->%d, puts %d->%d",
+			before.PoolGets, after.PoolGets, before.PoolPuts, after.PoolPuts)
+	}
+	if result := pa.GetPrefixes(); len(result) != 1 || result[0] != "10.0.0.0/23" {
+		t.Errorf("Expected [10.0.0.0/23], got %v", result)
+	}
+}
+
+func TestCompactReducesMemory(t *testing.T) {
+	pa := NewPrefixAggregator()
+
+	// Fill and merge 4096 adjacent /24s down to a single /12
+	for i := 0; i < 4096; i++ {
+		if err := pa.AddPrefix(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+	}
+	excludes := make([]string, 0, 64)
+	for i := 0; i < 64; i++ {
+		excludes = append(excludes, fmt.Sprintf("172.16.%d.0/24", i))
+	}
+	if err := pa.SetExcludePrefixes(excludes); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	before := pa.GetMemoryStats().AggregatorBytes
+	resultBefore := pa.GetPrefixes()
+
+	pa.Compact(CompactOptions{ReleaseConstraints: true, ClearPool: true})
+
+	after := pa.GetMemoryStats().AggregatorBytes
+	if after >= before {
+		t.Errorf("Expected Compact to reduce memory, before=%d after=%d", before, after)
+	}
+	if cap(pa.IPv4Prefixes) != len(pa.IPv4Prefixes) {
+		t.Errorf("Expected exact capacity %d, got %d", len(pa.IPv4Prefixes), cap(pa.IPv4Prefixes))
+	}
+	if len(pa.ExcludeIPv4) != 0 {
+		t.Errorf("Expected exclusions to be released, got %d", len(pa.ExcludeIPv4))
+	}
+
+	resultAfter := pa.GetPrefixes()
+	if fmt.Sprint(resultBefore) != fmt.Sprint(resultAfter) {
+		t.Errorf("Compact changed the result: %v -> %v", resultBefore, resultAfter)
+	}
+	t.Logf("AggregatorBytes before=%d after=%d", before, after)
+}
+
+func TestMemoryStatsBreakdown(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.1.0/24", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetIncludePrefixes([]string{"192.0.2.0/24"}); err != nil {
+		t.Fatalf("Failed to set include prefixes: %v", err)
+	}
+	excludes := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		excludes = append(excludes, fmt.Sprintf("172.%d.%d.0/24", 16+i/256, i%256))
+	}
+	if err := pa.SetExcludePrefixes(excludes); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.AddExclusionSet("extra", []string{"2001:db8:1::/48"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	// Header, pointer array and one IPPrefix with its two uint256s each
+	perPrefix := int64(unsafe.Sizeof(IPPrefix{}) + 2*unsafe.Sizeof(uint256.Int{}))
+	slice := func(list []*IPPrefix) int64 {
+		if len(list) == 0 {
+			return 0
+		}
+		return int64(unsafe.Sizeof(list)) + int64(cap(list))*int64(unsafe.Sizeof(list[0])) + int64(len(list))*perPrefix
+	}
+	set := pa.exclusionSets[0]
+	want := MemoryStats{
+		ResultBytes:  slice(pa.IPv4Prefixes) + slice(pa.IPv6Prefixes),
+		IncludeBytes: slice(pa.IncludeIPv4),
+		ExcludeBytes: slice(pa.ExcludeIPv4) + slice(set.ipv6),
+		OtherBytes:   int64(unsafe.Sizeof(*pa)),
+	}
+	want.AggregatorBytes = want.ResultBytes + want.IncludeBytes + want.ExcludeBytes + want.OtherBytes
+
+	got := pa.GetMemoryStats()
+	if got.ResultBytes != want.ResultBytes || got.IncludeBytes != want.IncludeBytes ||
+		got.ExcludeBytes != want.ExcludeBytes || got.OtherBytes != want.OtherBytes || got.AggregatorBytes != want.AggregatorBytes {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if got.ExcludeBytes < 500*perPrefix {
+		t.Errorf("Expected the 500 exclusions to dominate ExcludeBytes, got %d", got.ExcludeBytes)
+	}
+	if stats := pa.GetStats(); stats.MemoryUsageBytes != got.AggregatorBytes {
+		t.Errorf("Expected MemoryUsageBytes %d to match AggregatorBytes %d", stats.MemoryUsageBytes, got.AggregatorBytes)
+	}
+
+	// A change invalidates the cached breakdown
+	if err := pa.AddPrefix("198.51.100.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if after := pa.GetMemoryStats(); after.ResultBytes <= got.ResultBytes || after.ExcludeBytes != got.ExcludeBytes {
+		t.Errorf("Expected only ResultBytes to grow, got %+v then %+v", got, after)
+	}
+}
+
+func TestCompactKeepsConstraintsByDefault(t *testing.T) {
+	pa := NewPrefixAggregator()
+
+	if err := pa.AddPrefix("192.168.0.0/16"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"192.168.1.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	pa.Compact(CompactOptions{})
+
+	if len(pa.ExcludeIPv4) != 1 {
+		t.Errorf("Expected exclusions to be kept, got %d", len(pa.ExcludeIPv4))
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 2*memoryCheckInterval; i++ {
+		fmt.Fprintf(&input, "10.%d.%d.0/24\n", i/256, i%256)
+	}
+
+	pa := NewPrefixAggregator()
+	if err := pa.SetMemoryBudget(1); err != nil {
+		t.Fatalf("Failed to set memory budget: %v", err)
+	}
+
+	// The first check happens on the 10,000th add
+	result, err := pa.AddFromReaderCount(strings.NewReader(input.String()))
+	if !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Fatalf("Expected ErrMemoryBudgetExceeded, got %v", err)
+	}
+	var budgetErr *MemoryBudgetError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("Expected *MemoryBudgetError, got %T", err)
+	}
+	if budgetErr.Prefixes != memoryCheckInterval-1 || result.Added != memoryCheckInterval-1 {
+		t.Errorf("Expected %d prefixes at the failed check, got %d (added %d)", memoryCheckInterval-1, budgetErr.Prefixes, result.Added)
+	}
+	if budgetErr.Budget != 1 || budgetErr.Usage <= 1 {
+		t.Errorf("Unexpected budget error fields: %+v", budgetErr)
+	}
+
+	// Once crossed, every further add fails
+	if err := pa.AddPrefix("192.168.0.0/24"); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Errorf("Expected later adds to fail, got %v", err)
+	}
+
+	generous := NewPrefixAggregator()
+	if err := generous.SetMemoryBudget(1 << 30); err != nil {
+		t.Fatalf("Failed to set memory budget: %v", err)
+	}
+	if err := generous.AddFromReader(strings.NewReader(input.String())); err != nil {
+		t.Errorf("Expected a generous budget to pass, got %v", err)
+	}
+
+	if err := pa.SetMemoryBudget(-1); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a negative budget, got %v", err)
+	}
+}
+
+func TestCloseReleasesPrefixes(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.2.0/24", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetIncludePrefixes([]string{"192.0.2.0/24"}); err != nil {
+		t.Fatalf("Failed to set include prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.2.0/25"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.AddExclusionSet("lab", []string{"2001:db8:1::/48"}); err != nil {
+		t.Fatalf("Failed to add exclusion set: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	held := len(pa.IPv4Prefixes) + len(pa.IPv6Prefixes) + len(pa.IncludeIPv4) + len(pa.ExcludeIPv4) + 1
+	before := pa.GetMemoryStats().PoolPuts
+	if err := pa.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if puts := NewPrefixAggregator().GetMemoryStats().PoolPuts - before; puts < int64(held) {
+		t.Errorf("Expected Close to return at least %d prefixes to the pool, got %d", held, puts)
+	}
+	if pa.IPv4Prefixes != nil || pa.IPv6Prefixes != nil || pa.IncludeIPv4 != nil || pa.ExcludeIPv4 != nil {
+		t.Error("Expected Close to drop the prefix lists")
+	}
+}
+
+func TestUseAfterClose(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefix("10.0.0.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	warnings := pa.WarningsChan(1)
+	if err := pa.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if err := pa.Close(); err != nil {
+		t.Errorf("Expected a second Close to succeed, got %v", err)
+	}
+	if _, ok := <-warnings; ok {
+		t.Error("Expected Close to close the warnings channel")
+	}
+
+	calls := map[string]error{
+		"AddPrefix":          pa.AddPrefix("10.0.1.0/24"),
+		"AddPrefixes":        pa.AddPrefixes([]string{"10.0.1.0/24", "10.0.2.0/24"}),
+		"AddFromReader":      pa.AddFromReader(strings.NewReader("10.0.1.0/24\n")),
+		"SetExcludePrefixes": pa.SetExcludePrefixes([]string{"10.0.0.0/25"}),
+		"AddExclusionSet":    pa.AddExclusionSet("lab", []string{"10.0.0.0/25"}),
+		"Aggregate":          pa.Aggregate(),
+		"Reset":              pa.Reset(),
+		"WriteToWriter":      pa.WriteToWriter(&strings.Builder{}),
+	}
+	_, calls["CoversAll"] = pa.CoversAll([]string{"10.0.0.0/24"})
+	for name, err := range calls {
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("%s after Close: expected ErrClosed, got %v", name, err)
+		}
+	}
+
+	if prefixes := pa.GetPrefixes(); len(prefixes) != 0 {
+		t.Errorf("Expected no prefixes after Close, got %v", prefixes)
+	}
+	if _, ok := <-pa.WarningsChan(1); ok {
+		t.Error("Expected WarningsChan after Close to return a closed channel")
+	}
+	if c := pa.Clone(); !errors.Is(c.Aggregate(), ErrClosed) {
+		t.Error("Expected a clone of a closed aggregator to be closed")
+	}
+	pa.Compact(CompactOptions{ReleaseConstraints: true})
+	if pa.IPv4Prefixes != nil || pa.IncludeIPv4 != nil || pa.ExcludeIPv4 != nil {
+		t.Error("Expected Compact after Close to leave the dropped lists alone")
+	}
+}
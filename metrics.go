@@ -0,0 +1,49 @@
+package netjugo
+
+import "log/slog"
+
+// Metrics receives counters and observations for instrumentation, such
+// as Prometheus collectors, without the library depending on a metrics
+// package. Implementations must be safe for concurrent use: AddFromFiles
+// reports skipped lines from its workers. Methods other than those of the
+// loaders are called with the aggregator locked and must not call back
+// into it. Embed NopMetrics to implement only some of the methods.
+type Metrics interface {
+	// AddPrefixesLoaded is called once per AddFromReader, AddFromFile or
+	// AddFromFiles call with the number of prefixes it added
+	AddPrefixesLoaded(n int)
+	// IncSkippedLine is called for every input line that was skipped
+	IncSkippedLine()
+	// IncWarning is called for every warning recorded by Aggregate
+	IncWarning(code WarningCode)
+	// ObserveAggregation is called after every successful Aggregate that
+	// did work, with the resulting statistics
+	ObserveAggregation(stats AggregationStats)
+}
+
+// NopMetrics is a Metrics that does nothing. It is the default.
+type NopMetrics struct{}
+
+func (NopMetrics) AddPrefixesLoaded(int)               {}
+func (NopMetrics) IncSkippedLine()                     {}
+func (NopMetrics) IncWarning(WarningCode)              {}
+func (NopMetrics) ObserveAggregation(AggregationStats) {}
+
+// SetMetrics sets the instrumentation hooks; nil restores NopMetrics
+func (pa *PrefixAggregator) SetMetrics(m Metrics) {
+	if m == nil {
+		m = NopMetrics{}
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.metrics = m
+}
+
+// observers returns the logger and metrics for code running without the
+// lock
+func (pa *PrefixAggregator) observers() (*slog.Logger, Metrics) {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+	return pa.logger, pa.metrics
+}
@@ -0,0 +1,122 @@
+package netjugo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingMetrics counts every call it is given
+type countingMetrics struct {
+	mu           sync.Mutex
+	loaded       int
+	loads        int
+	skipped      int
+	warnings     map[WarningCode]int
+	aggregations []AggregationStats
+}
+
+func (m *countingMetrics) AddPrefixesLoaded(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loaded += n
+	m.loads++
+}
+
+func (m *countingMetrics) IncSkippedLine() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.skipped++
+}
+
+func (m *countingMetrics) IncWarning(code WarningCode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.warnings == nil {
+		m.warnings = make(map[WarningCode]int)
+	}
+	m.warnings[code]++
+}
+
+func (m *countingMetrics) ObserveAggregation(stats AggregationStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aggregations = append(m.aggregations, stats)
+}
+
+func TestSetMetrics(t *testing.T) {
+	m := &countingMetrics{}
+	pa := NewPrefixAggregator()
+	pa.SetMetrics(m)
+
+	input := "10.0.0.0/24\n10.0.1.0/24\nnot-a-prefix\n2001:db8::/32\n10.0.0.0/40\n"
+	if err := pa.AddFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to read input: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.0.0/31"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	// Nothing changed, so no second observation
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	if m.loads != 1 || m.loaded != 3 {
+		t.Errorf("Expected one load of 3 prefixes, got %d loads of %d", m.loads, m.loaded)
+	}
+	if m.skipped != 2 {
+		t.Errorf("Expected 2 skipped lines, got %d", m.skipped)
+	}
+	if m.warnings[WarnSpecificExclusion] != 1 {
+		t.Errorf("Expected one WarnSpecificExclusion, got %v", m.warnings)
+	}
+	if len(m.aggregations) != 1 {
+		t.Fatalf("Expected 1 aggregation, got %d", len(m.aggregations))
+	}
+	if got, want := m.aggregations[0], pa.GetStats(); got.TotalPrefixes != want.TotalPrefixes || got.IPv4PrefixCount != want.IPv4PrefixCount {
+		t.Errorf("Observed stats %+v, want %+v", got, want)
+	}
+
+	// nil restores the no-op default
+	pa.SetMetrics(nil)
+	if err := pa.AddPrefix("192.168.0.0/16"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if len(m.aggregations) != 1 {
+		t.Errorf("Expected no observations after SetMetrics(nil), got %d", len(m.aggregations))
+	}
+}
+
+func TestMetricsAddFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 3)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, "feed"+string(rune('a'+i))+".txt")
+		content := "10.0." + string(rune('0'+i)) + ".0/24\nbogus\n"
+		if err := os.WriteFile(paths[i], []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", paths[i], err)
+		}
+	}
+
+	m := &countingMetrics{}
+	pa := NewPrefixAggregator()
+	pa.SetMetrics(m)
+	if err := pa.AddFromFiles(paths, 2); err != nil {
+		t.Fatalf("Failed to read files: %v", err)
+	}
+
+	if m.loads != 1 || m.loaded != 3 {
+		t.Errorf("Expected one load of 3 prefixes, got %d loads of %d", m.loads, m.loaded)
+	}
+	if m.skipped != 3 {
+		t.Errorf("Expected 3 skipped lines, got %d", m.skipped)
+	}
+}
@@ -0,0 +1,170 @@
+package netjugo
+
+import (
+	"math"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// inputRecord is one added prefix as written, kept in minimal change mode
+type inputRecord struct {
+	prefix netip.Prefix // masked
+	text   string
+}
+
+// SetMinimalChange makes GetPrefixes and the writers change the input
+// only where aggregation did, for reviewing the result as a diff. A
+// result prefix identical to an input prefix keeps the text it was added
+// with, and every result is listed at the position of the first input it
+// covers or was split from; results that only come from includes follow
+// at the end. The output order is ignored, and the IPv6 format only
+// applies to rewritten prefixes.
+//
+// The input text is recorded from now on, so enable it before adding
+// prefixes. Turning it off drops the record.
+func (pa *PrefixAggregator) SetMinimalChange(enabled bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	pa.minimalChange = enabled
+	if !enabled {
+		pa.inputs = nil
+	}
+}
+
+// recordInput keeps the text of an added prefix in minimal change mode
+func (pa *PrefixAggregator) recordInput(prefix netip.Prefix, text string) {
+	if pa.minimalChange {
+		pa.inputs = append(pa.inputs, inputRecord{prefix: prefix.Masked(), text: strings.TrimSpace(text)})
+	}
+}
+
+// resultView is the result as it is read: both families in output order
+// and, in minimal change mode, the input text of untouched prefixes
+type resultView struct {
+	lists [2][]*IPPrefix
+	text  map[*IPPrefix]string
+}
+
+func (pa *PrefixAggregator) resultView() resultView {
+	if !pa.minimalChange || len(pa.inputs) == 0 {
+		return resultView{lists: [2][]*IPPrefix{pa.orderedPrefixes(pa.IPv4Prefixes), pa.orderedPrefixes(pa.IPv6Prefixes)}}
+	}
+	return pa.minimalView()
+}
+
+func (pa *PrefixAggregator) appendViewString(dst []string, view resultView, p *IPPrefix) []string {
+	if text, ok := view.text[p]; ok {
+		return append(dst, text)
+	}
+	return append(dst, pa.formatPrefix(p.Prefix))
+}
+
+func (pa *PrefixAggregator) appendViewBytes(dst []byte, view resultView, p *IPPrefix) []byte {
+	if text, ok := view.text[p]; ok {
+		return append(dst, text...)
+	}
+	return pa.appendFormattedPrefix(dst, p.Prefix)
+}
+
+// minimalView places each result prefix at the input position it
+// derives from and attaches the input text to those it left untouched
+func (pa *PrefixAggregator) minimalView() resultView {
+	first := make(map[netip.Prefix]int, len(pa.inputs))
+	for i := len(pa.inputs) - 1; i >= 0; i-- {
+		first[pa.inputs[i].prefix] = i
+	}
+
+	type placed struct {
+		p   *IPPrefix
+		pos int
+	}
+	var all []placed
+	view := resultView{text: make(map[*IPPrefix]string)}
+	for _, results := range [][]*IPPrefix{pa.IPv4Prefixes, pa.IPv6Prefixes} {
+		sorted := append([]*IPPrefix(nil), results...)
+		sortPrefixes(sorted)
+
+		positions := pa.inputPositions(sorted)
+		for i, p := range sorted {
+			pos := positions[i]
+			if j, ok := first[p.Prefix.Masked()]; ok {
+				pos = j
+				view.text[p] = pa.inputs[j].text
+			}
+			all = append(all, placed{p, pos})
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].pos < all[j].pos
+	})
+	ordered := make([]*IPPrefix, len(all))
+	for i, e := range all {
+		ordered[i] = e.p
+	}
+	view.lists[0] = ordered
+	return view
+}
+
+// inputPositions returns, for each of the sorted result prefixes of one
+// family, the position of the earliest input prefix overlapping it, or
+// math.MaxInt if there is none. Inputs are swept in address order with a
+// stack of the nested inputs that are still open.
+func (pa *PrefixAggregator) inputPositions(results []*IPPrefix) []int {
+	positions := make([]int, len(results))
+	if len(results) == 0 {
+		return positions
+	}
+
+	isIPv4 := results[0].Prefix.Addr().Is4()
+	var order []int
+	for i, in := range pa.inputs {
+		if in.prefix.Addr().Is4() == isIPv4 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ComparePrefixes(pa.inputs[order[a]].prefix, pa.inputs[order[b]].prefix) < 0
+	})
+
+	type open struct {
+		prefix netip.Prefix
+		// earliest is the first position on the stack up to here
+		earliest int
+	}
+	var stack []open
+	j := 0
+	for i, p := range results {
+		r := p.Prefix.Masked()
+		best := math.MaxInt
+		for ; j < len(order); j++ {
+			in := pa.inputs[order[j]].prefix
+			if !in.Addr().Less(r.Addr()) && !r.Contains(in.Addr()) {
+				break
+			}
+			for len(stack) > 0 && !stack[len(stack)-1].prefix.Overlaps(in) {
+				stack = stack[:len(stack)-1]
+			}
+			earliest := order[j]
+			if len(stack) > 0 {
+				earliest = min(earliest, stack[len(stack)-1].earliest)
+			}
+			stack = append(stack, open{in, earliest})
+			if in.Overlaps(r) {
+				best = min(best, order[j])
+			}
+		}
+		// An open input that overlaps r is nested in those below it, so
+		// they all overlap r
+		for len(stack) > 0 && !stack[len(stack)-1].prefix.Overlaps(r) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			best = min(best, stack[len(stack)-1].earliest)
+		}
+		positions[i] = best
+	}
+	return positions
+}
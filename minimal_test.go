@@ -0,0 +1,103 @@
+package netjugo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// minimalChangeInput is unsorted, mixes families and spellings, and has a
+// single pair of /24s that merges
+const minimalChangeInput = `192.0.2.1
+2001:DB8:0:1::/64
+10.20.0.0/16
+198.51.100.0/24
+198.51.101.0/24
+2001:db8::1
+172.16.5.0/24
+10.1.0.0/16
+`
+
+func TestMinimalChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(path, []byte(minimalChangeInput), 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	run := func(minimal bool, loaders ...func(*PrefixAggregator) error) string {
+		pa := NewPrefixAggregator()
+		pa.SetMinimalChange(minimal)
+		for _, load := range loaders {
+			if err := load(pa); err != nil {
+				t.Fatalf("Failed to load input: %v", err)
+			}
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := pa.WriteToWriter(&buf); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		if got := strings.Join(pa.GetPrefixes(), "\n") + "\n"; got != buf.String() {
+			t.Errorf("GetPrefixes and WriteToWriter disagree:\n%s\n%s", got, buf.String())
+		}
+		return buf.String()
+	}
+	fromFile := func(pa *PrefixAggregator) error { return pa.AddFromFile(path) }
+
+	output := run(true, fromFile)
+	want := "192.0.2.1\n2001:DB8:0:1::/64\n10.20.0.0/16\n198.51.100.0/23\n2001:db8::1\n172.16.5.0/24\n10.1.0.0/16\n"
+	if output != want {
+		t.Errorf("Unexpected minimal change output:\n%s", output)
+	}
+	if n := changedLines(minimalChangeInput, output); n != 3 {
+		t.Errorf("Diff touches %d lines, want 3", n)
+	}
+	if n := changedLines(minimalChangeInput, run(false, fromFile)); n <= 3 {
+		t.Errorf("Expected the canonical output to change more lines, diff touches %d", n)
+	}
+
+	// The parallel loader keeps the text too
+	parallel := func(pa *PrefixAggregator) error { return pa.AddFromFiles([]string{path}, 2) }
+	if got := run(true, parallel); got != want {
+		t.Errorf("Unexpected output from AddFromFiles:\n%s", got)
+	}
+
+	// Pieces of a split input keep its place, includes go last
+	constrained := run(true, fromFile, func(pa *PrefixAggregator) error {
+		if err := pa.SetIncludePrefixes([]string{"203.0.113.0/24"}); err != nil {
+			return err
+		}
+		return pa.SetExcludePrefixes([]string{"10.20.0.0/17"})
+	})
+	want = "192.0.2.1\n2001:DB8:0:1::/64\n10.20.128.0/17\n198.51.100.0/23\n2001:db8::1\n172.16.5.0/24\n10.1.0.0/16\n203.0.113.0/24\n"
+	if constrained != want {
+		t.Errorf("Unexpected output with constraints:\n%s", constrained)
+	}
+}
+
+// changedLines returns the number of lines a minimal line diff from a to
+// b removes or adds
+func changedLines(a, b string) int {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// Longest common subsequence, one row at a time
+	prev := make([]int, len(y)+1)
+	for i := range x {
+		cur := make([]int, len(y)+1)
+		for j := range y {
+			if x[i] == y[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(cur[j], prev[j+1])
+			}
+		}
+		prev = cur
+	}
+	return len(x) + len(y) - 2*slices.Max(prev)
+}
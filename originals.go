@@ -0,0 +1,114 @@
+package netjugo
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// SetRetainOriginals makes the aggregator keep a compact copy of every
+// prefix added from now on, so the input survives Aggregate. Turning it
+// off drops the copy. Enable it before adding prefixes: if some were
+// added without it, the copy is incomplete and cannot be rebuilt from
+// until Reset.
+func (pa *PrefixAggregator) SetRetainOriginals(retain bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if retain && !pa.retainOriginals && len(pa.IPv4Prefixes)+len(pa.IPv6Prefixes) > 0 {
+		pa.originalsIncomplete = true
+	}
+	pa.retainOriginals = retain
+	if !retain {
+		pa.originals = nil
+		pa.lastStats = nil
+	}
+}
+
+// GetOriginalPrefixes returns the retained input prefixes in the order
+// they were added
+func (pa *PrefixAggregator) GetOriginalPrefixes() []string {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	result := make([]string, len(pa.originals))
+	for i, p := range pa.originals {
+		result[i] = pa.formatPrefix(p)
+	}
+	return result
+}
+
+// Reaggregate rebuilds the working set from the retained input and
+// aggregates it again under the current settings and constraints, even if
+// nothing changed. It fails with ErrOriginalsNotRetained unless
+// SetRetainOriginals(true) was called before the input was added.
+func (pa *PrefixAggregator) Reaggregate() error {
+	start := time.Now()
+
+	pa.mu.run.Lock()
+	defer pa.mu.run.Unlock()
+
+	return pa.aggregateCopy(start, (*PrefixAggregator).restoreOriginals)
+}
+
+// reconfigure records a change to a setting that shapes the result. Before
+// the first Aggregate it only marks the aggregator dirty; afterwards the
+// input has been merged, so the next Aggregate has to rebuild it from the
+// originals.
+func (pa *PrefixAggregator) reconfigure() {
+	pa.dirty = true
+	if pa.aggregated {
+		pa.reconfigured = true
+	}
+}
+
+// restoreOriginals replaces the working lists with fresh copies of the
+// retained input
+func (pa *PrefixAggregator) restoreOriginals() error {
+	if !pa.retainOriginals || pa.originalsIncomplete {
+		return fmt.Errorf("%w: enable SetRetainOriginals before adding prefixes, or Reset and reload them", ErrOriginalsNotRetained)
+	}
+
+	ipv4, ipv6, err := rebuildOriginals(pa.originals)
+	if err != nil {
+		return err
+	}
+
+	for _, list := range [][]*IPPrefix{pa.IPv4Prefixes, pa.IPv6Prefixes} {
+		for _, p := range list {
+			releaseIPPrefix(p)
+		}
+	}
+	pa.IPv4Prefixes, pa.IPv6Prefixes = ipv4, ipv6
+	pa.changes++
+	pa.sortedIPv4, pa.sortedIPv6 = 0, 0
+	pa.aggregated, pa.reconfigured = false, false
+	pa.exclusionCauses = nil
+	pa.appliedExclusions = nil
+	pa.dirty = true
+	return nil
+}
+
+// rebuildOriginals converts the retained prefixes back into pooled
+// working lists
+func rebuildOriginals(originals []netip.Prefix) (ipv4, ipv6 []*IPPrefix, err error) {
+	ipv4 = make([]*IPPrefix, 0)
+	ipv6 = make([]*IPPrefix, 0)
+	for _, prefix := range originals {
+		p, err := ipPrefixFrom(prefix)
+		if err != nil {
+			for _, list := range [][]*IPPrefix{ipv4, ipv6} {
+				for _, q := range list {
+					releaseIPPrefix(q)
+				}
+			}
+			return nil, nil, err
+		}
+		if prefix.Addr().Is4() {
+			ipv4 = append(ipv4, p)
+		} else {
+			ipv6 = append(ipv6, p)
+		}
+	}
+	return ipv4, ipv6, nil
+}
@@ -0,0 +1,331 @@
+package netjugo
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReaggregate(t *testing.T) {
+	feed := generateTestPrefixes(2000)
+	excludes := []string{"10.0.0.0/8", "2001:db8::/32"}
+
+	pa := NewPrefixAggregator()
+	pa.SetRetainOriginals(true)
+	if err := pa.AddPrefixes(feed); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes(excludes); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	for _, lengths := range [][2]int{{16, 32}, {24, 48}, {0, 0}, {8, 16}} {
+		if err := pa.SetMinPrefixLength(lengths[0], lengths[1]); err != nil {
+			t.Fatalf("Failed to set minimum lengths: %v", err)
+		}
+		if err := pa.Reaggregate(); err != nil {
+			t.Fatalf("Reaggregate failed: %v", err)
+		}
+
+		fresh := NewPrefixAggregator()
+		if err := fresh.SetMinPrefixLength(lengths[0], lengths[1]); err != nil {
+			t.Fatalf("Failed to set minimum lengths: %v", err)
+		}
+		if err := fresh.AddPrefixes(feed); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := fresh.SetExcludePrefixes(excludes); err != nil {
+			t.Fatalf("Failed to set exclusions: %v", err)
+		}
+		if err := fresh.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+
+		got, want := pa.GetPrefixes(), fresh.GetPrefixes()
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Min lengths %v: Reaggregate gave %d prefixes, fresh aggregator %d", lengths, len(got), len(want))
+		}
+	}
+
+	if got := pa.GetOriginalPrefixes(); len(got) != len(feed) || got[0] != feed[0] || got[len(got)-1] != feed[len(feed)-1] {
+		t.Errorf("Original prefixes not retained in input order: %d of %d", len(got), len(feed))
+	}
+	if stats := pa.GetStats(); stats.OriginalCount != len(feed) {
+		t.Errorf("OriginalCount = %d after Reaggregate, want %d", stats.OriginalCount, len(feed))
+	}
+
+	if err := pa.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if got := pa.GetOriginalPrefixes(); len(got) != 0 {
+		t.Errorf("Expected Reset to drop originals, got %d", len(got))
+	}
+}
+
+func TestReaggregateRequiresRetainOriginals(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefix("10.0.0.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.Reaggregate(); !errors.Is(err, ErrOriginalsNotRetained) {
+		t.Errorf("Expected ErrOriginalsNotRetained, got %v", err)
+	}
+	if got := pa.GetOriginalPrefixes(); len(got) != 0 {
+		t.Errorf("Expected no originals without SetRetainOriginals, got %v", got)
+	}
+}
+
+func TestReconfigureAfterAggregate(t *testing.T) {
+	build := func(retain bool) *PrefixAggregator {
+		pa := NewPrefixAggregator()
+		pa.SetRetainOriginals(retain)
+		if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.1.0/24", "2001:db8::/48"}); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		if err := pa.SetExcludePrefixes([]string{"10.0.1.0/24"}); err != nil {
+			t.Fatalf("Failed to set exclusions: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		return pa
+	}
+
+	t.Run("retained", func(t *testing.T) {
+		pa := build(true)
+		// Dropping the exclusion gives its space back
+		if err := pa.SetExcludePrefixes(nil); err != nil {
+			t.Fatalf("Failed to clear exclusions: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if got := strings.Join(pa.GetPrefixes(), ","); got != "10.0.0.0/23,2001:db8::/48" {
+			t.Errorf("Unexpected result after clearing exclusions: %s", got)
+		}
+
+		// Lowering the minimum length undoes earlier rounding
+		if err := pa.SetMinPrefixLength(16, 32); err != nil {
+			t.Fatalf("Failed to set minimum lengths: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if err := pa.SetMinPrefixLength(0, 0); err != nil {
+			t.Fatalf("Failed to set minimum lengths: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if got := strings.Join(pa.GetPrefixes(), ","); got != "10.0.0.0/23,2001:db8::/48" {
+			t.Errorf("Unexpected result after restoring minimum lengths: %s", got)
+		}
+	})
+
+	t.Run("not retained", func(t *testing.T) {
+		pa := build(false)
+
+		// Adding input and changing output settings stay incremental
+		if err := pa.AddPrefix("192.168.0.0/24"); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+		if err := pa.SetOutputOrder(OrderPrefixLengthFirst); err != nil {
+			t.Fatalf("Failed to set output order: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Incremental Aggregate failed: %v", err)
+		}
+		want := strings.Join(pa.GetPrefixes(), ",")
+
+		if err := pa.SetExcludePrefixes(nil); err != nil {
+			t.Fatalf("Failed to clear exclusions: %v", err)
+		}
+		if err := pa.Aggregate(); !errors.Is(err, ErrOriginalsNotRetained) {
+			t.Fatalf("Expected ErrOriginalsNotRetained, got %v", err)
+		}
+		if got := strings.Join(pa.GetPrefixes(), ","); got != want {
+			t.Errorf("Failed Aggregate changed the result: %s, want %s", got, want)
+		}
+	})
+
+	t.Run("retained too late", func(t *testing.T) {
+		pa := build(false)
+		pa.SetRetainOriginals(true)
+		if err := pa.SetMinPrefixLength(8, 16); err != nil {
+			t.Fatalf("Failed to set minimum lengths: %v", err)
+		}
+		if err := pa.Aggregate(); !errors.Is(err, ErrOriginalsNotRetained) {
+			t.Errorf("Expected ErrOriginalsNotRetained for an incomplete copy, got %v", err)
+		}
+	})
+}
+
+func TestReconfigureWithoutOriginals(t *testing.T) {
+	build := func() *PrefixAggregator {
+		pa := NewPrefixAggregator()
+		if err := pa.SetExcludePrefixes([]string{"10.9.0.0/24"}); err != nil {
+			t.Fatalf("Failed to set exclusions: %v", err)
+		}
+		if err := pa.AddPrefix("10.0.0.0/24"); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		return pa
+	}
+	addAndAggregate := func(t *testing.T, pa *PrefixAggregator, want string) {
+		t.Helper()
+		if err := pa.AddPrefix("10.0.1.0/24"); err != nil {
+			t.Fatalf("Failed to add prefix: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Aggregate after adding failed: %v", err)
+		}
+		if got := strings.Join(pa.GetPrefixes(), ","); got != want {
+			t.Errorf("Got %s, want %s", got, want)
+		}
+	}
+
+	// Setting what is already set changes nothing that needs the originals
+	t.Run("same values", func(t *testing.T) {
+		pa := build()
+		for name, set := range map[string]func() error{
+			"minimum lengths":  func() error { return pa.SetMinPrefixLength(0, 0) },
+			"constraint order": func() error { return pa.SetConstraintOrder(ExcludesWin) },
+			"include rounding": func() error { return pa.SetIncludeRounding(IncludeExpand) },
+			"includes":         func() error { return pa.SetIncludePrefixes(nil) },
+			"exclusions":       func() error { return pa.SetExcludePrefixes([]string{"10.9.0.0/24"}) },
+			"netip exclusions": func() error { return pa.SetExcludeNetipPrefixes([]netip.Prefix{netip.MustParsePrefix("10.9.0.0/24")}) },
+			"added includes":   func() error { return pa.AddIncludeNetipPrefixes(nil) },
+			"restriction":      func() error { return pa.SetRestrictToPrefixes(nil) },
+		} {
+			if err := set(); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if pa.dirty || pa.reconfigured {
+				t.Errorf("%s: same value marked the aggregator dirty %v, reconfigured %v", name, pa.dirty, pa.reconfigured)
+			}
+			if err := pa.Aggregate(); err != nil {
+				t.Fatalf("%s: Aggregate failed: %v", name, err)
+			}
+		}
+		addAndAggregate(t, pa, "10.0.0.0/23")
+	})
+
+	// A real change fails every Aggregate until the input is reloaded, so a
+	// result that ignores the new setting is never returned
+	for name, set := range map[string]func(pa *PrefixAggregator) error{
+		"minimum lengths": func(pa *PrefixAggregator) error { return pa.SetMinPrefixLength(16, 48) },
+		"includes":        func(pa *PrefixAggregator) error { return pa.SetIncludePrefixes([]string{"192.168.0.0/24"}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			pa := build()
+			if err := set(pa); err != nil {
+				t.Fatalf("Failed to change the setting: %v", err)
+			}
+			for i := range 2 {
+				if err := pa.Aggregate(); !errors.Is(err, ErrOriginalsNotRetained) {
+					t.Fatalf("Aggregate %d: expected ErrOriginalsNotRetained, got %v", i+1, err)
+				}
+				if got := strings.Join(pa.GetPrefixes(), ","); got != "10.0.0.0/24" {
+					t.Errorf("Aggregate %d: failed run changed the result to %s", i+1, got)
+				}
+			}
+			if err := pa.AddPrefix("10.0.1.0/24"); err != nil {
+				t.Fatalf("Failed to add prefix: %v", err)
+			}
+			if err := pa.Aggregate(); !errors.Is(err, ErrOriginalsNotRetained) {
+				t.Fatalf("Aggregate after adding: expected ErrOriginalsNotRetained, got %v", err)
+			}
+
+			// Reloading the input clears the failure
+			pa.Reset()
+			if err := set(pa); err != nil {
+				t.Fatalf("Failed to change the setting after Reset: %v", err)
+			}
+			if err := pa.AddPrefix("10.0.0.0/24"); err != nil {
+				t.Fatalf("Failed to add prefix: %v", err)
+			}
+			if err := pa.Aggregate(); err != nil {
+				t.Fatalf("Aggregate after Reset failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestAddAfterAggregate(t *testing.T) {
+	feed := generateTestPrefixes(3000)
+
+	// Cut the first /26 or /56 out of a few feed prefixes
+	var excludes []string
+	for i := 0; i < 300; i += 30 {
+		p := netip.MustParsePrefix(feed[i])
+		bits := 26
+		if p.Addr().Is6() {
+			bits = 56
+		}
+		excludes = append(excludes, netip.PrefixFrom(p.Addr(), bits).String())
+	}
+	includes := []string{"198.18.0.0/15", "2001:db8:ffff::/48", excludes[1]}
+
+	for _, order := range []ConstraintOrder{ExcludesWin, IncludesWin} {
+		for _, minLens := range [][2]int{{0, 0}, {22, 40}} {
+			build := func() *PrefixAggregator {
+				pa := NewPrefixAggregator()
+				if err := pa.SetMinPrefixLength(minLens[0], minLens[1]); err != nil {
+					t.Fatalf("Failed to set minimum lengths: %v", err)
+				}
+				if err := pa.SetConstraintOrder(order); err != nil {
+					t.Fatalf("Failed to set constraint order: %v", err)
+				}
+				if err := pa.SetIncludePrefixes(includes); err != nil {
+					t.Fatalf("Failed to set include prefixes: %v", err)
+				}
+				if err := pa.SetExcludePrefixes(excludes); err != nil {
+					t.Fatalf("Failed to set exclude prefixes: %v", err)
+				}
+				return pa
+			}
+
+			single := build()
+			if err := single.AddPrefixes(feed); err != nil {
+				t.Fatalf("Failed to add prefixes: %v", err)
+			}
+			if err := single.Aggregate(); err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+
+			// Add, Aggregate, Add, Aggregate, ... over the same input
+			incremental := build()
+			for _, batch := range [][]string{feed[:1000], feed[1000:1001], feed[1001:2500], feed[2500:]} {
+				if err := incremental.AddPrefixes(batch); err != nil {
+					t.Fatalf("Failed to add prefixes: %v", err)
+				}
+				if err := incremental.Aggregate(); err != nil {
+					t.Fatalf("Failed to aggregate: %v", err)
+				}
+			}
+
+			name := fmt.Sprintf("order %d, minimum lengths %v", order, minLens)
+			if got, want := incremental.GetPrefixes(), single.GetPrefixes(); !slices.Equal(got, want) {
+				t.Errorf("%s: incremental result has %d prefixes, single-shot %d", name, len(got), len(want))
+			}
+			got, want := incremental.GetStats(), single.GetStats()
+			got.ProcessingTimeMs, want.ProcessingTimeMs = 0, 0
+			got.MemoryUsageBytes, want.MemoryUsageBytes = 0, 0
+			if got != want {
+				t.Errorf("%s: stats differ:\n%+v\n%+v", name, got, want)
+			}
+			if got, want := incremental.GetWarnings(), single.GetWarnings(); !slices.Equal(got, want) {
+				t.Errorf("%s: warnings differ:\n%v\n%v", name, got, want)
+			}
+		}
+	}
+}
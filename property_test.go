@@ -0,0 +1,216 @@
+package netjugo
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// universe is a tiny address space for the property test: a base prefix
+// with 16 host bits, small enough to expand every prefix into addresses
+type universe struct {
+	base netip.Prefix
+}
+
+const universeHostBits = 16
+
+func (u universe) randomPrefix(rng *rand.Rand, minBits int) netip.Prefix {
+	bits := u.base.Bits() + minBits + rng.Intn(universeHostBits-minBits+1)
+	return netip.PrefixFrom(u.addr(rng.Intn(1<<universeHostBits)), bits).Masked()
+}
+
+// addressSet is the reference expansion of a set of prefixes in a
+// universe, one flag per address
+type addressSet []bool
+
+func (u universe) newSet() addressSet {
+	return make(addressSet, 1<<universeHostBits)
+}
+
+// span returns the offsets of the first and last address of p in u
+func (u universe) span(t *testing.T, p netip.Prefix) (first, last int) {
+	t.Helper()
+	if !u.base.Contains(p.Addr()) || p.Bits() < u.base.Bits() {
+		t.Fatalf("%s is outside the universe %s", p, u.base)
+	}
+	b := p.Masked().Addr().AsSlice()
+	first = int(b[len(b)-2])<<8 | int(b[len(b)-1])
+	return first, first + 1<<(u.base.Addr().BitLen()-p.Bits()) - 1
+}
+
+func (u universe) fill(t *testing.T, set addressSet, p netip.Prefix, value bool) {
+	t.Helper()
+	first, last := u.span(t, p)
+	for i := first; i <= last; i++ {
+		set[i] = value
+	}
+}
+
+// roundToMin applies the minimum length policy: prefixes at or beyond the
+// minimum length are widened to it
+func roundToMin(p netip.Prefix, minLen int) netip.Prefix {
+	if minLen == 0 || p.Bits() < minLen {
+		return p
+	}
+	rounded, _ := p.Addr().Prefix(minLen)
+	return rounded
+}
+
+type propertyCase struct {
+	inputs, includes, excludes, setExcludes []netip.Prefix
+	minLen                                  int
+	order                                   ConstraintOrder
+}
+
+func (c propertyCase) String() string {
+	return fmt.Sprintf("input %v, include %v, exclude %v, exclusion set %v, min length %d, order %d",
+		c.inputs, c.includes, c.excludes, c.setExcludes, c.minLen, c.order)
+}
+
+// addr returns the address at offset i in u
+func (u universe) addr(i int) netip.Addr {
+	b := u.base.Addr().AsSlice()
+	b[len(b)-2], b[len(b)-1] = byte(i>>8), byte(i)
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+func (u universe) randomCase(rng *rand.Rand) propertyCase {
+	prefixes := func(n, minBits int) []netip.Prefix {
+		list := make([]netip.Prefix, rng.Intn(n+1))
+		for i := range list {
+			list[i] = u.randomPrefix(rng, minBits)
+		}
+		return list
+	}
+
+	c := propertyCase{
+		inputs:   prefixes(12, 2),
+		includes: prefixes(3, 4),
+		excludes: prefixes(5, 4),
+		order:    ExcludesWin,
+	}
+	if rng.Intn(3) == 0 {
+		c.setExcludes = prefixes(3, 6)
+	}
+	if rng.Intn(2) == 0 {
+		c.minLen = u.base.Bits() + 4 + rng.Intn(universeHostBits-3)
+	}
+	if rng.Intn(4) == 0 {
+		c.order = IncludesWin
+	}
+	return c
+}
+
+// expected is the reference result: the rounded input and includes, minus
+// the exclusions, plus the rounded includes again when includes win
+func (u universe) expected(t *testing.T, c propertyCase) addressSet {
+	set := u.newSet()
+	for _, list := range [][]netip.Prefix{c.inputs, c.includes} {
+		for _, p := range list {
+			u.fill(t, set, roundToMin(p, c.minLen), true)
+		}
+	}
+	for _, list := range [][]netip.Prefix{c.excludes, c.setExcludes} {
+		for _, p := range list {
+			u.fill(t, set, p, false)
+		}
+	}
+	if c.order == IncludesWin {
+		for _, p := range c.includes {
+			u.fill(t, set, roundToMin(p, c.minLen), true)
+		}
+	}
+	return set
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	result := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		result[i] = p.String()
+	}
+	return result
+}
+
+func (u universe) aggregate(t *testing.T, c propertyCase) (addressSet, []string, error) {
+	pa := NewPrefixAggregator()
+	minV4, minV6 := 0, 0
+	if u.base.Addr().Is4() {
+		minV4 = c.minLen
+	} else {
+		minV6 = c.minLen
+	}
+	if err := pa.SetMinPrefixLength(minV4, minV6); err != nil {
+		return nil, nil, err
+	}
+	if err := pa.SetConstraintOrder(c.order); err != nil {
+		return nil, nil, err
+	}
+	if err := pa.AddPrefixes(prefixStrings(c.inputs)); err != nil {
+		return nil, nil, err
+	}
+	if err := pa.SetIncludePrefixes(prefixStrings(c.includes)); err != nil {
+		return nil, nil, err
+	}
+	if err := pa.SetExcludePrefixes(prefixStrings(c.excludes)); err != nil {
+		return nil, nil, err
+	}
+	if len(c.setExcludes) > 0 {
+		if err := pa.AddExclusionSet("extra", prefixStrings(c.setExcludes)); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := pa.Aggregate(); err != nil {
+		return nil, nil, err
+	}
+
+	result := pa.GetPrefixes()
+	set := u.newSet()
+	for _, s := range result {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		first, last := u.span(t, p)
+		for i := first; i <= last; i++ {
+			if set[i] {
+				return nil, nil, fmt.Errorf("result prefixes overlap at %s", p)
+			}
+			set[i] = true
+		}
+	}
+	return set, result, nil
+}
+
+// TestAggregationMatchesReference checks, for random cases in tiny
+// universes, that the result covers exactly the addresses the reference
+// expansion of the input and constraints does
+func TestAggregationMatchesReference(t *testing.T) {
+	seeds := 2000
+	if testing.Short() {
+		seeds = 200
+	}
+
+	universes := []universe{
+		{netip.MustParsePrefix("10.0.0.0/16")},
+		{netip.MustParsePrefix("2001:db8::/112")},
+	}
+	for _, u := range universes {
+		t.Run(u.base.String(), func(t *testing.T) {
+			for seed := 0; seed < seeds; seed++ {
+				c := u.randomCase(rand.New(rand.NewSource(int64(seed))))
+				want := u.expected(t, c)
+				got, result, err := u.aggregate(t, c)
+				if err != nil {
+					t.Fatalf("seed %d: %v\ncase: %s", seed, err, c)
+				}
+				for i := range want {
+					if got[i] != want[i] {
+						t.Fatalf("seed %d: %s in result is %v, want %v\ncase: %s\nresult: %v", seed, u.addr(i), got[i], want[i], c, result)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package netjugo
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// aggregatorLock is the lock of a PrefixAggregator. Lock and Unlock take
+// both the run lock and the read-write lock, RLock and RUnlock only the
+// latter. Aggregate holds just the run lock while it works on a working
+// copy, excluding every other writer, and takes the read-write lock only
+// to publish the copy, so readers keep seeing the last complete result
+// until then.
+type aggregatorLock struct {
+	run sync.Mutex
+	rw  sync.RWMutex
+}
+
+func (l *aggregatorLock) Lock() {
+	l.run.Lock()
+	l.rw.Lock()
+}
+
+func (l *aggregatorLock) Unlock() {
+	l.rw.Unlock()
+	l.run.Unlock()
+}
+
+func (l *aggregatorLock) RLock()   { l.rw.RLock() }
+func (l *aggregatorLock) RUnlock() { l.rw.RUnlock() }
+
+// aggregateCopy aggregates a working copy of the aggregator, after
+// prepare if it is not nil, and publishes the copy's result. The caller
+// holds the run lock, so nothing else changes the aggregator meanwhile.
+// When prepare or aggregation fails the copy is dropped and the previous
+// result stays in place; only the warnings of a failed aggregation are
+// kept, as they explain the failure.
+func (pa *PrefixAggregator) aggregateCopy(start time.Time, prepare func(w *PrefixAggregator) error) error {
+	if pa.closed {
+		return ErrClosed
+	}
+	w := pa.workingCopy()
+	if prepare != nil {
+		if err := prepare(w); err != nil {
+			releaseLists(w.IPv4Prefixes, w.IPv6Prefixes)
+			return err
+		}
+	}
+	if err := w.aggregateLocked(start); err != nil {
+		releaseLists(w.IPv4Prefixes, w.IPv6Prefixes)
+		pa.mu.rw.Lock()
+		pa.warnings, pa.warningCounts, pa.totalWarnings = w.warnings, w.warningCounts, w.totalWarnings
+		pa.mu.rw.Unlock()
+		return err
+	}
+	pa.publish(w)
+	return nil
+}
+
+// workingCopy returns a copy of the aggregator for aggregateCopy. The
+// working lists and the state Aggregate rewrites are copied, everything
+// else is shared, as Aggregate only reads it. The caller holds a lock.
+func (pa *PrefixAggregator) workingCopy() *PrefixAggregator {
+	return &PrefixAggregator{
+		IPv4Prefixes:        clonePrefixSlice(pa.IPv4Prefixes),
+		IPv6Prefixes:        clonePrefixSlice(pa.IPv6Prefixes),
+		IncludeIPv4:         pa.IncludeIPv4,
+		IncludeIPv6:         pa.IncludeIPv6,
+		ExcludeIPv4:         pa.ExcludeIPv4,
+		ExcludeIPv6:         pa.ExcludeIPv6,
+		restrictIPv4:        pa.restrictIPv4,
+		restrictIPv6:        pa.restrictIPv6,
+		restricted:          pa.restricted,
+		MinPrefixLenIPv4:    pa.MinPrefixLenIPv4,
+		MinPrefixLenIPv6:    pa.MinPrefixLenIPv6,
+		originalCount:       pa.originalCount,
+		originalIPv4:        pa.originalIPv4,
+		skippedLines:        pa.skippedLines,
+		skippedByReason:     pa.skippedByReason,
+		dedupOnIngest:       pa.dedupOnIngest,
+		ingested:            pa.ingested,
+		duplicatesRemoved:   pa.duplicatesRemoved,
+		skippedExclusions:   pa.skippedExclusions,
+		lastProcessTime:     pa.lastProcessTime,
+		warnings:            append([]Warning(nil), pa.warnings...),
+		maxWarnings:         pa.maxWarnings,
+		totalWarnings:       pa.totalWarnings,
+		warningCounts:       append([]WarningSummary(nil), pa.warningCounts...),
+		warningHandler:      pa.warningHandler,
+		warningChans:        pa.warningChans,
+		warningsOverflow:    pa.warningsOverflow,
+		closed:              pa.closed,
+		logger:              pa.logger,
+		metrics:             pa.metrics,
+		outputOrder:         pa.outputOrder,
+		ipv6Format:          pa.ipv6Format,
+		syntax:              pa.syntax,
+		constraintOrder:     pa.constraintOrder,
+		includeRounding:     pa.includeRounding,
+		exclusionSets:       pa.exclusionSets,
+		rejectDefaultRoute:  pa.rejectDefaultRoute,
+		pointToPoint:        pa.pointToPoint,
+		maxResultPrefixes:   pa.maxResultPrefixes,
+		failOnEmptyResult:   pa.failOnEmptyResult,
+		outputHeader:        pa.outputHeader,
+		now:                 pa.now,
+		validateConstraints: pa.validateConstraints,
+		memoryBudget:        pa.memoryBudget,
+		addsSinceCheck:      pa.addsSinceCheck,
+		retainOriginals:     pa.retainOriginals,
+		originalsIncomplete: pa.originalsIncomplete,
+		originals:           pa.originals,
+		minimalChange:       pa.minimalChange,
+		inputs:              pa.inputs,
+		tags:                pa.tags,
+		aggregated:          pa.aggregated,
+		reconfigured:        pa.reconfigured,
+		trackExclusions:     pa.trackExclusions,
+		exclusionCauses:     cloneExclusionCauses(pa.exclusionCauses),
+		appliedExclusions:   maps.Clone(pa.appliedExclusions),
+		explicitDefaultIPv4: pa.explicitDefaultIPv4,
+		explicitDefaultIPv6: pa.explicitDefaultIPv6,
+		sortedIPv4:          pa.sortedIPv4,
+		sortedIPv6:          pa.sortedIPv6,
+		dirty:               pa.dirty,
+		changes:             pa.changes,
+		lastStats:           pa.lastStats,
+	}
+}
+
+// publish moves the state Aggregate rewrote from the working copy w into
+// the aggregator, under the write lock, and releases the lists it
+// replaces. The caller holds the run lock.
+func (pa *PrefixAggregator) publish(w *PrefixAggregator) {
+	pa.mu.rw.Lock()
+	ipv4, ipv6 := pa.IPv4Prefixes, pa.IPv6Prefixes
+	pa.IPv4Prefixes, pa.IPv6Prefixes = w.IPv4Prefixes, w.IPv6Prefixes
+	pa.sortedIPv4, pa.sortedIPv6 = w.sortedIPv4, w.sortedIPv6
+	pa.aggregated, pa.reconfigured = w.aggregated, w.reconfigured
+	pa.skippedExclusions = w.skippedExclusions
+	pa.lastProcessTime = w.lastProcessTime
+	pa.warnings, pa.warningCounts, pa.totalWarnings = w.warnings, w.warningCounts, w.totalWarnings
+	pa.exclusionCauses, pa.appliedExclusions = w.exclusionCauses, w.appliedExclusions
+	pa.dirty, pa.changes, pa.lastStats = w.dirty, w.changes, w.lastStats
+	pa.mu.rw.Unlock()
+
+	releaseLists(ipv4, ipv6)
+}
+
+// releaseLists returns the prefixes of the lists to the pool
+func releaseLists(lists ...[]*IPPrefix) {
+	for _, list := range lists {
+		for _, p := range list {
+			releaseIPPrefix(p)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package netjugo
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadersDuringAggregate(t *testing.T) {
+	list, err := Generate(GenerateOptions{Count: 100000, Seed: 5, IPv6Ratio: 0.2, IPv4Lengths: []int{20, 24, 28}, OverlapRatio: 0.3})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	pa := NewPrefixAggregator()
+	// A host exclusion warns on every run, which gives the handler below a
+	// point inside Aggregate
+	if err := pa.SetExcludePrefixes([]string{"198.51.100.7/32"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.AddPrefixes(list[:len(list)/2]); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if err := pa.AddPrefixes(list[len(list)/2:]); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	before, beforeStats := pa.GetPrefixes(), pa.GetStats()
+
+	// Readers run from inside Aggregate, where they would deadlock if it
+	// held the read-write lock, and see the result from before it
+	var once sync.Once
+	pa.SetWarningHandler(func(string) {
+		once.Do(func() {
+			done := make(chan struct{})
+			start := time.Now()
+			var got []string
+			var stats AggregationStats
+			go func() {
+				defer close(done)
+				got, stats = pa.GetPrefixes(), pa.GetStats()
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("GetPrefixes and GetStats blocked on the running Aggregate")
+			}
+			if !slices.Equal(got, before) || stats.TotalPrefixes != beforeStats.TotalPrefixes {
+				t.Errorf("Readers during Aggregate saw %d prefixes, want the %d from before", len(got), len(before))
+			}
+			t.Logf("Readers returned in %v during Aggregate", time.Since(start))
+		})
+	})
+
+	// Meanwhile readers in a loop only ever see one complete result or the
+	// other
+	var stop atomic.Bool
+	type reading struct{ stats, prefixes int }
+	seen := make(chan map[reading]bool)
+	go func() {
+		readings := map[reading]bool{}
+		for !stop.Load() {
+			readings[reading{pa.GetStats().TotalPrefixes, -1}] = true
+			readings[reading{-1, len(pa.GetPrefixes())}] = true
+		}
+		seen <- readings
+	}()
+
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	stop.Store(true)
+	allowed := map[reading]bool{
+		{beforeStats.TotalPrefixes, -1}: true, {-1, len(before)}: true,
+		{pa.GetStats().TotalPrefixes, -1}: true, {-1, len(pa.GetPrefixes())}: true,
+	}
+	for r := range <-seen {
+		if !allowed[r] {
+			t.Errorf("A reader saw a partial result: %+v", r)
+		}
+	}
+
+	// The published result is the one aggregating everything at once gives
+	fresh := NewPrefixAggregator()
+	if err := fresh.SetExcludePrefixes([]string{"198.51.100.7/32"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := fresh.AddPrefixes(list); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := fresh.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if !slices.Equal(pa.GetPrefixes(), fresh.GetPrefixes()) {
+		t.Error("Result after the concurrent reads differs from aggregating at once")
+	}
+}
+
+func TestFailedAggregateKeepsResult(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.SetExcludePrefixes([]string{"10.9.0.0/24"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.AddPrefix("10.0.0.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	// Cutting the exclusion out of the /15 needs more prefixes than allowed
+	if err := pa.SetMaxResultPrefixes(3); err != nil {
+		t.Fatalf("Failed to set the result limit: %v", err)
+	}
+	if err := pa.AddPrefix("10.8.0.0/15"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	before, beforeStats := pa.GetPrefixes(), pa.GetStats()
+	if err := pa.Aggregate(); !errors.Is(err, ErrResultTooLarge) {
+		t.Fatalf("Expected ErrResultTooLarge, got %v", err)
+	}
+	if got := pa.GetPrefixes(); !slices.Equal(got, before) {
+		t.Errorf("Failed Aggregate published %v, want %v", got, before)
+	}
+	if stats := pa.GetStats(); stats.TotalPrefixes != beforeStats.TotalPrefixes {
+		t.Errorf("Failed Aggregate changed the stats to %d prefixes, want %d", stats.TotalPrefixes, beforeStats.TotalPrefixes)
+	}
+
+	// The input is still there for a run that succeeds
+	if err := pa.SetMaxResultPrefixes(0); err != nil {
+		t.Fatalf("Failed to clear the result limit: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Aggregate without the limit failed: %v", err)
+	}
+	if got := len(pa.GetPrefixes()); got <= 3 {
+		t.Errorf("Got %d prefixes after the limit was cleared, want more than 3", got)
+	}
+}
@@ -0,0 +1,87 @@
+package netjugo
+
+import "unsafe"
+
+// renderedResult is the result view as the writers and GetPrefixes output
+// it, kept so that repeated reads of an unchanged result skip formatting
+type renderedResult struct {
+	// changes and the output settings it was rendered for
+	changes uint64
+	order   OutputOrder
+	format  IPv6Format
+	minimal bool
+
+	// text holds every line, each ending in '\n', and ends[i] is the
+	// offset just past line i
+	text   string
+	ends   []int
+	counts [2]int // lengths of the two view lists
+}
+
+// rendered returns the current result rendered, rendering it on the first
+// read after the lists or output settings change. Callers hold at least
+// the read lock; renderMu keeps concurrent readers from rendering twice.
+// Holders of the write lock may reset pa.render without renderMu.
+func (pa *PrefixAggregator) rendered() *renderedResult {
+	pa.renderMu.Lock()
+	defer pa.renderMu.Unlock()
+
+	if r := pa.render; r != nil && r.changes == pa.changes && r.order == pa.outputOrder &&
+		r.format == pa.ipv6Format && r.minimal == pa.minimalChange {
+		return r
+	}
+
+	view := pa.resultView()
+	r := &renderedResult{
+		changes: pa.changes,
+		order:   pa.outputOrder,
+		format:  pa.ipv6Format,
+		minimal: pa.minimalChange,
+		counts:  [2]int{len(view.lists[0]), len(view.lists[1])},
+	}
+	n := r.counts[0] + r.counts[1]
+	r.ends = make([]int, 0, n)
+	buf := make([]byte, 0, n*16)
+	for _, list := range view.lists {
+		for _, p := range list {
+			buf = pa.appendViewBytes(buf, view, p)
+			buf = append(buf, '\n')
+			r.ends = append(r.ends, len(buf))
+		}
+	}
+	// buf is never written again, so it can back the string directly
+	r.text = unsafe.String(unsafe.SliceData(buf), len(buf))
+	pa.render = r
+	return r
+}
+
+// len is the number of lines
+func (r *renderedResult) len() int {
+	return len(r.ends)
+}
+
+// line returns line i, newline included
+func (r *renderedResult) line(i int) string {
+	start := 0
+	if i > 0 {
+		start = r.ends[i-1]
+	}
+	return r.text[start:r.ends[i]]
+}
+
+// prefix returns line i without its newline
+func (r *renderedResult) prefix(i int) string {
+	line := r.line(i)
+	return line[:len(line)-1]
+}
+
+// renderedMemory estimates the rendered result
+func (pa *PrefixAggregator) renderedMemory() int64 {
+	pa.renderMu.Lock()
+	defer pa.renderMu.Unlock()
+
+	if pa.render == nil {
+		return 0
+	}
+	return int64(len(pa.render.text)) + int64(cap(pa.render.ends))*8
+}
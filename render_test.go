@@ -0,0 +1,126 @@
+package netjugo
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRenderedResult(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.1.0/24", "192.0.2.0/25", "2001:db8::/48"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	written := func() string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := pa.WriteToWriter(&buf); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		return buf.String()
+	}
+	check := func(step string, want ...string) {
+		t.Helper()
+		if got := pa.GetPrefixes(); !slices.Equal(got, want) {
+			t.Errorf("%s: GetPrefixes() = %v, want %v", step, got, want)
+		}
+		var lines strings.Builder
+		for _, p := range want {
+			lines.WriteString(p + "\n")
+		}
+		if got := written(); got != lines.String() {
+			t.Errorf("%s: WriteToWriter wrote %q", step, got)
+		}
+	}
+
+	check("first read", "10.0.0.0/23", "192.0.2.0/25", "2001:db8::/48")
+	check("second read", "10.0.0.0/23", "192.0.2.0/25", "2001:db8::/48")
+
+	if err := pa.SetIPv6Format(IPv6Expanded); err != nil {
+		t.Fatalf("SetIPv6Format failed: %v", err)
+	}
+	check("format change", "10.0.0.0/23", "192.0.2.0/25", "2001:0db8:0000:0000:0000:0000:0000:0000/48")
+	if err := pa.SetIPv6Format(IPv6Compressed); err != nil {
+		t.Fatalf("SetIPv6Format failed: %v", err)
+	}
+
+	if err := pa.SetOutputOrder(OrderPrefixLengthFirst); err != nil {
+		t.Fatalf("SetOutputOrder failed: %v", err)
+	}
+	check("order change", "192.0.2.0/25", "10.0.0.0/23", "2001:db8::/48")
+	if err := pa.SetOutputOrder(OrderAddress); err != nil {
+		t.Fatalf("SetOutputOrder failed: %v", err)
+	}
+
+	if err := pa.AddPrefix("192.0.2.128/25"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	check("add", "10.0.0.0/23", "192.0.2.0/25", "192.0.2.128/25", "2001:db8::/48")
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	check("aggregate", "10.0.0.0/23", "192.0.2.0/24", "2001:db8::/48")
+
+	if err := pa.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	check("reset")
+}
+
+func TestRenderedResultAllocs(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(generateTestPrefixes(10000)); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if err := pa.WriteToWriter(io.Discard); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	// Only the buffered writer and line counter are allocated once the
+	// result is rendered
+	if allocs := testing.AllocsPerRun(20, func() { _ = pa.WriteToWriter(io.Discard) }); allocs > 3 {
+		t.Errorf("Repeated WriteToWriter allocated %v times", allocs)
+	}
+	if allocs := testing.AllocsPerRun(20, func() { _ = pa.GetPrefixes() }); allocs > 1 {
+		t.Errorf("Repeated GetPrefixes allocated %v times", allocs)
+	}
+}
+
+// BenchmarkWriteToWriterRepeated compares writing a 1M-prefix result that
+// must be rendered with writing it again unchanged
+func BenchmarkWriteToWriterRepeated(b *testing.B) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(generateTestPrefixes(1000000)); err != nil {
+		b.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	b.Run("Render", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pa.mu.Lock()
+			pa.render = nil
+			pa.mu.Unlock()
+			if err := pa.WriteToWriter(io.Discard); err != nil {
+				b.Fatalf("Failed to write: %v", err)
+			}
+		}
+	})
+
+	b.Run("Cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := pa.WriteToWriter(io.Discard); err != nil {
+				b.Fatalf("Failed to write: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package netjugo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// reportTopPrefixes is how many of the largest prefixes a Report lists
+const reportTopPrefixes = 10
+
+// Report bundles the statistics, per-family breakdown, largest prefixes
+// and warnings of the current result, for periodic reporting
+type Report struct {
+	Stats AggregationStats
+	// Families holds IPv4, then IPv6
+	Families []FamilyReport
+	Top      []PrefixSize
+	Warnings []WarningSummary
+	// SkippedLines is Stats.SkippedLines, repeated for convenience
+	SkippedLines int
+}
+
+// FamilyReport describes the result prefixes of one family
+type FamilyReport struct {
+	Family    string // "ipv4" or "ipv6"
+	Original  int    // prefixes added
+	Prefixes  int    // result prefixes
+	Addresses *big.Int
+	// Lengths counts the result prefixes of each length, shortest first
+	Lengths []LengthCount
+}
+
+// LengthCount is one bar of a prefix length histogram
+type LengthCount struct {
+	Length int
+	Count  int
+}
+
+// WarningSummary counts the warnings of one code
+type WarningSummary struct {
+	Code  WarningCode
+	Count int
+	// First is the message of the first such warning
+	First string
+}
+
+// GenerateReport summarises the current result in one call
+func (pa *PrefixAggregator) GenerateReport() Report {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	stats := pa.stats(pa.calculateMemoryUsage())
+	r := Report{
+		Stats: stats,
+		Families: []FamilyReport{
+			familyReport("ipv4", stats.OriginalIPv4Count, pa.IPv4Prefixes),
+			familyReport("ipv6", stats.OriginalIPv6Count, pa.IPv6Prefixes),
+		},
+		Top:          topPrefixes(pa.maskedPrefixes(), reportTopPrefixes),
+		SkippedLines: stats.SkippedLines,
+	}
+
+	r.Warnings = append([]WarningSummary(nil), pa.warningCounts...)
+	return r
+}
+
+func familyReport(family string, original int, prefixes []*IPPrefix) FamilyReport {
+	f := FamilyReport{Family: family, Original: original, Prefixes: len(prefixes), Addresses: new(big.Int)}
+
+	counts := make(map[int]int)
+	for _, p := range prefixes {
+		counts[p.Prefix.Bits()]++
+		f.Addresses.Add(f.Addresses, prefixSize(p.Prefix))
+	}
+	for length, count := range counts {
+		f.Lengths = append(f.Lengths, LengthCount{Length: length, Count: count})
+	}
+	sort.Slice(f.Lengths, func(i, j int) bool {
+		return f.Lengths[i].Length < f.Lengths[j].Length
+	})
+	return f
+}
+
+// reportJSON is the JSON form of a Report. Address counts are strings, as
+// IPv6 counts do not fit a JSON number exactly.
+type reportJSON struct {
+	Stats        statsJSON     `json:"stats"`
+	Families     []familyJSON  `json:"families"`
+	Top          []sizeJSON    `json:"top_prefixes"`
+	Warnings     []warningJSON `json:"warnings"`
+	SkippedLines int           `json:"skipped_lines"`
+}
+
+type statsJSON struct {
+	IPv4Prefixes     int     `json:"ipv4_prefixes"`
+	IPv6Prefixes     int     `json:"ipv6_prefixes"`
+	TotalPrefixes    int     `json:"total_prefixes"`
+	OriginalPrefixes int     `json:"original_prefixes"`
+	Included         int     `json:"included"`
+	Excluded         int     `json:"excluded"`
+	ReductionRatio   float64 `json:"reduction_ratio"`
+	ProcessingTimeMs int64   `json:"processing_time_ms"`
+	MemoryUsageBytes int64   `json:"memory_usage_bytes"`
+}
+
+type familyJSON struct {
+	Family    string       `json:"family"`
+	Original  int          `json:"original"`
+	Prefixes  int          `json:"prefixes"`
+	Addresses string       `json:"addresses"`
+	Lengths   []lengthJSON `json:"lengths"`
+}
+
+type lengthJSON struct {
+	Length int `json:"length"`
+	Count  int `json:"count"`
+}
+
+type sizeJSON struct {
+	Prefix    string `json:"prefix"`
+	Family    string `json:"family"`
+	Addresses string `json:"addresses"`
+}
+
+type warningJSON struct {
+	Code  WarningCode `json:"code"`
+	Count int         `json:"count"`
+	First string      `json:"first"`
+}
+
+// MarshalJSON encodes the report with snake_case keys
+func (r Report) MarshalJSON() ([]byte, error) {
+	s := r.Stats
+	out := reportJSON{
+		Stats: statsJSON{
+			IPv4Prefixes:     s.IPv4PrefixCount,
+			IPv6Prefixes:     s.IPv6PrefixCount,
+			TotalPrefixes:    s.TotalPrefixes,
+			OriginalPrefixes: s.OriginalCount,
+			Included:         s.IncludedCount,
+			Excluded:         s.ExcludedCount,
+			ReductionRatio:   s.ReductionRatio,
+			ProcessingTimeMs: s.ProcessingTimeMs,
+			MemoryUsageBytes: s.MemoryUsageBytes,
+		},
+		Families:     make([]familyJSON, len(r.Families)),
+		Top:          make([]sizeJSON, len(r.Top)),
+		Warnings:     make([]warningJSON, len(r.Warnings)),
+		SkippedLines: r.SkippedLines,
+	}
+
+	for i, f := range r.Families {
+		lengths := make([]lengthJSON, len(f.Lengths))
+		for j, l := range f.Lengths {
+			lengths[j] = lengthJSON(l)
+		}
+		out.Families[i] = familyJSON{Family: f.Family, Original: f.Original, Prefixes: f.Prefixes, Addresses: f.Addresses.String(), Lengths: lengths}
+	}
+	for i, p := range r.Top {
+		out.Top[i] = sizeJSON{Prefix: p.Prefix.String(), Family: p.Family, Addresses: p.Addresses.String()}
+	}
+	for i, w := range r.Warnings {
+		out.Warnings[i] = warningJSON(w)
+	}
+
+	return json.Marshal(out)
+}
+
+// WriteText renders the report for people
+func (r Report) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	s := r.Stats
+	_, _ = fmt.Fprintf(bw, "Aggregation Report\n")
+	_, _ = fmt.Fprintf(bw, "  Original prefixes: %d\n", s.OriginalCount)
+	_, _ = fmt.Fprintf(bw, "  Included prefixes: %d\n", s.IncludedCount)
+	_, _ = fmt.Fprintf(bw, "  Excluded prefixes: %d\n", s.ExcludedCount)
+	_, _ = fmt.Fprintf(bw, "  Skipped lines: %d\n", r.SkippedLines)
+	_, _ = fmt.Fprintf(bw, "  Aggregated prefixes: %d\n", s.TotalPrefixes)
+	_, _ = fmt.Fprintf(bw, "  Reduction ratio: %.2f%%\n", s.ReductionRatio*100)
+	_, _ = fmt.Fprintf(bw, "  Processing time: %d ms\n", s.ProcessingTimeMs)
+	_, _ = fmt.Fprintf(bw, "  Memory usage: %d bytes\n", s.MemoryUsageBytes)
+
+	for _, f := range r.Families {
+		_, _ = fmt.Fprintf(bw, "\n%s: %d prefixes from %d input, %s addresses\n", strings.Replace(f.Family, "ip", "IP", 1), f.Prefixes, f.Original, f.Addresses)
+		for _, l := range f.Lengths {
+			_, _ = fmt.Fprintf(bw, "  /%-3d %d\n", l.Length, l.Count)
+		}
+	}
+
+	if len(r.Top) > 0 {
+		width := 0
+		for _, p := range r.Top {
+			width = max(width, len(p.Prefix.String()))
+		}
+		_, _ = fmt.Fprintf(bw, "\nLargest prefixes:\n")
+		for _, p := range r.Top {
+			_, _ = fmt.Fprintf(bw, "  %-*s  %s addresses\n", width, p.Prefix, p.Addresses)
+		}
+	}
+
+	if len(r.Warnings) > 0 {
+		_, _ = fmt.Fprintf(bw, "\nWarnings:\n")
+		for _, w := range r.Warnings {
+			_, _ = fmt.Fprintf(bw, "  %s: %d, first: %s\n", w.Code, w.Count, w.First)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package netjugo
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// reportFixture aggregates the report test data with an exclusion that
+// warns, and clears the fields that vary between runs
+func reportFixture(t *testing.T) Report {
+	t.Helper()
+
+	input, err := os.ReadFile(filepath.Join("testdata", "report", "input.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read input: %v", err)
+	}
+	pa := NewPrefixAggregator()
+	if err := pa.AddFromReader(bytes.NewReader(input)); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"172.16.5.0/31"}); err != nil {
+		t.Fatalf("Failed to set exclusions: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	r := pa.GenerateReport()
+	r.Stats.ProcessingTimeMs = 0
+	r.Stats.MemoryUsageBytes = 0
+	return r
+}
+
+func TestGenerateReportJSON(t *testing.T) {
+	r := reportFixture(t)
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to encode report: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "report", "report.json"))
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if string(data)+"\n" != string(want) {
+		t.Errorf("JSON mismatch:\n%s\nwant:\n%s", data, want)
+	}
+
+	// Every documented key is present with the documented type
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Report is not valid JSON: %v", err)
+	}
+	for key, kind := range map[string]string{
+		"stats": "object", "families": "array", "top_prefixes": "array", "warnings": "array", "skipped_lines": "number",
+	} {
+		var ok bool
+		switch kind {
+		case "object":
+			_, ok = doc[key].(map[string]any)
+		case "array":
+			_, ok = doc[key].([]any)
+		case "number":
+			_, ok = doc[key].(float64)
+		}
+		if !ok {
+			t.Errorf("Key %q missing or not a JSON %s", key, kind)
+		}
+	}
+	for _, f := range doc["families"].([]any) {
+		if _, ok := f.(map[string]any)["addresses"].(string); !ok {
+			t.Errorf("Family addresses should be a string: %v", f)
+		}
+	}
+}
+
+func TestGenerateReportText(t *testing.T) {
+	r := reportFixture(t)
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "report", "report.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("Text mismatch:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,158 @@
+package netjugo
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/holiman/uint256"
+)
+
+// SetRestrictToPrefixes makes Aggregate keep only the address space inside
+// prefixes, the inverse of SetExcludePrefixes: everything outside their
+// union is cut out of the result, splitting prefixes that straddle the
+// edge. The restriction applies to the input and the includes, even
+// under IncludesWin, and before the exclusions. A family with no
+// restricting prefix is dropped entirely. Passing no prefixes lifts the
+// restriction.
+func (pa *PrefixAggregator) SetRestrictToPrefixes(prefixes []string) error {
+	var ipv4, ipv6 []*IPPrefix
+	release := func() {
+		for _, p := range append(ipv4, ipv6...) {
+			releaseIPPrefix(p)
+		}
+	}
+	for _, prefixStr := range prefixes {
+		ipPrefix, err := parseIPPrefix(prefixStr)
+		if err != nil {
+			release()
+			return fmt.Errorf("failed to parse restrict prefix %q: %w", prefixStr, err)
+		}
+
+		if ipPrefix.Prefix.Addr().Is4() {
+			ipv4 = append(ipv4, ipPrefix)
+		} else {
+			ipv6 = append(ipv6, ipPrefix)
+		}
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		release()
+		return ErrClosed
+	}
+
+	if restricted := len(prefixes) > 0; restricted == pa.restricted && samePrefixes(ipv4, pa.restrictIPv4) && samePrefixes(ipv6, pa.restrictIPv6) {
+		release()
+		return nil
+	}
+	pa.releaseRestriction()
+	pa.restrictIPv4, pa.restrictIPv6 = ipv4, ipv6
+	pa.restricted = len(prefixes) > 0
+	pa.reconfigure()
+	return nil
+}
+
+// releaseRestriction returns the restricting prefixes to the pool
+func (pa *PrefixAggregator) releaseRestriction() {
+	for _, list := range [][]*IPPrefix{pa.restrictIPv4, pa.restrictIPv6} {
+		for _, p := range list {
+			releaseIPPrefix(p)
+		}
+	}
+	pa.restrictIPv4, pa.restrictIPv6 = nil, nil
+	pa.restricted = false
+}
+
+// applyRestriction cuts everything outside the restricting prefixes out
+// of both lists, excluding the complement of their union piece by piece
+func (pa *PrefixAggregator) applyRestriction() error {
+	if !pa.restricted {
+		return nil
+	}
+
+	for _, family := range []struct {
+		list     *[]*IPPrefix
+		restrict []*IPPrefix
+		isIPv4   bool
+	}{
+		{&pa.IPv4Prefixes, pa.restrictIPv4, true},
+		{&pa.IPv6Prefixes, pa.restrictIPv6, false},
+	} {
+		if len(*family.list) == 0 {
+			continue
+		}
+		if err := pa.ensureExclusionOrder(family.list); err != nil {
+			return err
+		}
+
+		outside, err := restrictionComplement(family.restrict, family.isIPv4)
+		if err != nil {
+			return err
+		}
+		for _, exclude := range outside {
+			overlapping := pa.findOverlappingPrefixes(exclude, *family.list)
+			if len(overlapping) == 0 {
+				continue
+			}
+			kept, err := pa.processExclusionNew(exclude, overlapping, family.isIPv4)
+			if err != nil {
+				releaseRestrictionComplement(outside)
+				return fmt.Errorf("%w: %s outside the restriction: %w", ErrExclusionFailed, exclude.Prefix, err)
+			}
+			*family.list = pa.replacePrefixesInList(*family.list, overlapping, kept)
+			releaseReplaced(overlapping, kept)
+		}
+		releaseRestrictionComplement(outside)
+
+		if err := pa.checkResultSize(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restrictionComplement returns the smallest set of prefixes of one family
+// covering every address outside restrict
+func restrictionComplement(restrict []*IPPrefix, isIPv4 bool) ([]*IPPrefix, error) {
+	all := netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+	if isIPv4 {
+		all = netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+	}
+	full, err := ipPrefixFrom(all)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseIPPrefix(full)
+
+	var outside []*IPPrefix
+	next := new(uint256.Int)
+	for _, r := range coverRanges(restrict) {
+		if r.min.Gt(next) {
+			gap, err := createOptimalPrefixes(next, new(uint256.Int).SubUint64(&r.min, 1), isIPv4)
+			if err != nil {
+				releaseRestrictionComplement(outside)
+				return nil, err
+			}
+			outside = append(outside, gap...)
+		}
+		if r.max.Eq(full.Max) {
+			return outside, nil
+		}
+		next.AddUint64(&r.max, 1)
+	}
+
+	rest, err := createOptimalPrefixes(next, full.Max, isIPv4)
+	if err != nil {
+		releaseRestrictionComplement(outside)
+		return nil, err
+	}
+	return append(outside, rest...), nil
+}
+
+func releaseRestrictionComplement(prefixes []*IPPrefix) {
+	for _, p := range prefixes {
+		releaseIPPrefix(p)
+	}
+}
@@ -0,0 +1,93 @@
+package netjugo
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestRestrictToPrefixes(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"0.0.0.0/1", "128.0.0.0/1", "10.1.128.0/17", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetRestrictToPrefixes([]string{"10.1.0.0/16", "172.16.0.0/16"}); err != nil {
+		t.Fatalf("SetRestrictToPrefixes failed: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	// The family without a restricting prefix is dropped
+	if want := []string{"10.1.0.0/16", "172.16.0.0/16"}; !slices.Equal(pa.GetPrefixes(), want) {
+		t.Fatalf("Expected %v, got %v", want, pa.GetPrefixes())
+	}
+
+	set := pa.Snapshot()
+	inside := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("172.16.0.0/16")}
+	rng := rand.New(rand.NewPCG(3, 0))
+	for i := 0; i < 100000; i++ {
+		addr := netip.AddrFrom4([4]byte{byte(rng.IntN(256)), byte(rng.IntN(256)), byte(rng.IntN(256)), byte(rng.IntN(256))})
+		if i%2 == 0 {
+			// Half the samples land inside the restriction
+			p := inside[i%4/2]
+			b := p.Addr().As4()
+			addr = netip.AddrFrom4([4]byte{b[0], b[1], byte(rng.IntN(256)), byte(rng.IntN(256))})
+		}
+		want := inside[0].Contains(addr) || inside[1].Contains(addr)
+		if set.Contains(addr) != want {
+			t.Fatalf("Contains(%s) = %v, want %v", addr, !want, want)
+		}
+	}
+}
+
+func TestRestrictToPrefixesOrder(t *testing.T) {
+	// Includes are restricted even when they win over exclusions, and the
+	// exclusions then cut into what is left
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/8", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.SetIncludePrefixes([]string{"192.0.2.0/24", "10.2.0.0/16"}); err != nil {
+		t.Fatalf("SetIncludePrefixes failed: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.1.0.0/17"}); err != nil {
+		t.Fatalf("SetExcludePrefixes failed: %v", err)
+	}
+	if err := pa.SetConstraintOrder(IncludesWin); err != nil {
+		t.Fatalf("SetConstraintOrder failed: %v", err)
+	}
+	if err := pa.SetRestrictToPrefixes([]string{"10.1.0.0/16", "2001:db8:1::/48"}); err != nil {
+		t.Fatalf("SetRestrictToPrefixes failed: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if want := []string{"10.1.128.0/17", "2001:db8:1::/48"}; !slices.Equal(pa.GetPrefixes(), want) {
+		t.Errorf("Expected %v, got %v", want, pa.GetPrefixes())
+	}
+	for _, w := range pa.GetWarnings() {
+		t.Errorf("Unexpected warning: %s", w)
+	}
+
+	// Lifting the restriction needs no restricting prefixes
+	c := NewPrefixAggregator()
+	if err := c.AddPrefix("10.0.0.0/8"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := c.SetRestrictToPrefixes([]string{"10.1.0.0/16"}); err != nil {
+		t.Fatalf("SetRestrictToPrefixes failed: %v", err)
+	}
+	if err := c.SetRestrictToPrefixes(nil); err != nil {
+		t.Fatalf("SetRestrictToPrefixes failed: %v", err)
+	}
+	if err := c.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := c.GetPrefixes(); !slices.Equal(got, []string{"10.0.0.0/8"}) {
+		t.Errorf("Expected the lifted restriction to keep 10.0.0.0/8, got %v", got)
+	}
+	if err := c.SetRestrictToPrefixes([]string{"bogus"}); err == nil {
+		t.Error("Expected an error for an invalid prefix")
+	}
+}
@@ -0,0 +1,148 @@
+package netjugo
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+)
+
+// PrefixSize is a result prefix with the number of addresses it covers
+type PrefixSize struct {
+	Prefix    netip.Prefix
+	Family    string // "ipv4" or "ipv6"
+	Addresses *big.Int
+}
+
+// TopPrefixesBySize returns the n result prefixes covering the most
+// addresses, largest first, across both families. Equal sizes are ordered
+// IPv4 first, then by address. Fewer are returned if there are fewer
+// prefixes, and none if n is not positive.
+func (pa *PrefixAggregator) TopPrefixesBySize(n int) []PrefixSize {
+	if n <= 0 {
+		return nil
+	}
+
+	pa.mu.RLock()
+	prefixes := pa.maskedPrefixes()
+	pa.mu.RUnlock()
+
+	return topPrefixes(prefixes, n)
+}
+
+// maskedPrefixes copies both result lists, for callers holding the lock
+func (pa *PrefixAggregator) maskedPrefixes() []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(pa.IPv4Prefixes)+len(pa.IPv6Prefixes))
+	for _, list := range [][]*IPPrefix{pa.IPv4Prefixes, pa.IPv6Prefixes} {
+		for _, p := range list {
+			prefixes = append(prefixes, p.Prefix.Masked())
+		}
+	}
+	return prefixes
+}
+
+// topPrefixes sorts prefixes by size and returns the n largest
+func topPrefixes(prefixes []netip.Prefix, n int) []PrefixSize {
+
+	// Sizes are powers of two, so comparing host bits is enough;
+	// netip.Addr.Compare already orders IPv4 before IPv6
+	sort.Slice(prefixes, func(i, j int) bool {
+		a, b := prefixes[i], prefixes[j]
+		if ha, hb := a.Addr().BitLen()-a.Bits(), b.Addr().BitLen()-b.Bits(); ha != hb {
+			return ha > hb
+		}
+		return a.Addr().Compare(b.Addr()) < 0
+	})
+
+	prefixes = prefixes[:min(n, len(prefixes))]
+	result := make([]PrefixSize, len(prefixes))
+	for i, p := range prefixes {
+		result[i] = PrefixSize{Prefix: p, Family: prefixFamily(p), Addresses: prefixSize(p)}
+	}
+	return result
+}
+
+// PrefixRange is a result prefix with its first and last address
+type PrefixRange struct {
+	Prefix netip.Prefix
+	First  netip.Addr
+	Last   netip.Addr
+}
+
+// GetPrefixRanges returns the result prefixes with their first and last
+// addresses, IPv4 first, in the configured output order. The addresses
+// come from the stored ranges rather than being recomputed.
+func (pa *PrefixAggregator) GetPrefixRanges() []PrefixRange {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	ranges := make([]PrefixRange, 0, len(pa.IPv4Prefixes)+len(pa.IPv6Prefixes))
+	for _, list := range [][]*IPPrefix{pa.orderedPrefixes(pa.IPv4Prefixes), pa.orderedPrefixes(pa.IPv6Prefixes)} {
+		for _, p := range list {
+			isIPv4 := p.Prefix.Addr().Is4()
+			ranges = append(ranges, PrefixRange{
+				Prefix: p.Prefix.Masked(),
+				First:  uint256ToAddr(p.Min, isIPv4),
+				Last:   uint256ToAddr(p.Max, isIPv4),
+			})
+		}
+	}
+	return ranges
+}
+
+// GroupStats summarises the result prefixes inside one parent prefix
+type GroupStats struct {
+	Prefixes  int
+	Addresses *big.Int
+}
+
+// GroupByParent counts the result prefixes and the addresses they cover
+// under each parent of ipv4Bits (IPv4) or ipv6Bits (IPv6) bits, keyed by
+// the parent prefix. A length longer than the shortest result prefix of
+// its family returns ErrInvalidOption, as that prefix would not fit any
+// parent.
+func (pa *PrefixAggregator) GroupByParent(ipv4Bits, ipv6Bits int) (map[string]GroupStats, error) {
+	if ipv4Bits < 0 || ipv4Bits > 32 {
+		return nil, fmt.Errorf("%w: IPv4 grouping length must be 0-32, got %d", ErrInvalidOption, ipv4Bits)
+	}
+	if ipv6Bits < 0 || ipv6Bits > 128 {
+		return nil, fmt.Errorf("%w: IPv6 grouping length must be 0-128, got %d", ErrInvalidOption, ipv6Bits)
+	}
+
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if pa.closed {
+		return nil, ErrClosed
+	}
+
+	groups := make(map[string]GroupStats)
+	if err := groupPrefixes(groups, pa.IPv4Prefixes, ipv4Bits, "IPv4"); err != nil {
+		return nil, err
+	}
+	if err := groupPrefixes(groups, pa.IPv6Prefixes, ipv6Bits, "IPv6"); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func groupPrefixes(groups map[string]GroupStats, prefixes []*IPPrefix, length int, family string) error {
+	for _, p := range prefixes {
+		if p.Prefix.Bits() < length {
+			return fmt.Errorf("%w: %s grouping length /%d is longer than result prefix %s", ErrInvalidOption, family, length, p.Prefix)
+		}
+	}
+
+	for _, p := range prefixes {
+		parent, _ := p.Prefix.Addr().Prefix(length)
+		key := parent.String()
+		g := groups[key]
+		if g.Addresses == nil {
+			g.Addresses = new(big.Int)
+		}
+		g.Prefixes++
+		g.Addresses.Add(g.Addresses, prefixSize(p.Prefix))
+		groups[key] = g
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package netjugo
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestTopPrefixesBySize(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{
+		"192.168.1.0/24", "10.0.0.0/8", "172.16.0.0/16", "192.168.3.0/24",
+		"20.0.0.0/8", "172.20.0.0/16", "2001:db8::/120",
+	}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	var got []string
+	for _, p := range pa.TopPrefixesBySize(7) {
+		got = append(got, fmt.Sprintf("%s %s %s", p.Prefix, p.Family, p.Addresses))
+	}
+	expected := []string{
+		"10.0.0.0/8 ipv4 16777216",
+		"20.0.0.0/8 ipv4 16777216",
+		"172.16.0.0/16 ipv4 65536",
+		"172.20.0.0/16 ipv4 65536",
+		// Equal sizes order IPv4 before IPv6
+		"192.168.1.0/24 ipv4 256",
+		"192.168.3.0/24 ipv4 256",
+		"2001:db8::/120 ipv6 256",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected order:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if top := pa.TopPrefixesBySize(3); len(top) != 3 || top[2].Prefix.String() != "172.16.0.0/16" {
+		t.Errorf("Unexpected top 3: %v", top)
+	}
+	if all := pa.TopPrefixesBySize(100); len(all) != 7 {
+		t.Errorf("Expected all 7 prefixes, got %d", len(all))
+	}
+	if none := pa.TopPrefixesBySize(0); len(none) != 0 {
+		t.Errorf("Expected no prefixes for n=0, got %d", len(none))
+	}
+}
+
+func TestGetPrefixRanges(t *testing.T) {
+	tests := []struct {
+		prefix      string
+		first, last string
+	}{
+		{"0.0.0.0/0", "0.0.0.0", "255.255.255.255"},
+		{"192.0.2.77/32", "192.0.2.77", "192.0.2.77"},
+		{"::/0", "::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"2001:db8:1:2::/64", "2001:db8:1:2::", "2001:db8:1:2:ffff:ffff:ffff:ffff"},
+		{"2001:db8::1/128", "2001:db8::1", "2001:db8::1"},
+		// Host bits are masked off
+		{"10.1.2.3/16", "10.1.0.0", "10.1.255.255"},
+	}
+	for _, tt := range tests {
+		pa := NewPrefixAggregator()
+		if err := pa.AddPrefix(tt.prefix); err != nil {
+			t.Fatalf("Failed to add %s: %v", tt.prefix, err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		ranges := pa.GetPrefixRanges()
+		if len(ranges) != 1 {
+			t.Fatalf("%s: expected 1 range, got %v", tt.prefix, ranges)
+		}
+		r := ranges[0]
+		if r.Prefix != netip.MustParsePrefix(tt.prefix).Masked() || r.First.String() != tt.first || r.Last.String() != tt.last {
+			t.Errorf("%s: got %s %s-%s, want %s-%s", tt.prefix, r.Prefix, r.First, r.Last, tt.first, tt.last)
+		}
+	}
+
+	// Both families, IPv4 first, in output order
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"2001:db8::/48", "10.0.0.0/24", "10.0.1.0/24", "192.0.2.0/25"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	var got []string
+	for _, r := range pa.GetPrefixRanges() {
+		got = append(got, fmt.Sprintf("%s %s-%s", r.Prefix, r.First, r.Last))
+	}
+	want := "10.0.0.0/23 10.0.0.0-10.0.1.255,192.0.2.0/25 192.0.2.0-192.0.2.127,2001:db8::/48 2001:db8::-2001:db8:0:ffff:ffff:ffff:ffff:ffff"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Got %v, want %s", got, want)
+	}
+}
+
+func TestGroupByParent(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{
+		"10.0.0.0/24", "10.0.2.0/24", "10.1.0.0/16", "11.0.0.0/16",
+		"2001:db8::/48", "2001:db8:2::/48", "2001:db9::/32",
+	}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	groups, err := pa.GroupByParent(8, 16)
+	if err != nil {
+		t.Fatalf("GroupByParent failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"10.0.0.0/8": "3 66048",
+		"11.0.0.0/8": "1 65536",
+		"2001::/16":  "3 79230580365903566851893362688",
+	}
+	if len(groups) != len(expected) {
+		t.Errorf("Expected %d groups, got %d: %v", len(expected), len(groups), groups)
+	}
+	for parent, want := range expected {
+		g, ok := groups[parent]
+		if !ok {
+			t.Errorf("Missing group %s", parent)
+			continue
+		}
+		if got := fmt.Sprintf("%d %s", g.Prefixes, g.Addresses); got != want {
+			t.Errorf("Group %s = %s, want %s", parent, got, want)
+		}
+	}
+
+	// 11.0.0.0/16 does not fit in a /24 parent
+	if _, err := pa.GroupByParent(24, 16); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a /24 grouping, got %v", err)
+	}
+	if _, err := pa.GroupByParent(8, 40); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a /40 IPv6 grouping, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package netjugo
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/holiman/uint256"
+)
+
+// AggregatedSet is an immutable, lock-free view of a set of prefixes.
+// It is safe for concurrent use by any number of goroutines and is not
+// affected by later changes to the aggregator it was taken from.
+type AggregatedSet struct {
+	ipv4 []setEntry
+	ipv6 []setEntry
+}
+
+type setEntry struct {
+	prefix netip.Prefix
+	min    uint256.Int
+	max    uint256.Int
+	// reach is the highest Max of this entry and every entry before it,
+	// which bounds the backwards scan in lookups when entries overlap.
+	reach uint256.Int
+}
+
+// Snapshot returns an immutable copy of the current prefixes. It is
+// usually taken after Aggregate, but works on unaggregated data as well.
+func (pa *PrefixAggregator) Snapshot() *AggregatedSet {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	return &AggregatedSet{
+		ipv4: newSetEntries(pa.IPv4Prefixes),
+		ipv6: newSetEntries(pa.IPv6Prefixes),
+	}
+}
+
+func newSetEntries(prefixes []*IPPrefix) []setEntry {
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	entries := make([]setEntry, len(prefixes))
+	for i, p := range prefixes {
+		entries[i].prefix = p.Prefix
+		entries[i].min.Set(p.Min)
+		entries[i].max.Set(p.Max)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if c := entries[i].min.Cmp(&entries[j].min); c != 0 {
+			return c < 0
+		}
+		return entries[i].max.Cmp(&entries[j].max) > 0
+	})
+
+	for i := range entries {
+		entries[i].reach.Set(&entries[i].max)
+		if i > 0 && entries[i-1].reach.Cmp(&entries[i].reach) > 0 {
+			entries[i].reach.Set(&entries[i-1].reach)
+		}
+	}
+
+	return entries
+}
+
+// Len returns the number of prefixes in the set.
+func (s *AggregatedSet) Len() int {
+	return len(s.ipv4) + len(s.ipv6)
+}
+
+// Contains reports whether addr is covered by any prefix in the set.
+func (s *AggregatedSet) Contains(addr netip.Addr) bool {
+	_, ok := s.lookup(addr, false)
+	return ok
+}
+
+// LongestPrefixMatch returns the most specific prefix in the set that
+// covers addr.
+func (s *AggregatedSet) LongestPrefixMatch(addr netip.Addr) (netip.Prefix, bool) {
+	return s.lookup(addr, true)
+}
+
+// ForEach calls fn for every prefix in the set, IPv4 first, each family
+// in address order. Iteration stops early if fn returns false.
+func (s *AggregatedSet) ForEach(fn func(netip.Prefix) bool) {
+	for i := range s.ipv4 {
+		if !fn(s.ipv4[i].prefix) {
+			return
+		}
+	}
+	for i := range s.ipv6 {
+		if !fn(s.ipv6[i].prefix) {
+			return
+		}
+	}
+}
+
+func (s *AggregatedSet) lookup(addr netip.Addr, longest bool) (netip.Prefix, bool) {
+	if !addr.IsValid() {
+		return netip.Prefix{}, false
+	}
+
+	entries := s.ipv6
+	if addr.Is4() {
+		entries = s.ipv4
+	}
+	if len(entries) == 0 {
+		return netip.Prefix{}, false
+	}
+
+	var v uint256.Int
+	addrToUint256(addr, &v)
+
+	// Index of the last entry whose Min <= v
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].min.Cmp(&v) > 0
+	}) - 1
+
+	var best netip.Prefix
+	found := false
+	for ; i >= 0 && entries[i].reach.Cmp(&v) >= 0; i-- {
+		if entries[i].max.Cmp(&v) < 0 {
+			continue
+		}
+		if !longest {
+			return entries[i].prefix, true
+		}
+		if !found || entries[i].prefix.Bits() > best.Bits() {
+			best = entries[i].prefix
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// addrToUint256 stores the numeric value of addr in dst
+func addrToUint256(addr netip.Addr, dst *uint256.Int) {
+	if addr.Is4() {
+		b := addr.As4()
+		dst.SetBytes(b[:])
+		return
+	}
+	b := addr.As16()
+	dst.SetBytes(b[:])
+}
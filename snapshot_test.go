@@ -0,0 +1,216 @@
+package netjugo
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func TestSnapshotLookups(t *testing.T) {
+	pa := NewPrefixAggregator()
+	err := pa.AddPrefixes([]string{
+		"10.0.0.0/24",
+		"10.0.1.0/24",
+		"192.168.0.0/16",
+		"2001:db8::/32",
+	})
+	if err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	snap := pa.Snapshot()
+
+	if snap.Len() != 3 {
+		t.Errorf("Expected 3 prefixes in snapshot, got %d", snap.Len())
+	}
+
+	tests := []struct {
+		addr     string
+		contains bool
+		match    string
+	}{
+		{"10.0.0.1", true, "10.0.0.0/23"},
+		{"10.0.1.255", true, "10.0.0.0/23"},
+		{"10.0.2.0", false, ""},
+		{"192.168.255.255", true, "192.168.0.0/16"},
+		{"9.255.255.255", false, ""},
+		{"2001:db8::1", true, "2001:db8::/32"},
+		{"2001:db9::1", false, ""},
+	}
+
+	for _, tt := range tests {
+		addr := netip.MustParseAddr(tt.addr)
+		if got := snap.Contains(addr); got != tt.contains {
+			t.Errorf("Contains(%s) = %v, want %v", tt.addr, got, tt.contains)
+		}
+		match, ok := snap.LongestPrefixMatch(addr)
+		if ok != tt.contains {
+			t.Errorf("LongestPrefixMatch(%s) ok = %v, want %v", tt.addr, ok, tt.contains)
+		}
+		if ok && match.String() != tt.match {
+			t.Errorf("LongestPrefixMatch(%s) = %s, want %s", tt.addr, match, tt.match)
+		}
+	}
+
+	var visited []string
+	snap.ForEach(func(p netip.Prefix) bool {
+		visited = append(visited, p.String())
+		return true
+	})
+	expected := []string{"10.0.0.0/23", "192.168.0.0/16", "2001:db8::/32"}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("ForEach visited %v, want %v", visited, expected)
+	}
+
+	count := 0
+	snap.ForEach(func(netip.Prefix) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("Expected ForEach to stop after 1 prefix, visited %d", count)
+	}
+}
+
+func TestSnapshotLongestPrefixMatchOverlapping(t *testing.T) {
+	// Without aggregation the snapshot holds nested prefixes
+	pa := NewPrefixAggregator()
+	err := pa.AddPrefixes([]string{
+		"10.0.0.0/8",
+		"10.1.0.0/16",
+		"10.1.2.0/24",
+		"10.200.0.0/16",
+	})
+	if err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	snap := pa.Snapshot()
+
+	tests := []struct {
+		addr  string
+		match string
+	}{
+		{"10.1.2.3", "10.1.2.0/24"},
+		{"10.1.3.3", "10.1.0.0/16"},
+		{"10.2.0.1", "10.0.0.0/8"},
+		{"10.255.0.1", "10.0.0.0/8"},
+	}
+
+	for _, tt := range tests {
+		match, ok := snap.LongestPrefixMatch(netip.MustParseAddr(tt.addr))
+		if !ok || match.String() != tt.match {
+			t.Errorf("LongestPrefixMatch(%s) = %s (%v), want %s", tt.addr, match, ok, tt.match)
+		}
+	}
+}
+
+func TestSnapshotIndependentOfAggregator(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefix("10.0.0.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	snap := pa.Snapshot()
+
+	if err := pa.AddPrefix("172.16.0.0/12"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if err := pa.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+
+	if snap.Len() != 1 {
+		t.Errorf("Expected snapshot to keep 1 prefix, got %d", snap.Len())
+	}
+	if !snap.Contains(netip.MustParseAddr("10.0.0.5")) {
+		t.Error("Snapshot lost 10.0.0.0/24 after aggregator was reset")
+	}
+	if snap.Contains(netip.MustParseAddr("172.16.0.1")) {
+		t.Error("Snapshot picked up a prefix added after it was taken")
+	}
+}
+
+func TestSnapshotEmpty(t *testing.T) {
+	snap := NewPrefixAggregator().Snapshot()
+
+	if snap.Len() != 0 {
+		t.Errorf("Expected empty snapshot, got %d prefixes", snap.Len())
+	}
+	if snap.Contains(netip.MustParseAddr("10.0.0.1")) {
+		t.Error("Empty snapshot should not contain anything")
+	}
+	if snap.Contains(netip.Addr{}) {
+		t.Error("Invalid address should never be contained")
+	}
+}
+
+// lockedContains mirrors what a caller has to do without snapshots:
+// take the aggregator's read lock and search the live slices.
+func lockedContains(pa *PrefixAggregator, addr netip.Addr) bool {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	prefixes := pa.IPv6Prefixes
+	if addr.Is4() {
+		prefixes = pa.IPv4Prefixes
+	}
+
+	var v uint256.Int
+	addrToUint256(addr, &v)
+
+	i := sort.Search(len(prefixes), func(i int) bool {
+		return prefixes[i].Min.Cmp(&v) > 0
+	}) - 1
+	return i >= 0 && prefixes[i].Max.Cmp(&v) >= 0
+}
+
+func BenchmarkSnapshotContains(b *testing.B) {
+	pa := NewPrefixAggregator()
+	for i := 0; i < 10000; i++ {
+		_ = pa.AddPrefix(fmt.Sprintf("10.%d.%d.0/24", i/128, (i%128)*2))
+	}
+	if err := pa.Aggregate(); err != nil {
+		b.Fatalf("Aggregation failed: %v", err)
+	}
+
+	addrs := make([]netip.Addr, 1024)
+	for i := range addrs {
+		addrs[i] = netip.AddrFrom4([4]byte{10, byte(i % 80), byte(i % 256), 1})
+	}
+
+	b.Run("Snapshot", func(b *testing.B) {
+		snap := pa.Snapshot()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				_ = snap.Contains(addrs[i%len(addrs)])
+				i++
+			}
+		})
+	})
+
+	b.Run("RWMutex", func(b *testing.B) {
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				_ = lockedContains(pa, addrs[i%len(addrs)])
+				i++
+			}
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package netjugo
+
+import "net/netip"
+
+// SpecialUse identifies the special-purpose address block a prefix falls
+// under
+type SpecialUse string
+
+const (
+	// SpecialUseNone marks a prefix outside every known special-purpose
+	// block
+	SpecialUseNone SpecialUse = ""
+	// SpecialUseUniqueLocal marks IPv6 unique local addresses, fc00::/7
+	// (RFC 4193)
+	SpecialUseUniqueLocal SpecialUse = "unique-local"
+	// SpecialUseLinkLocal marks IPv6 link-local unicast, fe80::/10
+	// (RFC 4291)
+	SpecialUseLinkLocal SpecialUse = "link-local"
+	// SpecialUseDocumentation marks the IPv6 documentation blocks,
+	// 2001:db8::/32 (RFC 3849) and 3fff::/20 (RFC 9637)
+	SpecialUseDocumentation SpecialUse = "documentation"
+	// SpecialUse6to4 marks 6to4, 2002::/16 (RFC 3056)
+	SpecialUse6to4 SpecialUse = "6to4"
+	// SpecialUseTeredo marks Teredo, 2001::/32 (RFC 4380)
+	SpecialUseTeredo SpecialUse = "teredo"
+	// SpecialUseSiteLocal marks the deprecated IPv6 site-local space,
+	// fec0::/10 (RFC 3879)
+	SpecialUseSiteLocal SpecialUse = "site-local"
+)
+
+// ReservedExclusionSet is the name of the exclusion set added by
+// ExcludeReservedNetworks
+const ReservedExclusionSet = "reserved"
+
+var specialUseBlocks = []struct {
+	use      SpecialUse
+	prefixes []netip.Prefix
+}{
+	{SpecialUseUniqueLocal, []netip.Prefix{netip.MustParsePrefix("fc00::/7")}},
+	{SpecialUseLinkLocal, []netip.Prefix{netip.MustParsePrefix("fe80::/10")}},
+	{SpecialUseDocumentation, []netip.Prefix{netip.MustParsePrefix("2001:db8::/32"), netip.MustParsePrefix("3fff::/20")}},
+	{SpecialUse6to4, []netip.Prefix{netip.MustParsePrefix("2002::/16")}},
+	{SpecialUseTeredo, []netip.Prefix{netip.MustParsePrefix("2001::/32")}},
+	{SpecialUseSiteLocal, []netip.Prefix{netip.MustParsePrefix("fec0::/10")}},
+}
+
+// specialUsePrefixes returns a copy of the prefixes of use
+func specialUsePrefixes(use SpecialUse) []netip.Prefix {
+	for _, block := range specialUseBlocks {
+		if block.use == use {
+			return append([]netip.Prefix(nil), block.prefixes...)
+		}
+	}
+	return nil
+}
+
+// IPv6UniqueLocal returns the IPv6 unique local block, fc00::/7
+func IPv6UniqueLocal() []netip.Prefix { return specialUsePrefixes(SpecialUseUniqueLocal) }
+
+// IPv6LinkLocal returns the IPv6 link-local unicast block, fe80::/10
+func IPv6LinkLocal() []netip.Prefix { return specialUsePrefixes(SpecialUseLinkLocal) }
+
+// IPv6Documentation returns the IPv6 documentation blocks, 2001:db8::/32
+// and 3fff::/20
+func IPv6Documentation() []netip.Prefix { return specialUsePrefixes(SpecialUseDocumentation) }
+
+// IPv6SixToFour returns the 6to4 block, 2002::/16
+func IPv6SixToFour() []netip.Prefix { return specialUsePrefixes(SpecialUse6to4) }
+
+// IPv6Teredo returns the Teredo block, 2001::/32
+func IPv6Teredo() []netip.Prefix { return specialUsePrefixes(SpecialUseTeredo) }
+
+// IPv6SiteLocal returns the deprecated IPv6 site-local block, fec0::/10
+func IPv6SiteLocal() []netip.Prefix { return specialUsePrefixes(SpecialUseSiteLocal) }
+
+// ReservedNetworks returns every special-purpose prefix above, in
+// ComparePrefixes order
+func ReservedNetworks() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, block := range specialUseBlocks {
+		prefixes = append(prefixes, block.prefixes...)
+	}
+	SortPrefixes(prefixes)
+	return prefixes
+}
+
+// ClassifyPrefix reports the special-purpose block p falls under, or
+// SpecialUseNone if it is outside all of them or only partly inside one.
+// Host bits are ignored.
+func ClassifyPrefix(p netip.Prefix) SpecialUse {
+	if !p.IsValid() {
+		return SpecialUseNone
+	}
+	p = p.Masked()
+	for _, block := range specialUseBlocks {
+		for _, b := range block.prefixes {
+			if p.Bits() >= b.Bits() && b.Contains(p.Addr()) {
+				return block.use
+			}
+		}
+	}
+	return SpecialUseNone
+}
+
+// ExcludeReservedNetworks adds ReservedNetworks as the exclusion set named
+// ReservedExclusionSet, so it can be switched off like any other set
+func (pa *PrefixAggregator) ExcludeReservedNetworks() error {
+	prefixes := ReservedNetworks()
+	strs := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		strs[i] = p.String()
+	}
+	return pa.AddExclusionSet(ReservedExclusionSet, strs)
+}
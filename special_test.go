@@ -0,0 +1,102 @@
+package netjugo
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestSpecialUsePrefixes(t *testing.T) {
+	// Pinned against the IANA IPv6 Special-Purpose Address Registry and,
+	// for site-local, the IPv6 Address Space registry
+	tests := []struct {
+		name string
+		got  []netip.Prefix
+		want []string
+	}{
+		{"IPv6UniqueLocal", IPv6UniqueLocal(), []string{"fc00::/7"}},
+		{"IPv6LinkLocal", IPv6LinkLocal(), []string{"fe80::/10"}},
+		{"IPv6Documentation", IPv6Documentation(), []string{"2001:db8::/32", "3fff::/20"}},
+		{"IPv6SixToFour", IPv6SixToFour(), []string{"2002::/16"}},
+		{"IPv6Teredo", IPv6Teredo(), []string{"2001::/32"}},
+		{"IPv6SiteLocal", IPv6SiteLocal(), []string{"fec0::/10"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range tt.got {
+			got = append(got, p.String())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Callers get their own copy
+	IPv6UniqueLocal()[0] = netip.MustParsePrefix("10.0.0.0/8")
+	if got := IPv6UniqueLocal()[0].String(); got != "fc00::/7" {
+		t.Errorf("Expected a fresh slice, got %s", got)
+	}
+
+	want := []string{"2001::/32", "2001:db8::/32", "2002::/16", "3fff::/20", "fc00::/7", "fe80::/10", "fec0::/10"}
+	var got []string
+	for _, p := range ReservedNetworks() {
+		got = append(got, p.String())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ReservedNetworks() = %v, want %v", got, want)
+	}
+}
+
+func TestClassifyPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   SpecialUse
+	}{
+		{"fd12:3456:789a::/48", SpecialUseUniqueLocal},
+		{"fc00::/7", SpecialUseUniqueLocal},
+		{"fe80::1/64", SpecialUseLinkLocal},
+		{"febf:ffff::/32", SpecialUseLinkLocal},
+		{"2001:db8:1::/48", SpecialUseDocumentation},
+		{"3fff:fff::/32", SpecialUseDocumentation},
+		{"2002:c000:204::/48", SpecialUse6to4},
+		{"2001:0:4136:e378::/64", SpecialUseTeredo},
+		{"fec0::/16", SpecialUseSiteLocal},
+		// Just outside the blocks
+		{"2001:1::/32", SpecialUseNone},
+		{"2001:db9::/32", SpecialUseNone},
+		{"4000::/20", SpecialUseNone},
+		{"ff00::/8", SpecialUseNone},
+		// A prefix only partly inside a block falls under none of them
+		{"fc00::/6", SpecialUseNone},
+		{"2001::/16", SpecialUseNone},
+		{"10.0.0.0/8", SpecialUseNone},
+	}
+	for _, tt := range tests {
+		if got := ClassifyPrefix(netip.MustParsePrefix(tt.prefix)); got != tt.want {
+			t.Errorf("ClassifyPrefix(%s) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+	if got := ClassifyPrefix(netip.Prefix{}); got != SpecialUseNone {
+		t.Errorf("Expected SpecialUseNone for an invalid prefix, got %q", got)
+	}
+}
+
+func TestExcludeReservedNetworks(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"fe00::/7", "2001:db8::/31", "192.0.2.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.ExcludeReservedNetworks(); err != nil {
+		t.Fatalf("ExcludeReservedNetworks failed: %v", err)
+	}
+	if sets := pa.ExclusionSets(); !slices.Equal(sets, []string{ReservedExclusionSet}) {
+		t.Errorf("Expected the %q set, got %v", ReservedExclusionSet, sets)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	want := []string{"192.0.2.0/24", "2001:db9::/32", "fe00::/9", "ff00::/8"}
+	if got := pa.GetPrefixes(); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
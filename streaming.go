@@ -0,0 +1,66 @@
+package netjugo
+
+import (
+	"fmt"
+	"io"
+)
+
+// AggregateFromReaderStreaming loads reader like AddFromReader and
+// aggregates it, but folds every chunkSize prefixes into the running
+// result as it goes. Peak memory is then bounded by the chunk size plus
+// the partial result instead of the whole input. Includes, exclusions and
+// minimum lengths are applied once at the end, so the result matches
+// AddFromReader followed by Aggregate.
+func (pa *PrefixAggregator) AggregateFromReaderStreaming(reader io.Reader, chunkSize int) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("%w: chunk size must be positive, got %d", ErrInvalidOption, chunkSize)
+	}
+
+	pending := 0
+	_, err := pa.addFromReader(reader, "", func() error {
+		if pending++; pending < chunkSize {
+			return nil
+		}
+		pending = 0
+		return pa.foldPending()
+	})
+	if err != nil {
+		return err
+	}
+
+	return pa.Aggregate()
+}
+
+// foldPending merges the prefixes added since the last fold into the
+// sorted, aggregated head of each list
+func (pa *PrefixAggregator) foldPending() error {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.changes++
+	if err := pa.sortAndDeduplicateIPv4(); err != nil {
+		return err
+	}
+	if err := pa.sortAndDeduplicateIPv6(); err != nil {
+		return err
+	}
+
+	if err := pa.aggregatePrefixes(&pa.IPv4Prefixes); err != nil {
+		return err
+	}
+	if err := pa.aggregatePrefixes(&pa.IPv6Prefixes); err != nil {
+		return err
+	}
+
+	// Aggregation keeps address order but not necessarily the length
+	// tie-break, so restore full canonical order for the next merge
+	sortPrefixes(pa.IPv4Prefixes)
+	sortPrefixes(pa.IPv6Prefixes)
+	pa.sortedIPv4 = len(pa.IPv4Prefixes)
+	pa.sortedIPv6 = len(pa.IPv6Prefixes)
+	return nil
+}
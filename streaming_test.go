@@ -0,0 +1,144 @@
+package netjugo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamingSample returns the 100k sample, or the checked-in large
+// dataset when the sample is not available
+func streamingSample(tb testing.TB) []byte {
+	tb.Helper()
+	datasetPath := ".samples/sample-100k-prefixes.txt"
+	if _, err := os.Stat(datasetPath); os.IsNotExist(err) {
+		datasetPath = "testdata/large_dataset.txt"
+	}
+	data, err := os.ReadFile(datasetPath)
+	if err != nil {
+		tb.Fatalf("Failed to read %s: %v", datasetPath, err)
+	}
+	return data
+}
+
+func TestAggregateFromReaderStreamingMatchesInMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping streaming comparison in short mode")
+	}
+	data := streamingSample(t)
+
+	configure := func(pa *PrefixAggregator) {
+		if err := pa.SetMinPrefixLength(16, 32); err != nil {
+			t.Fatalf("Failed to set min prefix length: %v", err)
+		}
+		if err := pa.SetExcludePrefixes([]string{"10.0.0.0/8", "2001:db8::/32"}); err != nil {
+			t.Fatalf("Failed to set excludes: %v", err)
+		}
+	}
+
+	inMemory := NewPrefixAggregator()
+	configure(inMemory)
+	if err := inMemory.AddFromReader(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if err := inMemory.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	want := inMemory.GetPrefixes()
+
+	for _, chunkSize := range []int{1000, 25000} {
+		streamed := NewPrefixAggregator()
+		configure(streamed)
+		if err := streamed.AggregateFromReaderStreaming(bytes.NewReader(data), chunkSize); err != nil {
+			t.Fatalf("Streaming aggregation failed: %v", err)
+		}
+
+		got := streamed.GetPrefixes()
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Chunk size %d: streamed result (%d prefixes) differs from in-memory result (%d prefixes)", chunkSize, len(got), len(want))
+		}
+		if s, m := streamed.GetStats(), inMemory.GetStats(); s.OriginalCount != m.OriginalCount || s.SkippedLines != m.SkippedLines {
+			t.Errorf("Chunk size %d: stats differ: streamed %+v, in-memory %+v", chunkSize, s, m)
+		}
+	}
+}
+
+func TestAggregateFromReaderStreamingInvalidChunk(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AggregateFromReaderStreaming(strings.NewReader("10.0.0.0/8\n"), 0); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+// peakHeap runs fn while sampling the heap and returns the highest
+// HeapInuse seen
+func peakHeap(fn func()) uint64 {
+	runtime.GC()
+
+	var peak uint64
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var ms runtime.MemStats
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&ms)
+			peak = max(peak, ms.HeapInuse)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	fn()
+	close(done)
+	wg.Wait()
+	return peak
+}
+
+// BenchmarkAggregateFromReader compares loading and aggregating the sample
+// in memory with streaming it in chunks; peak-heap-MB shows the reduction
+func BenchmarkAggregateFromReader(b *testing.B) {
+	data := streamingSample(b)
+	SetPooling(false)
+	defer SetPooling(true)
+
+	run := func(b *testing.B, aggregate func(pa *PrefixAggregator) error) {
+		var peak uint64
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			peak = max(peak, peakHeap(func() {
+				if err := aggregate(NewPrefixAggregator()); err != nil {
+					b.Fatalf("Aggregation failed: %v", err)
+				}
+			}))
+		}
+		b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+	}
+
+	b.Run("InMemory", func(b *testing.B) {
+		run(b, func(pa *PrefixAggregator) error {
+			if err := pa.AddFromReader(bytes.NewReader(data)); err != nil {
+				return err
+			}
+			return pa.Aggregate()
+		})
+	})
+
+	b.Run("Streaming", func(b *testing.B) {
+		run(b, func(pa *PrefixAggregator) error {
+			return pa.AggregateFromReaderStreaming(bytes.NewReader(data), 10000)
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package netjugo
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+)
+
+// AddFromReaderTagged is AddFromReader, also recording tag as the source
+// of every prefix it adds, for GetPrefixTags. Skipped line reports carry
+// the tag too.
+func (pa *PrefixAggregator) AddFromReaderTagged(reader io.Reader, tag string) error {
+	_, err := pa.AddFromReaderTaggedCount(reader, tag)
+	return err
+}
+
+// AddFromReaderTaggedCount is AddFromReaderTagged, also returning how many
+// prefixes were added and how many lines were skipped
+func (pa *PrefixAggregator) AddFromReaderTaggedCount(reader io.Reader, tag string) (ReadResult, error) {
+	if tag == "" {
+		return ReadResult{}, fmt.Errorf("%w: tag must not be empty", ErrInvalidOption)
+	}
+	return pa.addFromReader(reader, tag, nil)
+}
+
+// GetPrefixTags returns, sorted and without duplicates, the tags of every
+// tagged input prefix that overlaps prefix. Tags are kept per input
+// prefix, so they survive aggregation: a result prefix carries the tags
+// of everything merged into it, and a piece of an input split by an
+// exclusion keeps the tags of that input.
+func (pa *PrefixAggregator) GetPrefixTags(prefix string) ([]string, error) {
+	target, err := parseIPPrefix(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prefix %q: %w", prefix, err)
+	}
+	defer releaseIPPrefix(target)
+
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if pa.closed {
+		return nil, ErrClosed
+	}
+
+	var tags []string
+	for input, inputTags := range pa.tags {
+		if input.Overlaps(target.Prefix) {
+			tags = append(tags, inputTags...)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags), nil
+}
+
+// GetResultTags returns the tags of every current prefix that has any,
+// keyed by the prefix as GetPrefixes formats it outside minimal change
+// mode. It gives the same tags as GetPrefixTags for each prefix, in one
+// pass over the result.
+func (pa *PrefixAggregator) GetResultTags() map[string][]string {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	result := make(map[string][]string)
+	if len(pa.tags) == 0 {
+		return result
+	}
+	lists := [2][]*IPPrefix{slices.Clone(pa.IPv4Prefixes), slices.Clone(pa.IPv6Prefixes)}
+	disjoint := [2]bool{}
+	for i, list := range lists {
+		slices.SortFunc(list, compareIPPrefix)
+		disjoint[i] = true
+		for j := 1; j < len(list); j++ {
+			if list[j].Min.Cmp(list[j-1].Max) <= 0 {
+				disjoint[i] = false
+				break
+			}
+		}
+	}
+
+	tags := make(map[*IPPrefix][]string)
+	for input, inputTags := range pa.tags {
+		family := 0
+		if input.Addr().Is6() {
+			family = 1
+		}
+		p, err := ipPrefixFrom(input)
+		if err != nil {
+			continue
+		}
+		list := lists[family]
+		// Every prefix that overlaps input starts at or before its end
+		end, _ := slices.BinarySearchFunc(list, p, func(q, p *IPPrefix) int {
+			if q.Min.Cmp(p.Max) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		for j := end - 1; j >= 0; j-- {
+			if list[j].Max.Cmp(p.Min) >= 0 {
+				tags[list[j]] = append(tags[list[j]], inputTags...)
+			} else if disjoint[family] {
+				break
+			}
+		}
+		releaseIPPrefix(p)
+	}
+
+	for p, t := range tags {
+		slices.Sort(t)
+		result[pa.formatPrefix(p.Prefix)] = slices.Compact(t)
+	}
+	return result
+}
+
+// recordTag adds tag to the tags of an added prefix. The caller holds the
+// write lock.
+func (pa *PrefixAggregator) recordTag(prefix netip.Prefix, tag string) {
+	if tag == "" {
+		return
+	}
+	prefix = prefix.Masked()
+	tags := pa.tags[prefix]
+	i, found := slices.BinarySearch(tags, tag)
+	if found {
+		return
+	}
+	if pa.tags == nil {
+		pa.tags = make(map[netip.Prefix][]string)
+	}
+	pa.tags[prefix] = slices.Insert(slices.Clip(tags), i, tag)
+}
+
+// cloneTags copies the tags of every input prefix
+func cloneTags(tags map[netip.Prefix][]string) map[netip.Prefix][]string {
+	if tags == nil {
+		return nil
+	}
+	c := make(map[netip.Prefix][]string, len(tags))
+	for prefix, t := range tags {
+		c[prefix] = slices.Clone(t)
+	}
+	return c
+}
@@ -0,0 +1,101 @@
+package netjugo
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAddFromReaderTagged(t *testing.T) {
+	pa := NewPrefixAggregator()
+	feeds := map[string]string{
+		"feed-a": "10.0.0.0/24\n192.0.2.0/24\n",
+		"feed-b": "10.0.1.0/24\n192.0.2.0/24\n",
+		"feed-c": "2001:db8::/48\n",
+	}
+	for _, tag := range []string{"feed-a", "feed-b", "feed-c"} {
+		if err := pa.AddFromReaderTagged(strings.NewReader(feeds[tag]), tag); err != nil {
+			t.Fatalf("AddFromReaderTagged(%s) failed: %v", tag, err)
+		}
+	}
+	if err := pa.AddPrefix("198.51.100.0/24"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"2001:db8::/64"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		// Merged result prefixes carry the tags of every input in them
+		{"10.0.0.0/23", []string{"feed-a", "feed-b"}},
+		{"192.0.2.0/24", []string{"feed-a", "feed-b"}},
+		// Original prefixes can still be looked up
+		{"10.0.1.0/24", []string{"feed-b"}},
+		// Pieces left by an exclusion keep the tags of their input
+		{"2001:db8:0:1::/64", []string{"feed-c"}},
+		// Untagged input has no tags
+		{"198.51.100.0/24", nil},
+	}
+	for _, tt := range tests {
+		got, err := pa.GetPrefixTags(tt.prefix)
+		if err != nil {
+			t.Fatalf("GetPrefixTags(%s) failed: %v", tt.prefix, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("GetPrefixTags(%s) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	// GetResultTags agrees with GetPrefixTags for the whole result
+	resultTags := pa.GetResultTags()
+	for _, prefix := range pa.GetPrefixes() {
+		want, _ := pa.GetPrefixTags(prefix)
+		if got := resultTags[prefix]; !slices.Equal(got, want) {
+			t.Errorf("GetResultTags()[%s] = %v, want %v", prefix, got, want)
+		}
+	}
+	if _, ok := resultTags["198.51.100.0/24"]; ok {
+		t.Error("Expected no entry for an untagged result prefix")
+	}
+
+	// Tags are copied by Clone and dropped by Reset
+	clone := pa.Clone()
+	if err := pa.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if got, _ := pa.GetPrefixTags("10.0.0.0/8"); len(got) != 0 {
+		t.Errorf("Expected no tags after Reset, got %v", got)
+	}
+	if got, _ := clone.GetPrefixTags("10.0.0.0/8"); !slices.Equal(got, []string{"feed-a", "feed-b"}) {
+		t.Errorf("Expected the clone to keep its tags, got %v", got)
+	}
+}
+
+func TestAddFromReaderTaggedSkippedLines(t *testing.T) {
+	h := &recordHandler{}
+	pa := NewPrefixAggregator()
+	pa.SetLogger(slog.New(h))
+
+	if err := pa.AddFromReaderTagged(strings.NewReader("10.0.0.0/24\nbogus\n"), "feed-a"); err != nil {
+		t.Fatalf("AddFromReaderTagged failed: %v", err)
+	}
+	attrs, ok := h.find(slog.LevelDebug, "skipped input line")
+	if !ok || attrs["tag"].String() != "feed-a" || attrs["line"].Int64() != 2 {
+		t.Errorf("Expected a Debug record naming the tag and line, got %v", attrs)
+	}
+
+	if err := pa.AddFromReaderTagged(strings.NewReader("10.0.1.0/24\n"), ""); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for an empty tag, got %v", err)
+	}
+	if _, err := pa.GetPrefixTags("bogus"); err == nil {
+		t.Error("Expected an error for an invalid prefix")
+	}
+}
@@ -0,0 +1,45 @@
+package netjugo
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+)
+
+// generateTestPrefixes returns a reproducible mixed-family prefix list
+func generateTestPrefixes(count int) []string {
+	prefixes, err := Generate(GenerateOptions{
+		Count:        count,
+		Seed:         1,
+		IPv6Ratio:    0.5,
+		IPv4Lengths:  []int{23, 24},
+		IPv6Lengths:  []int{48, 64},
+		OverlapRatio: 0.25,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return prefixes
+}
+
+// createTempPrefixFile writes prefixes to a file in the test's temporary
+// directory, which is removed when the test finishes
+func createTempPrefixFile(tb testing.TB, prefixes []string) string {
+	tb.Helper()
+
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes(prefixes); err != nil {
+		tb.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	path := filepath.Join(tb.TempDir(), "prefixes.txt")
+	if err := pa.WriteToFile(path); err != nil {
+		tb.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	return path
+}
+
+func parseNetipPrefix(s string) (netip.Prefix, error) {
+	return netip.ParsePrefix(s)
+}
@@ -38,6 +38,11 @@ func parseIPPrefix(prefixStr string) (*IPPrefix, error) {
 		return nil, fmt.Errorf("%w: invalid prefix %q", ErrInvalidPrefix, prefixStr)
 	}
 
+	return ipPrefixFrom(prefix)
+}
+
+// ipPrefixFrom returns a pooled IPPrefix for a valid prefix
+func ipPrefixFrom(prefix netip.Prefix) (*IPPrefix, error) {
 	minAddr, maxAddr, err := prefixToUint256Range(prefix)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert prefix to uint256 range: %w", err)
@@ -95,65 +100,14 @@ func ipv6PrefixToUint256Range(addr netip.Addr, bits int) (*uint256.Int, *uint256
 		return nil, nil, fmt.Errorf("%w: IPv6 prefix length must be 0-128, got %d", ErrInvalidPrefix, bits)
 	}
 
-	ipv6Bytes := addr.As16()
-
-	minAddr := uint256.NewInt(0)
-	minAddr.SetBytes(ipv6Bytes[:])
-
-	if bits == 128 {
-		return minAddr, new(uint256.Int).Set(minAddr), nil
-	}
-
-	hostBits := 128 - bits
-
-	maxAddr := new(uint256.Int).Set(minAddr)
-
-	if hostBits >= 64 {
-		ones := new(uint256.Int).SetUint64(0xFFFFFFFFFFFFFFFF)
-		maxAddr.Or(maxAddr, ones)
-
-		if hostBits > 64 {
-			shift := hostBits - 64
-			if shift >= 64 {
-				// When shift >= 64, we need all remaining bits to be 1
-				// This only happens for /0 prefix (hostBits = 128, shift = 64)
-				highOnes := new(uint256.Int).SetUint64(0xFFFFFFFFFFFFFFFF)
-				highOnes.Lsh(highOnes, 64)
-				maxAddr.Or(maxAddr, highOnes)
-			} else {
-				// Original logic for shift < 64
-				highOnes := new(uint256.Int).SetUint64((1 << shift) - 1)
-				highOnes.Lsh(highOnes, 64)
-				maxAddr.Or(maxAddr, highOnes)
-			}
-		}
-	} else {
-		ones := new(uint256.Int).SetUint64((1 << hostBits) - 1)
-		maxAddr.Or(maxAddr, ones)
-	}
-
-	bytesToClear := hostBits / 8
-	if bytesToClear > 0 {
-		mask := make([]byte, 16)
-		for i := 0; i < 16-bytesToClear; i++ {
-			mask[i] = 0xFF
-		}
+	// Mask the fixed-width address: the variable-length Bytes() of a
+	// uint256 drops leading zero bytes, which shifts any byte index into it
+	network := netip.PrefixFrom(addr, bits).Masked().Addr().As16()
+	minAddr := new(uint256.Int).SetBytes16(network[:])
 
-		maskInt := uint256.NewInt(0)
-		maskInt.SetBytes(mask)
-		minAddr.And(minAddr, maskInt)
-	}
-
-	if hostBits%8 != 0 {
-		byteIndex := 15 - (hostBits / 8)
-		bitMask := uint8(0xFF) << (hostBits % 8)
-
-		minBytes := minAddr.Bytes()
-		if len(minBytes) > byteIndex {
-			minBytes[byteIndex] &= bitMask
-		}
-		minAddr.SetBytes(minBytes)
-	}
+	hostMask := new(uint256.Int).Lsh(uint256.NewInt(1), uint(128-bits))
+	hostMask.SubUint64(hostMask, 1)
+	maxAddr := new(uint256.Int).Or(minAddr, hostMask)
 
 	return minAddr, maxAddr, nil
 }
@@ -188,13 +142,15 @@ func uint256RangeToIPv4Prefix(minVal, maxVal *uint256.Int) (netip.Prefix, error)
 		return netip.Prefix{}, fmt.Errorf("%w: min > max in range", ErrInvalidPrefix)
 	}
 
-	minV := minVal.Uint64()
-	maxV := maxVal.Uint64()
-
-	if minV > 0xFFFFFFFF || maxV > 0xFFFFFFFF {
+	// Check the bit length before Uint64, which keeps only the low 64 bits
+	// and would wrap a value past 2^64 back into the IPv4 space
+	if minVal.BitLen() > 32 || maxVal.BitLen() > 32 {
 		return netip.Prefix{}, fmt.Errorf("%w: IPv4 address out of range", ErrInvalidPrefix)
 	}
 
+	minV := minVal.Uint64()
+	maxV := maxVal.Uint64()
+
 	if minV == maxV {
 		minBytes := [4]byte{
 			byte(minV >> 24),
@@ -240,48 +196,30 @@ func uint256RangeToIPv6Prefix(minVal, maxVal *uint256.Int) (netip.Prefix, error)
 		return netip.Prefix{}, fmt.Errorf("%w: min > max in range", ErrInvalidPrefix)
 	}
 
-	if minVal.Cmp(maxVal) == 0 {
-		minBytes := make([]byte, 32)
-		minVal.WriteToSlice(minBytes)
+	if minVal.BitLen() > 128 || maxVal.BitLen() > 128 {
+		return netip.Prefix{}, fmt.Errorf("%w: IPv6 address out of range", ErrInvalidPrefix)
+	}
 
-		var addr16 [16]byte
-		copy(addr16[:], minBytes[len(minBytes)-16:])
-		addr := netip.AddrFrom16(addr16)
-		return netip.PrefixFrom(addr, 128), nil
+	if minVal.Cmp(maxVal) == 0 {
+		return netip.PrefixFrom(uint256ToAddr(minVal, false), 128), nil
 	}
 
 	diff := new(uint256.Int).Sub(maxVal, minVal)
-	diff.Add(diff, uint256.NewInt(1))
+	diff.AddUint64(diff, 1)
 
 	if !isPowerOfTwo(diff) {
 		return netip.Prefix{}, fmt.Errorf("%w: range is not a power of 2", ErrInvalidPrefix)
 	}
 
-	prefixBits := 128
-	temp := new(uint256.Int).Set(diff)
-	for temp.Cmp(uint256.NewInt(1)) > 0 {
-		temp.Rsh(temp, 1)
-		prefixBits--
-	}
-
-	hostBits := 128 - prefixBits
-	if hostBits > 0 {
-		mask := new(uint256.Int).Lsh(uint256.NewInt(1), uint(hostBits))
-		mask.Sub(mask, uint256.NewInt(1))
-		remainder := new(uint256.Int).And(minVal, mask)
-		if !remainder.IsZero() {
-			return netip.Prefix{}, fmt.Errorf("%w: range not aligned to prefix boundary", ErrInvalidPrefix)
-		}
+	// diff is 2^hostBits; a full ::/0 range is 2^128
+	hostBits := diff.BitLen() - 1
+	mask := new(uint256.Int).Lsh(uint256.NewInt(1), uint(hostBits))
+	mask.SubUint64(mask, 1)
+	if remainder := new(uint256.Int).And(minVal, mask); !remainder.IsZero() {
+		return netip.Prefix{}, fmt.Errorf("%w: range not aligned to prefix boundary", ErrInvalidPrefix)
 	}
 
-	minBytes := make([]byte, 32)
-	minVal.WriteToSlice(minBytes)
-
-	var addr16 [16]byte
-	copy(addr16[:], minBytes[len(minBytes)-16:])
-	addr := netip.AddrFrom16(addr16)
-
-	return netip.PrefixFrom(addr, prefixBits), nil
+	return netip.PrefixFrom(uint256ToAddr(minVal, false), 128-hostBits), nil
 }
 
 func isPowerOfTwo(n *uint256.Int) bool {
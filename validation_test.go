@@ -1,8 +1,12 @@
 package netjugo
 
 import (
+	"errors"
+	"fmt"
 	"net/netip"
 	"testing"
+
+	"github.com/holiman/uint256"
 )
 
 func TestParseIPPrefix(t *testing.T) {
@@ -450,3 +454,175 @@ func TestMinPrefixLengthWithReaggregation(t *testing.T) {
 		t.Errorf("Expected 1.0.0.0/21, got %s", finalResults[0])
 	}
 }
+
+func TestAddPrefixesCollectsErrors(t *testing.T) {
+	pa := NewPrefixAggregator()
+	err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.0.0.0/33", "2001:db8::/48", "", "not-a-prefix", "10.0.1.0/24"})
+
+	var me *MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("Expected *MultiError, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidPrefix) {
+		t.Error("Expected MultiError to match ErrInvalidPrefix")
+	}
+
+	wantIndex := []int{1, 3, 4}
+	if len(me.Errors) != len(wantIndex) {
+		t.Fatalf("Expected %d entry errors, got %d: %v", len(wantIndex), len(me.Errors), me.Errors)
+	}
+	for i, e := range me.Errors {
+		if e.Index != wantIndex[i] {
+			t.Errorf("Entry error %d has index %d, want %d", i, e.Index, wantIndex[i])
+		}
+		if !errors.Is(e, ErrInvalidPrefix) {
+			t.Errorf("Entry error %d does not wrap ErrInvalidPrefix: %v", i, e)
+		}
+	}
+	if me.Errors[2].Input != "not-a-prefix" {
+		t.Errorf("Expected input %q, got %q", "not-a-prefix", me.Errors[2].Input)
+	}
+
+	// Valid entries are still added and counted
+	if stats := pa.GetStats(); stats.OriginalCount != 3 {
+		t.Errorf("Expected original count 3, got %d", stats.OriginalCount)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	got := pa.GetPrefixes()
+	if len(got) != 2 || got[0] != "10.0.0.0/23" || got[1] != "2001:db8::/48" {
+		t.Errorf("Unexpected result: %v", got)
+	}
+
+	if err := pa.AddPrefixes([]string{"10.1.0.0/24"}); err != nil {
+		t.Errorf("Expected nil error for a valid batch, got %v", err)
+	}
+}
+
+func TestAddPrefixesRetry(t *testing.T) {
+	batch := make([]string, 10)
+	for i := range batch {
+		batch[i] = fmt.Sprintf("10.0.%d.0/24", i)
+	}
+	batch[6] = "10.0.6.0/33"
+
+	pa := NewPrefixAggregator()
+	var me *MultiError
+	if err := pa.AddPrefixes(batch); !errors.As(err, &me) {
+		t.Fatalf("Expected *MultiError, got %v", err)
+	}
+	if me.Added != 9 || len(me.Errors) != 1 {
+		t.Fatalf("Expected 9 added and 1 failed, got %d and %v", me.Added, me.Errors)
+	}
+	if got := pa.GetStats().OriginalCount; got != me.Added {
+		t.Errorf("Expected original count %d, got %d", me.Added, got)
+	}
+
+	// Retrying only the failed entry brings the count to the batch size
+	if err := pa.AddPrefixes([]string{"10.0.6.0/24"}); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if got := pa.GetStats().OriginalCount; got != len(batch) {
+		t.Errorf("Expected original count %d after the retry, got %d", len(batch), got)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := pa.GetPrefixes(); len(got) != 2 || got[0] != "10.0.0.0/21" || got[1] != "10.0.8.0/23" {
+		t.Errorf("Unexpected result: %v", got)
+	}
+
+	// A closed aggregator stops the batch at its first entry
+	if err := pa.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if err := pa.AddPrefixes(batch); !errors.As(err, &me) || me.Added != 0 || len(me.Errors) != 1 {
+		t.Errorf("Expected one ErrClosed entry and nothing added, got %v", err)
+	}
+}
+
+func TestIPv6RangeRoundTrip(t *testing.T) {
+	bases := []string{
+		"::",
+		"::1",
+		"::ffff:ffff",
+		"::1234:5678",
+		"0:0:0:1::",
+		"0:0:0:ff00:abcd::1",
+		"2001:db8::",
+		"2001:db8:dead:beef:cafe:babe:f00d:1",
+		"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+	}
+
+	for _, base := range bases {
+		addr := netip.MustParseAddr(base)
+		for bits := 0; bits <= 128; bits++ {
+			want := netip.PrefixFrom(addr, bits).Masked()
+
+			minVal, maxVal, err := prefixToUint256Range(netip.PrefixFrom(addr, bits))
+			if err != nil {
+				t.Fatalf("prefixToUint256Range(%s/%d) failed: %v", base, bits, err)
+			}
+			if got := uint256ToAddr(minVal, false); got != want.Addr() {
+				t.Fatalf("%s/%d: range starts at %s, want %s", base, bits, got, want.Addr())
+			}
+			size := new(uint256.Int).Sub(maxVal, minVal)
+			if size.AddUint64(size, 1); size.BitLen() != 128-bits+1 || !isPowerOfTwo(size) {
+				t.Fatalf("%s/%d: range holds %s addresses, want 2^%d", base, bits, size.Dec(), 128-bits)
+			}
+
+			got, err := uint256RangeToPrefix(minVal, maxVal, false)
+			if err != nil {
+				t.Fatalf("uint256RangeToPrefix for %s/%d failed: %v", base, bits, err)
+			}
+			if got != want {
+				t.Fatalf("%s/%d: round trip gave %s, want %s", base, bits, got, want)
+			}
+		}
+	}
+
+	// Values past 128 bits are not IPv6 addresses
+	tooLarge := new(uint256.Int).Lsh(uint256.NewInt(1), 128)
+	if _, err := uint256RangeToPrefix(tooLarge, tooLarge, false); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Expected ErrInvalidPrefix for a 129-bit value, got %v", err)
+	}
+}
+
+func FuzzParseIPPrefix(f *testing.F) {
+	for _, seed := range []string{
+		"192.168.1.0/24", "10.0.0.1", "0.0.0.0/0", "255.255.255.255/32",
+		"2001:db8::/32", "::", "::1/128", "::ffff:1.2.3.4/120",
+		"1.2.3.4/+24", "1.2.3.4/-1", "1.2.3.4/024", "1.2.3.4/33",
+		"fe80::1%eth0", "fe80::1%eth0/64", " 10.0.0.0/8 ", "", "/", "1.2.3.4/",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		p, err := parseIPPrefix(s)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidPrefix) {
+				t.Fatalf("parseIPPrefix(%q) failed without ErrInvalidPrefix: %v", s, err)
+			}
+			return
+		}
+		defer releaseIPPrefix(p)
+
+		if !p.Prefix.IsValid() {
+			t.Fatalf("parseIPPrefix(%q) returned invalid prefix %s", s, p.Prefix)
+		}
+		if p.Min.Gt(p.Max) {
+			t.Fatalf("parseIPPrefix(%q): Min %s > Max %s", s, p.Min.Hex(), p.Max.Hex())
+		}
+		isIPv4 := p.Prefix.Addr().Is4()
+		if got := uint256ToAddr(p.Min, isIPv4); got != p.Prefix.Masked().Addr() {
+			t.Fatalf("parseIPPrefix(%q): range starts at %s, not at the network address of %s", s, got, p.Prefix)
+		}
+		for _, end := range []*uint256.Int{p.Min, p.Max} {
+			if addr := uint256ToAddr(end, isIPv4); !p.Prefix.Contains(addr) {
+				t.Fatalf("parseIPPrefix(%q): %s does not contain endpoint %s", s, p.Prefix, addr)
+			}
+		}
+	})
+}
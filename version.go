@@ -0,0 +1,11 @@
+package netjugo
+
+// version is the library version. Release builds can stamp their own with
+// -ldflags "-X github.com/rretina/netjugo.version=1.2.3".
+var version = "1.0.0"
+
+// Version returns the library version, as named in output headers and
+// reported by the CLI
+func Version() string {
+	return version
+}
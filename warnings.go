@@ -0,0 +1,294 @@
+package netjugo
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// WarningCode identifies the kind of a Warning
+type WarningCode string
+
+const (
+	// WarnSpecificExclusion marks an exclusion more specific than the
+	// recommended minimum length
+	WarnSpecificExclusion WarningCode = "specific-exclusion"
+	// WarnExcludeOverlapsInclude marks an exclusion that overlaps an
+	// include prefix
+	WarnExcludeOverlapsInclude WarningCode = "exclude-overlaps-include"
+	// WarnDefaultRoute marks a result that aggregated to 0.0.0.0/0 or ::/0
+	// although no input prefix was a default route
+	WarnDefaultRoute WarningCode = "default-route"
+	// WarnEmptyResult marks a result with no prefixes left although some
+	// were added
+	WarnEmptyResult WarningCode = "empty-result"
+	// WarnConstraintFamily marks an include or exclusion of a family the
+	// input has no prefixes of, usually a prefix in the wrong file
+	WarnConstraintFamily WarningCode = "constraint-family"
+	// WarnConstraintOutsideInput marks an exclusion that overlaps no input
+	// prefix
+	WarnConstraintOutsideInput WarningCode = "constraint-outside-input"
+	// WarnDuplicateConstraint marks a prefix listed twice in the same
+	// include or exclude list
+	WarnDuplicateConstraint WarningCode = "duplicate-constraint"
+	// WarnExclusionTooSpecific marks an exclusion Aggregate skipped
+	// because it is longer than MinExclusionLenIPv4 or MinExclusionLenIPv6
+	WarnExclusionTooSpecific WarningCode = "exclusion-too-specific"
+	// WarnExclusionNoEffect marks an exclusion Aggregate skipped because
+	// it overlapped none of the prefixes left when it was applied
+	WarnExclusionNoEffect WarningCode = "exclusion-no-effect"
+	// WarnIncludeRounded marks an include prefix rounded up to the minimum
+	// prefix length under IncludeExpand
+	WarnIncludeRounded WarningCode = "include-rounded"
+)
+
+// Warning is a problem found during Aggregate that did not stop it
+type Warning struct {
+	Code    WarningCode
+	Message string
+	// Prefix is the constraint the warning is about
+	Prefix netip.Prefix
+	// Related is the other prefix involved, if any
+	Related netip.Prefix
+	// Set names the exclusion set responsible, if any
+	Set string
+}
+
+func (w Warning) String() string {
+	return w.Message
+}
+
+// DefaultMaxWarnings is how many warnings an aggregator stores unless
+// SetMaxWarnings says otherwise
+const DefaultMaxWarnings = 10000
+
+// WarningsOverflow selects what happens to a warning when a channel from
+// WarningsChan is full
+type WarningsOverflow int
+
+const (
+	// DropWarnings skips a full channel, so a slow consumer never slows
+	// Aggregate but misses warnings. This is the default.
+	DropWarnings WarningsOverflow = iota
+	// BlockOnWarnings waits until the consumer makes room, so no warning
+	// is lost but Aggregate runs at the consumer's pace
+	BlockOnWarnings
+)
+
+// WarningsChan returns a channel that receives every warning from now
+// on, as GetWarningDetails records it, buffered for up to buffer
+// warnings. What happens when the buffer is full is set with
+// SetWarningsOverflow. Each call returns a new channel; all of them are
+// closed by Reset and Close, and after Close the channel is returned
+// closed.
+//
+// Warnings are sent while the aggregator is locked, so under
+// BlockOnWarnings the consumer must keep receiving without calling back
+// into the aggregator.
+func (pa *PrefixAggregator) WarningsChan(buffer int) <-chan Warning {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	ch := make(chan Warning, max(buffer, 0))
+	if pa.closed {
+		close(ch)
+		return ch
+	}
+	pa.warningChans = append(pa.warningChans, ch)
+	return ch
+}
+
+// SetWarningsOverflow selects whether warnings are dropped (DropWarnings,
+// the default) or Aggregate blocks (BlockOnWarnings) when a channel from
+// WarningsChan is full
+func (pa *PrefixAggregator) SetWarningsOverflow(overflow WarningsOverflow) error {
+	if overflow != DropWarnings && overflow != BlockOnWarnings {
+		return fmt.Errorf("%w: unknown warnings overflow %d", ErrInvalidOption, overflow)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.warningsOverflow = overflow
+	return nil
+}
+
+func (pa *PrefixAggregator) closeWarningChans() {
+	for _, ch := range pa.warningChans {
+		close(ch)
+	}
+	pa.warningChans = nil
+}
+
+// sendWarning passes w to the channels from WarningsChan
+func (pa *PrefixAggregator) sendWarning(w Warning) {
+	for _, ch := range pa.warningChans {
+		if pa.warningsOverflow == BlockOnWarnings {
+			ch <- w
+			continue
+		}
+		select {
+		case ch <- w:
+		default:
+		}
+	}
+}
+
+// SetWarningHandler sets a custom handler for warnings
+func (pa *PrefixAggregator) SetWarningHandler(handler func(string)) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.warningHandler = handler
+}
+
+// SetMaxWarnings caps how many warnings GetWarnings and
+// GetWarningDetails keep, DefaultMaxWarnings by default. Warnings beyond
+// the cap are still counted in AggregationStats.TotalWarnings and the
+// report, and still reach the handler, logger, metrics and channels. Zero
+// means no cap.
+func (pa *PrefixAggregator) SetMaxWarnings(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%w: maximum warnings must not be negative, got %d", ErrInvalidOption, n)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if pa.closed {
+		return ErrClosed
+	}
+
+	pa.maxWarnings = n
+	return nil
+}
+
+// GetWarnings returns the warnings generated during processing, up to the
+// SetMaxWarnings cap. When warnings were dropped, the last one says how
+// many.
+func (pa *PrefixAggregator) GetWarnings() []string {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if len(pa.warnings) == 0 {
+		return nil
+	}
+
+	result := make([]string, len(pa.warnings))
+	for i, w := range pa.warnings {
+		result[i] = w.Message
+	}
+	result[len(result)-1] = pa.truncationNote(result[len(result)-1])
+	return result
+}
+
+// GetWarningDetails returns the warnings from GetWarnings with their codes
+// and prefixes
+func (pa *PrefixAggregator) GetWarningDetails() []Warning {
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if len(pa.warnings) == 0 {
+		return nil
+	}
+
+	// Return a copy to prevent external modification
+	result := make([]Warning, len(pa.warnings))
+	copy(result, pa.warnings)
+	result[len(result)-1].Message = pa.truncationNote(result[len(result)-1].Message)
+	return result
+}
+
+// truncationNote appends to message how many warnings were not stored,
+// if any
+func (pa *PrefixAggregator) truncationNote(message string) string {
+	if dropped := pa.totalWarnings - len(pa.warnings); dropped > 0 {
+		return fmt.Sprintf("%s (%d more warnings not stored)", message, dropped)
+	}
+	return message
+}
+
+// addWarning records a warning, up to the SetMaxWarnings cap, counts it
+// and passes its message to the handler
+func (pa *PrefixAggregator) addWarning(w Warning) {
+	pa.totalWarnings++
+	if pa.maxWarnings == 0 || len(pa.warnings) < pa.maxWarnings {
+		pa.warnings = append(pa.warnings, w)
+	}
+	pa.countWarning(w)
+	pa.logWarning(w)
+	pa.metrics.IncWarning(w.Code)
+	pa.sendWarning(w)
+
+	// Call handler if set
+	if pa.warningHandler != nil {
+		pa.warningHandler(w.Message)
+	}
+}
+
+// countWarning adds w to the per-code counts of the report
+func (pa *PrefixAggregator) countWarning(w Warning) {
+	for i := range pa.warningCounts {
+		if pa.warningCounts[i].Code == w.Code {
+			pa.warningCounts[i].Count++
+			return
+		}
+	}
+	pa.warningCounts = append(pa.warningCounts, WarningSummary{Code: w.Code, Count: 1, First: w.Message})
+}
+
+// clearWarnings clears all warnings
+func (pa *PrefixAggregator) clearWarnings() {
+	pa.warnings = nil
+	pa.warningCounts = nil
+	pa.totalWarnings = 0
+}
+
+// warnExcludesOverlappingIncludes warns about every exclusion that
+// overlaps an include of the same family
+func (pa *PrefixAggregator) warnExcludesOverlappingIncludes() {
+	for _, source := range pa.activeExclusions(true) {
+		pa.warnConstraintOverlaps(source, pa.IncludeIPv4)
+	}
+	for _, source := range pa.activeExclusions(false) {
+		pa.warnConstraintOverlaps(source, pa.IncludeIPv6)
+	}
+}
+
+func (pa *PrefixAggregator) warnConstraintOverlaps(source exclusionSource, includes []*IPPrefix) {
+	if len(source.prefixes) == 0 || len(includes) == 0 {
+		return
+	}
+
+	// Sweep both lists in address order; the loop over includes stops at
+	// the first one starting after the exclusion ends
+	ex := append([]*IPPrefix(nil), source.prefixes...)
+	in := append([]*IPPrefix(nil), includes...)
+	sortPrefixes(ex)
+	sortPrefixes(in)
+
+	outcome := "removes part of"
+	if pa.constraintOrder == IncludesWin {
+		outcome = "is overridden by"
+	}
+
+	for _, e := range ex {
+		for _, i := range in {
+			if i.Min.Gt(e.Max) {
+				break
+			}
+			if i.Max.Lt(e.Min) {
+				continue
+			}
+			pa.addWarning(Warning{
+				Code:    WarnExcludeOverlapsInclude,
+				Message: fmt.Sprintf("WARNING: exclusion %s%s %s include %s", e.Prefix, source.describe(), outcome, i.Prefix),
+				Prefix:  e.Prefix,
+				Related: i.Prefix,
+				Set:     source.set,
+			})
+		}
+	}
+}
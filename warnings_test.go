@@ -0,0 +1,209 @@
+package netjugo
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newWarningAggregator returns an aggregator whose Aggregate raises one
+// specific-exclusion warning per exclusion, five in all
+func newWarningAggregator(t *testing.T) *PrefixAggregator {
+	t.Helper()
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefix("10.0.0.0/16"); err != nil {
+		t.Fatalf("Failed to add prefix: %v", err)
+	}
+	if err := pa.SetExcludePrefixes([]string{"10.0.1.0/31", "10.0.2.0/31", "10.0.3.0/31", "10.0.4.0/31", "10.0.5.0/31"}); err != nil {
+		t.Fatalf("Failed to set exclude prefixes: %v", err)
+	}
+	return pa
+}
+
+func receiveAll(ch <-chan Warning) []Warning {
+	var got []Warning
+	for {
+		select {
+		case w, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, w)
+		default:
+			return got
+		}
+	}
+}
+
+func TestWarningsChanDrop(t *testing.T) {
+	pa := newWarningAggregator(t)
+	ch := pa.WarningsChan(2)
+
+	// Nobody is receiving, so Aggregate must not wait for the buffer
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	details := pa.GetWarningDetails()
+	if len(details) != 5 {
+		t.Fatalf("Expected 5 warnings, got %d", len(details))
+	}
+	if got := receiveAll(ch); !slices.Equal(got, details[:2]) {
+		t.Errorf("Expected the first 2 warnings on the channel, got %v", got)
+	}
+}
+
+func TestWarningsChanBlock(t *testing.T) {
+	pa := newWarningAggregator(t)
+	if err := pa.SetWarningsOverflow(BlockOnWarnings); err != nil {
+		t.Fatalf("Failed to set overflow: %v", err)
+	}
+	ch := pa.WarningsChan(1)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pa.Aggregate()
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Aggregate finished with a full channel: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var got []Warning
+	for len(got) < 5 {
+		got = append(got, <-ch)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if details := pa.GetWarningDetails(); !slices.Equal(got, details) {
+		t.Errorf("Expected every warning on the channel, got %v, want %v", got, details)
+	}
+}
+
+func TestWarningsChanClose(t *testing.T) {
+	pa := newWarningAggregator(t)
+	first, second := pa.WarningsChan(10), pa.WarningsChan(10)
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	// Reset closes every channel after the buffered warnings
+	if err := pa.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	for _, ch := range []<-chan Warning{first, second} {
+		count := 0
+		for range ch {
+			count++
+		}
+		if count != 5 {
+			t.Errorf("Expected 5 warnings before the channel closed, got %d", count)
+		}
+	}
+
+	// Close does too
+	ch := pa.WarningsChan(10)
+	if err := pa.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("Expected the channel to be closed by Close")
+	}
+}
+
+func TestSetWarningsOverflowInvalid(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.SetWarningsOverflow(WarningsOverflow(99)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestMaxWarnings(t *testing.T) {
+	pa := newWarningAggregator(t)
+	if err := pa.SetMaxWarnings(3); err != nil {
+		t.Fatalf("Failed to set max warnings: %v", err)
+	}
+	handled := 0
+	pa.SetWarningHandler(func(string) { handled++ })
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	warnings := pa.GetWarnings()
+	if len(warnings) != 3 || cap(warnings) != 3 {
+		t.Fatalf("Expected 3 stored warnings, got %d (cap %d)", len(warnings), cap(warnings))
+	}
+	if !strings.HasSuffix(warnings[2], "(2 more warnings not stored)") {
+		t.Errorf("Expected the last warning to note the truncation, got %q", warnings[2])
+	}
+	if strings.Contains(warnings[1], "not stored") {
+		t.Errorf("Expected only the last warning to note the truncation, got %q", warnings[1])
+	}
+	if details := pa.GetWarningDetails(); len(details) != 3 || details[2].Message != warnings[2] {
+		t.Errorf("Expected the details to match GetWarnings, got %v", details)
+	}
+
+	if got := pa.GetStats().TotalWarnings; got != 5 {
+		t.Errorf("Expected TotalWarnings 5, got %d", got)
+	}
+	if handled != 5 {
+		t.Errorf("Expected the handler to see all 5 warnings, got %d", handled)
+	}
+	if r := pa.GenerateReport(); len(r.Warnings) != 1 || r.Warnings[0].Count != 5 {
+		t.Errorf("Expected the report to count all 5 warnings, got %v", r.Warnings)
+	}
+
+	// No cap keeps everything
+	pa = newWarningAggregator(t)
+	if err := pa.SetMaxWarnings(0); err != nil {
+		t.Fatalf("Failed to clear max warnings: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if got := len(pa.GetWarnings()); got != 5 || pa.GetStats().TotalWarnings != 5 {
+		t.Errorf("Expected 5 stored warnings without a cap, got %d", got)
+	}
+
+	if err := pa.SetMaxWarnings(-1); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestPointToPointFriendly(t *testing.T) {
+	specific := func(pointToPoint bool) []string {
+		t.Helper()
+		pa := NewPrefixAggregator()
+		pa.SetPointToPointFriendly(pointToPoint)
+		if err := pa.AddPrefixes([]string{"10.0.0.0/16", "2001:db8::/48"}); err != nil {
+			t.Fatalf("Failed to add prefixes: %v", err)
+		}
+		excludes := []string{"10.0.1.0/30", "10.0.2.0/31", "10.0.3.1/32", "2001:db8::/64", "2001:db8:0:1::/127", "2001:db8:0:2::1/128"}
+		if err := pa.SetExcludePrefixes(excludes); err != nil {
+			t.Fatalf("Failed to set exclude prefixes: %v", err)
+		}
+		if err := pa.Aggregate(); err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+
+		var got []string
+		for _, w := range pa.GetWarningDetails() {
+			if w.Code == WarnSpecificExclusion {
+				got = append(got, w.Prefix.String())
+			}
+		}
+		return got
+	}
+
+	if got, want := specific(false), []string{"10.0.2.0/31", "10.0.3.1/32", "2001:db8:0:1::/127", "2001:db8:0:2::1/128"}; !slices.Equal(got, want) {
+		t.Errorf("By default got specific-exclusion warnings for %v, want %v", got, want)
+	}
+	if got, want := specific(true), []string{"10.0.3.1/32", "2001:db8:0:2::1/128"}; !slices.Equal(got, want) {
+		t.Errorf("Point-to-point friendly got specific-exclusion warnings for %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package netjugo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PrefixesWithin returns the current prefixes fully contained in
+// supernet, and separately the ones that only partly overlap it, which
+// after Aggregate are the prefixes containing it. Both lists are in
+// address order. The sorted part of each list is binary searched, so this
+// is cheap after Aggregate.
+func (pa *PrefixAggregator) PrefixesWithin(supernet string) (within, straddling []string, err error) {
+	target, err := parseIPPrefix(supernet)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse supernet %q: %w", supernet, err)
+	}
+	defer releaseIPPrefix(target)
+
+	pa.mu.RLock()
+	defer pa.mu.RUnlock()
+
+	if pa.closed {
+		return nil, nil, ErrClosed
+	}
+
+	prefixes, sorted := pa.IPv6Prefixes, pa.sortedIPv6
+	if target.Prefix.Addr().Is4() {
+		prefixes, sorted = pa.IPv4Prefixes, pa.sortedIPv4
+	}
+
+	var in, partial []*IPPrefix
+	classify := func(p *IPPrefix) {
+		switch {
+		case !overlaps(target, p):
+		case contains(target, p):
+			in = append(in, p)
+		default:
+			partial = append(partial, p)
+		}
+	}
+
+	// Entries starting inside the supernet follow the first one that does
+	// not start before it; the containing entries precede it
+	head := prefixes[:sorted]
+	first := sort.Search(len(head), func(i int) bool {
+		return !head[i].Min.Lt(target.Min)
+	})
+	for i := first - 1; i >= 0 && !head[i].Max.Lt(target.Min); i-- {
+		classify(head[i])
+	}
+	for i := first; i < len(head) && !head[i].Min.Gt(target.Max); i++ {
+		classify(head[i])
+	}
+
+	// Pending entries added since the last Aggregate are not in order
+	for _, p := range prefixes[sorted:] {
+		classify(p)
+	}
+
+	return pa.sortedStrings(in), pa.sortedStrings(partial), nil
+}
+
+// sortedStrings renders prefixes in canonical order
+func (pa *PrefixAggregator) sortedStrings(prefixes []*IPPrefix) []string {
+	sortPrefixes(prefixes)
+	result := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		result[i] = pa.formatPrefix(p.Prefix)
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package netjugo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrefixesWithin(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{
+		"203.0.113.0/28", "203.0.113.64/26", "203.0.113.200/32",
+		"198.51.0.0/16", "10.0.0.0/8", "192.0.2.0/24",
+		"2001:db8::/48", "2001:db8:1::/48",
+	}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+
+	tests := []struct {
+		supernet   string
+		within     string
+		straddling string
+	}{
+		{"203.0.113.0/24", "203.0.113.0/28,203.0.113.64/26,203.0.113.200/32", ""},
+		// Exact match counts as contained
+		{"192.0.2.0/24", "192.0.2.0/24", ""},
+		// The supernet sits inside a larger result prefix
+		{"198.51.100.0/24", "", "198.51.0.0/16"},
+		{"10.1.0.0/16", "", "10.0.0.0/8"},
+		{"172.16.0.0/12", "", ""},
+		{"2001:db8::/32", "2001:db8::/47", ""},
+		{"2001:db8:1:8000::/49", "", "2001:db8::/47"},
+	}
+
+	for _, tt := range tests {
+		within, straddling, err := pa.PrefixesWithin(tt.supernet)
+		if err != nil {
+			t.Fatalf("PrefixesWithin(%q) failed: %v", tt.supernet, err)
+		}
+		if got := strings.Join(within, ","); got != tt.within {
+			t.Errorf("PrefixesWithin(%q) within = %s, want %s", tt.supernet, got, tt.within)
+		}
+		if got := strings.Join(straddling, ","); got != tt.straddling {
+			t.Errorf("PrefixesWithin(%q) straddling = %s, want %s", tt.supernet, got, tt.straddling)
+		}
+	}
+
+	if _, _, err := pa.PrefixesWithin("203.0.113.0/33"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Expected ErrInvalidPrefix, got %v", err)
+	}
+}
+
+func TestPrefixesWithinPending(t *testing.T) {
+	pa := NewPrefixAggregator()
+	if err := pa.AddPrefixes([]string{"10.0.0.0/24", "10.2.0.0/24"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+	if err := pa.Aggregate(); err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	// Added after Aggregate, so not yet in sorted position
+	if err := pa.AddPrefixes([]string{"10.1.0.0/24", "10.0.0.0/16"}); err != nil {
+		t.Fatalf("Failed to add prefixes: %v", err)
+	}
+
+	within, straddling, err := pa.PrefixesWithin("10.0.0.0/15")
+	if err != nil {
+		t.Fatalf("PrefixesWithin failed: %v", err)
+	}
+	if got := strings.Join(within, ","); got != "10.0.0.0/16,10.0.0.0/24,10.1.0.0/24" {
+		t.Errorf("Unexpected within list: %s", got)
+	}
+	if len(straddling) != 0 {
+		t.Errorf("Unexpected straddling list: %v", straddling)
+	}
+}